@@ -0,0 +1,22 @@
+package api
+
+type AlertRule struct {
+	ID          *uint
+	Product     string  `validate:"required,max=100"`
+	Store       *string `json:",omitempty" validate:"omitempty,min=1,max=100"` // 省略時は全店舗
+	TargetPrice *uint   `json:",omitempty" validate:"omitempty,min=1"`         // 目標価格以下で通知
+	DropPercent *uint   `json:",omitempty" validate:"omitempty,min=1,max=99"`  // 平均価格からの値下がり率（%）以上で通知
+}
+
+type Notification struct {
+	ID          uint
+	AlertRuleID uint
+	PriceID     uint
+	CreatedAt   string
+	DateTime    string
+	Store       string
+	Product     string
+	Price       uint
+	Average     *uint `json:",omitempty"`
+	Read        bool
+}
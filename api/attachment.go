@@ -0,0 +1,10 @@
+package api
+
+type Attachment struct {
+	ID          uint
+	PriceID     uint
+	FileName    string
+	ContentType string
+	Size        int64
+	CreatedAt   string
+}
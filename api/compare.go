@@ -0,0 +1,35 @@
+package api
+
+type ComparedPrice struct {
+	Price
+	Stale bool // 古い価格
+}
+
+type ProductComparison struct {
+	Product string
+	Prices  []*ComparedPrice // 店舗ごとの最新の価格（単価または価格の昇順で先頭が最安）
+}
+
+type Basket struct {
+	Items []BasketItem `validate:"required,min=1,max=50,dive"`
+}
+
+type BasketItem struct {
+	Product  string  `validate:"required,max=100"`
+	Count    uint    `json:",omitempty" validate:"excluded_with=Unit,max=1000"`                            // 未指定なら1
+	Quantity float64 `json:",omitempty" validate:"required_with=Unit,omitempty,gt=0,max=1000000"`          // 必要な量（単価で比較）
+	Unit     string  `json:",omitempty" validate:"required_with=Quantity,omitempty,oneof=g kg ml L piece"` // 必要な量の単位
+}
+
+type BasketComparison struct {
+	SingleStore *BasketPrices `json:",omitempty"` // Missing以外の全ての商品がある店舗のうち合計が最安
+	Split       *BasketPrices `json:",omitempty"` // 商品ごとに最安の店舗で買う場合
+	Missing     []string      `json:",omitempty"` // どの店舗にも価格がない商品
+}
+
+type BasketPrices struct {
+	Store  string `json:",omitempty"` // 店舗を分けて買う場合は省略
+	Total  uint
+	Stale  bool             // 古い価格を含む
+	Prices []*ComparedPrice // 買い物かごの商品の順
+}
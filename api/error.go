@@ -4,6 +4,12 @@ type ErrorResponse struct {
 	Title         string         `json:"title"`            // human-readable
 	Detail        *string        `json:"detail,omitempty"` // human-readable
 	InvalidParams []InvalidParam `json:"invalid-params,omitempty"`
+	ExpectedRange *PriceRange    `json:"expected-range,omitempty"` // 外れ値の価格の想定の範囲
+}
+
+type PriceRange struct {
+	Min uint `json:"min"`
+	Max uint `json:"max"`
 }
 
 type InvalidParam struct {
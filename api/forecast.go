@@ -0,0 +1,28 @@
+package api
+
+type PriceForecast struct {
+	Product        string
+	Quantity       float64          `json:",omitempty"` // 予測の価格の内容量（最新の価格の内容量）
+	Unit           string           `json:",omitempty"`
+	Samples        int              // 予測に使った価格の件数
+	Level          uint             // 季節性を除いて平滑化した価格
+	Weekdays       []SeasonalFactor `json:",omitempty"` // 件数が足りる曜日のみ
+	Months         []SeasonalFactor `json:",omitempty"` // 件数が足りる月のみ
+	Forecasts      []ForecastPoint  // 今日から日付の順
+	Recommendation string           // buy（今買う）、wait（待つ）
+	BestDate       string           // 予測の期間で最も安い日
+	Reasons        []string
+}
+
+type SeasonalFactor struct {
+	Period  string // 曜日（Sunday〜Saturday）または月（January〜December）
+	Factor  float64
+	Samples int
+}
+
+type ForecastPoint struct {
+	Date     string // YYYY-MM-DD
+	Expected uint
+	Min      uint
+	Max      uint
+}
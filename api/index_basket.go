@@ -0,0 +1,28 @@
+package api
+
+type IndexBasket struct {
+	ID        *uint
+	Name      string            `validate:"required,max=100"`
+	Items     []IndexBasketItem `validate:"required,min=1,max=100,unique=Product,dive"`
+	UpdatedAt *string           // レスポンスのみ
+}
+
+type IndexBasketItem struct {
+	Product string  `validate:"required,max=100"`
+	Weight  float64 `validate:"gt=0"` // 基準月の支出の割合（合計が1でなくてもよい）
+}
+
+type PriceIndex struct {
+	BasketID uint
+	Base     string // 基準月（YYYY-MM）
+	Periods  []PriceIndexPeriod
+}
+
+type PriceIndexPeriod struct {
+	Month    string   // YYYY-MM
+	Index    float64  // 基準月を100とした連鎖指数
+	Coverage float64  // 当月に価格がある商品の重みの割合
+	Observed int      // 当月に価格がある商品の数
+	Carried  []string `json:",omitempty"` // 前月以前の価格を繰り越した商品
+	Missing  []string `json:",omitempty"` // まだ価格がない商品
+}
@@ -2,8 +2,87 @@ package api
 
 type Price struct {
 	ID       *uint
-	DateTime *string `validate:"omitempty,max=100"`
-	Store    string  `validate:"required,max=100"`
-	Product  string  `validate:"required,max=100"`
-	Price    uint    `validate:"required"`
+	DateTime *string  `validate:"omitempty,max=100"`
+	Store    string   `validate:"required,max=100"`
+	Product  string   `validate:"required,max=100"`
+	GTIN     string   `json:",omitempty" validate:"omitempty,gtin"` // JAN-8、JAN-13（EAN-13）、UPC-A
+	Price    uint     `validate:"required"`
+	Quantity float64  `json:",omitempty" validate:"required_with=Unit,omitempty,gt=0"`                      // 内容量
+	Unit     string   `json:",omitempty" validate:"required_with=Quantity,omitempty,oneof=g kg ml L piece"` // 内容量の単位
+	Tags     []string `json:",omitempty" validate:"max=10,dive,required,max=30"`
+
+	PriceType string  `json:",omitempty" validate:"omitempty,oneof=regular sale member"` // 省略時はregular（レスポンスではregularを省略）
+	ValidFrom *string `json:",omitempty" validate:"omitempty,max=100"`                   // 有効期間の始まり（省略時はDateTimeから）
+	ValidTo   *string `json:",omitempty" validate:"omitempty,max=100"`                   // 有効期間の終わり（含まない、省略時は次の価格まで）
+
+	UnitPrice      *float64 `json:",omitempty"` // 100gあたり、1Lあたり、1個あたりの価格（レスポンスのみ）
+	UnitPriceBasis string   `json:",omitempty"` // 100g、1L、1piece（レスポンスのみ）
+	Suspicious     bool     `json:",omitempty"` // 過去の価格から外れ値の疑い（レスポンスのみ）
+}
+
+type PriceOperation struct {
+	Op    string `validate:"required,oneof=create update delete"`
+	ID    *uint
+	Price *Price
+}
+
+type PriceOperationResult struct {
+	Status int
+	Price  *Price         `json:",omitempty"`
+	Error  *ErrorResponse `json:",omitempty"`
+}
+
+type PriceImportReport struct {
+	Rows     int // ヘッダを除いた行数
+	Imported int
+	Errors   []PriceImportError  `json:",omitempty"`
+	Failed   *PriceImportFailure `json:",omitempty"` // 登録に失敗したチャンク（それより前の行は登録済み）
+}
+
+type PriceImportFailure struct {
+	FromRow int // ヘッダを1行目とした行番号
+	ToRow   int
+	Error   *ErrorResponse
+}
+
+type PriceImportError struct {
+	Row           int            // ヘッダを1行目とした行番号
+	InvalidParams []InvalidParam `json:"invalid-params"`
+}
+
+type DeletedPrice struct {
+	Price
+	DeletedAt string
+}
+
+type PriceRevision struct {
+	ID        uint
+	PriceID   uint
+	UserID    uint
+	Operation string
+	CreatedAt string
+	Before    *PriceValues
+	After     *PriceValues
+}
+
+type PriceValues struct {
+	DateTime string
+	Store    string
+	Product  string
+	GTIN     string `json:",omitempty"`
+	Price    uint
+	Quantity float64 `json:",omitempty"`
+	Unit     string  `json:",omitempty"`
+
+	PriceType string  `json:",omitempty"` // regularは省略
+	ValidFrom *string `json:",omitempty"`
+	ValidTo   *string `json:",omitempty"`
+
+	Tags []string `json:",omitempty"`
+}
+
+type Product struct {
+	GTIN    string
+	Product string   // 最新の価格の商品名
+	Prices  []*Price // 日時の降順
 }
@@ -0,0 +1,25 @@
+package api
+
+type ProductAlias struct {
+	ID      *uint
+	Alias   string `validate:"required,max=100"`
+	Product string `validate:"required,max=100"` // 正式な商品名（別名を指定するとその正式な商品名）
+}
+
+type ProductMerge struct {
+	Product string `validate:"required,max=100"` // 統合元の商品名
+	Into    string `validate:"required,max=100"` // 統合先の商品名
+}
+
+type ProductMergeResult struct {
+	Product string // 統合先の正式な商品名
+	Merged  int64  // 商品名を変更した価格の件数（論理削除済みを含む）
+}
+
+type ProductDuplicate struct {
+	Product   string
+	Into      string  // 統合先の候補（価格の件数が多い商品）
+	Distance  float64 // 正規化した商品名の編集距離を長い方の文字数で割った値
+	Count     uint
+	IntoCount uint
+}
@@ -0,0 +1,30 @@
+package api
+
+type Purchase struct {
+	ID       *uint
+	DateTime *string        `validate:"omitempty,max=100"`
+	Store    string         `validate:"required,max=100"`
+	Items    []PurchaseItem `validate:"required,min=1,max=100,dive"`
+	Total    uint           // 明細の金額の合計（レスポンスのみ）
+}
+
+type PurchaseItem struct {
+	Product  string `validate:"required,max=100"`
+	Category string `json:",omitempty" validate:"max=30"`
+	Quantity uint   `json:",omitempty" validate:"max=1000"` // 個数（未指定なら1）
+	Price    uint   `validate:"required"`                   // 支払った単価
+	Amount   uint   // 単価と個数の積（レスポンスのみ）
+	PriceID  *uint  `json:",omitempty"` // 価格として記録した場合の価格のID（レスポンスのみ）
+}
+
+type SpendingReport struct {
+	By    string // month、store、category
+	Total uint
+	Rows  []SpendingRow
+}
+
+type SpendingRow struct {
+	Key       string // 月（YYYY-MM）、店舗名、カテゴリ（空は未分類）
+	Total     uint
+	Purchases int // 購入の件数
+}
@@ -0,0 +1,41 @@
+package api
+
+type ShoppingList struct {
+	ID        *uint
+	Name      string             `validate:"required,max=100"`
+	Items     []ShoppingListItem `validate:"max=100,dive"` // 並び順
+	UpdatedAt *string            // レスポンスのみ
+}
+
+type ShoppingListItem struct {
+	ID       *uint
+	Product  string `validate:"required,max=100"`
+	Quantity uint   `json:",omitempty" validate:"max=1000"` // 買う個数（未指定なら1）
+	Checked  bool
+}
+
+type ShoppingListOrder struct {
+	Items []uint `validate:"required,max=100"` // 全ての品目のIDを新しい並び順に
+}
+
+type ShoppingListEstimate struct {
+	ListID   uint
+	Strategy string // latest（最新の価格）、cheapest（最安の価格）
+	Total    uint
+	Stale    bool                // 古い価格を含む
+	Stores   []*StoreEstimate    // 店舗名の順
+	Missing  []*ShoppingListItem `json:",omitempty"` // 価格がない品目
+}
+
+type StoreEstimate struct {
+	Store string
+	Total uint
+	Stale bool             // 古い価格を含む
+	Items []*EstimatedItem // リストの並び順
+}
+
+type EstimatedItem struct {
+	Item     ShoppingListItem
+	Price    ComparedPrice
+	Subtotal uint // 価格と個数の積
+}
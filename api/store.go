@@ -0,0 +1,17 @@
+package api
+
+type Store struct {
+	ID        *uint
+	Name      string   `validate:"required,max=100"` // 価格の店舗名と対応
+	Latitude  *float64 `validate:"required,min=-90,max=90"`
+	Longitude *float64 `validate:"required,min=-180,max=180"`
+	Address   string   `json:",omitempty" validate:"max=255"`
+}
+
+type NearbyPrice struct {
+	Price
+	Distance  uint // 検索地点からの距離（メートル）
+	Latitude  float64
+	Longitude float64
+	Address   string `json:",omitempty"`
+}
@@ -0,0 +1,11 @@
+package api
+
+type Tag struct {
+	ID    *uint
+	Name  string `validate:"required,max=30"`
+	Count uint   // 付与されている価格の件数（論理削除済みを除く）
+}
+
+type TagMerge struct {
+	Into uint `validate:"required"` // 統合先のタグのID
+}
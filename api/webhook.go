@@ -0,0 +1,23 @@
+package api
+
+import "encoding/json"
+
+type Webhook struct {
+	ID     *uint
+	URL    string   `validate:"required,url,max=2048"`
+	Events []string `validate:"required,min=1,dive,oneof=price.created price.updated price.deleted"`
+	Secret string   `json:",omitempty" validate:"required,min=16,max=255"` // レスポンスには含めない
+}
+
+type WebhookDelivery struct {
+	ID             uint
+	Event          string
+	Payload        json.RawMessage
+	Status         string // pending, succeeded, dead
+	Attempts       int
+	NextAttemptAt  *string `json:",omitempty"` // pendingの場合のみ
+	LastStatusCode *int    `json:",omitempty"`
+	LastError      *string `json:",omitempty"`
+	CreatedAt      string
+	DeliveredAt    *string `json:",omitempty"`
+}
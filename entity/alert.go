@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 価格のアラートの条件（目標価格か平均価格からの値下がり率のどちらか）
+type AlertRule struct {
+	gorm.Model
+
+	UserID      uint    `gorm:"not null;index"`
+	Product     string  `gorm:"not null;size:255"`
+	Store       *string `gorm:"size:255"` // nilは全店舗
+	TargetPrice *uint
+	DropPercent *uint
+}
+
+// アラートの条件に一致した価格の通知
+type Notification struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+
+	UserID      uint      `gorm:"not null;index"`
+	AlertRuleID uint      `gorm:"not null"`
+	PriceID     uint      `gorm:"not null"`
+	DateTime    time.Time `gorm:"not null"` // 以下は通知時点の価格
+	Store       string    `gorm:"not null;size:255"`
+	Product     string    `gorm:"not null;size:255"`
+	Price       uint      `gorm:"not null"`
+	Average     *uint     // 値下がり率の条件の場合の平均価格
+	ReadAt      *time.Time
+}
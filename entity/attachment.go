@@ -0,0 +1,18 @@
+package entity
+
+import (
+	"time"
+)
+
+// 価格の証跡となるレシートや値札の写真（本体はBlobStoreに保存）
+type Attachment struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+
+	PriceID     uint   `gorm:"not null;index"`
+	UserID      uint   `gorm:"not null"`
+	FileName    string `gorm:"not null;size:255"`
+	ContentType string `gorm:"not null;size:100"` // 内容から判定したメディアタイプ
+	Size        int64  `gorm:"not null"`
+	BlobKey     string `gorm:"not null;size:255;uniqueIndex"`
+}
@@ -0,0 +1,19 @@
+package entity
+
+import (
+	"time"
+)
+
+// POSTの再送で同じ処理を繰り返さないためのキーと保存したレスポンス
+type IdempotencyKey struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID      uint   `gorm:"not null;uniqueIndex:idx_idempotency_keys_user_id_key"`
+	Key         string `gorm:"column:idempotency_key;not null;size:255;uniqueIndex:idx_idempotency_keys_user_id_key"`
+	Fingerprint string `gorm:"not null;size:64"`   // メソッド、パス、リクエストボディのSHA-256
+	Status      int    `gorm:"not null;default:0"` // 0は処理中
+	Header      string `gorm:"not null;type:text"` // 再送時に返すレスポンスヘッダ（JSON）
+	Body        []byte
+}
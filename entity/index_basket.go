@@ -0,0 +1,26 @@
+package entity
+
+import (
+	"time"
+)
+
+// 物価指数の買い物かご
+type IndexBasket struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID uint              `gorm:"not null;index"`
+	Name   string            `gorm:"not null;size:255"`
+	Items  []IndexBasketItem `gorm:"-"` // 商品と重み（別のテーブル）
+}
+
+// 物価指数の買い物かごの商品
+type IndexBasketItem struct {
+	ID uint `gorm:"primarykey"`
+
+	BasketID uint    `gorm:"not null;index"`
+	UserID   uint    `gorm:"not null"`
+	Product  string  `gorm:"not null;size:255"`
+	Weight   float64 `gorm:"not null"` // 基準月の支出の割合（合計が1でなくてもよい）
+}
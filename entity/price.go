@@ -6,12 +6,34 @@ import (
 	"gorm.io/gorm"
 )
 
+// 価格の種類
+const (
+	PriceTypeRegular = "regular" // 通常価格
+	PriceTypeSale    = "sale"    // セール価格
+	PriceTypeMember  = "member"  // 会員価格
+)
+
 type Price struct {
 	gorm.Model
 
-	UserID   uint      `gorm:"not null,index"`
-	DateTime time.Time `gorm:"not null"`
-	Store    string    `gorm:"not null;size:255"`
-	Product  string    `gorm:"not null;size:255"`
-	Price    uint      `gorm:"not null"`
+	UserID     uint      `gorm:"not null,index"`
+	DateTime   time.Time `gorm:"not null"`
+	Store      string    `gorm:"not null;size:255"`
+	Product    string    `gorm:"not null;size:255"`
+	GTIN       string    `gorm:"column:gtin;not null;default:'';size:14;index"` // JAN/EANまたはUPCのバーコード（任意）
+	Price      uint      `gorm:"not null"`
+	Quantity   float64   `gorm:"not null;default:0"`          // 内容量（0は指定なし）
+	Unit       string    `gorm:"not null;default:'';size:10"` // g、kg、ml、L、piece
+	Suspicious bool      `gorm:"not null;default:false"`      // 過去の価格から外れ値の疑い
+	Version    uint      `gorm:"not null;default:1"`          // 楽観的排他制御
+
+	PriceType string     `gorm:"not null;default:'regular';size:10"` // regular、sale、member
+	ValidFrom *time.Time `gorm:"default:null"`                       // 有効期間の始まり（nilはDateTimeから）
+	ValidTo   *time.Time `gorm:"default:null"`                       // 有効期間の終わり（含まない、nilは次の価格まで）
+}
+
+// 価格の有効期間（部分更新では始まりと終わりをまとめて変更する）
+type PriceValidity struct {
+	From *time.Time
+	To   *time.Time
 }
@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"time"
+)
+
+// 価格の変更履歴（追記のみ）
+type PriceRevision struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+
+	PriceID   uint         `gorm:"not null;index"`
+	UserID    uint         `gorm:"not null"` // 変更したユーザ
+	Operation string       `gorm:"not null;size:20"`
+	Before    *PriceValues `gorm:"serializer:json;type:text"` // createの場合はnil
+	After     *PriceValues `gorm:"serializer:json;type:text"` // delete、purgeの場合はnil
+}
+
+type PriceValues struct {
+	DateTime time.Time
+	Store    string
+	Product  string
+	GTIN     string `json:",omitempty"`
+	Price    uint
+	Quantity float64 `json:",omitempty"`
+	Unit     string  `json:",omitempty"`
+
+	PriceType string     `json:",omitempty"` // 種類の追加前の変更履歴は空（regular）
+	ValidFrom *time.Time `json:",omitempty"`
+	ValidTo   *time.Time `json:",omitempty"`
+
+	Tags []string // タグの名前（変更前はタグの変更による更新だけ記録、記録していなければnil）
+}
@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"time"
+)
+
+// 商品名の別名（ユーザごとに別名が一意で、価格の登録時に正式な商品名に置き換える）
+type ProductAlias struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID  uint   `gorm:"not null;uniqueIndex:idx_product_aliases_user_id_alias"`
+	Alias   string `gorm:"not null;size:255;uniqueIndex:idx_product_aliases_user_id_alias"`
+	Product string `gorm:"not null;size:255"` // 正式な商品名（別名ではない）
+}
+
+// 商品ごとの価格の件数
+type ProductCount struct {
+	Product string
+	Count   uint
+}
@@ -0,0 +1,32 @@
+package entity
+
+import (
+	"time"
+)
+
+// 購入（レシート）
+type Purchase struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID   uint           `gorm:"not null;index:idx_purchases_user_id_date_time"`
+	DateTime time.Time      `gorm:"not null;index:idx_purchases_user_id_date_time"`
+	Store    string         `gorm:"not null;size:255"`
+	Total    uint           `gorm:"not null"` // 明細の金額の合計
+	Items    []PurchaseItem `gorm:"-"`        // 明細（別のテーブル）
+}
+
+// 購入の明細
+type PurchaseItem struct {
+	ID uint `gorm:"primarykey"`
+
+	PurchaseID uint   `gorm:"not null;index"`
+	UserID     uint   `gorm:"not null"`
+	Product    string `gorm:"not null;size:255"`
+	Category   string `gorm:"not null;size:100"` // 空は未分類
+	Quantity   uint   `gorm:"not null"`
+	Price      uint   `gorm:"not null"` // 支払った単価
+	Amount     uint   `gorm:"not null"` // 単価と個数の積
+	PriceID    *uint  // 価格として記録した場合の価格のID
+}
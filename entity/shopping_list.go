@@ -0,0 +1,30 @@
+package entity
+
+import (
+	"time"
+)
+
+// 買い物リスト
+type ShoppingList struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID uint               `gorm:"not null;index"`
+	Name   string             `gorm:"not null;size:255"`
+	Items  []ShoppingListItem `gorm:"-"` // 並び順の品目（別のテーブル）
+}
+
+// 買い物リストの品目
+type ShoppingListItem struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	ListID   uint   `gorm:"not null;index:idx_shopping_list_items_list_id_position"`
+	UserID   uint   `gorm:"not null"`
+	Product  string `gorm:"not null;size:255"`
+	Quantity uint   `gorm:"not null"` // 買う個数
+	Checked  bool   `gorm:"not null;default:false"`
+	Position int    `gorm:"not null;index:idx_shopping_list_items_list_id_position"` // 並び順
+}
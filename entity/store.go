@@ -0,0 +1,19 @@
+package entity
+
+import (
+	"time"
+)
+
+// 店舗の位置（ユーザごとに名前が一意で、価格の店舗名と対応）
+type Store struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID    uint    `gorm:"not null;uniqueIndex:idx_stores_user_id_name;index:idx_stores_user_id_latitude"`
+	Name      string  `gorm:"not null;size:255;uniqueIndex:idx_stores_user_id_name"`
+	Latitude  float64 `gorm:"not null;index:idx_stores_user_id_latitude"`
+	Longitude float64 `gorm:"not null"`
+	Address   string  `gorm:"not null;size:255"`
+	Distance  float64 `gorm:"->;-:migration"` // 検索地点からの距離（メートル、近隣検索のみ）
+}
@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"time"
+)
+
+// 価格のタグ（ユーザごとに名前が一意）
+type Tag struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID uint   `gorm:"not null;uniqueIndex:idx_tags_user_id_name"`
+	Name   string `gorm:"not null;size:255;uniqueIndex:idx_tags_user_id_name"`
+	Count  uint   `gorm:"->;-:migration"` // 付与されている価格の件数（論理削除済みを除く）
+}
+
+// 価格とタグの関連
+type PriceTag struct {
+	PriceID uint `gorm:"primaryKey;autoIncrement:false"`
+	TagID   uint `gorm:"primaryKey;autoIncrement:false;index"`
+}
@@ -10,3 +10,9 @@ type User struct {
 	Name     string `gorm:"not null;uniqueIndex;size:255"`
 	Password string `gorm:"not null;size:255"`
 }
+
+// ユーザごとの名前（登録済みの名前の正規化）
+type UserName struct {
+	UserID uint
+	Name   string
+}
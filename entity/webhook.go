@@ -0,0 +1,35 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhookの購読
+type Webhook struct {
+	gorm.Model
+
+	UserID uint     `gorm:"not null;index"`
+	URL    string   `gorm:"not null;size:2048"`
+	Events []string `gorm:"not null;serializer:json;type:text"` // 購読するイベント種別
+	Secret string   `gorm:"not null;size:255"`                  // 署名の鍵
+}
+
+// Webhookの配信（配信キューを兼ねる）
+type WebhookDelivery struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	WebhookID      uint       `gorm:"not null;index"`
+	UserID         uint       `gorm:"not null"`
+	Event          string     `gorm:"not null;size:50"`
+	Payload        string     `gorm:"not null;type:text"`
+	Status         string     `gorm:"not null;size:20;index:idx_webhook_deliveries_due,priority:1"`
+	Attempts       int        `gorm:"not null"`
+	NextAttemptAt  time.Time  `gorm:"not null;index:idx_webhook_deliveries_due,priority:2"`
+	LastStatusCode *int       // 最後の試行のHTTPステータスコード
+	LastError      *string    `gorm:"size:1000"`
+	DeliveredAt    *time.Time // 配信に成功した日時
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -14,22 +15,108 @@ import (
 
 var (
 	// 400
-	ErrAlreadyRegistered = errors.New("already registered")
-	ErrIDCannotRequest   = errors.New("ID cannot be requested")
-	ErrIDUnchangeable    = errors.New("ID is unchangeable")
+	ErrAlreadyRegistered  = errors.New("already registered")
+	ErrIDCannotRequest    = errors.New("ID cannot be requested")
+	ErrIDUnchangeable     = errors.New("ID is unchangeable")
+	ErrDateTimeRequired   = errors.New("DateTime is required")
+	ErrIDRequired         = errors.New("ID is required")
+	ErrPriceRequired      = errors.New("Price is required")
+	ErrBatchSize          = errors.New("number of operations is out of range")
+	ErrColumnNotFound     = errors.New("column not found")
+	ErrNotRevertible      = errors.New("revision is not revertible")
+	ErrAlertCondition     = errors.New("either TargetPrice or DropPercent is required")
+	ErrNotificationStatus = errors.New("status must be unread or read")
+	ErrWebhookURLScheme   = errors.New("URL scheme must be http or https")
+	ErrWebhookURLHost     = errors.New("URL host must not be a loopback, private or link-local address")
+	ErrTagMatch           = errors.New("match must be any or all")
+	ErrTagAlreadyExists   = errors.New("tag already exists")
+	ErrTagMergeSelf       = errors.New("tag cannot be merged into itself")
+	ErrAttachmentRequired = errors.New("file is required")
+	ErrAttachmentEmpty    = errors.New("file is empty")
+	ErrAttachmentFileName = errors.New("file name is invalid")
+	ErrTooManyAttachments = errors.New("too many attachments")
+	ErrStoreAlreadyExists = errors.New("store already exists")
+	ErrLatitude           = errors.New("lat must be between -90 and 90")
+	ErrLongitude          = errors.New("lng must be between -180 and 180")
+	ErrRadius             = errors.New("radius must be greater than 0 and at most 50000")
+	ErrGTIN               = errors.New("invalid GTIN")
+	ErrUnitMismatch       = errors.New("unit family does not match other prices of the product")
+	ErrBasketUnitMismatch = errors.New("items of the same product must have the same unit family")
+	ErrSort               = errors.New("sort must be datetime or unitprice")
+	ErrMaxAge             = errors.New("maxAge must be between 1 and 3650")
+	ErrIdempotencyKey     = errors.New("Idempotency-Key must be at most 255 characters")
+	ErrTooManyListItems   = errors.New("too many items in the list")
+	ErrListOrder          = errors.New("Items must contain every item of the list exactly once")
+	ErrEstimateStrategy   = errors.New("strategy must be latest or cheapest")
+	ErrSpendingBy         = errors.New("by must be month, store or category")
+	ErrMonth              = errors.New("month must be in YYYY-MM format")
+	ErrMonthRange         = errors.New("start month must not be after end month")
+	ErrForecastDays       = errors.New("days must be between 1 and 90")
+	ErrIndexMonths        = errors.New("period must be at most 120 months")
+	ErrValidity           = errors.New("ValidTo must be after ValidFrom or DateTime")
+	ErrPriceType          = errors.New("type must be regular, sale or member")
+	ErrProductAliasSelf   = errors.New("alias must differ from the product")
+	ErrProductAliasExists = errors.New("alias already exists")
+	ErrProductMergeSelf   = errors.New("product cannot be merged into itself")
+	ErrDuplicateThreshold = errors.New("threshold must be at least 0 and less than 1")
+	ErrControlCharacter   = errors.New("Store and Product must not contain control characters")
 
 	// 401
 	ErrAuthenticationFailed = errors.New("authentication failed")
 
 	// 404
 	ErrNotFound = errors.New("not found")
+
+	// 409
+	ErrIdempotencyKeyReused     = errors.New("Idempotency-Key is already used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("request with the same Idempotency-Key is in progress")
+	ErrPatchConflict            = errors.New("price was updated concurrently while applying the patch")
+
+	// 412
+	ErrPreconditionFailed = errors.New("precondition failed")
+
+	// 415
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrUnsupportedCharset   = errors.New("unsupported charset")
+
+	// 422
+	ErrPriceOutOfRange     = errors.New("price is out of the expected range")
+	ErrInsufficientHistory = errors.New("at least 5 prices are required for a forecast")
+
+	// 424
+	ErrAborted = errors.New("aborted due to another operation")
 )
 
 func newHTTPError(code int, err error) *echo.HTTPError {
 	return echo.NewHTTPError(code, err).SetInternal(plyerrors.WrapSkipFrames(err, "", 1))
 }
 
+// 想定の範囲を含む価格の範囲外のエラー
+type priceRangeError struct {
+	min uint
+	max uint
+}
+
+func (e *priceRangeError) Error() string {
+	return fmt.Sprintf("%s: expected %d to %d", ErrPriceOutOfRange, e.min, e.max)
+}
+
+func (e *priceRangeError) Unwrap() error {
+	return ErrPriceOutOfRange
+}
+
 func (h *Handler) errorHandler(err error, c echo.Context) {
+	// レスポンスの生成（ストリーミング中のエラーは送信済みのため不可）
+	if !c.Response().Committed {
+		code, res := h.errorResponse(err)
+		c.JSONPretty(code, res, h.indent)
+	}
+
+	// エラーログ
+	slog.DebugContext(c.Request().Context(), err.Error())
+}
+
+func (h *Handler) errorResponse(err error) (int, *api.ErrorResponse) {
 	var code int
 	var detail string
 	var he *echo.HTTPError
@@ -52,6 +139,16 @@ func (h *Handler) errorHandler(err error, c echo.Context) {
 		title = "Authentication Error"
 	case http.StatusNotFound:
 		title = "Not Found"
+	case http.StatusConflict:
+		title = "Conflict"
+	case http.StatusPreconditionFailed:
+		title = "Precondition Failed"
+	case http.StatusUnsupportedMediaType:
+		title = "Unsupported Media Type"
+	case http.StatusUnprocessableEntity:
+		title = "Unprocessable Entity"
+	case http.StatusFailedDependency:
+		title = "Failed Dependency"
 	case http.StatusServiceUnavailable:
 		title = "System Error"
 		detail = "Service Unavailable"
@@ -68,29 +165,35 @@ func (h *Handler) errorHandler(err error, c echo.Context) {
 	}
 
 	// バリデーションエラー
-	var params []api.InvalidParam
-	var verrs validator.ValidationErrors
-	if errors.As(err, &verrs) {
-		trans := verrs.Translate(h.validator.translator)
-		params = make([]api.InvalidParam, len(verrs))
-		for i, v := range verrs {
-			params[i] = api.InvalidParam{
-				Name:   v.Field(),
-				Reason: trans[v.Namespace()],
-			}
-		}
-	}
+	params := h.invalidParams(err)
 
-	// レスポンスの生成
-	res := api.ErrorResponse{
+	res := &api.ErrorResponse{
 		Title:         title,
 		InvalidParams: params,
 	}
 	if params == nil && detail != "" && !strings.EqualFold(detail, title) {
 		res.Detail = &detail
 	}
-	c.JSONPretty(code, &res, h.indent)
+	var rangeErr *priceRangeError
+	if errors.As(err, &rangeErr) {
+		res.ExpectedRange = &api.PriceRange{Min: rangeErr.min, Max: rangeErr.max}
+	}
 
-	// エラーログ
-	slog.DebugContext(c.Request().Context(), err.Error())
+	return code, res
+}
+
+func (h *Handler) invalidParams(err error) []api.InvalidParam {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil
+	}
+	trans := verrs.Translate(h.validator.translator)
+	params := make([]api.InvalidParam, len(verrs))
+	for i, v := range verrs {
+		params[i] = api.InvalidParam{
+			Name:   v.Field(),
+			Reason: trans[v.Namespace()],
+		}
+	}
+	return params
 }
@@ -30,6 +30,7 @@ func TestErrorHandler(t *testing.T) {
 		{echo.NewHTTPError(http.StatusBadRequest, cause).SetInternal(cause), 400},
 		{echo.NewHTTPError(http.StatusUnauthorized).SetInternal(cause), 401},
 		{echo.NewHTTPError(http.StatusNotFound).SetInternal(cause), 404},
+		{echo.NewHTTPError(http.StatusUnsupportedMediaType).SetInternal(cause), 415},
 		{echo.NewHTTPError(http.StatusRequestEntityTooLarge).SetInternal(cause), 413},
 		{echo.NewHTTPError(http.StatusTooManyRequests).SetInternal(cause), 429},
 		{echo.NewHTTPError(http.StatusServiceUnavailable).SetInternal(cause), 503},
@@ -102,3 +103,41 @@ func TestErrorHandlerJa(t *testing.T) {
 	assert.Equal(t, "Password", rerRes.InvalidParams[0].Name)
 	assert.Equal(t, "Passwordは必須フィールドです", rerRes.InvalidParams[0].Reason)
 }
+
+// 独自のタグの翻訳
+func TestErrorHandlerCustomValidation(t *testing.T) {
+	cases := []struct {
+		locale string
+		reason string
+	}{
+		{"", "GTIN must be a valid GTIN (JAN/EAN-8, JAN/EAN-13 or UPC-A)"},
+		{"ja", "GTINは正しいGTIN（JAN/EAN-8、JAN/EAN-13、UPC-A）でなければなりません"},
+	}
+
+	for _, v := range cases {
+		// セットアップ
+		h := handler.NewHandler(nil, &handler.HandlerConfig{Locale: v.locale, RequestBodyLimit: "1K"})
+		e := handler.NewEcho(h)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		param := &api.Price{Store: "pcshop", Product: "ssd1T", GTIN: "4901234567890", Price: 9500}
+		he := echo.NewHTTPError(http.StatusBadRequest).SetInternal(c.Validate(param))
+
+		// テストの実行
+		e.HTTPErrorHandler(he, c)
+
+		// アサーション
+		assert.Equal(t, 400, c.Response().Status)
+
+		var rerRes api.ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &rerRes); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 1, len(rerRes.InvalidParams))
+		assert.Equal(t, "GTIN", rerRes.InvalidParams[0].Name)
+		assert.Equal(t, v.reason, rerRes.InvalidParams[0].Reason)
+	}
+}
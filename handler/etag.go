@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerETag        = "ETag"
+	headerIfMatch     = "If-Match"
+	headerIfNoneMatch = "If-None-Match"
+
+	cacheControlRevalidate = "private, no-cache" // キャッシュは可能だが利用前に必ず再検証
+)
+
+// 価格のETag（バージョンから生成する強いETag）
+func priceETag(price *entity.Price) string {
+	return strconv.Quote(strconv.FormatUint(uint64(price.Version), 10))
+}
+
+// 価格の一覧のETag（内容が変わる要素の属性から生成する強いETag）
+func pricesETag(prices []entity.Price) string {
+	hash := sha256.New()
+	for _, v := range prices {
+		var deletedAt int64
+		if v.DeletedAt.Valid {
+			deletedAt = v.DeletedAt.Time.UnixNano()
+		}
+		fmt.Fprintf(hash, "%d:%d:%d:%d\n", v.ID, v.Version, v.UpdatedAt.UnixNano(), deletedAt)
+	}
+	return strconv.Quote(hex.EncodeToString(hash.Sum(nil)))
+}
+
+// 変更履歴の一覧のETag（変更履歴は追記のみのためIDから生成）
+func revisionsETag(revisions []entity.PriceRevision) string {
+	hash := sha256.New()
+	for _, v := range revisions {
+		fmt.Fprintf(hash, "%d\n", v.ID)
+	}
+	return strconv.Quote(hex.EncodeToString(hash.Sum(nil)))
+}
+
+// レスポンスの本文のETag（更新日時を持たない集計などの参照系向けの強いETag）
+func contentETag(body any) (string, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(b)
+	return strconv.Quote(hex.EncodeToString(hash[:])), nil
+}
+
+// 本文のETagで条件付きGETを判定してJSONのレスポンスを返す
+func (h *Handler) revalidateJSON(c echo.Context, body any) error {
+	etag, err := contentETag(body)
+	if err != nil {
+		return err
+	}
+	if notModified(c, etag, time.Time{}) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.JSONPretty(http.StatusOK, body, h.indent)
+}
+
+func setPriceETag(c echo.Context, price *entity.Price) {
+	c.Response().Header().Set(headerETag, priceETag(price))
+}
+
+// If-Matchヘッダからバージョンの一覧を取得
+//
+// ヘッダがない場合と"*"の場合はnil（無条件）。強い比較のため弱いETagは一致しない
+func parseIfMatch(c echo.Context) ([]uint, error) {
+	header := strings.TrimSpace(c.Request().Header.Get(headerIfMatch))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	versions := []uint{}
+	for tag := range strings.SplitSeq(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		version, err := strconv.ParseUint(tag[1:len(tag)-1], 10, 0)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, uint(version))
+	}
+	if len(versions) == 0 {
+		return nil, newHTTPError(http.StatusPreconditionFailed, ErrPreconditionFailed)
+	}
+
+	return versions, nil
+}
+
+// 条件付きGETの判定。キャッシュのヘッダを設定して、クライアントのキャッシュが有効ならtrue
+//
+// If-None-Matchがある場合はIf-Modified-Sinceを無視する（RFC 9110）
+func notModified(c echo.Context, etag string, lastModified time.Time) bool {
+	header := c.Response().Header()
+	header.Set(echo.HeaderCacheControl, cacheControlRevalidate)
+	header.Set(headerETag, etag)
+	if !lastModified.IsZero() {
+		header.Set(echo.HeaderLastModified, lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	req := c.Request().Header
+	if ifNoneMatch := req.Get(headerIfNoneMatch); ifNoneMatch != "" {
+		return matchETag(ifNoneMatch, etag)
+	}
+	if ifModifiedSince := req.Get(echo.HeaderIfModifiedSince); ifModifiedSince != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ifModifiedSince)
+		if err != nil {
+			return false
+		}
+		return !lastModified.Truncate(time.Second).After(since) // HTTPの日時は秒単位
+	}
+
+	return false
+}
+
+// If-None-Matchの弱い比較
+func matchETag(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for tag := range strings.SplitSeq(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
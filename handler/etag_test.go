@@ -0,0 +1,278 @@
+package handler_test
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ystkg/rest-example/handler"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ETagとIf-Matchによる楽観的排他制御
+func TestPriceETag(t *testing.T) {
+	testname := "TestPriceETag"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 登録
+	body := `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9500}`
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+	location := "/v1/prices/1"
+
+	// 取得
+	rec, err = execHandler(e, newRequest(http.MethodGet, location, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+
+	// 一致するIf-Matchで更新
+	body = `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":8900}`
+	req := newRequest(http.MethodPut, location, &body, echo.MIMEApplicationJSON, jwt)
+	req.Header.Set("If-Match", `"1"`)
+	rec, err = execHandler(e, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	// 古いETagでの更新は失敗
+	body = `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":8000}`
+	req = newRequest(http.MethodPut, location, &body, echo.MIMEApplicationJSON, jwt)
+	req.Header.Set("If-Match", `"1"`)
+	code, cause, err := execHandlerValidation(e, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 412, code)
+	assert.Equal(t, handler.ErrPreconditionFailed, cause)
+
+	// 部分更新
+	body = `{"Store":"pcstore"}`
+	req = newRequest(http.MethodPatch, location, &body, "application/merge-patch+json", jwt)
+	req.Header.Set("If-Match", `"2"`)
+	rec, err = execHandler(e, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+
+	// 古いETagでの削除は失敗
+	req = newRequest(http.MethodDelete, location, nil, "", jwt)
+	req.Header.Set("If-Match", `"2"`)
+	code, cause, err = execHandlerValidation(e, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 412, code)
+	assert.Equal(t, handler.ErrPreconditionFailed, cause)
+
+	// 最新のETagで削除
+	req = newRequest(http.MethodDelete, location, nil, "", jwt)
+	req.Header.Set("If-Match", `"3"`)
+	rec, err = execHandler(e, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 204, rec.Code)
+}
+
+// If-Matchのバリデーション
+func TestIfMatchValidation(t *testing.T) {
+	testname := "TestIfMatchValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	userId := uint(1)
+	now := time.Now()
+	priceId, err := insertPrice(tx, &now, &now, nil, userId, now, "pcshop", "ssd1T", 9500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, userId)
+	priceIdStr := strconv.FormatUint(uint64(priceId), 10)
+	cases := []struct {
+		priceId string
+		ifMatch string
+		code    int
+		err     error
+	}{
+		{priceIdStr, `W/"1"`, 412, handler.ErrPreconditionFailed},
+		{priceIdStr, `1`, 412, handler.ErrPreconditionFailed},
+		{priceIdStr, `"a"`, 412, handler.ErrPreconditionFailed},
+		{priceIdStr, `"0"`, 412, handler.ErrPreconditionFailed},
+		{priceIdStr + "1", `"1"`, 404, handler.ErrNotFound},
+		{priceIdStr, `"1"`, 200, nil},
+		{priceIdStr, `"5", "2"`, 200, nil},
+		{priceIdStr, `*`, 200, nil},
+	}
+
+	for _, v := range cases {
+		// リクエストの生成
+		body := `{"Store":"pcshop", "Product":"ssd1T", "Price":9500}`
+		req := newRequest(
+			http.MethodPut,
+			fmt.Sprintf("/v1/prices/%s", v.priceId),
+			&body,
+			echo.MIMEApplicationJSON,
+			jwt,
+		)
+		req.Header.Set("If-Match", v.ifMatch)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause)
+		}
+	}
+}
+
+// 条件付きGET
+func TestConditionalGet(t *testing.T) {
+	testname := "TestConditionalGet"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	if _, err := insertPrices(tx, &now, somePrices()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	targets := []string{
+		"/v1/prices", "/v1/prices/1", "/v1/prices/1/revisions", "/v1/trash/prices",
+		"/v1/stores", "/v1/tags", "/v1/products/memory8G/compare", "/v1/reports/spending", // 本文のETag
+	}
+	for _, target := range targets {
+		// 初回の取得
+		rec, err := execHandler(e, newRequest(http.MethodGet, target, nil, "", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 200, rec.Code, target)
+		assert.Equal(t, "private, no-cache", rec.Header().Get("Cache-Control"), target)
+		etag := rec.Header().Get("ETag")
+		assert.NotEmpty(t, etag, target)
+
+		// ETagによる再検証
+		for _, ifNoneMatch := range []string{etag, "W/" + etag, `"x", ` + etag, "*"} {
+			req := newRequest(http.MethodGet, target, nil, "", jwt)
+			req.Header.Set("If-None-Match", ifNoneMatch)
+			rec, err = execHandler(e, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, 304, rec.Code, target)
+			assert.Empty(t, rec.Body.Bytes(), target)
+			assert.Equal(t, etag, rec.Header().Get("ETag"), target)
+		}
+		req := newRequest(http.MethodGet, target, nil, "", jwt)
+		req.Header.Set("If-None-Match", `"x"`)
+		rec, err = execHandler(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 200, rec.Code, target)
+	}
+
+	// 更新日時による再検証
+	rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/prices", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := rec.Header().Get("ETag")
+	lastModified := rec.Header().Get("Last-Modified")
+	rec, err = execHandler(e, newRequest(http.MethodGet, "/v1/products/memory8G/compare", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareETag := rec.Header().Get("ETag")
+	assert.Empty(t, rec.Header().Get("Last-Modified"))
+	assert.Equal(t, now.UTC().Format(http.TimeFormat), lastModified)
+	req := newRequest(http.MethodGet, "/v1/prices", nil, "", jwt)
+	req.Header.Set("If-Modified-Since", lastModified)
+	rec, err = execHandler(e, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 304, rec.Code)
+
+	// 削除すると一覧は変更あり
+	time.Sleep(time.Second)
+	if _, err := execHandler(e, newRequest(http.MethodDelete, "/v1/prices/1", nil, "", jwt)); err != nil {
+		t.Fatal(err)
+	}
+	for header, value := range map[string]string{"If-None-Match": etag, "If-Modified-Since": lastModified} {
+		req := newRequest(http.MethodGet, "/v1/prices", nil, "", jwt)
+		req.Header.Set(header, value)
+		rec, err = execHandler(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 200, rec.Code, header)
+		assert.NotEmpty(t, rec.Body.Bytes(), header)
+	}
+
+	// 削除すると比較の結果も変更あり
+	req = newRequest(http.MethodGet, "/v1/products/memory8G/compare", nil, "", jwt)
+	req.Header.Set("If-None-Match", compareETag)
+	rec, err = execHandler(e, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	assert.NotEqual(t, compareETag, rec.Header().Get("ETag"))
+}
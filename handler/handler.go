@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"time"
 
 	"github.com/ystkg/rest-example/service"
@@ -30,21 +31,49 @@ type Handler struct {
 
 	timeoutSec int
 
+	// Server-Sent Events
+	heartbeat      time.Duration
+	shutdown       context.Context // シャットダウンでストリームを終了
+	shutdownCancel context.CancelFunc
+
+	// 比較
+	compareMaxAgeDays int
+	staleDays         int
+
+	idempotencyKeyRetention time.Duration
+
+	strictOutlier bool
+
+	normalizeText bool
+
+	allowPrivateWebhook bool
+
 	// Limit
-	requestBodyLimit string
-	rateLimit        int
+	requestBodyLimit           string
+	batchRequestBodyLimit      string
+	attachmentRequestBodyLimit string
+	rateLimit                  int
 }
 
 type HandlerConfig struct {
-	JwtKey           []byte
-	ValidityMin      int // JWTのexp
-	DateTimeLayout   string
-	Location         *time.Location
-	Locale           string
-	Indent           string // レスポンスのJSONのインデント
-	TimeoutSec       int
-	RequestBodyLimit string
-	RateLimit        int
+	JwtKey                       []byte
+	ValidityMin                  int // JWTのexp
+	DateTimeLayout               string
+	Location                     *time.Location
+	Locale                       string
+	Indent                       string // レスポンスのJSONのインデント
+	TimeoutSec                   int
+	RequestBodyLimit             string
+	BatchRequestBodyLimit        string // 一括処理とインポートのリクエストボディの上限（未指定ならRequestBodyLimit）
+	AttachmentRequestBodyLimit   string // 添付ファイルのアップロードのリクエストボディの上限（未指定なら5M）
+	RateLimit                    int
+	HeartbeatSec                 int  // イベントのストリームのハートビートの間隔（未指定なら15秒）
+	CompareMaxAgeDays            int  // 比較の対象にする価格の経過日数の上限（未指定なら90日）
+	StaleDays                    int  // 比較で古い価格とみなす経過日数（未指定なら30日）
+	IdempotencyKeyRetentionHours int  // Idempotency-Keyの保持期間（未指定なら24時間）
+	StrictOutlier                bool // 外れ値の価格をconfirmの指定なしでは登録しない
+	NormalizeText                bool // 価格の店舗と商品名をNFKCで正規化し、制御文字を拒否
+	AllowPrivateWebhook          bool // 内部ネットワークへのWebhookの配信を許可（開発用）
 }
 
 func NewHandler(s service.Service, config *HandlerConfig) *Handler {
@@ -62,20 +91,56 @@ func NewHandler(s service.Service, config *HandlerConfig) *Handler {
 	if jwtContextKey == "" {
 		jwtContextKey = "user" // デフォルトは"user"になる
 	}
+	batchRequestBodyLimit := config.BatchRequestBodyLimit
+	if batchRequestBodyLimit == "" {
+		batchRequestBodyLimit = config.RequestBodyLimit
+	}
+	attachmentRequestBodyLimit := config.AttachmentRequestBodyLimit
+	if attachmentRequestBodyLimit == "" {
+		attachmentRequestBodyLimit = "5M"
+	}
+	heartbeatSec := config.HeartbeatSec
+	if heartbeatSec <= 0 {
+		heartbeatSec = 15
+	}
+	compareMaxAgeDays := config.CompareMaxAgeDays
+	if compareMaxAgeDays <= 0 {
+		compareMaxAgeDays = 90
+	}
+	staleDays := config.StaleDays
+	if staleDays <= 0 {
+		staleDays = 30
+	}
+	idempotencyKeyRetentionHours := config.IdempotencyKeyRetentionHours
+	if idempotencyKeyRetentionHours <= 0 {
+		idempotencyKeyRetentionHours = 24
+	}
+	shutdown, shutdownCancel := context.WithCancel(context.Background())
 
 	return &Handler{
-		service:          s,
-		validator:        newValidator(config.Locale),
-		jwtConfig:        jwtConfig,
-		signingMethod:    jwt.GetSigningMethod(signingMethod),
-		jwtContextKey:    jwtContextKey,
-		validityMin:      config.ValidityMin,
-		layout:           config.DateTimeLayout,
-		location:         config.Location,
-		indent:           config.Indent,
-		timeoutSec:       config.TimeoutSec,
-		requestBodyLimit: config.RequestBodyLimit,
-		rateLimit:        config.RateLimit,
+		service:                    s,
+		validator:                  newValidator(config.Locale),
+		jwtConfig:                  jwtConfig,
+		signingMethod:              jwt.GetSigningMethod(signingMethod),
+		jwtContextKey:              jwtContextKey,
+		validityMin:                config.ValidityMin,
+		layout:                     config.DateTimeLayout,
+		location:                   config.Location,
+		indent:                     config.Indent,
+		timeoutSec:                 config.TimeoutSec,
+		heartbeat:                  time.Duration(heartbeatSec) * time.Second,
+		compareMaxAgeDays:          compareMaxAgeDays,
+		staleDays:                  staleDays,
+		idempotencyKeyRetention:    time.Duration(idempotencyKeyRetentionHours) * time.Hour,
+		strictOutlier:              config.StrictOutlier,
+		normalizeText:              config.NormalizeText,
+		shutdown:                   shutdown,
+		shutdownCancel:             shutdownCancel,
+		allowPrivateWebhook:        config.AllowPrivateWebhook,
+		requestBodyLimit:           config.RequestBodyLimit,
+		batchRequestBodyLimit:      batchRequestBodyLimit,
+		attachmentRequestBodyLimit: attachmentRequestBodyLimit,
+		rateLimit:                  config.RateLimit,
 	}
 }
 
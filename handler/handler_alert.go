@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/entity"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+// アラートの条件の登録
+func (h *Handler) createAlertRule(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	req := &api.AlertRule{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック
+	if err := h.validateAlertRule(c, req); err != nil {
+		return err
+	}
+	if req.ID != nil {
+		return newHTTPError(http.StatusBadRequest, ErrIDCannotRequest)
+	}
+
+	// サービスの実行
+	rule, err := h.service.CreateAlertRule(ctx, alertRuleToEntity(req, 0, userId))
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusCreated, alertRuleToResponse(rule), h.indent)
+}
+
+// アラートの条件の一覧
+func (h *Handler) findAlertRules(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+
+	// サービスの実行
+	entities, err := h.service.FindAlertRules(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成（登録順）
+	rules := make([]*api.AlertRule, len(entities))
+	for i, v := range entities {
+		rules[i] = alertRuleToResponse(&v)
+	}
+
+	return h.revalidateJSON(c, rules)
+}
+
+// アラートの条件の取得
+func (h *Handler) findAlertRule(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	ruleId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	rule, err := h.service.FindAlertRule(ctx, uint(ruleId), userId)
+	if err != nil {
+		return err
+	}
+	if rule == nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// レスポンスの生成
+	return h.revalidateJSON(c, alertRuleToResponse(rule))
+}
+
+// アラートの条件の更新
+func (h *Handler) updateAlertRule(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	req := &api.AlertRule{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック
+	ruleId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	if err = h.validateAlertRule(c, req); err != nil {
+		return err
+	}
+	if req.ID != nil && *req.ID != uint(ruleId) {
+		return newHTTPError(http.StatusBadRequest, ErrIDUnchangeable)
+	}
+
+	// サービスの実行
+	rule, err := h.service.UpdateAlertRule(ctx, alertRuleToEntity(req, uint(ruleId), userId))
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusOK, alertRuleToResponse(rule), h.indent)
+}
+
+// アラートの条件の削除
+func (h *Handler) deleteAlertRule(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	ruleId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	if err = h.service.DeleteAlertRule(ctx, uint(ruleId), userId); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) validateAlertRule(c echo.Context, req *api.AlertRule) error {
+	if err := h.normalizeNames(&req.Product, req.Store); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if err := c.Validate(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if (req.TargetPrice == nil) == (req.DropPercent == nil) {
+		return newHTTPError(http.StatusBadRequest, ErrAlertCondition)
+	}
+	return nil
+}
+
+func alertRuleToEntity(req *api.AlertRule, ruleId, userId uint) *entity.AlertRule {
+	rule := &entity.AlertRule{
+		UserID:      userId,
+		Product:     req.Product,
+		Store:       req.Store,
+		TargetPrice: req.TargetPrice,
+		DropPercent: req.DropPercent,
+	}
+	rule.ID = ruleId
+	return rule
+}
+
+func alertRuleToResponse(rule *entity.AlertRule) *api.AlertRule {
+	return &api.AlertRule{
+		ID:          &rule.ID,
+		Product:     rule.Product,
+		Store:       rule.Store,
+		TargetPrice: rule.TargetPrice,
+		DropPercent: rule.DropPercent,
+	}
+}
+
+// 通知の一覧
+func (h *Handler) findNotifications(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	status := c.QueryParam("status")
+
+	// 入力チェック
+	var read *bool
+	switch status {
+	case "":
+	case "unread", "read":
+		v := status == "read"
+		read = &v
+	default:
+		return newHTTPError(http.StatusBadRequest, ErrNotificationStatus)
+	}
+
+	// サービスの実行
+	entities, err := h.service.FindNotifications(ctx, userId, read)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成（新しい順）
+	notifications := make([]*api.Notification, len(entities))
+	for i, v := range entities {
+		notifications[i] = h.notificationToResponse(&v)
+	}
+
+	return h.revalidateJSON(c, notifications)
+}
+
+// 通知を既読にする
+func (h *Handler) readNotification(c echo.Context) error {
+	return h.markNotification(c, true)
+}
+
+// 通知を未読に戻す
+func (h *Handler) unreadNotification(c echo.Context) error {
+	return h.markNotification(c, false)
+}
+
+func (h *Handler) markNotification(c echo.Context, read bool) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	notificationId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	notification, err := h.service.MarkNotification(ctx, uint(notificationId), userId, read)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusOK, h.notificationToResponse(notification), h.indent)
+}
+
+func (h *Handler) notificationToResponse(notification *entity.Notification) *api.Notification {
+	return &api.Notification{
+		ID:          notification.ID,
+		AlertRuleID: notification.AlertRuleID,
+		PriceID:     notification.PriceID,
+		CreatedAt:   h.formatDateTime(notification.CreatedAt),
+		DateTime:    h.formatDateTime(notification.DateTime),
+		Store:       notification.Store,
+		Product:     notification.Product,
+		Price:       notification.Price,
+		Average:     notification.Average,
+		Read:        notification.ReadAt != nil,
+	}
+}
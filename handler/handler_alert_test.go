@@ -0,0 +1,408 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// アラートの条件の登録、一覧、更新、削除
+func TestAlertRules(t *testing.T) {
+	testname := "TestAlertRules"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 登録
+	body := `{"Product":"ssd1T", "Store":"pcshop", "TargetPrice":9000}`
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/alerts", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 201, rec.Code)
+	rule := &api.AlertRule{}
+	if err := json.Unmarshal(rec.Body.Bytes(), rule); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotNil(t, rule.ID)
+	assert.Equal(t, "ssd1T", rule.Product)
+	assert.Equal(t, "pcshop", *rule.Store)
+	assert.Equal(t, uint(9000), *rule.TargetPrice)
+	assert.Nil(t, rule.DropPercent)
+	target := fmt.Sprintf("/v1/alerts/%d", *rule.ID)
+
+	// 更新（店舗の指定をなくして値下がり率に変更）
+	body = `{"Product":"ssd1T", "DropPercent":10}`
+	rec, err = execHandler(e, newRequest(http.MethodPut, target, &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	updated := &api.AlertRule{}
+	if err := json.Unmarshal(rec.Body.Bytes(), updated); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, api.AlertRule{ID: rule.ID, Product: "ssd1T", DropPercent: updated.DropPercent}, *updated)
+	assert.Equal(t, uint(10), *updated.DropPercent)
+
+	// 一覧
+	rec, err = execHandler(e, newRequest(http.MethodGet, "/v1/alerts", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := []api.AlertRule{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &rules); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []api.AlertRule{*updated}, rules)
+
+	// 他のユーザからは見えない
+	rec, err = execHandler(e, newRequest(http.MethodGet, "/v1/alerts", nil, "", genToken(conf, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "[]", rec.Body.String())
+
+	// 削除
+	rec, err = execHandler(e, newRequest(http.MethodDelete, target, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 204, rec.Code)
+	code, cause, err := execHandlerValidation(e, newRequest(http.MethodGet, target, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 404, code)
+	assert.Equal(t, handler.ErrNotFound, cause)
+}
+
+// アラートの条件のバリデーション
+func TestCreateAlertRuleValidation(t *testing.T) {
+	testname := "TestCreateAlertRuleValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	cases := []struct {
+		body string
+		code int
+		err  error
+	}{
+		{`{"Product":"ssd1T"}`, 400, handler.ErrAlertCondition},
+		{`{"Product":"ssd1T", "TargetPrice":9000, "DropPercent":10}`, 400, handler.ErrAlertCondition},
+		{`{"Product":"ssd1T", "DropPercent":100}`, 400, nil},
+		{`{"Product":"ssd1T", "DropPercent":0}`, 400, nil},
+		{`{"Product":"ssd1T", "TargetPrice":0}`, 400, nil},
+		{`{"Product":"ssd1T", "Store":"", "TargetPrice":9000}`, 400, nil},
+		{`{"TargetPrice":9000}`, 400, nil},
+		{`{"ID":1, "Product":"ssd1T", "TargetPrice":9000}`, 400, handler.ErrIDCannotRequest},
+		{`{"Product":"ssd1T", "TargetPrice":9000}`, 201, nil},
+	}
+
+	for _, v := range cases {
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, newRequest(http.MethodPost, "/v1/alerts", &v.body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.body)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.body)
+		}
+	}
+}
+
+// 価格の登録と更新でアラートの条件に一致すると通知
+func TestNotifications(t *testing.T) {
+	testname := "TestNotifications"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成（平均価格10000）
+	userId := uint(1)
+	now := time.Now()
+	for _, v := range []uint{9000, 10000, 11000} {
+		if _, err := insertPrice(tx, &now, &now, nil, userId, now, "pcshop", "ssd1T", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, userId)
+
+	// アラートの条件の登録
+	for _, body := range []string{
+		`{"Product":"ssd1T", "TargetPrice":8000}`,
+		`{"Product":"ssd1T", "Store":"pcshop", "DropPercent":15}`,
+		`{"Product":"ssd2T", "TargetPrice":99999}`,
+	} {
+		if _, err := execHandler(e, newRequest(http.MethodPost, "/v1/alerts", &body, echo.MIMEApplicationJSON, jwt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// どの条件にも一致しない価格
+	body := `{"Store":"pcshop", "Product":"ssd1T", "Price":8600}`
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 201, rec.Code)
+	price := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+		t.Fatal(err)
+	}
+
+	// 値下がり率の条件に一致するよう更新（平均価格10000から15%以上の値下がり）
+	body = `{"Store":"pcshop", "Product":"ssd1T", "Price":8500}`
+	if _, err := execHandler(e, newRequest(http.MethodPut, fmt.Sprintf("/v1/prices/%d", *price.ID), &body, echo.MIMEApplicationJSON, jwt)); err != nil {
+		t.Fatal(err)
+	}
+	notifications := waitNotifications(t, e, jwt, 1)
+	assert.Equal(t, *price.ID, notifications[0].PriceID)
+	assert.Equal(t, uint(8500), notifications[0].Price)
+	assert.Equal(t, uint(10000), *notifications[0].Average)
+	assert.False(t, notifications[0].Read)
+
+	// 両方の条件に一致する価格
+	body = `{"Store":"pcshop", "Product":"ssd1T", "Price":7900}`
+	if _, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt)); err != nil {
+		t.Fatal(err)
+	}
+	notifications = waitNotifications(t, e, jwt, 3)
+	assert.Equal(t, uint(7900), notifications[0].Price)
+	assert.Equal(t, uint(7900), notifications[1].Price)
+
+	// 既読にする
+	rec, err = execHandler(e, newRequest(http.MethodPost, fmt.Sprintf("/v1/notifications/%d/read", notifications[2].ID), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	read := &api.Notification{}
+	if err := json.Unmarshal(rec.Body.Bytes(), read); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, read.Read)
+
+	// 未読と既読の一覧
+	for status, count := range map[string]int{"unread": 2, "read": 1} {
+		rec, err = execHandler(e, newRequest(http.MethodGet, "/v1/notifications?status="+status, nil, "", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		list := []api.Notification{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, count, len(list), status)
+	}
+
+	// 未読に戻す
+	rec, err = execHandler(e, newRequest(http.MethodPost, fmt.Sprintf("/v1/notifications/%d/unread", notifications[2].ID), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	if err := json.Unmarshal(rec.Body.Bytes(), read); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, read.Read)
+}
+
+// 通知のバリデーション
+func TestNotificationsValidation(t *testing.T) {
+	testname := "TestNotificationsValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	cases := []struct {
+		method string
+		target string
+		code   int
+		err    error
+	}{
+		{http.MethodGet, "/v1/notifications?status=new", 400, handler.ErrNotificationStatus},
+		{http.MethodGet, "/v1/notifications?status=unread", 200, nil},
+		{http.MethodPost, "/v1/notifications/1/read", 404, handler.ErrNotFound},
+		{http.MethodPost, "/v1/notifications/a/unread", 404, handler.ErrNotFound},
+	}
+
+	for _, v := range cases {
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, newRequest(v.method, v.target, nil, "", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.target)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.target)
+		}
+	}
+}
+
+// 一括処理、インポート、変更の取り消しでのアラートの条件の評価とシャットダウンでの評価待ち
+func TestAlertsOnOtherWrites(t *testing.T) {
+	testname := "TestAlertsOnOtherWrites"
+
+	// セットアップ
+	e, conf, testDB, tx, s, err := setupTestMain(testname, service.NewService)
+	if err != nil {
+		cleanDB(testDB)
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	send := func(method, target, body, contentType string) *httptest.ResponseRecorder {
+		var reqBody *string
+		if body != "" {
+			reqBody = &body
+		}
+		rec, err := execHandler(e, newRequest(method, target, reqBody, contentType, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	// アラートの条件の登録
+	rec := send(http.MethodPost, "/v1/alerts", `{"Product":"ssd1T", "TargetPrice":8000}`, echo.MIMEApplicationJSON)
+	if !assert.Equal(t, 201, rec.Code) {
+		t.FailNow()
+	}
+
+	// 一括処理（条件に一致する登録と一致しない登録）
+	rec = send(http.MethodPost, "/v1/prices:batch", `[
+		{"Op":"create", "Price":{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":7900}},
+		{"Op":"create", "Price":{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9500}}
+	]`, echo.MIMEApplicationJSON)
+	assert.Equal(t, 200, rec.Code)
+	waitNotifications(t, e, jwt, 1)
+
+	// インポート
+	rec = send(http.MethodPost, "/v1/prices/import", "Store,Product,Price\nshop2,ssd1T,7800\n", "text/csv")
+	assert.Equal(t, 201, rec.Code)
+	waitNotifications(t, e, jwt, 2)
+
+	// 条件に一致しない価格に更新して、登録時の値に戻す
+	rec = send(http.MethodPost, "/v1/prices", `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":7700}`, echo.MIMEApplicationJSON)
+	price := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+		t.Fatal(err)
+	}
+	waitNotifications(t, e, jwt, 3)
+	target := fmt.Sprintf("/v1/prices/%d", *price.ID)
+	rec = send(http.MethodPut, target, `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9900}`, echo.MIMEApplicationJSON)
+	assert.Equal(t, 200, rec.Code)
+	rec = send(http.MethodGet, target+"/revisions", "", "")
+	revisions := []api.PriceRevision{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &revisions); err != nil {
+		t.Fatal(err)
+	}
+	var created *api.PriceRevision
+	for i, v := range revisions {
+		if v.Operation == service.RevisionCreate {
+			created = &revisions[i]
+		}
+	}
+	if !assert.NotNil(t, created) {
+		t.FailNow()
+	}
+	rec = send(http.MethodPost, fmt.Sprintf("%s/revisions/%d/revert", target, created.ID), "", "")
+	assert.Equal(t, 200, rec.Code)
+
+	// シャットダウンは評価待ちを終えるまで待つ
+	if err := s.Shutdown(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	rec = send(http.MethodGet, "/v1/notifications", "", "")
+	notifications := []api.Notification{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &notifications); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 4, len(notifications))
+}
+
+// アラートの条件の評価は非同期のため通知の件数がそろうまで待つ
+func waitNotifications(t *testing.T, e *echo.Echo, jwt *string, count int) []api.Notification {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/notifications", nil, "", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		notifications := []api.Notification{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &notifications); err != nil {
+			t.Fatal(err)
+		}
+		if len(notifications) >= count || time.Now().After(deadline) {
+			assert.Equal(t, count, len(notifications))
+			return notifications
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
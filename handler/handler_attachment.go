@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/entity"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+const attachmentFormField = "file"
+
+// 添付できるメディアタイプ（内容から判定）
+var attachmentContentTypes = []string{
+	"image/jpeg",
+	"image/png",
+	"image/gif",
+	"image/webp",
+	"application/pdf",
+}
+
+// 添付ファイルの登録
+func (h *Handler) createAttachment(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	priceId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	fileHeader, err := c.FormFile(attachmentFormField)
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+			return newHTTPError(http.StatusBadRequest, ErrAttachmentRequired)
+		}
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return newHTTPError(http.StatusBadRequest, ErrAttachmentEmpty)
+	}
+
+	// クライアントの申告ではなく内容からメディアタイプを判定
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if !slices.Contains(attachmentContentTypes, contentType) {
+		return newHTTPError(http.StatusUnsupportedMediaType, ErrUnsupportedMediaType)
+	}
+	fileName := filepath.Base(fileHeader.Filename)
+	if fileName == "." || fileName == string(filepath.Separator) || 255 < len(fileName) {
+		return newHTTPError(http.StatusBadRequest, ErrAttachmentFileName)
+	}
+
+	// サービスの実行
+	attachment, err := h.service.CreateAttachment(ctx, uint(priceId), userId, fileName, contentType, data)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		if errors.Is(err, service.ErrTooManyAttachments) {
+			return newHTTPError(http.StatusBadRequest, ErrTooManyAttachments)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusCreated, h.attachmentToResponse(attachment), h.indent)
+}
+
+// 添付ファイルの一覧
+func (h *Handler) findAttachments(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	priceId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	entities, err := h.service.FindAttachments(ctx, uint(priceId), userId)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成（登録順）
+	attachments := make([]*api.Attachment, len(entities))
+	for i, v := range entities {
+		attachments[i] = h.attachmentToResponse(&v)
+	}
+
+	return h.revalidateJSON(c, attachments)
+}
+
+// 添付ファイルのダウンロード
+func (h *Handler) downloadAttachment(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	reqAttachment := c.Param("attachment")
+
+	// 入力チェック
+	priceId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	attachmentId, err := strconv.ParseUint(reqAttachment, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	attachment, body, err := h.service.FindAttachment(ctx, uint(attachmentId), uint(priceId), userId)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+	defer body.Close()
+
+	// レスポンスの生成
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(attachment.Size, 10))
+	return c.Stream(http.StatusOK, attachment.ContentType, body)
+}
+
+// 添付ファイルの削除
+func (h *Handler) deleteAttachment(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	reqAttachment := c.Param("attachment")
+
+	// 入力チェック
+	priceId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	attachmentId, err := strconv.ParseUint(reqAttachment, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	if err = h.service.DeleteAttachment(ctx, uint(attachmentId), uint(priceId), userId); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) attachmentToResponse(attachment *entity.Attachment) *api.Attachment {
+	return &api.Attachment{
+		ID:          attachment.ID,
+		PriceID:     attachment.PriceID,
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		CreatedAt:   h.formatDateTime(attachment.CreatedAt),
+	}
+}
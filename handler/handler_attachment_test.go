@@ -0,0 +1,375 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+	"github.com/ystkg/rest-example/repository"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// 1x1のPNG
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89\x00\x00\x00\rIDATx\x9cc\xf8\x0f\x00\x00\x01\x01\x00\x05\x18\xd8N\x00\x00\x00\x00IEND\xaeB`\x82")
+
+func newMultipartRequest(target, fieldName, fileName string, data []byte, jwt *string) *http.Request {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	if fieldName != "" {
+		part, _ := w.CreateFormFile(fieldName, fileName)
+		part.Write(data)
+	}
+	w.Close()
+	s := body.String()
+	return newRequest(http.MethodPost, target, &s, w.FormDataContentType(), jwt)
+}
+
+// 添付ファイルの登録、一覧、ダウンロード、削除
+func TestAttachments(t *testing.T) {
+	testname := "TestAttachments"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	body := `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9500}`
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	price := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+		t.Fatal(err)
+	}
+	target := fmt.Sprintf("/v1/prices/%d/attachments", *price.ID)
+
+	// 登録（申告したファイル名の拡張子ではなく内容で判定）
+	rec, err = execHandler(e, newMultipartRequest(target, "file", "receipt.txt", testPNG, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 201, rec.Code)
+	attachment := &api.Attachment{}
+	if err := json.Unmarshal(rec.Body.Bytes(), attachment); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, *price.ID, attachment.PriceID)
+	assert.Equal(t, "receipt.txt", attachment.FileName)
+	assert.Equal(t, "image/png", attachment.ContentType)
+	assert.Equal(t, int64(len(testPNG)), attachment.Size)
+	download := fmt.Sprintf("%s/%d", target, attachment.ID)
+
+	// 一覧
+	rec, err = execHandler(e, newRequest(http.MethodGet, target, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachments := []api.Attachment{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &attachments); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []api.Attachment{*attachment}, attachments)
+
+	// ダウンロード
+	rec, err = execHandler(e, newRequest(http.MethodGet, download, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, `attachment; filename=receipt.txt`, rec.Header().Get(echo.HeaderContentDisposition))
+	assert.Equal(t, testPNG, rec.Body.Bytes())
+
+	// 他のユーザからは見えない
+	code, cause, err := execHandlerValidation(e, newRequest(http.MethodGet, download, nil, "", genToken(conf, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 404, code)
+	assert.Equal(t, handler.ErrNotFound, cause)
+
+	// 削除
+	rec, err = execHandler(e, newRequest(http.MethodDelete, download, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 204, rec.Code)
+	assert.Equal(t, 0, countBlobs(t, testDB.blobDir))
+	code, _, err = execHandlerValidation(e, newRequest(http.MethodGet, download, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 404, code)
+
+	// 価格を完全削除すると本体も削除
+	if _, err = execHandler(e, newMultipartRequest(target, "file", "label.png", testPNG, jwt)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, countBlobs(t, testDB.blobDir))
+	priceTarget := fmt.Sprintf("/v1/prices/%d", *price.ID)
+	if _, err = execHandler(e, newRequest(http.MethodDelete, priceTarget, nil, "", jwt)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, countBlobs(t, testDB.blobDir)) // ゴミ箱から復元できるよう残す
+	rec, err = execHandler(e, newRequest(http.MethodDelete, "/v1/trash"+priceTarget, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 204, rec.Code)
+	assert.Equal(t, 0, countBlobs(t, testDB.blobDir))
+}
+
+// 保持期間を過ぎて完全削除した価格の添付ファイルの本体の削除
+func TestPurgeDeletedPriceAttachments(t *testing.T) {
+	testname := "TestPurgeDeletedPriceAttachments"
+
+	// セットアップ
+	e, conf, testDB, tx, s, err := setupTestMain(testname, service.NewService)
+	if err != nil {
+		cleanDB(testDB)
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 添付ファイルがある価格の登録と削除
+	var targets []string
+	for range 2 {
+		body := `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9500}`
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		price := &api.Price{}
+		if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+			t.Fatal(err)
+		}
+		target := fmt.Sprintf("/v1/prices/%d", *price.ID)
+		rec, err = execHandler(e, newMultipartRequest(target+"/attachments", "file", "receipt.png", testPNG, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 201, rec.Code)
+		targets = append(targets, target)
+	}
+	rec, err := execHandler(e, newRequest(http.MethodDelete, targets[0], nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 204, rec.Code)
+	assert.Equal(t, 2, countBlobs(t, testDB.blobDir)) // ゴミ箱から復元できるよう残す
+
+	// テストの実行
+	rows, err := s.PurgeDeletedPrices(t.Context(), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション（削除していない価格の添付ファイルは残す）
+	assert.Equal(t, int64(1), rows)
+	assert.Equal(t, 1, countBlobs(t, testDB.blobDir))
+	rec, err = execHandler(e, newRequest(http.MethodGet, targets[1]+"/attachments", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachments := []api.Attachment{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &attachments); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, attachments, 1)
+}
+
+func TestCreateAttachmentValidation(t *testing.T) {
+	testname := "TestCreateAttachmentValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	body := `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9500}`
+	if _, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt)); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		target    string
+		fieldName string
+		data      []byte
+		code      int
+		err       error
+	}{
+		{"/v1/prices/1/attachments", "", nil, 400, handler.ErrAttachmentRequired},
+		{"/v1/prices/1/attachments", "photo", testPNG, 400, handler.ErrAttachmentRequired},
+		{"/v1/prices/1/attachments", "file", []byte{}, 400, handler.ErrAttachmentEmpty},
+		{"/v1/prices/1/attachments", "file", []byte("<html><script>alert(1)</script></html>"), 415, handler.ErrUnsupportedMediaType},
+		{"/v1/prices/1/attachments", "file", []byte("plain text"), 415, handler.ErrUnsupportedMediaType},
+		{"/v1/prices/2/attachments", "file", testPNG, 404, handler.ErrNotFound},
+		{"/v1/prices/1/attachments", "file", []byte("%PDF-1.4\n"), 201, nil},
+	}
+
+	for _, v := range cases {
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, newMultipartRequest(v.target, v.fieldName, "receipt", v.data, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, string(v.data))
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, string(v.data))
+		}
+	}
+
+	// 件数の上限
+	for range 9 {
+		if _, err := execHandler(e, newMultipartRequest("/v1/prices/1/attachments", "file", "receipt", testPNG, jwt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	code, cause, err := execHandlerValidation(e, newMultipartRequest("/v1/prices/1/attachments", "file", "receipt", testPNG, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 400, code)
+	assert.Equal(t, handler.ErrTooManyAttachments, cause)
+}
+
+// S3互換のオブジェクトストレージの代替サーバでの読み書き
+func TestS3BlobStore(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 署名の形式とペイロードのハッシュの検証
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=testkey/") || !strings.Contains(auth, "/ap-northeast-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path] = body
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	store, err := repository.NewS3BlobStore(&repository.S3Config{
+		Endpoint:  server.URL,
+		Region:    "ap-northeast-1",
+		Bucket:    "receipts",
+		AccessKey: "testkey",
+		SecretKey: "testsecret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	key := "attachments/1/2/abc"
+
+	// 保存
+	if err := store.Put(ctx, key, testPNG, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testPNG, objects["/receipts/"+key])
+
+	// 取得
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testPNG, data)
+
+	// 削除
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Get(ctx, key)
+	assert.ErrorIs(t, err, repository.ErrBlobNotFound)
+	assert.NoError(t, store.Delete(ctx, key)) // 存在しなくてもエラーにしない
+
+	// 不正なキー
+	assert.ErrorIs(t, store.Put(ctx, "../secret", testPNG, "image/png"), repository.ErrBlobKey)
+}
+
+func countBlobs(t *testing.T, dir string) int {
+	count := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/entity"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+const maxCompareAgeDays = 3650
+
+// 商品の店舗ごとの最新の価格の比較
+func (h *Handler) compareProductPrices(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	product, err := h.productParam(c)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	since, excludeSuspicious, err := h.compareOptions(c, now)
+	if err != nil {
+		return err
+	}
+
+	// サービスの実行
+	entities, err := h.service.CompareProductPrices(ctx, userId, product, since, now, excludeSuspicious)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成
+	prices, err := h.comparedPricesResponse(ctx, entities, now)
+	if err != nil {
+		return err
+	}
+
+	return h.revalidateJSON(c, &api.ProductComparison{Product: product, Prices: prices})
+}
+
+// 買い物かごの最安の店舗の比較
+func (h *Handler) compareBasket(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	req := &api.Basket{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック（正規化した名前で検証）
+	for i := range req.Items {
+		if err := h.normalizeNames(&req.Items[i].Product); err != nil {
+			return newHTTPError(http.StatusBadRequest, err)
+		}
+	}
+	if err := c.Validate(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	now := time.Now()
+	since, excludeSuspicious, err := h.compareOptions(c, now)
+	if err != nil {
+		return err
+	}
+	items := make([]service.BasketItem, len(req.Items))
+	for i, v := range req.Items {
+		count := v.Count
+		if count == 0 && v.Unit == "" {
+			count = 1
+		}
+		items[i] = service.BasketItem{Product: v.Product, Count: count, Quantity: v.Quantity, Unit: v.Unit}
+	}
+
+	// サービスの実行
+	comparison, err := h.service.CompareBasket(ctx, userId, items, since, now, excludeSuspicious)
+	if err != nil {
+		if errors.Is(err, service.ErrUnitMismatch) {
+			return newHTTPError(http.StatusBadRequest, ErrBasketUnitMismatch)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	res := &api.BasketComparison{Missing: comparison.Missing}
+	if res.SingleStore, err = h.basketPricesResponse(ctx, comparison.SingleStore, now); err != nil {
+		return err
+	}
+	if res.Split, err = h.basketPricesResponse(ctx, comparison.Split, now); err != nil {
+		return err
+	}
+
+	return c.JSONPretty(http.StatusOK, res, h.indent)
+}
+
+// パスパラメータの商品名（エスケープが必要な文字を含む場合はパスパラメータがエスケープされたまま）
+//
+// 登録した価格と同じく正規化する
+func (h *Handler) productParam(c echo.Context) (string, error) {
+	product := c.Param("product")
+	if c.Request().URL.RawPath != "" {
+		unescaped, err := url.PathUnescape(product)
+		if err != nil {
+			return "", newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		product = unescaped
+	}
+	if err := h.normalizeNames(&product); err != nil {
+		return "", newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	return product, nil
+}
+
+// 比較の対象にする価格の日時の下限（maxAgeは日数）と外れ値の疑いがある価格を除くか
+func (h *Handler) compareOptions(c echo.Context, now time.Time) (time.Time, bool, error) {
+	maxAge := h.compareMaxAgeDays
+	var excludeSuspicious bool
+	if err := echo.QueryParamsBinder(c).Int("maxAge", &maxAge).Bool("excludeSuspicious", &excludeSuspicious).BindError(); err != nil {
+		return time.Time{}, false, newHTTPError(http.StatusBadRequest, err)
+	}
+	if maxAge < 1 || maxCompareAgeDays < maxAge {
+		return time.Time{}, false, newHTTPError(http.StatusBadRequest, ErrMaxAge)
+	}
+	return now.AddDate(0, 0, -maxAge), excludeSuspicious, nil
+}
+
+func (h *Handler) isStale(price *entity.Price, now time.Time) bool {
+	return price.DateTime.Before(now.AddDate(0, 0, -h.staleDays))
+}
+
+func (h *Handler) comparedPricesResponse(ctx context.Context, entities []entity.Price, now time.Time) ([]*api.ComparedPrice, error) {
+	prices, err := h.pricesResponse(ctx, entities)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*api.ComparedPrice, len(prices))
+	for i, v := range prices {
+		res[i] = &api.ComparedPrice{Price: *v, Stale: h.isStale(&entities[i], now)}
+	}
+	return res, nil
+}
+
+func (h *Handler) basketPricesResponse(ctx context.Context, basket *service.BasketPrices, now time.Time) (*api.BasketPrices, error) {
+	if basket == nil {
+		return nil, nil
+	}
+	prices, err := h.comparedPricesResponse(ctx, basket.Prices, now)
+	if err != nil {
+		return nil, err
+	}
+	res := &api.BasketPrices{Store: basket.Store, Total: basket.Total, Prices: prices}
+	for _, v := range prices {
+		res.Stale = res.Stale || v.Stale
+	}
+	return res, nil
+}
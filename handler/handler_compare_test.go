@@ -0,0 +1,475 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// 商品の最安の店舗の比較
+func TestCompareProduct(t *testing.T) {
+	testname := "TestCompareProduct"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	ids := createComparePrices(t, e, conf)
+
+	// 商品の比較
+	products := []struct {
+		target   string
+		product  string
+		expected []uint
+		stale    []bool
+	}{
+		{"/v1/products/milk/compare", "milk", []uint{ids[2], ids[1]}, []bool{true, false}},
+		{"/v1/products/milk/compare?maxAge=120", "milk", []uint{ids[2], ids[3], ids[1]}, []bool{true, true, false}},
+		{"/v1/products/milk/compare?maxAge=30", "milk", []uint{ids[1]}, []bool{false}},
+		{"/v1/products/milk%2Flow-fat/compare", "milk/low-fat", []uint{ids[7]}, []bool{false}},
+		{"/v1/products/tofu/compare", "tofu", []uint{}, []bool{}},
+	}
+	for _, v := range products {
+		rec, err := execHandler(e, newRequest(http.MethodGet, v.target, nil, "", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 200, rec.Code, v.target)
+		comparison := &api.ProductComparison{}
+		if err := json.Unmarshal(rec.Body.Bytes(), comparison); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, v.product, comparison.Product, v.target)
+		actual := make([]uint, len(comparison.Prices))
+		stale := make([]bool, len(comparison.Prices))
+		for i, p := range comparison.Prices {
+			actual[i] = *p.ID
+			stale[i] = p.Stale
+		}
+		assert.Equal(t, v.expected, actual, v.target)
+		assert.Equal(t, v.stale, stale, v.target)
+	}
+}
+
+// 有効期間外の価格は比較の対象外
+func TestCompareProductValidity(t *testing.T) {
+	testname := "TestCompareProductValidity"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	now := time.Now().In(conf.Location)
+	days := func(n int) string {
+		return now.AddDate(0, 0, n).Format(time.DateTime)
+	}
+
+	// 価格の登録
+	var ids []uint
+	for _, body := range []string{
+		fmt.Sprintf(`{"DateTime":"%s", "Store":"super", "Product":"milk", "Price":200}`, days(-10)),
+		fmt.Sprintf(`{"DateTime":"%s", "Store":"super", "Product":"milk", "Price":150, "PriceType":"sale", "ValidTo":"%s"}`, days(-2), days(-1)), // 期限切れ
+		fmt.Sprintf(`{"DateTime":"%s", "Store":"mart", "Product":"milk", "Price":120, "PriceType":"sale", "ValidFrom":"%s"}`, days(-1), days(1)), // 開始前
+		fmt.Sprintf(`{"DateTime":"%s", "Store":"shop", "Product":"milk", "Price":180, "ValidFrom":"%s", "ValidTo":"%s"}`, days(-3), days(-3), days(1)),
+	} {
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assert.Equal(t, 201, rec.Code, body) {
+			t.FailNow()
+		}
+		price := &api.Price{}
+		if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, *price.ID)
+	}
+
+	// テストの実行
+	rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/products/milk/compare", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション（期限切れの新しい価格より前の価格が有効）
+	assert.Equal(t, 200, rec.Code)
+	comparison := &api.ProductComparison{}
+	if err := json.Unmarshal(rec.Body.Bytes(), comparison); err != nil {
+		t.Fatal(err)
+	}
+	actual := make([]uint, len(comparison.Prices))
+	for i, p := range comparison.Prices {
+		actual[i] = *p.ID
+	}
+	assert.Equal(t, []uint{ids[3], ids[0]}, actual)
+}
+
+// 商品の比較のバリデーション
+func TestCompareProductValidation(t *testing.T) {
+	testname := "TestCompareProductValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	cases := []struct {
+		target string
+		body   string
+		code   int
+		err    error
+	}{
+		{"/v1/products/milk/compare?maxAge=0", "", 400, handler.ErrMaxAge},
+		{"/v1/products/milk/compare?maxAge=3651", "", 400, handler.ErrMaxAge},
+		{"/v1/products/milk/compare?maxAge=a", "", 400, nil},
+		{"/v1/products/milk/compare", "", 200, nil},
+	}
+
+	for _, v := range cases {
+		// リクエストの生成
+		var body *string
+		contentType := ""
+		if v.body != "" {
+			body = &v.body
+			contentType = echo.MIMEApplicationJSON
+		}
+		req := newRequest(http.MethodGet, v.target, body, contentType, jwt)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.target)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.target)
+		}
+	}
+}
+
+// 買い物かごの最安の店舗の比較
+func TestCompareBasket(t *testing.T) {
+	testname := "TestCompareBasket"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	ids := createComparePrices(t, e, conf)
+
+	// 買い物かごの比較
+	body := `{"Items":[{"Product":"milk", "Count":2}, {"Product":"egg"}, {"Product":"bread"}, {"Product":"tofu"}]}`
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/compare", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	basket := &api.BasketComparison{}
+	if err := json.Unmarshal(rec.Body.Bytes(), basket); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"tofu"}, basket.Missing)
+	if assert.NotNil(t, basket.SingleStore) {
+		assert.Equal(t, "super", basket.SingleStore.Store)
+		assert.Equal(t, uint(198*2+260+180), basket.SingleStore.Total)
+		assert.True(t, basket.SingleStore.Stale)
+		assert.Equal(t, []uint{ids[2], ids[5], ids[6]}, comparedPriceIds(basket.SingleStore.Prices))
+	}
+	if assert.NotNil(t, basket.Split) {
+		assert.Empty(t, basket.Split.Store)
+		assert.Equal(t, uint(198*2+250+180), basket.Split.Total)
+		assert.True(t, basket.Split.Stale)
+		assert.Equal(t, []uint{ids[2], ids[4], ids[6]}, comparedPriceIds(basket.Split.Prices))
+	}
+
+	// 古い価格を除くと同じ店舗で揃う
+	body = `{"Items":[{"Product":"egg"}, {"Product":"milk"}]}`
+	rec, err = execHandler(e, newRequest(http.MethodPost, "/v1/compare?maxAge=30", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	basket = &api.BasketComparison{}
+	if err := json.Unmarshal(rec.Body.Bytes(), basket); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, basket.Missing)
+	if assert.NotNil(t, basket.SingleStore) && assert.NotNil(t, basket.Split) {
+		assert.Equal(t, "market", basket.SingleStore.Store)
+		assert.Equal(t, uint(250+238), basket.SingleStore.Total)
+		assert.False(t, basket.SingleStore.Stale)
+		assert.Equal(t, basket.SingleStore.Total, basket.Split.Total)
+		assert.Equal(t, []uint{ids[4], ids[1]}, comparedPriceIds(basket.Split.Prices))
+	}
+
+	// どの店舗にもなければ空
+	body = `{"Items":[{"Product":"tofu"}]}`
+	rec, err = execHandler(e, newRequest(http.MethodPost, "/v1/compare", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	basket = &api.BasketComparison{}
+	if err := json.Unmarshal(rec.Body.Bytes(), basket); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &api.BasketComparison{Missing: []string{"tofu"}}, basket)
+}
+
+// 買い物かごの比較のバリデーション
+// 内容量がある価格は単価で比較
+func TestCompareUnitPrice(t *testing.T) {
+	testname := "TestCompareUnitPrice"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	dateTime := time.Now().In(conf.Location).Format(time.DateTime)
+
+	// 価格の登録（1Lあたり300円、360円と内容量なし）
+	var ids []uint
+	for _, body := range []string{
+		fmt.Sprintf(`{"DateTime":"%s", "Store":"super", "Product":"juice", "Price":300, "Quantity":1, "Unit":"L"}`, dateTime),
+		fmt.Sprintf(`{"DateTime":"%s", "Store":"mart", "Product":"juice", "Price":180, "Quantity":500, "Unit":"ml"}`, dateTime),
+		fmt.Sprintf(`{"DateTime":"%s", "Store":"shop", "Product":"juice", "Price":150}`, dateTime),
+	} {
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assert.Equal(t, 201, rec.Code, body) {
+			t.FailNow()
+		}
+		price := &api.Price{}
+		if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, *price.ID)
+	}
+
+	// 商品の比較（単価の昇順、内容量がない価格は最後）
+	rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/products/juice/compare", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	comparison := &api.ProductComparison{}
+	if err := json.Unmarshal(rec.Body.Bytes(), comparison); err != nil {
+		t.Fatal(err)
+	}
+	actual := make([]uint, len(comparison.Prices))
+	for i, p := range comparison.Prices {
+		actual[i] = *p.ID
+	}
+	assert.Equal(t, []uint{ids[0], ids[1], ids[2]}, actual)
+
+	// 買い物かご（量の指定は単価で比較し、個数の指定は価格で比較）
+	baskets := []struct {
+		body     string
+		store    string
+		total    uint
+		expected uint
+	}{
+		{`{"Items":[{"Product":"juice", "Quantity":1.5, "Unit":"L"}]}`, "super", 450, ids[0]},
+		{`{"Items":[{"Product":"juice", "Quantity":500, "Unit":"ml"}, {"Product":"juice", "Quantity":1, "Unit":"L"}]}`, "super", 450, ids[0]},
+		{`{"Items":[{"Product":"juice", "Count":2}]}`, "shop", 300, ids[2]},
+	}
+	for _, v := range baskets {
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/compare", &v.body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 200, rec.Code, v.body)
+		res := &api.BasketComparison{}
+		if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+			t.Fatal(err)
+		}
+		if !assert.NotNil(t, res.SingleStore, v.body) {
+			continue
+		}
+		assert.Equal(t, v.store, res.SingleStore.Store, v.body)
+		assert.Equal(t, v.total, res.SingleStore.Total, v.body)
+		assert.Equal(t, v.total, res.Split.Total, v.body)
+		assert.Equal(t, v.expected, *res.Split.Prices[0].ID, v.body)
+	}
+
+	// 系統が異なる量の指定では比較できる価格がない
+	body := `{"Items":[{"Product":"juice", "Quantity":100, "Unit":"g"}]}`
+	rec, err = execHandler(e, newRequest(http.MethodPost, "/v1/compare", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	assert.JSONEq(t, `{"Missing":["juice"]}`, rec.Body.String())
+}
+
+func TestCompareBasketValidation(t *testing.T) {
+	testname := "TestCompareBasketValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	cases := []struct {
+		target string
+		body   string
+		code   int
+		err    error
+	}{
+		{"/v1/compare?maxAge=0", `{"Items":[{"Product":"milk"}]}`, 400, handler.ErrMaxAge},
+		{"/v1/compare", `{"Items":[]}`, 400, nil},
+		{"/v1/compare", `{}`, 400, nil},
+		{"/v1/compare", `{"Items":[{"Product":""}]}`, 400, nil},
+		{"/v1/compare", `{"Items":[{"Product":"milk", "Count":1001}]}`, 400, nil},
+		{"/v1/compare", `{"Items":[{"Product":"milk", "Count":1000}]}`, 200, nil},
+		{"/v1/compare", `{"Items":[{"Product":"milk", "Quantity":1}]}`, 400, nil},
+		{"/v1/compare", `{"Items":[{"Product":"milk", "Unit":"L"}]}`, 400, nil},
+		{"/v1/compare", `{"Items":[{"Product":"milk", "Quantity":1, "Unit":"pack"}]}`, 400, nil},
+		{"/v1/compare", `{"Items":[{"Product":"milk", "Count":2, "Quantity":1, "Unit":"L"}]}`, 400, nil},
+		{"/v1/compare", `{"Items":[{"Product":"milk", "Quantity":1, "Unit":"L"}, {"Product":"milk"}]}`, 400, handler.ErrBasketUnitMismatch},
+		{"/v1/compare", `{"Items":[{"Product":"milk", "Quantity":1, "Unit":"L"}, {"Product":"milk", "Quantity":500, "Unit":"g"}]}`, 400, handler.ErrBasketUnitMismatch},
+		{"/v1/compare", `{"Items":[{"Product":"milk", "Quantity":1, "Unit":"L"}, {"Product":"milk", "Quantity":500, "Unit":"ml"}]}`, 200, nil},
+	}
+
+	for _, v := range cases {
+		// リクエストの生成
+		var body *string
+		contentType := ""
+		if v.body != "" {
+			body = &v.body
+			contentType = echo.MIMEApplicationJSON
+		}
+		req := newRequest(http.MethodPost, v.target, body, contentType, jwt)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.target)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.target)
+		}
+	}
+}
+
+// 比較に使う価格の登録（登録した価格のIDを返す）
+func createComparePrices(t *testing.T, e *echo.Echo, conf *handler.HandlerConfig) []uint {
+	jwt := genToken(conf, 1)
+
+	// 何日前の価格か
+	daysAgo := func(days int) string {
+		return time.Now().AddDate(0, 0, -days).In(conf.Location).Format(conf.DateTimeLayout)
+	}
+	prices := []struct {
+		days    int
+		store   string
+		product string
+		price   uint
+	}{
+		{10, "market", "milk", 250}, // 同じ店舗の新しい価格があるので対象外
+		{2, "market", "milk", 238},
+		{40, "super", "milk", 198},   // 古い価格
+		{100, "corner", "milk", 210}, // 比較の対象外
+		{1, "market", "egg", 250},
+		{3, "super", "egg", 260},
+		{1, "super", "bread", 180},
+		{1, "market", "milk/low-fat", 228},
+		{1, "market", "milk", 238}, // 他のユーザー
+	}
+	ids := make([]uint, len(prices))
+	for i, v := range prices {
+		body := fmt.Sprintf(`{"DateTime":"%s", "Store":"%s", "Product":"%s", "Price":%d}`, daysAgo(v.days), v.store, v.product, v.price)
+		token := jwt
+		if i == len(prices)-1 {
+			token = genToken(conf, 2)
+		}
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, token))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 201, rec.Code, body)
+		price := &api.Price{}
+		if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = *price.ID
+	}
+
+	return ids
+}
+
+func comparedPriceIds(prices []*api.ComparedPrice) []uint {
+	ids := make([]uint, len(prices))
+	for i, v := range prices {
+		ids[i] = *v.ID
+	}
+	return ids
+}
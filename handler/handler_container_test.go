@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"net"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -24,7 +25,7 @@ import (
 
 func setupContainerTest(ctx context.Context, testname, driverName string, sqlDB *sql.DB) (*echo.Echo, error) {
 	// Repository
-	r, err := repository.NewRepository(driverName, sqlDB)
+	r, err := repository.NewRepository(driverName, sqlDB, repository.NewLocalBlobStore(os.TempDir()))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	mimeTextEventStream = "text/event-stream"
+	headerLastEventID   = "Last-Event-ID"
+
+	eventRetryMillis = 3000 // 切断時にクライアントが再接続するまでの間隔
+)
+
+// 価格の変更イベントのストリーム（Server-Sent Events）
+func (h *Handler) streamEvents(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	var lastEventId *uint64
+	if v := c.Request().Header.Get(headerLastEventID); v != "" {
+		if id, err := strconv.ParseUint(v, 10, 64); err == nil {
+			lastEventId = &id
+		}
+	}
+
+	// サービスの実行
+	subscription := h.service.SubscribePriceEvents(ctx, userId, lastEventId)
+	defer subscription.Close()
+
+	// レスポンスの生成
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, mimeTextEventStream)
+	res.Header().Set(echo.HeaderCacheControl, "no-store")
+	res.Header().Set("X-Accel-Buffering", "no") // リバースプロキシでバッファリングしない
+	res.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(res, "retry: %d\n\n", eventRetryMillis); err != nil {
+		return nil
+	}
+	if subscription.Reset {
+		// 取りこぼしがあるため一覧の再取得を促す
+		if _, err := fmt.Fprint(res, "event: reset\ndata: {}\n\n"); err != nil {
+			return nil
+		}
+	}
+	for _, event := range subscription.Backlog {
+		if err := h.writeEvent(res, &event); err != nil {
+			return nil
+		}
+	}
+	res.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-h.shutdown.Done():
+			return nil
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return nil // 購読が遅れて切断された
+			}
+			if err := h.writeEvent(res, &event); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+	}
+}
+
+func (h *Handler) writeEvent(res *echo.Response, event *service.PriceEvent) error {
+	data, err := json.Marshal(h.entityToResponse(&event.Price))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,116 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var eventIdPattern = regexp.MustCompile(`id: (\d+)\nevent: (price\.\w+)\n`)
+
+// 一定時間だけストリームを受信
+func streamEvents(e *echo.Echo, jwt *string, lastEventId string, d time.Duration) (*httptest.ResponseRecorder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	req := newRequest(http.MethodGet, "/v1/events", nil, "", jwt).WithContext(ctx)
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+	return execHandler(e, req)
+}
+
+// 価格の変更イベントの受信と再開
+func TestStreamEvents(t *testing.T) {
+	testname := "TestStreamEvents"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 受信中に登録
+	type result struct {
+		rec *httptest.ResponseRecorder
+		err error
+	}
+	done := make(chan result)
+	go func() {
+		rec, err := streamEvents(e, jwt, "", time.Second)
+		done <- result{rec, err}
+	}()
+	time.Sleep(200 * time.Millisecond) // 購読の開始を待つ
+
+	body := `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9500}`
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 201, rec.Code)
+	location := rec.Header().Get(echo.HeaderLocation)
+
+	// 他のユーザの登録は配信されない
+	body = `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"hdd2T", "Price":7000}`
+	if _, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, genToken(conf, 2))); err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-done
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	assert.Equal(t, 200, r.rec.Code)
+	assert.Equal(t, "text/event-stream", r.rec.Header().Get(echo.HeaderContentType))
+	stream := r.rec.Body.String()
+	assert.True(t, strings.HasPrefix(stream, "retry: "))
+	matches := eventIdPattern.FindAllStringSubmatch(stream, -1)
+	assert.Len(t, matches, 1)
+	assert.Equal(t, "price.created", matches[0][2])
+	assert.Contains(t, stream, `"Product":"ssd1T"`)
+	assert.NotContains(t, stream, `"Product":"hdd2T"`)
+	lastEventId := matches[0][1]
+
+	// 切断中に更新と削除
+	body = `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9000}`
+	if _, err := execHandler(e, newRequest(http.MethodPut, location, &body, echo.MIMEApplicationJSON, jwt)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execHandler(e, newRequest(http.MethodDelete, location, nil, "", jwt)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 最後に受信したIDから再開
+	rec, err = streamEvents(e, jwt, lastEventId, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream = rec.Body.String()
+	assert.NotContains(t, stream, "event: reset")
+	matches = eventIdPattern.FindAllStringSubmatch(stream, -1)
+	assert.Len(t, matches, 2)
+	assert.Equal(t, "price.updated", matches[0][2])
+	assert.Equal(t, "price.deleted", matches[1][2])
+	assert.Contains(t, stream, `"Price":9000`)
+
+	// バッファにない古いIDからの再開は取りこぼしを通知
+	rec, err = streamEvents(e, jwt, "1", 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, rec.Body.String(), "event: reset\n")
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	forecastDays       = 7   // 予測する日数のデフォルト
+	maxForecastDays    = 90  // 予測する日数の上限
+	forecastMaxAgeDays = 730 // 予測に使う価格の期間のデフォルト
+)
+
+// 商品の価格の予測と買い時
+func (h *Handler) forecastProductPrice(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	product, err := h.productParam(c)
+	if err != nil {
+		return err
+	}
+	days := forecastDays
+	maxAge := forecastMaxAgeDays
+	if err := echo.QueryParamsBinder(c).Int("days", &days).Int("maxAge", &maxAge).BindError(); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック
+	if days < 1 || maxForecastDays < days {
+		return newHTTPError(http.StatusBadRequest, ErrForecastDays)
+	}
+	if maxAge < 1 || maxCompareAgeDays < maxAge {
+		return newHTTPError(http.StatusBadRequest, ErrMaxAge)
+	}
+
+	// サービスの実行
+	now := time.Now()
+	forecast, err := h.service.ForecastProductPrice(ctx, userId, product, now.AddDate(0, 0, -maxAge), now, days, h.location)
+	if err != nil {
+		if errors.Is(err, service.ErrInsufficientHistory) {
+			return newHTTPError(http.StatusUnprocessableEntity, ErrInsufficientHistory)
+		}
+		return err
+	}
+	if forecast == nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// レスポンスの生成
+	res := &api.PriceForecast{
+		Product:        product,
+		Quantity:       forecast.Quantity,
+		Unit:           forecast.Unit,
+		Samples:        forecast.Samples,
+		Level:          forecast.Level,
+		Forecasts:      make([]api.ForecastPoint, len(forecast.Points)),
+		Recommendation: forecast.Recommendation,
+		BestDate:       forecast.BestDate.Format(time.DateOnly),
+		Reasons:        forecast.Reasons,
+	}
+	for _, v := range forecast.Weekdays {
+		res.Weekdays = append(res.Weekdays, seasonalFactorResponse(time.Weekday(v.Period).String(), &v))
+	}
+	for _, v := range forecast.Months {
+		res.Months = append(res.Months, seasonalFactorResponse(time.Month(v.Period).String(), &v))
+	}
+	for i, v := range forecast.Points {
+		res.Forecasts[i] = api.ForecastPoint{
+			Date:     v.Date.Format(time.DateOnly),
+			Expected: v.Expected,
+			Min:      v.Min,
+			Max:      v.Max,
+		}
+	}
+
+	return h.revalidateJSON(c, res)
+}
+
+func seasonalFactorResponse(period string, factor *service.SeasonalFactor) api.SeasonalFactor {
+	return api.SeasonalFactor{
+		Period:  period,
+		Factor:  math.Round(factor.Factor*1000) / 1000,
+		Samples: factor.Samples,
+	}
+}
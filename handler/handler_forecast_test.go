@@ -0,0 +1,213 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// 価格の予測と買い時
+func TestForecastProductPrice(t *testing.T) {
+	testname := "TestForecastProductPrice"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 価格の登録（土曜日が安い）
+	now := time.Now().In(conf.Location)
+	post := func(dateTime time.Time, product string, price uint) {
+		body := fmt.Sprintf(`{"DateTime":"%s", "Store":"super", "Product":"%s", "Price":%d}`, dateTime.Format(conf.DateTimeLayout), product, price)
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 201, rec.Code, body)
+	}
+	for days := 28; 0 < days; days-- {
+		dateTime := now.AddDate(0, 0, -days)
+		price := uint(200)
+		if dateTime.Weekday() == time.Saturday {
+			price = 180
+		}
+		post(dateTime, "egg roll", price)
+	}
+
+	// 予測
+	target := "/v1/products/" + url.PathEscape("egg roll") + "/forecast?days=10"
+	rec, err := execHandler(e, newRequest(http.MethodGet, target, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 200, rec.Code) {
+		t.FailNow()
+	}
+	forecast := &api.PriceForecast{}
+	if err := json.Unmarshal(rec.Body.Bytes(), forecast); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "egg roll", forecast.Product)
+	assert.Equal(t, 28, forecast.Samples)
+	assert.Len(t, forecast.Weekdays, 7)
+	if assert.Len(t, forecast.Forecasts, 10) {
+		assert.Equal(t, now.Format(time.DateOnly), forecast.Forecasts[0].Date)
+	}
+	for _, v := range forecast.Forecasts {
+		assert.LessOrEqual(t, v.Min, v.Expected, v.Date)
+		assert.LessOrEqual(t, v.Expected, v.Max, v.Date)
+	}
+	for _, v := range forecast.Weekdays {
+		if v.Period == time.Saturday.String() {
+			assert.Less(t, v.Factor, 0.95)
+		} else {
+			assert.Greater(t, v.Factor, 1.0)
+		}
+	}
+	bestDate, err := time.ParseInLocation(time.DateOnly, forecast.BestDate, conf.Location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, time.Saturday, bestDate.Weekday())
+	if now.Weekday() == time.Saturday {
+		assert.Equal(t, "buy", forecast.Recommendation)
+	} else {
+		assert.Equal(t, "wait", forecast.Recommendation)
+	}
+	assert.Contains(t, forecast.Reasons, "prices tend to be lowest on Saturday (9% below average)")
+}
+
+// 内容量がある価格は最新の価格の内容量に換算して予測
+func TestForecastUnitPrice(t *testing.T) {
+	testname := "TestForecastUnitPrice"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 価格の登録（単価は同じで内容量が異なる価格と内容量がない価格）
+	now := time.Now().In(conf.Location)
+	post := func(days int, price uint, quantity string) {
+		body := fmt.Sprintf(`{"DateTime":"%s", "Store":"super", "Product":"rice", "Price":%d%s}`, now.AddDate(0, 0, -days).Format(conf.DateTimeLayout), price, quantity)
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 201, rec.Code, body)
+	}
+	post(11, 300, "")
+	for days := 10; 0 < days; days-- {
+		if days%2 == 0 {
+			post(days, 2000, `, "Quantity":5, "Unit":"kg"`)
+		} else {
+			post(days, 800, `, "Quantity":2000, "Unit":"g"`)
+		}
+	}
+
+	// 予測
+	rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/products/rice/forecast", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 200, rec.Code) {
+		t.FailNow()
+	}
+	forecast := &api.PriceForecast{}
+	if err := json.Unmarshal(rec.Body.Bytes(), forecast); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2000.0, forecast.Quantity)
+	assert.Equal(t, "g", forecast.Unit)
+	assert.Equal(t, 10, forecast.Samples)
+	assert.Equal(t, uint(800), forecast.Level)
+	for _, v := range forecast.Forecasts {
+		assert.Equal(t, uint(800), v.Expected, v.Date)
+	}
+}
+
+// 価格の予測と買い時の入力チェック
+func TestForecastProductPriceValidation(t *testing.T) {
+	testname := "TestForecastProductPriceValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 価格の登録（予測に必要な件数に満たない）
+	now := time.Now().In(conf.Location)
+	for days := 3; 0 < days; days-- {
+		body := fmt.Sprintf(`{"DateTime":"%s", "Store":"super", "Product":"milk", "Price":198}`, now.AddDate(0, 0, -days).Format(conf.DateTimeLayout))
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 201, rec.Code, body)
+	}
+
+	cases := []struct {
+		target string
+		code   int
+		err    error
+	}{
+		{"/v1/products/milk/forecast", 422, handler.ErrInsufficientHistory},
+		{"/v1/products/bread/forecast", 404, handler.ErrNotFound},
+		{"/v1/products/milk/forecast?days=0", 400, handler.ErrForecastDays},
+		{"/v1/products/milk/forecast?days=91", 400, handler.ErrForecastDays},
+		{"/v1/products/milk/forecast?maxAge=0", 400, handler.ErrMaxAge},
+		{"/v1/products/milk/forecast?days=a", 400, nil},
+	}
+	for _, v := range cases {
+		// リクエストの生成
+		req := newRequest(http.MethodGet, v.target, nil, "", jwt)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.target)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.target)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// Idempotency-Keyによる再送の重複防止
+func TestIdempotencyKey(t *testing.T) {
+	testname := "TestIdempotencyKey"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	post := func(target, body, key string, jwt *string) *http.Request {
+		req := newRequest(http.MethodPost, target, &body, echo.MIMEApplicationJSON, jwt)
+		req.Header.Set("Idempotency-Key", key)
+		return req
+	}
+
+	// 初回は実行
+	body := `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9500}`
+	first, err := execHandler(e, post("/v1/prices", body, "key-1", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 201, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	// 再送は保存したレスポンス
+	retry, err := execHandler(e, post("/v1/prices", body, "key-1", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 201, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Header().Get(echo.HeaderContentType), retry.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, first.Header().Get("ETag"), retry.Header().Get("ETag"))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+
+	// 同じキーで異なるリクエスト
+	other := `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9000}`
+	rec, err := execHandler(e, post("/v1/prices", other, "key-1", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 409, rec.Code)
+	res := &api.ErrorResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, res.Detail) {
+		assert.Equal(t, handler.ErrIdempotencyKeyReused.Error(), *res.Detail)
+	}
+	rec, err = execHandler(e, post("/v1/stores", body, "key-1", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 409, rec.Code)
+
+	// キーはユーザごと
+	rec, err = execHandler(e, post("/v1/prices", body, "key-1", genToken(conf, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 201, rec.Code)
+	assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+
+	// 重複して登録されていない
+	rec, err = execHandler(e, newRequest(http.MethodGet, "/v1/prices", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prices []api.Price
+	if err := json.Unmarshal(rec.Body.Bytes(), &prices); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, prices, 1)
+
+	// キーがなければ毎回実行
+	for range 2 {
+		rec, err = execHandler(e, newRequest(http.MethodPost, "/v1/prices", &other, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 201, rec.Code)
+	}
+
+	// 入力エラーのレスポンスも再送で同じ
+	invalid := `{"Store":"pcshop", "Product":"ssd1T"}`
+	for i := range 2 {
+		rec, err = execHandler(e, post("/v1/prices", invalid, "key-2", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 400, rec.Code)
+		assert.Equal(t, i == 1, rec.Header().Get("Idempotent-Replayed") == "true")
+	}
+
+	// キーの長さの上限
+	code, cause, err := execHandlerValidation(e, post("/v1/prices", body, strings.Repeat("a", 256), jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 400, code)
+	assert.Equal(t, handler.ErrIdempotencyKey, cause)
+}
@@ -0,0 +1,288 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/entity"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+const maxIndexMonths = 120 // 物価指数を求める月数の上限
+
+// 物価指数の買い物かごの登録
+func (h *Handler) createIndexBasket(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	req := &api.IndexBasket{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック（正規化した名前で検証）
+	if err := h.normalizeBasketItems(req.Items); err != nil {
+		return err
+	}
+	if err := c.Validate(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if req.ID != nil {
+		return newHTTPError(http.StatusBadRequest, ErrIDCannotRequest)
+	}
+
+	// サービスの実行
+	basket, err := h.service.CreateIndexBasket(ctx, indexBasketToEntity(req, 0, userId))
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusCreated, h.indexBasketToResponse(basket), h.indent)
+}
+
+// 物価指数の買い物かごの一覧
+func (h *Handler) findIndexBaskets(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+
+	// サービスの実行
+	entities, err := h.service.FindIndexBaskets(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成（登録順）
+	baskets := make([]*api.IndexBasket, len(entities))
+	for i, v := range entities {
+		baskets[i] = h.indexBasketToResponse(&v)
+	}
+
+	return h.revalidateJSON(c, baskets)
+}
+
+// 物価指数の買い物かごの取得
+func (h *Handler) findIndexBasket(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	basketId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	basket, err := h.service.FindIndexBasket(ctx, uint(basketId), userId)
+	if err != nil {
+		return err
+	}
+	if basket == nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// レスポンスの生成
+	return h.revalidateJSON(c, h.indexBasketToResponse(basket))
+}
+
+// 物価指数の買い物かごの更新（商品は置き換え）
+func (h *Handler) updateIndexBasket(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	req := &api.IndexBasket{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック
+	basketId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	if err = h.normalizeBasketItems(req.Items); err != nil {
+		return err
+	}
+	if err = c.Validate(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if req.ID != nil && *req.ID != uint(basketId) {
+		return newHTTPError(http.StatusBadRequest, ErrIDUnchangeable)
+	}
+
+	// サービスの実行
+	basket, err := h.service.UpdateIndexBasket(ctx, indexBasketToEntity(req, uint(basketId), userId))
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusOK, h.indexBasketToResponse(basket), h.indent)
+}
+
+// 物価指数の買い物かごの削除
+func (h *Handler) deleteIndexBasket(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	basketId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	if err = h.service.DeleteIndexBasket(ctx, uint(basketId), userId); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.NoContent(http.StatusNoContent)
+}
+
+// 買い物かごの月ごとの物価指数
+func (h *Handler) priceIndex(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	var reqBase, reqTo string
+	if err := echo.QueryParamsBinder(c).String("base", &reqBase).String("to", &reqTo).BindError(); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック
+	basketId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	base, err := h.parseMonth(reqBase)
+	if err != nil {
+		return err
+	}
+	to, err := h.parseMonth(reqTo)
+	if err != nil {
+		return err
+	}
+	if to == nil {
+		y, m, _ := time.Now().In(h.location).Date()
+		t := time.Date(y, m, 1, 0, 0, 0, 0, h.location)
+		to = &t
+	}
+	end := to.AddDate(0, 1, 0) // 最後の月の翌月の初め
+	if base != nil {
+		if !base.Before(end) {
+			return newHTTPError(http.StatusBadRequest, ErrMonthRange)
+		}
+		if base.AddDate(0, maxIndexMonths, 0).Before(end) {
+			return newHTTPError(http.StatusBadRequest, ErrIndexMonths)
+		}
+	}
+
+	// サービスの実行
+	index, err := h.service.PriceIndex(ctx, uint(basketId), userId, base, end, h.location)
+	if err != nil {
+		return err
+	}
+	if index == nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// レスポンスの生成
+	res := &api.PriceIndex{
+		BasketID: uint(basketId),
+		Base:     index.Base.Format(monthLayout),
+		Periods:  make([]api.PriceIndexPeriod, len(index.Periods)),
+	}
+	for i, v := range index.Periods {
+		res.Periods[i] = api.PriceIndexPeriod{
+			Month:    v.Month.Format(monthLayout),
+			Index:    math.Round(v.Index*100) / 100,
+			Coverage: math.Round(v.Coverage*1000) / 1000,
+			Observed: v.Observed,
+			Carried:  v.Carried,
+			Missing:  v.Missing,
+		}
+	}
+
+	return h.revalidateJSON(c, res)
+}
+
+// 商品名の正規化
+func (h *Handler) normalizeBasketItems(items []api.IndexBasketItem) error {
+	for i := range items {
+		if err := h.normalizeNames(&items[i].Product); err != nil {
+			return newHTTPError(http.StatusBadRequest, err)
+		}
+	}
+	return nil
+}
+
+func indexBasketToEntity(basket *api.IndexBasket, basketId, userId uint) *entity.IndexBasket {
+	items := make([]entity.IndexBasketItem, len(basket.Items))
+	for i, v := range basket.Items {
+		items[i] = entity.IndexBasketItem{
+			BasketID: basketId,
+			UserID:   userId,
+			Product:  v.Product,
+			Weight:   v.Weight,
+		}
+	}
+	return &entity.IndexBasket{
+		ID:     basketId,
+		UserID: userId,
+		Name:   basket.Name,
+		Items:  items,
+	}
+}
+
+func (h *Handler) indexBasketToResponse(basket *entity.IndexBasket) *api.IndexBasket {
+	items := make([]api.IndexBasketItem, len(basket.Items))
+	for i, v := range basket.Items {
+		items[i] = api.IndexBasketItem{Product: v.Product, Weight: v.Weight}
+	}
+	updatedAt := h.formatDateTime(basket.UpdatedAt)
+	return &api.IndexBasket{
+		ID:        &basket.ID,
+		Name:      basket.Name,
+		Items:     items,
+		UpdatedAt: &updatedAt,
+	}
+}
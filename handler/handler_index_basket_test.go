@@ -0,0 +1,276 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// 物価指数の買い物かごと連鎖指数
+func TestPriceIndex(t *testing.T) {
+	testname := "TestPriceIndex"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 価格の登録
+	prices := []struct {
+		dateTime string
+		product  string
+		price    uint
+	}{
+		{"2023-12-20 10:00:00", "egg", 240},
+		{"2024-01-10 10:00:00", "milk", 200},
+		{"2024-02-05 10:00:00", "milk", 200},
+		{"2024-02-25 10:00:00", "milk", 220},
+		{"2024-02-25 10:00:00", "egg", 250},
+		{"2024-03-15 10:00:00", "egg", 275},
+		{"2024-04-01 10:00:00", "milk", 231},
+		{"2024-04-30 10:00:00", "egg", 275},
+		{"2024-05-01 10:00:00", "milk", 999}, // 期間外
+	}
+	for _, v := range prices {
+		body := fmt.Sprintf(`{"DateTime":"%s", "Store":"super", "Product":"%s", "Price":%d}`, v.dateTime, v.product, v.price)
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 201, rec.Code, body)
+	}
+
+	// 登録
+	body := `{"Name":"daily", "Items":[{"Product":"milk", "Weight":0.6}, {"Product":"egg", "Weight":0.4}, {"Product":"rice", "Weight":1}]}`
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/baskets", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 201, rec.Code) {
+		t.FailNow()
+	}
+	basket := &api.IndexBasket{}
+	if err := json.Unmarshal(rec.Body.Bytes(), basket); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "daily", basket.Name)
+	assert.Len(t, basket.Items, 3)
+	basketPath := fmt.Sprintf("/v1/baskets/%d", *basket.ID)
+
+	index := func(target string) *api.PriceIndex {
+		rec, err := execHandler(e, newRequest(http.MethodGet, target, nil, "", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assert.Equal(t, 200, rec.Code, target) {
+			t.FailNow()
+		}
+		index := &api.PriceIndex{}
+		if err := json.Unmarshal(rec.Body.Bytes(), index); err != nil {
+			t.Fatal(err)
+		}
+		return index
+	}
+
+	// 基準月の指定（価格がない月は繰り越し）
+	actual := index(basketPath + "/index?base=2024-01&to=2024-04")
+	assert.Equal(t, *basket.ID, actual.BasketID)
+	assert.Equal(t, "2024-01", actual.Base)
+	assert.Equal(t, []api.PriceIndexPeriod{
+		{Month: "2024-01", Index: 100, Coverage: 0.3, Observed: 1, Carried: []string{"egg"}, Missing: []string{"rice"}},
+		{Month: "2024-02", Index: 104.67, Coverage: 0.5, Observed: 2, Missing: []string{"rice"}},
+		{Month: "2024-03", Index: 108.83, Coverage: 0.2, Observed: 1, Carried: []string{"milk"}, Missing: []string{"rice"}},
+		{Month: "2024-04", Index: 115.13, Coverage: 0.5, Observed: 2, Missing: []string{"rice"}},
+	}, actual.Periods)
+
+	// 基準月の省略は最初に価格がある月
+	actual = index(basketPath + "/index?to=2024-02")
+	assert.Equal(t, "2023-12", actual.Base)
+	assert.Equal(t, []api.PriceIndexPeriod{
+		{Month: "2023-12", Index: 100, Coverage: 0.2, Observed: 1, Missing: []string{"milk", "rice"}},
+		{Month: "2024-01", Index: 100, Coverage: 0.3, Observed: 1, Carried: []string{"egg"}, Missing: []string{"rice"}},
+		{Month: "2024-02", Index: 104.67, Coverage: 0.5, Observed: 2, Missing: []string{"rice"}},
+	}, actual.Periods)
+
+	// 更新は商品を置き換え
+	body = `{"Name":"milk only", "Items":[{"Product":"milk", "Weight":1}]}`
+	rec, err = execHandler(e, newRequest(http.MethodPut, basketPath, &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	actual = index(basketPath + "/index?base=2024-01&to=2024-04")
+	assert.Equal(t, []float64{100, 105, 105, 115.5}, []float64{actual.Periods[0].Index, actual.Periods[1].Index, actual.Periods[2].Index, actual.Periods[3].Index})
+
+	// 削除
+	rec, err = execHandler(e, newRequest(http.MethodDelete, basketPath, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 204, rec.Code)
+	rec, err = execHandler(e, newRequest(http.MethodGet, "/v1/baskets", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.JSONEq(t, "[]", rec.Body.String())
+}
+
+// 物価指数の買い物かごと連鎖指数の入力チェック
+// 内容量がある価格は単価で物価指数を求める
+func TestPriceIndexUnitPrice(t *testing.T) {
+	testname := "TestPriceIndexUnitPrice"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 価格の登録（2月は内容量が減って単価は同じ、3月は単価が10%上昇）
+	for _, body := range []string{
+		`{"DateTime":"2024-01-10 10:00:00", "Store":"super", "Product":"rice", "Price":2000, "Quantity":5, "Unit":"kg"}`,
+		`{"DateTime":"2024-02-10 10:00:00", "Store":"super", "Product":"rice", "Price":800, "Quantity":2, "Unit":"kg"}`,
+		`{"DateTime":"2024-03-10 10:00:00", "Store":"super", "Product":"rice", "Price":880, "Quantity":2000, "Unit":"g"}`,
+	} {
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 201, rec.Code, body)
+	}
+
+	body := `{"Name":"rice", "Items":[{"Product":"rice", "Weight":1}]}`
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/baskets", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 201, rec.Code) {
+		t.FailNow()
+	}
+	basket := &api.IndexBasket{}
+	if err := json.Unmarshal(rec.Body.Bytes(), basket); err != nil {
+		t.Fatal(err)
+	}
+
+	// テストの実行
+	rec, err = execHandler(e, newRequest(http.MethodGet, fmt.Sprintf("/v1/baskets/%d/index?base=2024-01&to=2024-03", *basket.ID), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 200, rec.Code)
+	index := &api.PriceIndex{}
+	if err := json.Unmarshal(rec.Body.Bytes(), index); err != nil {
+		t.Fatal(err)
+	}
+	actual := make([]float64, len(index.Periods))
+	for i, v := range index.Periods {
+		actual[i] = v.Index
+	}
+	assert.Equal(t, []float64{100, 100, 110}, actual)
+}
+
+func TestPriceIndexValidation(t *testing.T) {
+	testname := "TestPriceIndexValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	otherJwt := genToken(conf, 2)
+
+	// 買い物かごの登録
+	body := `{"Name":"daily", "Items":[{"Product":"milk", "Weight":1}]}`
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/baskets", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 201, rec.Code) {
+		t.FailNow()
+	}
+	basket := &api.IndexBasket{}
+	if err := json.Unmarshal(rec.Body.Bytes(), basket); err != nil {
+		t.Fatal(err)
+	}
+	basketPath := fmt.Sprintf("/v1/baskets/%d", *basket.ID)
+
+	cases := []struct {
+		method string
+		target string
+		body   string
+		jwt    *string
+		code   int
+		err    error
+	}{
+		{http.MethodPost, "/v1/baskets", `{"Name":"a", "Items":[{"Product":"milk", "Weight":1}, {"Product":"milk", "Weight":2}]}`, jwt, 400, nil},
+		{http.MethodPost, "/v1/baskets", `{"Name":"a", "Items":[{"Product":"milk", "Weight":0}]}`, jwt, 400, nil},
+		{http.MethodPost, "/v1/baskets", `{"Name":"a", "Items":[]}`, jwt, 400, nil},
+		{http.MethodPost, "/v1/baskets", `{"ID":1, "Name":"a", "Items":[{"Product":"milk", "Weight":1}]}`, jwt, 400, handler.ErrIDCannotRequest},
+		{http.MethodPut, basketPath, fmt.Sprintf(`{"ID":%d, "Name":"a", "Items":[{"Product":"milk", "Weight":1}]}`, *basket.ID+1), jwt, 400, handler.ErrIDUnchangeable},
+		{http.MethodPut, basketPath, `{"Name":"a", "Items":[{"Product":"milk", "Weight":1}]}`, otherJwt, 404, handler.ErrNotFound},
+		{http.MethodPut, "/v1/baskets/a", `{"Name":"a", "Items":[{"Product":"milk", "Weight":1}]}`, jwt, 404, handler.ErrNotFound},
+		{http.MethodGet, basketPath + "/index?base=2024-1", "", jwt, 400, handler.ErrMonth},
+		{http.MethodGet, basketPath + "/index?base=2024-05&to=2024-04", "", jwt, 400, handler.ErrMonthRange},
+		{http.MethodGet, basketPath + "/index?base=2014-04&to=2024-04", "", jwt, 400, handler.ErrIndexMonths},
+		{http.MethodGet, basketPath + "/index", "", otherJwt, 404, handler.ErrNotFound},
+		{http.MethodGet, "/v1/baskets/999999/index", "", jwt, 404, handler.ErrNotFound},
+		{http.MethodDelete, basketPath, "", otherJwt, 404, handler.ErrNotFound},
+		{http.MethodDelete, "/v1/baskets/a", "", jwt, 404, handler.ErrNotFound},
+	}
+	for _, v := range cases {
+		// リクエストの生成
+		var body *string
+		contentType := ""
+		if v.body != "" {
+			body = &v.body
+			contentType = echo.MIMEApplicationJSON
+		}
+		req := newRequest(v.method, v.target, body, contentType, v.jwt)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.method+" "+v.target)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.method+" "+v.target)
+		}
+	}
+}
@@ -86,11 +86,13 @@ type priceRepositoryMock struct {
 	rowsAffected int
 	overwirte    bool
 
+	afterFind func() // 取得の後に呼び出す（他の更新の割り込み）
+
 	err error
 }
 
 func newMockPriceRepository(r repository.PriceRepository) *priceRepositoryMock {
-	return &priceRepositoryMock{r, 0, false, nil}
+	return &priceRepositoryMock{r, 0, false, nil, nil}
 }
 
 func (m *priceRepositoryMock) Create(
@@ -99,7 +101,13 @@ func (m *priceRepositoryMock) Create(
 	dateTime time.Time,
 	store string,
 	product string,
+	gtin string,
 	price uint,
+	quantity float64,
+	unit string,
+	priceType string,
+	validFrom *time.Time,
+	validTo *time.Time,
 ) (*entity.Price, error) {
 	if m.err != nil {
 		return nil, m.err
@@ -110,7 +118,13 @@ func (m *priceRepositoryMock) Create(
 		dateTime,
 		store,
 		product,
+		gtin,
 		price,
+		quantity,
+		unit,
+		priceType,
+		validFrom,
+		validTo,
 	)
 }
 
@@ -118,7 +132,18 @@ func (m *priceRepositoryMock) Find(ctx context.Context, id, userId uint) (*entit
 	if m.err != nil {
 		return nil, m.err
 	}
-	return m.PriceRepository.Find(ctx, id, userId)
+	price, err := m.PriceRepository.Find(ctx, id, userId)
+	if err == nil && m.afterFind != nil {
+		m.afterFind()
+	}
+	return price, err
+}
+
+func (m *priceRepositoryMock) FindForUpdate(ctx context.Context, id, userId uint) (*entity.Price, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.PriceRepository.FindForUpdate(ctx, id, userId)
 }
 
 func (m *priceRepositoryMock) FindByUserId(ctx context.Context, userId uint) ([]entity.Price, error) {
@@ -132,10 +157,17 @@ func (m *priceRepositoryMock) Update(
 	ctx context.Context,
 	id uint,
 	userId uint,
+	versions []uint,
 	dateTime time.Time,
 	store string,
 	product string,
+	gtin string,
 	price uint,
+	quantity float64,
+	unit string,
+	priceType string,
+	validFrom *time.Time,
+	validTo *time.Time,
 ) (*entity.Price, int64, error) {
 	if m.err != nil {
 		return nil, 0, m.err
@@ -147,19 +179,64 @@ func (m *priceRepositoryMock) Update(
 		ctx,
 		id,
 		userId,
+		versions,
+		dateTime,
+		store,
+		product,
+		gtin,
+		price,
+		quantity,
+		unit,
+		priceType,
+		validFrom,
+		validTo,
+	)
+}
+
+func (m *priceRepositoryMock) Patch(
+	ctx context.Context,
+	id uint,
+	userId uint,
+	versions []uint,
+	dateTime *time.Time,
+	store *string,
+	product *string,
+	gtin *string,
+	price *uint,
+	quantity *float64,
+	unit *string,
+	priceType *string,
+	validity *entity.PriceValidity,
+) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	if m.overwirte {
+		return int64(m.rowsAffected), nil
+	}
+	return m.PriceRepository.Patch(
+		ctx,
+		id,
+		userId,
+		versions,
 		dateTime,
 		store,
 		product,
+		gtin,
 		price,
+		quantity,
+		unit,
+		priceType,
+		validity,
 	)
 }
 
-func (m *priceRepositoryMock) Delete(ctx context.Context, id, userId uint) (int64, error) {
+func (m *priceRepositoryMock) Delete(ctx context.Context, id, userId uint, versions []uint) (int64, error) {
 	if m.err != nil {
 		return 0, m.err
 	}
 	if m.overwirte {
 		return int64(m.rowsAffected), nil
 	}
-	return m.PriceRepository.Delete(ctx, id, userId)
+	return m.PriceRepository.Delete(ctx, id, userId, versions)
 }
@@ -1,20 +1,43 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"slices"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/ystkg/rest-example/api"
 	"github.com/ystkg/rest-example/entity"
 	"github.com/ystkg/rest-example/service"
 
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	mimeApplicationMergePatchJSON = "application/merge-patch+json" // RFC 7396
+	mimeApplicationJSONPatchJSON  = "application/json-patch+json"  // RFC 6902
+
+	maxPatchAttempts = 3 // If-Matchの指定がない部分更新が他の更新と競合した場合の試行回数
+
+	// タグの絞り込みの条件
+	tagMatchAny = "any" // いずれかのタグ（デフォルト）
+	tagMatchAll = "all" // 全てのタグ
+
+	// 一覧の並び順
+	priceSortDateTime  = "datetime"  // 日時の降順（デフォルト）
+	priceSortUnitPrice = "unitprice" // 単価の昇順（内容量のない価格は末尾）
+)
+
 // 価格の登録
 func (h *Handler) createPrice(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -28,7 +51,10 @@ func (h *Handler) createPrice(c echo.Context) error {
 		return newHTTPError(http.StatusBadRequest, err)
 	}
 
-	// 入力チェック
+	// 入力チェック（正規化した名前で検証）
+	if err := h.normalizePrice(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
 	if err := c.Validate(req); err != nil {
 		return newHTTPError(http.StatusBadRequest, err)
 	}
@@ -39,6 +65,14 @@ func (h *Handler) createPrice(c echo.Context) error {
 	if err != nil {
 		return newHTTPError(http.StatusBadRequest, err)
 	}
+	validFrom, validTo, err := h.parseValidity(req, dateTime)
+	if err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	rejectOutlier, err := h.rejectOutlier(c)
+	if err != nil {
+		return err
+	}
 
 	// サービスの実行
 	price, err := h.service.CreatePrice(
@@ -47,14 +81,33 @@ func (h *Handler) createPrice(c echo.Context) error {
 		dateTime,
 		req.Store,
 		req.Product,
+		req.GTIN,
 		req.Price,
+		req.Quantity,
+		req.Unit,
+		requestPriceType(req.PriceType),
+		validFrom,
+		validTo,
+		req.Tags,
+		rejectOutlier,
 	)
 	if err != nil {
+		if errors.Is(err, service.ErrUnitMismatch) {
+			return newHTTPError(http.StatusBadRequest, ErrUnitMismatch)
+		}
+		if herr := outlierError(err); herr != nil {
+			return herr
+		}
 		return err
 	}
 
 	// レスポンスの生成
-	return c.JSONPretty(http.StatusCreated, h.entityToResponse(price), h.indent)
+	res, err := h.priceResponse(ctx, price)
+	if err != nil {
+		return err
+	}
+	setPriceETag(c, price)
+	return c.JSONPretty(http.StatusCreated, res, h.indent)
 }
 
 // 価格の一覧
@@ -65,9 +118,24 @@ func (h *Handler) findPrices(c echo.Context) error {
 
 	// リクエストの取得
 	userId := h.userId(c)
+	tags := c.QueryParams()["tag"]
+	match := c.QueryParam("match")
+	sortKey := c.QueryParam("sort")
 
-	// サービスの実行
-	entities, err := h.service.FindPrices(ctx, userId)
+	// 入力チェック
+	if match != "" && match != tagMatchAny && match != tagMatchAll {
+		return newHTTPError(http.StatusBadRequest, ErrTagMatch)
+	}
+	if sortKey != "" && sortKey != priceSortDateTime && sortKey != priceSortUnitPrice {
+		return newHTTPError(http.StatusBadRequest, ErrSort)
+	}
+
+	// サービスの実行（一覧より後の更新日時を返さないよう先に取得）
+	lastModified, err := h.service.FindPricesLastModified(ctx, userId)
+	if err != nil {
+		return err
+	}
+	entities, err := h.service.FindPrices(ctx, userId, tags, match == tagMatchAll)
 	if err != nil {
 		return err
 	}
@@ -82,14 +150,60 @@ func (h *Handler) findPrices(c echo.Context) error {
 		}
 		return iDateTime > jDateTime // 第一ソートキー
 	})
-	priceList := make([]*api.Price, len(entities))
-	for i, v := range entities {
-		priceList[i] = h.entityToResponse(&v)
+	if sortKey == priceSortUnitPrice {
+		sortByUnitPrice(entities)
+	}
+	if notModified(c, pricesETag(entities), lastModified) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	priceList, err := h.pricesResponse(ctx, entities)
+	if err != nil {
+		return err
 	}
 
 	return c.JSONPretty(http.StatusOK, priceList, h.indent)
 }
 
+// ある日時に有効な価格の一覧（店舗と商品ごとに一つ）
+func (h *Handler) findEffectivePrices(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	var reqAt *string
+	if v := c.QueryParam("at"); v != "" {
+		reqAt = &v
+	}
+	priceTypes := c.QueryParams()["type"]
+
+	// 入力チェック
+	at, err := h.parseDateTime(reqAt) // 省略時は現在日時
+	if err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	for _, v := range priceTypes {
+		if v != entity.PriceTypeRegular && v != entity.PriceTypeSale && v != entity.PriceTypeMember {
+			return newHTTPError(http.StatusBadRequest, ErrPriceType)
+		}
+	}
+
+	// サービスの実行
+	entities, err := h.service.FindEffectivePrices(ctx, userId, at, priceTypes)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成
+	priceList, err := h.pricesResponse(ctx, entities)
+	if err != nil {
+		return err
+	}
+
+	return h.revalidateJSON(c, priceList)
+}
+
 // 価格の取得
 func (h *Handler) findPrice(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -116,7 +230,14 @@ func (h *Handler) findPrice(c echo.Context) error {
 	}
 
 	// レスポンスの生成
-	return c.JSONPretty(http.StatusOK, h.entityToResponse(price), h.indent)
+	if notModified(c, priceETag(price), price.UpdatedAt) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	res, err := h.priceResponse(ctx, price)
+	if err != nil {
+		return err
+	}
+	return c.JSONPretty(http.StatusOK, res, h.indent)
 }
 
 // 価格の更新
@@ -138,6 +259,9 @@ func (h *Handler) updatePrice(c echo.Context) error {
 	if err != nil {
 		return newHTTPError(http.StatusNotFound, ErrNotFound)
 	}
+	if err = h.normalizePrice(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
 	if err = c.Validate(req); err != nil {
 		return newHTTPError(http.StatusBadRequest, err)
 	}
@@ -148,26 +272,276 @@ func (h *Handler) updatePrice(c echo.Context) error {
 	if err != nil {
 		return newHTTPError(http.StatusBadRequest, err)
 	}
+	validFrom, validTo, err := h.parseValidity(req, dateTime)
+	if err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	ifMatch, err := parseIfMatch(c)
+	if err != nil {
+		return err
+	}
+	rejectOutlier, err := h.rejectOutlier(c)
+	if err != nil {
+		return err
+	}
 
 	// サービスの実行
 	price, err := h.service.UpdatePrice(
 		ctx,
 		uint(priceId),
 		userId,
+		ifMatch,
 		dateTime,
 		req.Store,
 		req.Product,
+		req.GTIN,
 		req.Price,
+		req.Quantity,
+		req.Unit,
+		requestPriceType(req.PriceType),
+		validFrom,
+		validTo,
+		req.Tags,
+		rejectOutlier,
 	)
 	if err != nil {
 		if errors.Is(err, service.ErrNotFound) {
 			return newHTTPError(http.StatusNotFound, ErrNotFound)
 		}
+		if errors.Is(err, service.ErrPreconditionFailed) {
+			return newHTTPError(http.StatusPreconditionFailed, ErrPreconditionFailed)
+		}
+		if errors.Is(err, service.ErrUnitMismatch) {
+			return newHTTPError(http.StatusBadRequest, ErrUnitMismatch)
+		}
+		if herr := outlierError(err); herr != nil {
+			return herr
+		}
 		return err
 	}
 
 	// レスポンスの生成
-	return c.JSONPretty(http.StatusOK, h.entityToResponse(price), h.indent)
+	res, err := h.priceResponse(ctx, price)
+	if err != nil {
+		return err
+	}
+	setPriceETag(c, price)
+	return c.JSONPretty(http.StatusOK, res, h.indent)
+}
+
+// 価格の部分更新
+func (h *Handler) patchPrice(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	patch, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return err
+	}
+
+	// 入力チェック
+	priceId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	if mediaType != mimeApplicationMergePatchJSON && mediaType != mimeApplicationJSONPatchJSON {
+		return newHTTPError(http.StatusUnsupportedMediaType, ErrUnsupportedMediaType)
+	}
+	ifMatch, err := parseIfMatch(c)
+	if err != nil {
+		return err
+	}
+	rejectOutlier, err := h.rejectOutlier(c)
+	if err != nil {
+		return err
+	}
+
+	// パッチの適用と部分更新（If-Matchの指定がなく他の更新と競合した場合は現在の価格の取得からやり直す）
+	var patched *entity.Price
+	for attempt := 1; ; attempt++ {
+		patched, err = h.applyPricePatch(c, uint(priceId), userId, mediaType, patch, ifMatch, rejectOutlier)
+		if ifMatch != nil || !errors.Is(err, service.ErrPreconditionFailed) {
+			break
+		}
+		if attempt == maxPatchAttempts {
+			return newHTTPError(http.StatusConflict, ErrPatchConflict)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		if errors.Is(err, service.ErrPreconditionFailed) {
+			return newHTTPError(http.StatusPreconditionFailed, ErrPreconditionFailed)
+		}
+		if errors.Is(err, service.ErrUnitMismatch) {
+			return newHTTPError(http.StatusBadRequest, ErrUnitMismatch)
+		}
+		if herr := outlierError(err); herr != nil {
+			return herr
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	res, err := h.priceResponse(ctx, patched)
+	if err != nil {
+		return err
+	}
+	setPriceETag(c, patched)
+	return c.JSONPretty(http.StatusOK, res, h.indent)
+}
+
+// 現在の価格にパッチを適用して部分更新（ifMatchの指定がなければ取得した時点のバージョンを更新条件にする）
+func (h *Handler) applyPricePatch(c echo.Context, priceId, userId uint, mediaType string, patch []byte, ifMatch []uint, rejectOutlier bool) (*entity.Price, error) {
+	ctx := c.Request().Context()
+
+	// 現在の価格の取得
+	current, err := h.service.FindPrice(ctx, priceId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	expected := ifMatch
+	if expected == nil {
+		expected = []uint{current.Version}
+	}
+
+	// パッチの適用
+	currentRes, err := h.priceResponse(ctx, current)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := json.Marshal(currentRes)
+	if err != nil {
+		return nil, err
+	}
+	if doc, err = applyPatch(mediaType, doc, patch); err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, err)
+	}
+	req := &api.Price{}
+	if err = json.Unmarshal(doc, req); err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック（パッチ適用後）
+	if err = h.normalizePrice(req); err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, err)
+	}
+	if err = c.Validate(req); err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, err)
+	}
+	if req.ID != nil && *req.ID != priceId {
+		return nil, newHTTPError(http.StatusBadRequest, ErrIDUnchangeable)
+	}
+	if req.DateTime == nil {
+		return nil, newHTTPError(http.StatusBadRequest, ErrDateTimeRequired)
+	}
+
+	// 変更のある項目の抽出
+	var dateTime *time.Time
+	afterDateTime := current.DateTime
+	if *req.DateTime != h.formatDateTime(current.DateTime) {
+		d, err := h.parseDateTime(req.DateTime)
+		if err != nil {
+			return nil, newHTTPError(http.StatusBadRequest, err)
+		}
+		dateTime = &d
+		afterDateTime = d
+	}
+	var store, product, gtin *string
+	if req.Store != current.Store {
+		store = &req.Store
+	}
+	if req.Product != current.Product {
+		product = &req.Product
+	}
+	if req.GTIN != current.GTIN {
+		gtin = &req.GTIN
+	}
+	var price *uint
+	if req.Price != current.Price {
+		price = &req.Price
+	}
+	var quantity *float64
+	if req.Quantity != current.Quantity {
+		quantity = &req.Quantity
+	}
+	var unit *string
+	if req.Unit != current.Unit {
+		unit = &req.Unit
+	}
+	var priceType *string
+	if t := requestPriceType(req.PriceType); t != current.PriceType {
+		priceType = &t
+	}
+	validFrom, validTo, err := h.parseValidity(req, afterDateTime)
+	if err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, err)
+	}
+	var validity *entity.PriceValidity
+	if !equalDateTime(validFrom, current.ValidFrom) || !equalDateTime(validTo, current.ValidTo) {
+		validity = &entity.PriceValidity{From: validFrom, To: validTo}
+	}
+	var tags *[]string
+	if !sameTags(req.Tags, currentRes.Tags) {
+		tags = &req.Tags
+	}
+
+	// サービスの実行
+	return h.service.PatchPrice(
+		ctx,
+		priceId,
+		userId,
+		expected,
+		dateTime,
+		store,
+		product,
+		gtin,
+		price,
+		quantity,
+		unit,
+		priceType,
+		validity,
+		tags,
+		rejectOutlier,
+	)
+}
+
+// 外れ値を拒否するか（厳格モードでconfirmの指定がなければ拒否）
+func (h *Handler) rejectOutlier(c echo.Context) (bool, error) {
+	var confirm bool
+	if err := echo.QueryParamsBinder(c).Bool("confirm", &confirm).BindError(); err != nil {
+		return false, newHTTPError(http.StatusBadRequest, err)
+	}
+	return h.strictOutlier && !confirm, nil
+}
+
+// 外れ値のエラーは想定の範囲を含めて422（外れ値でなければnil）
+func outlierError(err error) error {
+	var outlier *service.OutlierError
+	if !errors.As(err, &outlier) {
+		return nil
+	}
+	return newHTTPError(http.StatusUnprocessableEntity, &priceRangeError{min: outlier.Min, max: outlier.Max})
+}
+
+func applyPatch(mediaType string, doc, patch []byte) ([]byte, error) {
+	if mediaType == mimeApplicationMergePatchJSON {
+		return jsonpatch.MergePatch(doc, patch)
+	}
+	decoded, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	return decoded.Apply(doc)
 }
 
 // 価格の削除
@@ -186,11 +560,19 @@ func (h *Handler) deletePrice(c echo.Context) error {
 		return newHTTPError(http.StatusNotFound, ErrNotFound)
 	}
 
+	ifMatch, err := parseIfMatch(c)
+	if err != nil {
+		return err
+	}
+
 	// サービスの実行
-	if err = h.service.DeletePrice(ctx, uint(priceId), userId); err != nil {
+	if err = h.service.DeletePrice(ctx, uint(priceId), userId, ifMatch); err != nil {
 		if errors.Is(err, service.ErrNotFound) {
 			return newHTTPError(http.StatusNotFound, ErrNotFound)
 		}
+		if errors.Is(err, service.ErrPreconditionFailed) {
+			return newHTTPError(http.StatusPreconditionFailed, ErrPreconditionFailed)
+		}
 		return err
 	}
 
@@ -209,13 +591,154 @@ func (h *Handler) formatDateTime(dateTime time.Time) string {
 	return dateTime.In(h.location).Format(h.layout)
 }
 
+// 店舗と商品名の正規化（入力チェックの前に実行し、文字数は正規化後の文字で数える）
+func (h *Handler) normalizePrice(price *api.Price) error {
+	return h.normalizeNames(&price.Store, &price.Product)
+}
+
+// 店舗や商品の名前の正規化（価格と同じ名前で検索や登録をするため）
+func (h *Handler) normalizeNames(names ...*string) error {
+	if !h.normalizeText {
+		return nil
+	}
+	for _, v := range names {
+		if v != nil && strings.ContainsFunc(*v, unicode.IsControl) {
+			return ErrControlCharacter
+		}
+	}
+	for _, v := range names {
+		if v != nil {
+			*v = service.NormalizeText(*v)
+		}
+	}
+	return nil
+}
+
+// 有効期間の始まりと終わり（省略はnil、終わりは始まりか日時より後）
+func (h *Handler) parseValidity(price *api.Price, dateTime time.Time) (*time.Time, *time.Time, error) {
+	var validFrom, validTo *time.Time
+	if price.ValidFrom != nil {
+		t, err := time.ParseInLocation(h.layout, *price.ValidFrom, h.location)
+		if err != nil {
+			return nil, nil, err
+		}
+		validFrom = &t
+	}
+	if price.ValidTo != nil {
+		t, err := time.ParseInLocation(h.layout, *price.ValidTo, h.location)
+		if err != nil {
+			return nil, nil, err
+		}
+		validTo = &t
+	}
+	start := dateTime
+	if validFrom != nil {
+		start = *validFrom
+	}
+	if validTo != nil && !start.Before(*validTo) {
+		return nil, nil, ErrValidity
+	}
+	return validFrom, validTo, nil
+}
+
+func (h *Handler) formatOptionalDateTime(dateTime *time.Time) *string {
+	if dateTime == nil {
+		return nil
+	}
+	formatted := h.formatDateTime(*dateTime)
+	return &formatted
+}
+
+// 種類の省略は通常価格
+func requestPriceType(priceType string) string {
+	if priceType == "" {
+		return entity.PriceTypeRegular
+	}
+	return priceType
+}
+
+// 通常価格はレスポンスで省略
+func responsePriceType(priceType string) string {
+	if priceType == entity.PriceTypeRegular {
+		return ""
+	}
+	return priceType
+}
+
 func (h *Handler) entityToResponse(entity *entity.Price) *api.Price {
 	dateTime := h.formatDateTime(entity.DateTime)
-	return &api.Price{
-		ID:       &entity.ID,
-		DateTime: &dateTime,
-		Store:    entity.Store,
-		Product:  entity.Product,
-		Price:    entity.Price,
+	res := &api.Price{
+		ID:         &entity.ID,
+		DateTime:   &dateTime,
+		Store:      entity.Store,
+		Product:    entity.Product,
+		GTIN:       entity.GTIN,
+		Price:      entity.Price,
+		Quantity:   entity.Quantity,
+		Unit:       entity.Unit,
+		Suspicious: entity.Suspicious,
+		PriceType:  responsePriceType(entity.PriceType),
+		ValidFrom:  h.formatOptionalDateTime(entity.ValidFrom),
+		ValidTo:    h.formatOptionalDateTime(entity.ValidTo),
+	}
+	if unitPrice, basis, ok := service.UnitPrice(entity); ok {
+		res.UnitPrice = &unitPrice
+		res.UnitPriceBasis = basis
+	}
+	return res
+}
+
+// タグを含むレスポンス
+func (h *Handler) priceResponse(ctx context.Context, entity *entity.Price) (*api.Price, error) {
+	tags, err := h.service.FindPriceTags(ctx, []uint{entity.ID})
+	if err != nil {
+		return nil, err
+	}
+	res := h.entityToResponse(entity)
+	res.Tags = tags[entity.ID]
+	return res, nil
+}
+
+func (h *Handler) pricesResponse(ctx context.Context, entities []entity.Price) ([]*api.Price, error) {
+	priceIds := make([]uint, len(entities))
+	for i, v := range entities {
+		priceIds[i] = v.ID
+	}
+	tags, err := h.service.FindPriceTags(ctx, priceIds)
+	if err != nil {
+		return nil, err
 	}
+	priceList := make([]*api.Price, len(entities))
+	for i, v := range entities {
+		priceList[i] = h.entityToResponse(&v)
+		priceList[i].Tags = tags[v.ID]
+	}
+	return priceList, nil
+}
+
+// 単価の昇順（同じ単価なら元の順序、内容量のない価格は末尾）
+func sortByUnitPrice(entities []entity.Price) {
+	sort.SliceStable(entities, func(i, j int) bool {
+		iUnitPrice, _, iOk := service.UnitPrice(&entities[i])
+		jUnitPrice, _, jOk := service.UnitPrice(&entities[j])
+		if iOk != jOk {
+			return iOk
+		}
+		return iOk && iUnitPrice < jUnitPrice
+	})
+}
+
+// 省略（nil）を含む日時の比較
+func equalDateTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// 順序と重複を無視したタグの比較
+func sameTags(a, b []string) bool {
+	a = slices.Compact(slices.Sorted(slices.Values(a)))
+	b = slices.Compact(slices.Sorted(slices.Values(b)))
+	return slices.Equal(a, b)
 }
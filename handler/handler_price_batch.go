@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+const maxBatchOperations = 100
+
+// 価格の一括処理
+func (h *Handler) batchPrices(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	var atomic bool
+	if err := echo.QueryParamsBinder(c).Bool("atomic", &atomic).BindError(); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	rejectOutlier, err := h.rejectOutlier(c)
+	if err != nil {
+		return err
+	}
+	var req []api.PriceOperation
+	if err := c.Bind(&req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック
+	if len(req) == 0 || maxBatchOperations < len(req) {
+		return newHTTPError(http.StatusBadRequest, ErrBatchSize)
+	}
+	results := make([]*api.PriceOperationResult, len(req))
+	ops := make([]service.PriceOperation, 0, len(req))
+	index := make([]int, 0, len(req)) // opsの要素とreqの要素の対応
+	for i, v := range req {
+		op, err := h.priceOperation(c, &v)
+		if err != nil {
+			results[i] = h.operationErrorResult(newHTTPError(http.StatusBadRequest, err))
+			continue
+		}
+		op.RejectOutlier = rejectOutlier
+		ops = append(ops, *op)
+		index = append(index, i)
+	}
+	if atomic && len(ops) != len(req) {
+		// 1件でも不正な操作があれば何も実行しない
+		for i, v := range results {
+			if v == nil {
+				results[i] = h.operationErrorResult(newHTTPError(http.StatusFailedDependency, ErrAborted))
+			}
+		}
+		return c.JSONPretty(http.StatusOK, results, h.indent)
+	}
+
+	// サービスの実行
+	opResults, err := h.service.BatchPrices(ctx, userId, ops, atomic)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成
+	priceIds := make([]uint, 0, len(opResults))
+	for _, v := range opResults {
+		if v.Price != nil {
+			priceIds = append(priceIds, v.Price.ID)
+		}
+	}
+	tags, err := h.service.FindPriceTags(ctx, priceIds)
+	if err != nil {
+		return err
+	}
+	for i, v := range opResults {
+		results[index[i]] = h.operationResult(ctx, ops[i].Op, &v)
+		if price := results[index[i]].Price; price != nil {
+			price.Tags = tags[*price.ID]
+		}
+	}
+
+	return c.JSONPretty(http.StatusOK, results, h.indent)
+}
+
+func (h *Handler) priceOperation(c echo.Context, req *api.PriceOperation) (*service.PriceOperation, error) {
+	if req.Price != nil {
+		if err := h.normalizePrice(req.Price); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Validate(req); err != nil {
+		return nil, err
+	}
+
+	op := &service.PriceOperation{Op: req.Op}
+	switch req.Op {
+	case service.PriceOpCreate:
+		if req.Price == nil {
+			return nil, ErrPriceRequired
+		}
+		if req.ID != nil || req.Price.ID != nil {
+			return nil, ErrIDCannotRequest
+		}
+	case service.PriceOpUpdate:
+		if req.ID == nil {
+			return nil, ErrIDRequired
+		}
+		if req.Price == nil {
+			return nil, ErrPriceRequired
+		}
+		if req.Price.ID != nil && *req.Price.ID != *req.ID {
+			return nil, ErrIDUnchangeable
+		}
+		op.PriceId = *req.ID
+	case service.PriceOpDelete:
+		if req.ID == nil {
+			return nil, ErrIDRequired
+		}
+		op.PriceId = *req.ID
+		return op, nil
+	}
+
+	dateTime, err := h.parseDateTime(req.Price.DateTime)
+	if err != nil {
+		return nil, err
+	}
+	if op.ValidFrom, op.ValidTo, err = h.parseValidity(req.Price, dateTime); err != nil {
+		return nil, err
+	}
+	op.DateTime = dateTime
+	op.Store = req.Price.Store
+	op.Product = req.Price.Product
+	op.GTIN = req.Price.GTIN
+	op.Price = req.Price.Price
+	op.Quantity = req.Price.Quantity
+	op.Unit = req.Price.Unit
+	op.Tags = req.Price.Tags
+	op.PriceType = requestPriceType(req.Price.PriceType)
+
+	return op, nil
+}
+
+func (h *Handler) operationResult(ctx context.Context, op string, result *service.PriceOperationResult) *api.PriceOperationResult {
+	if result.Err != nil {
+		// エラーログ
+		slog.DebugContext(ctx, result.Err.Error())
+
+		switch {
+		case errors.Is(result.Err, service.ErrNotFound):
+			return h.operationErrorResult(newHTTPError(http.StatusNotFound, ErrNotFound))
+		case errors.Is(result.Err, service.ErrAborted):
+			return h.operationErrorResult(newHTTPError(http.StatusFailedDependency, ErrAborted))
+		case errors.Is(result.Err, service.ErrUnitMismatch):
+			return h.operationErrorResult(newHTTPError(http.StatusBadRequest, ErrUnitMismatch))
+		}
+		if herr := outlierError(result.Err); herr != nil {
+			return h.operationErrorResult(herr)
+		}
+		return h.operationErrorResult(result.Err)
+	}
+
+	switch op {
+	case service.PriceOpCreate:
+		return &api.PriceOperationResult{Status: http.StatusCreated, Price: h.entityToResponse(result.Price)}
+	case service.PriceOpUpdate:
+		return &api.PriceOperationResult{Status: http.StatusOK, Price: h.entityToResponse(result.Price)}
+	}
+	return &api.PriceOperationResult{Status: http.StatusNoContent}
+}
+
+func (h *Handler) operationErrorResult(err error) *api.PriceOperationResult {
+	code, res := h.errorResponse(err)
+	return &api.PriceOperationResult{Status: code, Error: res}
+}
@@ -0,0 +1,346 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// 価格の一括処理の正常系
+func TestBatchPrices(t *testing.T) {
+	testname := "TestBatchPrices"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	if _, err := insertPrices(tx, &now, somePrices()); err != nil {
+		t.Fatal(err)
+	}
+
+	// リクエストの生成
+	userId := uint(1)
+	body := `[
+		{"Op":"create", "Price":{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9500}},
+		{"Op":"update", "ID":2, "Price":{"DateTime":"2023-05-19 12:34:56", "Store":"shop2", "Product":"memory16G", "Price":5800}},
+		{"Op":"delete", "ID":3},
+		{"Op":"delete", "ID":4}
+	]`
+	req := newRequest(
+		http.MethodPost,
+		"/v1/prices:batch",
+		&body,
+		echo.MIMEApplicationJSON,
+		genToken(conf, userId),
+	)
+
+	// テストの実行
+	rec, diff, _, err := execHandlerTest(e, testDB, tx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 200, rec.Code)
+
+	res := []api.PriceOperationResult{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 4, len(res))
+	assert.Equal(t, 201, res[0].Status)
+	assert.Equal(t, "ssd1T", res[0].Price.Product)
+	assert.Equal(t, 200, res[1].Status)
+	assert.Equal(t, uint(5800), res[1].Price.Price)
+	assert.Equal(t, 204, res[2].Status)
+	assert.Equal(t, 404, res[3].Status) // 他のユーザの価格
+	assert.Equal(t, "Not Found", res[3].Error.Title)
+
+	assert.NotNil(t, diff)
+	assert.Equal(t, 1, len(diff.created.prices))
+	assert.Equal(t, 1, len(diff.updated.prices))
+	assert.Equal(t, 1, len(diff.logicalDeleted.prices))
+	assert.Zero(t, diff.physicalDeleted.count())
+	assert.Equal(t, *res[0].Price.ID, diff.created.priceAny().ID)
+	assert.Equal(t, uint(5800), diff.updated.findPrice(2).Price)
+	assert.NotNil(t, diff.logicalDeleted.findPrice(3))
+}
+
+// 価格の一括処理の正常系（atomic）
+func TestBatchPricesAtomic(t *testing.T) {
+	testname := "TestBatchPricesAtomic"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	if _, err := insertPrices(tx, &now, somePrices()); err != nil {
+		t.Fatal(err)
+	}
+
+	// リクエストの生成
+	userId := uint(1)
+	body := `[
+		{"Op":"create", "Price":{"Store":"pcshop", "Product":"ssd1T", "Price":9500}},
+		{"Op":"delete", "ID":3},
+		{"Op":"delete", "ID":4}
+	]`
+	req := newRequest(
+		http.MethodPost,
+		"/v1/prices:batch?atomic=true",
+		&body,
+		echo.MIMEApplicationJSON,
+		genToken(conf, userId),
+	)
+
+	// テストの実行
+	rec, diff, _, err := execHandlerTest(e, testDB, tx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 200, rec.Code)
+
+	res := []api.PriceOperationResult{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, len(res))
+	assert.Equal(t, 424, res[0].Status)
+	assert.Equal(t, 424, res[1].Status)
+	assert.Equal(t, 404, res[2].Status)
+
+	assert.Nil(t, diff) // 全て取り消し
+}
+
+// 価格の一括処理の単位の系統の不一致（atomic）
+func TestBatchPricesAtomicUnitMismatch(t *testing.T) {
+	testname := "TestBatchPricesAtomicUnitMismatch"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	if _, err := insertPrices(tx, &now, somePrices()); err != nil {
+		t.Fatal(err)
+	}
+	const SQL = "INSERT INTO prices (created_at, updated_at, user_id, date_time, store, product, price, quantity, unit) VALUES ($1, $1, $2, $1, $3, $4, $5, $6, $7)"
+	if _, err := tx.Exec(t.Context(), SQL, now, 1, "market", "coffee", 598, 200, "g"); err != nil {
+		t.Fatal(err)
+	}
+
+	// リクエストの生成
+	userId := uint(1)
+	body := `[
+		{"Op":"delete", "ID":3},
+		{"Op":"create", "Price":{"Store":"market", "Product":"coffee", "Price":500, "Quantity":1, "Unit":"L"}},
+		{"Op":"create", "Price":{"Store":"pcshop", "Product":"ssd1T", "Price":9500}}
+	]`
+	req := newRequest(
+		http.MethodPost,
+		"/v1/prices:batch?atomic=true",
+		&body,
+		echo.MIMEApplicationJSON,
+		genToken(conf, userId),
+	)
+
+	// テストの実行
+	rec, diff, _, err := execHandlerTest(e, testDB, tx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 200, rec.Code)
+
+	res := []api.PriceOperationResult{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, len(res))
+	assert.Equal(t, 424, res[0].Status)
+	assert.Equal(t, 400, res[1].Status)
+	if assert.NotNil(t, res[1].Error.Detail) {
+		assert.Equal(t, handler.ErrUnitMismatch.Error(), *res[1].Error.Detail)
+	}
+	assert.Equal(t, 424, res[2].Status)
+
+	assert.Nil(t, diff) // 全て取り消し
+}
+
+// 価格の一括処理の外れ値の拒否（atomic）
+func TestBatchPricesAtomicOutlier(t *testing.T) {
+	testname := "TestBatchPricesAtomicOutlier"
+
+	// セットアップ
+	_, conf, testDB, tx, s, err := setupTestMain(testname, service.NewService)
+	if err != nil {
+		cleanDB(testDB)
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 外れ値を拒否する設定
+	strictConf := *conf
+	strictConf.StrictOutlier = true
+	strict := handler.NewEcho(handler.NewHandler(s, &strictConf))
+
+	// データベースの初期データ生成
+	userId := uint(1)
+	now := time.Now()
+	priceId, err := insertPrice(tx, &now, &now, nil, userId, now, "pcshop", "ssd2T", 12000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []uint{17800, 17500, 18200, 17980, 17600} {
+		if _, err := insertPrice(tx, &now, &now, nil, userId, now, "pcshop", "ssd1T", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// リクエストの生成
+	body := fmt.Sprintf(`[
+		{"Op":"delete", "ID":%d},
+		{"Op":"create", "Price":{"Store":"pcshop", "Product":"ssd1T", "Price":1780}}
+	]`, priceId)
+	req := newRequest(
+		http.MethodPost,
+		"/v1/prices:batch?atomic=true",
+		&body,
+		echo.MIMEApplicationJSON,
+		genToken(conf, userId),
+	)
+
+	// テストの実行
+	rec, diff, _, err := execHandlerTest(strict, testDB, tx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 200, rec.Code)
+
+	res := []api.PriceOperationResult{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(res))
+	assert.Equal(t, 424, res[0].Status)
+	assert.Equal(t, 422, res[1].Status)
+	assert.NotNil(t, res[1].Error.ExpectedRange)
+
+	assert.Nil(t, diff) // 全て取り消し
+}
+
+// 価格の一括処理のバリデーション
+func TestBatchPricesValidation(t *testing.T) {
+	testname := "TestBatchPricesValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	userId := uint(1)
+	now := time.Now()
+	priceId, err := insertPrice(tx, &now, &now, nil, userId, now, "store", "product", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, userId)
+	invalidToken := *jwt + "x"
+	tooMany := "[" + strings.Repeat(`{"Op":"delete", "ID":1},`, 100) + `{"Op":"delete", "ID":1}]`
+	cases := []struct {
+		jwt    *string
+		query  string
+		body   string
+		code   int
+		status []int
+	}{
+		{nil, "", "", 401, nil},
+		{&invalidToken, "", "", 401, nil},
+		{jwt, "", "", 400, nil},
+		{jwt, "", "=", 400, nil},
+		{jwt, "", `[]`, 400, nil},
+		{jwt, "", tooMany, 400, nil},
+		{jwt, "?atomic=x", `[{"Op":"delete", "ID":1}]`, 400, nil},
+		{jwt, "", `[{"Op":"create"}]`, 200, []int{400}},
+		{jwt, "", `[{"Op":"upsert", "ID":1}]`, 200, []int{400}},
+		{jwt, "", `[{"Op":"create", "ID":1, "Price":{"Store":"pcshop", "Product":"ssd2T", "Price":1200}}]`, 200, []int{400}},
+		{jwt, "", `[{"Op":"create", "Price":{"Store":"", "Product":"ssd2T", "Price":1200}}]`, 200, []int{400}},
+		{jwt, "", `[{"Op":"update", "Price":{"Store":"pcshop", "Product":"ssd2T", "Price":1200}}]`, 200, []int{400}},
+		{jwt, "", fmt.Sprintf(`[{"Op":"update", "ID":%d, "Price":{"ID":%d1, "Store":"pcshop", "Product":"ssd2T", "Price":1200}}]`, priceId, priceId), 200, []int{400}},
+		{jwt, "", `[{"Op":"delete"}]`, 200, []int{400}},
+		{jwt, "?atomic=true", `[{"Op":"create", "Price":{"Store":"pcshop", "Product":"ssd2T", "Price":1200}}, {"Op":"delete"}]`, 200, []int{424, 400}},
+		{jwt, "", `[{"Op":"create", "Price":{"Store":"pcshop", "Product":"ssd2T", "Price":1200}}, {"Op":"delete"}]`, 200, []int{201, 400}},
+		{jwt, "", fmt.Sprintf(`[{"Op":"update", "ID":%d, "Price":{"Store":"pcshop", "Product":"ssd2T", "Price":1200}}]`, priceId), 200, []int{200}},
+		{jwt, "", fmt.Sprintf(`[{"Op":"delete", "ID":%d}, {"Op":"delete", "ID":%d}]`, priceId, priceId), 200, []int{204, 404}},
+	}
+
+	for _, v := range cases {
+		// リクエストの生成
+		req := newRequest(
+			http.MethodPost,
+			"/v1/prices:batch"+v.query,
+			&v.body,
+			echo.MIMEApplicationJSON,
+			v.jwt,
+		)
+
+		// テストの実行
+		rec, err := execHandler(e, req)
+
+		// アサーション
+		if err != nil {
+			httpError, ok := err.(*echo.HTTPError)
+			assert.True(t, ok)
+			assert.Equal(t, v.code, httpError.Code)
+			continue
+		}
+		assert.Equal(t, v.code, rec.Code)
+		res := []api.PriceOperationResult{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		status := make([]int, len(res))
+		for i, r := range res {
+			status[i] = r.Status
+		}
+		assert.Equal(t, v.status, status)
+	}
+}
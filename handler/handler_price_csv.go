@@ -0,0 +1,372 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/entity"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/text/encoding/japanese"
+)
+
+const (
+	mimeTextCSV     = "text/csv"
+	csvChunkSize    = 100 // エクスポートの読み込み件数とインポートのコミット件数
+	csvTagSeparator = ";" // 1つの列に複数のタグを並べる区切り
+)
+
+var (
+	priceCSVHeader = []string{"ID", "DateTime", "Store", "Product", "Price", "GTIN", "Quantity", "Unit", "Tags", "PriceType", "ValidFrom", "ValidTo"}
+	utf8BOM        = []byte{0xEF, 0xBB, 0xBF}
+)
+
+// CSVの列の位置（列がない場合は-1）
+type priceCSVColumns struct {
+	dateTime  int
+	store     int
+	product   int
+	price     int
+	gtin      int
+	quantity  int
+	unit      int
+	tags      int
+	priceType int
+	validFrom int
+	validTo   int
+}
+
+// 価格のエクスポート
+func (h *Handler) exportPrices(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+
+	// レスポンスの生成（ストリーミング）
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, mimeTextCSV+"; charset=UTF-8")
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="prices.csv"`)
+	res.WriteHeader(http.StatusOK)
+	w := csv.NewWriter(res)
+	if err := w.Write(priceCSVHeader); err != nil {
+		return err
+	}
+
+	// サービスの実行
+	err := h.service.ExportPrices(ctx, userId, csvChunkSize, func(prices []entity.Price) error {
+		priceIds := make([]uint, len(prices))
+		for i, v := range prices {
+			priceIds[i] = v.ID
+		}
+		tags, err := h.service.FindPriceTags(ctx, priceIds)
+		if err != nil {
+			return err
+		}
+		for _, v := range prices {
+			if err := w.Write(h.priceCSVRecord(&v, tags[v.ID])); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		res.Flush()
+		return w.Error()
+	})
+	w.Flush()
+	if err != nil {
+		return err
+	}
+
+	return w.Error()
+}
+
+// 価格のインポート
+func (h *Handler) importPrices(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	var dryRun bool
+	if err := echo.QueryParamsBinder(c).Bool("dryrun", &dryRun).BindError(); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	mediaType, params, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return err
+	}
+
+	// 入力チェック
+	if mediaType != mimeTextCSV {
+		return newHTTPError(http.StatusUnsupportedMediaType, ErrUnsupportedMediaType)
+	}
+	if body, err = decodeCharset(body, params["charset"]); err != nil {
+		return newHTTPError(http.StatusUnsupportedMediaType, err)
+	}
+	r := csv.NewReader(bytes.NewReader(body))
+	r.FieldsPerRecord = -1 // 列数の過不足は行ごとのエラーにする
+	header, err := r.Read()
+	if err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	columns, err := priceColumns(c, header)
+	if err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 全行の検証（ドライラン）
+	report := &api.PriceImportReport{}
+	var prices []entity.Price
+	var tags [][]string
+	var rows []int // pricesの要素の行番号
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return newHTTPError(http.StatusBadRequest, err)
+		}
+		report.Rows++
+		row, _ := r.FieldPos(0)
+		price, priceTags, params := h.csvRecordToEntity(c, record, columns)
+		if params != nil {
+			report.Errors = append(report.Errors, api.PriceImportError{Row: row, InvalidParams: params})
+			continue
+		}
+		prices = append(prices, *price)
+		tags = append(tags, priceTags)
+		rows = append(rows, row)
+	}
+	if dryRun {
+		return c.JSONPretty(http.StatusOK, report, h.indent)
+	}
+	if report.Errors != nil {
+		return c.JSONPretty(http.StatusBadRequest, report, h.indent)
+	}
+
+	// サービスの実行（失敗した場合は登録済みの件数と失敗したチャンクの行の範囲を返す）
+	if report.Imported, err = h.service.ImportPrices(ctx, userId, prices, tags, csvChunkSize); err != nil {
+		// エラーログ
+		slog.DebugContext(ctx, err.Error())
+
+		if errors.Is(err, service.ErrUnitMismatch) {
+			err = newHTTPError(http.StatusBadRequest, ErrUnitMismatch)
+		}
+		code, res := h.errorResponse(err)
+		report.Failed = &api.PriceImportFailure{
+			FromRow: rows[report.Imported],
+			ToRow:   rows[min(report.Imported+csvChunkSize, len(rows))-1],
+			Error:   res,
+		}
+		return c.JSONPretty(code, report, h.indent)
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusCreated, report, h.indent)
+}
+
+// ヘッダ行から列の位置を決定（クエリパラメータで列名の対応付けを変更可能）
+func priceColumns(c echo.Context, header []string) (*priceCSVColumns, error) {
+	index := func(field string, required bool) (int, error) {
+		name := c.QueryParam(field)
+		if name == "" {
+			name = field
+		}
+		i := slices.IndexFunc(header, func(v string) bool {
+			return strings.EqualFold(strings.TrimSpace(v), name)
+		})
+		if i < 0 && required {
+			return i, fmt.Errorf("%w: %s", ErrColumnNotFound, name)
+		}
+		return i, nil
+	}
+
+	columns := &priceCSVColumns{}
+	var err error
+	if columns.dateTime, err = index("DateTime", false); err != nil {
+		return nil, err
+	}
+	if columns.store, err = index("Store", true); err != nil {
+		return nil, err
+	}
+	if columns.product, err = index("Product", true); err != nil {
+		return nil, err
+	}
+	if columns.price, err = index("Price", true); err != nil {
+		return nil, err
+	}
+	for field, i := range map[string]*int{
+		"GTIN":      &columns.gtin,
+		"Quantity":  &columns.quantity,
+		"Unit":      &columns.unit,
+		"Tags":      &columns.tags,
+		"PriceType": &columns.priceType,
+		"ValidFrom": &columns.validFrom,
+		"ValidTo":   &columns.validTo,
+	} {
+		if *i, err = index(field, false); err != nil {
+			return nil, err
+		}
+	}
+
+	return columns, nil
+}
+
+// エクスポートする価格の行（列の並びはpriceCSVHeader）
+func (h *Handler) priceCSVRecord(price *entity.Price, tags []string) []string {
+	quantity := ""
+	if price.Quantity != 0 {
+		quantity = strconv.FormatFloat(price.Quantity, 'f', -1, 64)
+	}
+	optional := func(dateTime *string) string {
+		if dateTime == nil {
+			return ""
+		}
+		return *dateTime
+	}
+	return []string{
+		strconv.FormatUint(uint64(price.ID), 10),
+		h.formatDateTime(price.DateTime),
+		price.Store,
+		price.Product,
+		strconv.FormatUint(uint64(price.Price), 10),
+		price.GTIN,
+		quantity,
+		price.Unit,
+		strings.Join(tags, csvTagSeparator),
+		responsePriceType(price.PriceType),
+		optional(h.formatOptionalDateTime(price.ValidFrom)),
+		optional(h.formatOptionalDateTime(price.ValidTo)),
+	}
+}
+
+func (h *Handler) csvRecordToEntity(c echo.Context, record []string, columns *priceCSVColumns) (*entity.Price, []string, []api.InvalidParam) {
+	field := func(i int) string {
+		if i < 0 || len(record) <= i {
+			return ""
+		}
+		return record[i]
+	}
+	optional := func(i int) *string {
+		if v := field(i); v != "" {
+			return &v
+		}
+		return nil
+	}
+
+	req := &api.Price{
+		DateTime:  optional(columns.dateTime),
+		Store:     field(columns.store),
+		Product:   field(columns.product),
+		GTIN:      strings.TrimSpace(field(columns.gtin)),
+		Unit:      strings.TrimSpace(field(columns.unit)),
+		PriceType: strings.TrimSpace(field(columns.priceType)),
+		ValidFrom: optional(columns.validFrom),
+		ValidTo:   optional(columns.validTo),
+	}
+	for _, v := range strings.Split(field(columns.tags), csvTagSeparator) {
+		if v = strings.TrimSpace(v); v != "" {
+			req.Tags = append(req.Tags, v)
+		}
+	}
+
+	// 入力チェック（APIと同じく正規化した名前で検証し、メッセージはロケールに合わせて翻訳）
+	var params []api.InvalidParam
+	if err := h.normalizePrice(req); err != nil {
+		for name, v := range map[string]string{"Store": req.Store, "Product": req.Product} {
+			if strings.ContainsFunc(v, unicode.IsControl) {
+				params = append(params, api.InvalidParam{Name: name, Reason: err.Error()})
+			}
+		}
+	}
+	if v := strings.TrimSpace(field(columns.price)); v != "" {
+		price, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			params = append(params, api.InvalidParam{Name: "Price", Reason: h.validator.translate("number", "Price")})
+		}
+		req.Price = uint(price)
+	}
+	if v := strings.TrimSpace(field(columns.quantity)); v != "" {
+		quantity, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			params = append(params, api.InvalidParam{Name: "Quantity", Reason: h.validator.translate("number", "Quantity")})
+		}
+		req.Quantity = quantity
+	}
+	if err := c.Validate(req); err != nil {
+		for _, v := range h.invalidParams(err) {
+			if !slices.ContainsFunc(params, func(p api.InvalidParam) bool { return p.Name == v.Name }) {
+				params = append(params, v)
+			}
+		}
+	}
+	for name, v := range map[string]*string{"DateTime": req.DateTime, "ValidFrom": req.ValidFrom, "ValidTo": req.ValidTo} {
+		if v == nil {
+			continue
+		}
+		if _, err := time.ParseInLocation(h.layout, *v, h.location); err != nil && !slices.ContainsFunc(params, func(p api.InvalidParam) bool { return p.Name == name }) {
+			params = append(params, api.InvalidParam{Name: name, Reason: h.validator.translate("datetime", name, h.layout)})
+		}
+	}
+	if params != nil {
+		slices.SortStableFunc(params, func(a, b api.InvalidParam) int { return strings.Compare(a.Name, b.Name) })
+		return nil, nil, params
+	}
+	dateTime, err := h.parseDateTime(req.DateTime)
+	if err != nil {
+		return nil, nil, []api.InvalidParam{{Name: "DateTime", Reason: h.validator.translate("datetime", "DateTime", h.layout)}}
+	}
+	validFrom, validTo, err := h.parseValidity(req, dateTime)
+	if err != nil {
+		return nil, nil, []api.InvalidParam{{Name: "ValidTo", Reason: ErrValidity.Error()}}
+	}
+
+	return &entity.Price{
+		DateTime:  dateTime,
+		Store:     req.Store,
+		Product:   req.Product,
+		GTIN:      req.GTIN,
+		Price:     req.Price,
+		Quantity:  req.Quantity,
+		Unit:      req.Unit,
+		PriceType: requestPriceType(req.PriceType),
+		ValidFrom: validFrom,
+		ValidTo:   validTo,
+	}, req.Tags, nil
+}
+
+// UTF-8に変換
+func decodeCharset(body []byte, charset string) ([]byte, error) {
+	switch strings.ToLower(charset) {
+	case "":
+		if !utf8.Valid(body) {
+			// 指定がなくUTF-8として不正ならShift_JIS（日本語版Excel）とみなす
+			return japanese.ShiftJIS.NewDecoder().Bytes(body)
+		}
+		return bytes.TrimPrefix(body, utf8BOM), nil
+	case "utf-8":
+		return bytes.TrimPrefix(body, utf8BOM), nil
+	case "shift_jis", "sjis", "windows-31j", "cp932":
+		return japanese.ShiftJIS.NewDecoder().Bytes(body)
+	}
+	return nil, ErrUnsupportedCharset
+}
@@ -0,0 +1,447 @@
+package handler_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// 価格のエクスポートの正常系
+func TestExportPrices(t *testing.T) {
+	testname := "TestExportPrices"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	if _, err := insertPrices(tx, &now, somePrices()); err != nil {
+		t.Fatal(err)
+	}
+
+	// リクエストの生成
+	userId := uint(1)
+	req := newRequest(
+		http.MethodGet,
+		"/v1/prices/export.csv",
+		nil,
+		"",
+		genToken(conf, userId),
+	)
+
+	// テストの実行
+	rec, diff, before, err := execHandlerTest(e, testDB, tx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "text/csv; charset=UTF-8", rec.Header().Get(echo.HeaderContentType))
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, diff)
+
+	assert.Equal(t, []string{"ID", "DateTime", "Store", "Product", "Price", "GTIN", "Quantity", "Unit", "Tags", "PriceType", "ValidFrom", "ValidTo"}, records[0])
+	count := 0
+	for _, v := range before.prices {
+		if v.UserID == userId && !v.DeletedAt.Valid {
+			count++
+		}
+	}
+	assert.Equal(t, count, len(records)-1)
+	for _, v := range records[1:] {
+		id, err := strconv.ParseUint(v[0], 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entity := before.findPrice(uint(id))
+		assert.NotNil(t, entity)
+		assert.False(t, entity.DeletedAt.Valid)
+		assert.Equal(t, userId, entity.UserID)
+		assert.Equal(t, v[1], entity.DateTime.In(conf.Location).Format(conf.DateTimeLayout))
+		assert.Equal(t, v[2], entity.Store)
+		assert.Equal(t, v[3], entity.Product)
+		assert.Equal(t, v[4], strconv.FormatUint(uint64(entity.Price), 10))
+	}
+}
+
+// 価格のインポートの正常系（Shift_JIS、列名の対応付け）
+func TestImportPrices(t *testing.T) {
+	testname := "TestImportPrices"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	if _, err := insertPrices(tx, &now, somePrices()); err != nil {
+		t.Fatal(err)
+	}
+
+	// リクエストの生成
+	userId := uint(1)
+	body, err := japanese.ShiftJIS.NewEncoder().String("日時,店舗,商品,価格\n2023-05-19 12:34:56,パソコン店,ssd1T,9500\n,パソコン店,ssd2T,15800\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := newRequest(
+		http.MethodPost,
+		"/v1/prices/import?DateTime=日時&Store=店舗&Product=商品&Price=価格",
+		&body,
+		"text/csv",
+		genToken(conf, userId),
+	)
+
+	// テストの実行
+	rec, diff, _, err := execHandlerTest(e, testDB, tx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 201, rec.Code)
+
+	res := &api.PriceImportReport{}
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, res.Rows)
+	assert.Equal(t, 2, res.Imported)
+	assert.Nil(t, res.Errors)
+
+	assert.NotNil(t, diff)
+	assert.Equal(t, 1, diff.created.count())
+	assert.Zero(t, diff.updated.count())
+	assert.Zero(t, diff.logicalDeleted.count())
+	assert.Zero(t, diff.physicalDeleted.count())
+
+	assert.Equal(t, 2, len(diff.created.prices))
+	for _, v := range diff.created.prices {
+		assert.Equal(t, userId, v.UserID)
+		assert.Equal(t, "パソコン店", v.Store)
+	}
+}
+
+// エクスポートした価格のインポート（全ての列）
+func TestImportExportedPrices(t *testing.T) {
+	testname := "TestImportExportedPrices"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 価格の登録
+	bodies := []string{
+		`{"DateTime":"2024-05-01 10:00:00", "Store":"super", "Product":"coffee", "GTIN":"4901234567894", "Price":598, "Quantity":200, "Unit":"g", "Tags":["drink", "daily"]}`,
+		`{"DateTime":"2024-05-05 09:00:00", "Store":"super", "Product":"milk", "Price":150, "Quantity":1.5, "Unit":"L", "PriceType":"sale", "ValidFrom":"2024-05-06 00:00:00", "ValidTo":"2024-05-08 00:00:00"}`,
+	}
+	for _, v := range bodies {
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &v, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 201, rec.Code, v)
+	}
+
+	// エクスポート
+	rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/prices/export.csv", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	exported := rec.Body.String()
+
+	// 別のユーザでインポート
+	otherJwt := genToken(conf, 2)
+	rec, err = execHandler(e, newRequest(http.MethodPost, "/v1/prices/import", &exported, "text/csv", otherJwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 201, rec.Code, rec.Body.String()) {
+		t.FailNow()
+	}
+
+	// 全ての項目がインポートされる
+	prices := func(jwt *string) []api.Price {
+		rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/prices", nil, "", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 200, rec.Code)
+		var res []api.Price
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		for i := range res {
+			res[i].ID = nil
+		}
+		return res
+	}
+	expected := prices(jwt)
+	assert.Len(t, expected, 2)
+	assert.ElementsMatch(t, expected, prices(otherJwt))
+}
+
+// 価格のインポートの途中での失敗（失敗したチャンクより前は登録済み）
+func TestImportPricesPartialFailure(t *testing.T) {
+	testname := "TestImportPricesPartialFailure"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// リクエストの生成（2つ目のチャンクの最後の行だけ単位の系統が異なる）
+	var b strings.Builder
+	b.WriteString("Store,Product,Price,Quantity,Unit\n")
+	for range 149 {
+		b.WriteString("super,coffee,598,200,g\n")
+	}
+	b.WriteString("super,coffee,500,1,L\n")
+	body := b.String()
+
+	// テストの実行
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices/import", &body, "text/csv", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 400, rec.Code)
+	res := &api.PriceImportReport{}
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 150, res.Rows)
+	assert.Equal(t, 100, res.Imported)
+	if assert.NotNil(t, res.Failed) {
+		assert.Equal(t, 102, res.Failed.FromRow)
+		assert.Equal(t, 151, res.Failed.ToRow)
+		if assert.NotNil(t, res.Failed.Error.Detail) {
+			assert.Equal(t, handler.ErrUnitMismatch.Error(), *res.Failed.Error.Detail)
+		}
+	}
+
+	rec, err = execHandler(e, newRequest(http.MethodGet, "/v1/prices", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prices []api.Price
+	if err := json.Unmarshal(rec.Body.Bytes(), &prices); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, prices, 100)
+}
+
+// 価格のインポートのドライラン
+func TestImportPricesDryRun(t *testing.T) {
+	testname := "TestImportPricesDryRun"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// リクエストの生成
+	userId := uint(1)
+	body := "Store,Product,Price,DateTime\npcshop,ssd1T,9500,\n,ssd2T,x,\npcshop,ssd4T,29800,2023-05-19\n"
+	req := newRequest(
+		http.MethodPost,
+		"/v1/prices/import?dryrun=true",
+		&body,
+		"text/csv; charset=UTF-8",
+		genToken(conf, userId),
+	)
+
+	// テストの実行
+	rec, diff, _, err := execHandlerTest(e, testDB, tx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 200, rec.Code)
+
+	res := &api.PriceImportReport{}
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, res.Rows)
+	assert.Zero(t, res.Imported)
+	assert.Equal(t, 2, len(res.Errors))
+	assert.Equal(t, 3, res.Errors[0].Row)
+	assert.Equal(t, []api.InvalidParam{
+		{Name: "Price", Reason: "Price must be a valid number"},
+		{Name: "Store", Reason: "Store is a required field"},
+	}, res.Errors[0].InvalidParams)
+	assert.Equal(t, 4, res.Errors[1].Row)
+	assert.Equal(t, []api.InvalidParam{
+		{Name: "DateTime", Reason: "DateTime does not match the 2006-01-02 15:04:05 format"},
+	}, res.Errors[1].InvalidParams)
+
+	assert.Nil(t, diff)
+}
+
+// 価格のインポートのエラーの翻訳
+func TestImportPricesDryRunJa(t *testing.T) {
+	testname := "TestImportPricesDryRunJa"
+
+	// セットアップ
+	_, conf, testDB, tx, s, err := setupTestMain(testname, service.NewService)
+	if err != nil {
+		cleanDB(testDB)
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	jaConf := *conf
+	jaConf.Locale = "ja"
+	e := handler.NewEcho(handler.NewHandler(s, &jaConf))
+
+	// リクエストの生成
+	body := "Store,Product,Price,DateTime\n,ssd2T,x,\npcshop,ssd4T,29800,2023-05-19\n"
+	req := newRequest(
+		http.MethodPost,
+		"/v1/prices/import?dryrun=true",
+		&body,
+		"text/csv; charset=UTF-8",
+		genToken(conf, 1),
+	)
+
+	// テストの実行
+	rec, err := execHandler(e, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 200, rec.Code)
+	res := &api.PriceImportReport{}
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 2, len(res.Errors)) {
+		t.FailNow()
+	}
+	assert.Equal(t, []api.InvalidParam{
+		{Name: "Price", Reason: "Priceは正しい数でなければなりません"},
+		{Name: "Store", Reason: "Storeは必須フィールドです"},
+	}, res.Errors[0].InvalidParams)
+	assert.Equal(t, []api.InvalidParam{
+		{Name: "DateTime", Reason: "DateTimeは2006-01-02 15:04:05の書式と一致しません"},
+	}, res.Errors[1].InvalidParams)
+}
+
+// 価格のインポートのバリデーション
+func TestImportPricesValidation(t *testing.T) {
+	testname := "TestImportPricesValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	userId := uint(1)
+	jwt := genToken(conf, userId)
+	invalidToken := *jwt + "x"
+	cases := []struct {
+		jwt         *string
+		query       string
+		contentType string
+		body        string
+		code        int
+		err         error
+	}{
+		{nil, "", "text/csv", "", 401, nil},
+		{&invalidToken, "", "text/csv", "", 401, nil},
+		{jwt, "", echo.MIMEApplicationJSON, "Store,Product,Price\n", 415, handler.ErrUnsupportedMediaType},
+		{jwt, "", "text/csv; charset=EUC-JP", "Store,Product,Price\n", 415, handler.ErrUnsupportedCharset},
+		{jwt, "", "text/csv", "", 400, nil},
+		{jwt, "?dryrun=x", "text/csv", "Store,Product,Price\n", 400, nil},
+		{jwt, "", "text/csv", "Store,Price\n", 400, handler.ErrColumnNotFound},
+		{jwt, "?Product=name", "text/csv", "Store,Product,Price\n", 400, handler.ErrColumnNotFound},
+		{jwt, "", "text/csv", "Store,Product,Price\n\"pcshop,ssd1T,9500\n", 400, nil},
+		{jwt, "", "text/csv", "Store,Product,Price\npcshop,ssd1T\n", 400, nil},
+		{jwt, "", "text/csv", "Store,Product,Price\n", 201, nil},
+		{jwt, "", "text/csv", "\ufeffstore,product,price\npcshop,ssd1T,9500\n", 201, nil},
+	}
+
+	for _, v := range cases {
+		// リクエストの生成
+		req := newRequest(
+			http.MethodPost,
+			"/v1/prices/import"+v.query,
+			&v.body,
+			v.contentType,
+			v.jwt,
+		)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code)
+		if v.err != nil {
+			assert.True(t, errors.Is(cause, v.err))
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// 有効期間と種類のある価格
+func TestFindEffectivePrices(t *testing.T) {
+	testname := "TestFindEffectivePrices"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 登録
+	bodies := []string{
+		`{"DateTime":"2024-04-01 10:00:00", "Store":"market", "Product":"milk", "Price":210}`,
+		`{"DateTime":"2024-05-01 10:00:00", "Store":"market", "Product":"milk", "Price":180, "PriceType":"member"}`,
+		`{"DateTime":"2024-05-01 10:00:00", "Store":"super", "Product":"milk", "Price":200}`,
+		`{"DateTime":"2024-05-05 09:00:00", "Store":"super", "Product":"milk", "Price":150, "PriceType":"sale", "ValidFrom":"2024-05-06 00:00:00", "ValidTo":"2024-05-08 00:00:00"}`,
+		`{"DateTime":"2024-05-10 10:00:00", "Store":"super", "Product":"milk", "Price":205}`,
+	}
+	created := make([]*api.Price, len(bodies))
+	for i, v := range bodies {
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &v, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assert.Equal(t, 201, rec.Code, v) {
+			t.FailNow()
+		}
+		price := &api.Price{}
+		if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+			t.Fatal(err)
+		}
+		created[i] = price
+	}
+	assert.Equal(t, "", created[0].PriceType) // 通常価格は省略
+	assert.Nil(t, created[0].ValidFrom)
+	assert.Equal(t, "sale", created[3].PriceType)
+	if assert.NotNil(t, created[3].ValidFrom) && assert.NotNil(t, created[3].ValidTo) {
+		assert.Equal(t, "2024-05-06 00:00:00", *created[3].ValidFrom)
+		assert.Equal(t, "2024-05-08 00:00:00", *created[3].ValidTo)
+	}
+
+	// 有効な価格（店舗、商品の順）
+	effective := func(query string) []uint {
+		rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/prices/effective?"+query, nil, "", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 200, rec.Code, query)
+		var prices []*api.Price
+		if err := json.Unmarshal(rec.Body.Bytes(), &prices); err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]uint, len(prices))
+		for i, v := range prices {
+			ids[i] = *v.ID
+		}
+		return ids
+	}
+	at := func(dateTime string) string {
+		return "at=" + url.QueryEscape(dateTime)
+	}
+	tests := []struct {
+		query string
+		want  []uint
+	}{
+		{at("2024-05-05 12:00:00"), []uint{*created[1].ID, *created[2].ID}},                   // セールの開始前
+		{at("2024-05-07 12:00:00"), []uint{*created[1].ID, *created[3].ID}},                   // セールが通常価格より優先
+		{at("2024-05-08 00:00:00"), []uint{*created[1].ID, *created[2].ID}},                   // 終わりは含まない
+		{at("2024-05-12 00:00:00"), []uint{*created[1].ID, *created[4].ID}},                   // 新しい通常価格
+		{at("2024-04-15 00:00:00"), []uint{*created[0].ID}},                                   // 登録前の価格はない
+		{at("2024-05-07 12:00:00") + "&type=regular", []uint{*created[0].ID, *created[2].ID}}, // 種類の指定
+		{at("2024-05-07 12:00:00") + "&type=member&type=sale", []uint{*created[1].ID, *created[3].ID}},
+		{"type=member", []uint{*created[1].ID}}, // 省略時は現在日時
+	}
+	for _, v := range tests {
+		assert.Equal(t, v.want, effective(v.query), v.query)
+	}
+
+	// 種類と有効期間の変更
+	patchPath := fmt.Sprintf("/v1/prices/%d", *created[2].ID)
+	patch := `{"PriceType":"sale", "ValidTo":"2024-05-03 00:00:00"}`
+	req := newRequest(http.MethodPatch, patchPath, &patch, "application/merge-patch+json", jwt)
+	rec, err := execHandler(e, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 200, rec.Code) {
+		t.FailNow()
+	}
+	patched := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), patched); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "sale", patched.PriceType)
+	assert.Nil(t, patched.ValidFrom)
+	if assert.NotNil(t, patched.ValidTo) {
+		assert.Equal(t, "2024-05-03 00:00:00", *patched.ValidTo)
+	}
+	assert.Equal(t, []uint{*created[1].ID}, effective(at("2024-05-05 12:00:00")))
+}
+
+// 有効期間と種類のある価格の入力チェック
+func TestFindEffectivePricesValidation(t *testing.T) {
+	testname := "TestFindEffectivePricesValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 価格の登録
+	body := `{"DateTime":"2024-05-01 10:00:00", "Store":"super", "Product":"milk", "Price":200}`
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 201, rec.Code) {
+		t.FailNow()
+	}
+	price := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+		t.Fatal(err)
+	}
+	pricePath := fmt.Sprintf("/v1/prices/%d", *price.ID)
+
+	cases := []struct {
+		method      string
+		target      string
+		body        string
+		contentType string
+		code        int
+		err         error
+	}{
+		{http.MethodGet, "/v1/prices/effective?type=bulk", "", "", 400, handler.ErrPriceType},
+		{http.MethodGet, "/v1/prices/effective?at=2024-05-07", "", "", 400, nil},
+		{http.MethodPost, "/v1/prices", `{"Store":"super", "Product":"milk", "Price":200, "PriceType":"bulk"}`, echo.MIMEApplicationJSON, 400, nil},
+		{http.MethodPost, "/v1/prices", `{"DateTime":"2024-05-01 10:00:00", "Store":"super", "Product":"milk", "Price":200, "ValidFrom":"2024-05-08 00:00:00", "ValidTo":"2024-05-08 00:00:00"}`, echo.MIMEApplicationJSON, 400, handler.ErrValidity},
+		{http.MethodPost, "/v1/prices", `{"DateTime":"2024-05-01 10:00:00", "Store":"super", "Product":"milk", "Price":200, "ValidTo":"2024-04-30 00:00:00"}`, echo.MIMEApplicationJSON, 400, handler.ErrValidity},
+		{http.MethodPost, "/v1/prices", `{"Store":"super", "Product":"milk", "Price":200, "ValidFrom":"2024-05-08"}`, echo.MIMEApplicationJSON, 400, nil},
+		{http.MethodPut, pricePath, `{"DateTime":"2024-05-01 10:00:00", "Store":"super", "Product":"milk", "Price":200, "ValidTo":"2024-04-30 00:00:00"}`, echo.MIMEApplicationJSON, 400, handler.ErrValidity},
+		{http.MethodPatch, pricePath, `{"ValidTo":"2024-05-01 10:00:00"}`, "application/merge-patch+json", 400, handler.ErrValidity},
+		{http.MethodPatch, pricePath, `{"ValidFrom":"2024-05-08 00:00:00", "ValidTo":"2024-05-07 00:00:00"}`, "application/merge-patch+json", 400, handler.ErrValidity},
+		{http.MethodPatch, pricePath, `{"PriceType":"bulk"}`, "application/merge-patch+json", 400, nil},
+	}
+	for _, v := range cases {
+		// リクエストの生成
+		var body *string
+		if v.body != "" {
+			body = &v.body
+		}
+		req := newRequest(v.method, v.target, body, v.contentType, jwt)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.method+" "+v.target+" "+v.body)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.method+" "+v.target+" "+v.body)
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package handler_test
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"testing"
 	"time"
 
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
@@ -376,6 +380,46 @@ func TestUpdatePriceCommitError(t *testing.T) {
 	assert.Nil(t, diff)
 }
 
+// 価格の部分更新の更新件数異常
+func TestPatchPriceRowsAffectedError(t *testing.T) {
+	testname := "TestPatchPriceRowsAffectedError"
+
+	// セットアップ
+	e, conf, testDB, tx, mock, err := setupMockTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// mockの挙動設定
+	mock.repository.price.rowsAffected, mock.repository.price.overwirte = 2, true
+
+	// データベースの初期データ生成
+	now := time.Now()
+	if _, err := insertPrices(tx, &now, somePrices()); err != nil {
+		t.Fatal(err)
+	}
+
+	// リクエストの生成
+	userId := uint(1)
+	priceId := uint(1)
+	body := `{"Store":"pcshop"}`
+	req := newRequest(
+		http.MethodPatch,
+		fmt.Sprintf("/v1/prices/%d", priceId),
+		&body,
+		"application/merge-patch+json",
+		genToken(conf, userId),
+	)
+
+	// テストの実行
+	_, diff, _, err := execHandlerTest(e, testDB, tx, req)
+
+	// アサーション
+	assert.EqualError(t, errors.Unwrap(err), fmt.Sprintf("RowsAffected:%d", mock.repository.price.rowsAffected))
+	assert.Nil(t, diff)
+}
+
 // 価格の削除のリポジトリエラー
 func TestDeletePriceDeleteError(t *testing.T) {
 	testname := "TestDeletePriceDeleteError"
@@ -531,3 +575,94 @@ func TestDeletePriceCommitError(t *testing.T) {
 	assert.Equal(t, mock.repository.commitErr, err)
 	assert.Nil(t, diff)
 }
+
+// If-Matchを指定しない部分更新と他の更新の競合
+func TestPatchPriceConcurrentUpdate(t *testing.T) {
+	testname := "TestPatchPriceConcurrentUpdate"
+
+	// セットアップ
+	e, conf, testDB, tx, mock, err := setupMockTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	priceId, err := insertPrice(tx, &now, &now, nil, 1, now, "pcshop", "ssd1T", 9500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	target := fmt.Sprintf("/v1/prices/%d", priceId)
+
+	// mockの挙動設定（パッチを適用する価格の取得の後に他の更新を割り込ませる）
+	interrupt := func(fire func(call int) bool) {
+		call := 0
+		mock.repository.price.afterFind = func() {
+			call++
+			if !fire(call) {
+				return
+			}
+			if _, err := testDB.pool.Exec(t.Context(), "UPDATE prices SET price = price - 100, version = version + 1 WHERE id = $1", priceId); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// 1回だけ競合すると最新の価格にパッチを適用し直す（他の更新を上書きしない）
+	interrupt(func(call int) bool { return call == 1 })
+	body := `{"Store":"pcstore"}`
+	rec, err := execHandler(e, newRequest(http.MethodPatch, target, &body, "application/merge-patch+json", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	patched := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), patched); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "pcstore", patched.Store)
+	assert.Equal(t, uint(9400), patched.Price)
+
+	// testの操作も最新の価格で判定
+	interrupt(func(call int) bool { return call == 1 })
+	body = `[{"op":"test", "path":"/Price", "value":9400}, {"op":"replace", "path":"/Store", "value":"pcshop"}]`
+	code, _, err := execHandlerValidation(e, newRequest(http.MethodPatch, target, &body, "application/json-patch+json", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 400, code)
+
+	// 競合が続くと409（トランザクション外の取得のたびに割り込ませる）
+	interrupt(func(call int) bool { return call%2 == 1 })
+	body = `{"Store":"pcshop"}`
+	code, cause, err := execHandlerValidation(e, newRequest(http.MethodPatch, target, &body, "application/merge-patch+json", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 409, code)
+	assert.Equal(t, handler.ErrPatchConflict, cause)
+
+	// If-Matchを指定した場合はやり直さずに412
+	mock.repository.price.afterFind = nil
+	rec, err = execHandler(e, newRequest(http.MethodGet, target, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	interrupt(func(call int) bool { return call == 1 })
+	req := newRequest(http.MethodPatch, target, &body, "application/merge-patch+json", jwt)
+	req.Header.Set("If-Match", rec.Header().Get("ETag"))
+	code, cause, err = execHandlerValidation(e, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 412, code)
+	assert.Equal(t, handler.ErrPreconditionFailed, cause)
+}
@@ -0,0 +1,409 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// 店舗と商品名の正規化
+func TestNormalizePrice(t *testing.T) {
+	testname := "TestNormalizePrice"
+
+	// セットアップ
+	e, conf, testDB, tx, s, err := setupTestMain(testname, service.NewService)
+	if err != nil {
+		cleanDB(testDB)
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 正規化する設定
+	normalizeConf := *conf
+	normalizeConf.NormalizeText = true
+	normalize := handler.NewEcho(handler.NewHandler(s, &normalizeConf))
+
+	jwt := genToken(conf, 1)
+
+	send := func(e *echo.Echo, method, target, store, product string) (int, *api.Price) {
+		body := fmt.Sprintf(`{"DateTime":"2024-05-01 10:00:00", "Store":"%s", "Product":"%s", "Price":198}`, store, product)
+		rec, err := execHandler(e, newRequest(method, target, &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusCreated && rec.Code != http.StatusOK {
+			return rec.Code, nil
+		}
+		res := &api.Price{}
+		if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, res
+	}
+
+	// 全角英数字、半角カナ、空白の連続
+	code, price := send(normalize, http.MethodPost, "/v1/prices", "　ＳＵＰＥＲ　　ｍａｒｔ ", "ｷﾞｭｳﾆｭｳ  １Ｌ")
+	if !assert.Equal(t, 201, code) {
+		t.FailNow()
+	}
+	assert.Equal(t, "SUPER mart", price.Store)
+	assert.Equal(t, "ギュウニュウ 1L", price.Product)
+
+	// 更新も正規化
+	code, updated := send(normalize, http.MethodPut, fmt.Sprintf("/v1/prices/%d", *price.ID), "SUPER mart", "ＭＩＬＫ　1L")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, "MILK 1L", updated.Product)
+
+	// 正規化しない設定では入力のまま
+	code, price = send(e, http.MethodPost, "/v1/prices", "ＳＵＰＥＲ", "ｷﾞｭｳﾆｭｳ")
+	assert.Equal(t, 201, code)
+	assert.Equal(t, "ＳＵＰＥＲ", price.Store)
+	assert.Equal(t, "ｷﾞｭｳﾆｭｳ", price.Product)
+
+	// 文字数は正規化後の文字で数える（半角カナの濁点は1文字）
+	long := strings.Repeat("ｶﾞ", 100)
+	code, price = send(normalize, http.MethodPost, "/v1/prices", "super", long)
+	assert.Equal(t, 201, code)
+	assert.Equal(t, strings.Repeat("ガ", 100), price.Product)
+	code, _ = send(e, http.MethodPost, "/v1/prices", "super", long)
+	assert.Equal(t, 400, code)
+
+	// 入力チェック
+	validations := []struct {
+		method  string
+		target  string
+		store   string
+		product string
+		err     error
+	}{
+		{http.MethodPost, "/v1/prices", "super", `milk\u0007`, handler.ErrControlCharacter},
+		{http.MethodPost, "/v1/prices", `super\tmart`, "milk", handler.ErrControlCharacter},
+		{http.MethodPost, "/v1/prices", "super", "　 ", nil}, // 正規化すると空
+		{http.MethodPut, fmt.Sprintf("/v1/prices/%d", *updated.ID), "super", `milk\n`, handler.ErrControlCharacter},
+	}
+	for _, v := range validations {
+		body := fmt.Sprintf(`{"Store":"%s", "Product":"%s", "Price":198}`, v.store, v.product)
+		code, cause, err := execHandlerValidation(normalize, newRequest(v.method, v.target, &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 400, code, body)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, body)
+		}
+	}
+}
+
+// インポートする価格の店舗と商品名の正規化
+func TestNormalizeImportedPrices(t *testing.T) {
+	testname := "TestNormalizeImportedPrices"
+
+	// セットアップ
+	_, conf, testDB, tx, s, err := setupTestMain(testname, service.NewService)
+	if err != nil {
+		cleanDB(testDB)
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 正規化する設定
+	normalizeConf := *conf
+	normalizeConf.NormalizeText = true
+	normalize := handler.NewEcho(handler.NewHandler(s, &normalizeConf))
+
+	jwt := genToken(conf, 1)
+
+	importPrices := func(query, body string) (int, *api.PriceImportReport) {
+		rec, err := execHandler(normalize, newRequest(http.MethodPost, "/v1/prices/import"+query, &body, "text/csv", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := &api.PriceImportReport{}
+		if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, res
+	}
+
+	// APIと同じく正規化して登録
+	code, _ := importPrices("", "Store,Product,Price\n　ＳＵＰＥＲ　　ｍａｒｔ ,ｷﾞｭｳﾆｭｳ  １Ｌ,198\n")
+	assert.Equal(t, 201, code)
+	rec, err := execHandler(normalize, newRequest(http.MethodGet, "/v1/prices", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prices []api.Price
+	if err := json.Unmarshal(rec.Body.Bytes(), &prices); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, prices, 1) {
+		assert.Equal(t, "SUPER mart", prices[0].Store)
+		assert.Equal(t, "ギュウニュウ 1L", prices[0].Product)
+	}
+
+	// 制御文字を含む行はエラー
+	code, report := importPrices("?dryrun=true", "Store,Product,Price\nsuper,\"milk\n1L\",198\n")
+	assert.Equal(t, 200, code)
+	if assert.Len(t, report.Errors, 1) {
+		assert.Equal(t, []api.InvalidParam{
+			{Name: "Product", Reason: handler.ErrControlCharacter.Error()},
+		}, report.Errors[0].InvalidParams)
+	}
+}
+
+// 登録済みの価格の店舗と商品名の正規化
+func TestNormalizePriceNames(t *testing.T) {
+	testname := "TestNormalizePriceNames"
+
+	// セットアップ
+	_, _, testDB, tx, mock, err := setupMockTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	milkId, err := insertPrice(tx, &now, &now, nil, 1, now, "ＳＵＰＥＲ", "ｷﾞｭｳﾆｭｳ　１Ｌ", 198)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deletedId, err := insertPrice(tx, &now, &now, &now, 1, now, " pcshop ", "ssd1T", 9500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalizedId, err := insertPrice(tx, &now, &now, nil, 1, now, "pcshop", "ssd2T", 15800)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blankId, err := insertPrice(tx, &now, &now, nil, 2, now, "pcshop", "　", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const aliasSQL = "INSERT INTO product_aliases (created_at, updated_at, user_id, alias, product) VALUES ($1, $1, $2, $3, $4)"
+	if _, err := tx.Exec(t.Context(), aliasSQL, now, 1, "ギュウニュウ 1L", "牛乳 1L"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	testDB.conn.Release()
+
+	// テストの実行（2件ずつコミット）
+	rows, err := mock.NormalizePriceNames(t.Context(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, int64(2), rows)
+
+	tests := []struct {
+		id      uint
+		store   string
+		product string
+		version int
+	}{
+		{milkId, "SUPER", "牛乳 1L", 2}, // 正規化した商品名が別名なら正式な商品名
+		{deletedId, "pcshop", "ssd1T", 2},
+		{normalizedId, "pcshop", "ssd2T", 1},
+		{blankId, "pcshop", "　", 1}, // 正規化で空になる名前は変更しない
+	}
+	for _, v := range tests {
+		var store, product string
+		var version int
+		if err := testDB.pool.QueryRow(t.Context(), "SELECT store, product, version FROM prices WHERE id = $1", v.id).Scan(&store, &product, &version); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, v.store, store, v.id)
+		assert.Equal(t, v.product, product, v.id)
+		assert.Equal(t, v.version, version, v.id)
+	}
+
+	// 変更した価格は更新として変更履歴に記録
+	var revisions int
+	if err := testDB.pool.QueryRow(t.Context(), "SELECT count(*) FROM price_revisions WHERE operation = 'update' AND price_id IN ($1, $2)", milkId, deletedId).Scan(&revisions); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, revisions)
+	var unchanged int
+	if err := testDB.pool.QueryRow(t.Context(), "SELECT count(*) FROM price_revisions WHERE price_id IN ($1, $2)", normalizedId, blankId).Scan(&unchanged); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, unchanged)
+}
+
+// 価格と照合する登録済みの名前の正規化
+func TestNormalizeRelatedNames(t *testing.T) {
+	testname := "TestNormalizeRelatedNames"
+
+	// セットアップ
+	_, _, testDB, tx, mock, err := setupMockTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	inserts := []struct {
+		sql  string
+		args []any
+	}{
+		{"INSERT INTO product_aliases (created_at, updated_at, user_id, alias, product) VALUES ($1, $1, $2, $3, $4)", []any{now, 1, "ｷﾞｭｳﾆｭｳ　１Ｌ", "牛乳　1L"}},
+		{"INSERT INTO stores (created_at, updated_at, user_id, name, latitude, longitude, address) VALUES ($1, $1, $2, $3, 0, 0, '')", []any{now, 1, "ＳＵＰＥＲ"}},
+		{"INSERT INTO stores (created_at, updated_at, user_id, name, latitude, longitude, address) VALUES ($1, $1, $2, $3, 0, 0, '')", []any{now, 1, "SUPER"}},
+		{"INSERT INTO stores (created_at, updated_at, user_id, name, latitude, longitude, address) VALUES ($1, $1, $2, $3, 0, 0, '')", []any{now, 2, " mart "}},
+		{"INSERT INTO alert_rules (created_at, updated_at, user_id, product, store, target_price) VALUES ($1, $1, $2, $3, $4, 100)", []any{now, 1, "ｷﾞｭｳﾆｭｳ 1L", "ＳＵＰＥＲ"}},
+		{"INSERT INTO shopping_list_items (created_at, updated_at, list_id, user_id, product, quantity, checked, position) VALUES ($1, $1, 1, $2, $3, 1, false, 0)", []any{now, 1, "牛乳　1L"}},
+		{"INSERT INTO index_basket_items (basket_id, user_id, product, weight) VALUES (1, $1, $2, 1)", []any{1, "　"}},
+	}
+	for _, v := range inserts {
+		if _, err := tx.Exec(t.Context(), v.sql, v.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	testDB.conn.Release()
+
+	// テストの実行
+	rows, err := mock.NormalizeRelatedNames(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, int64(6), rows)
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"SELECT alias || ':' || product FROM product_aliases ORDER BY id", []string{"ギュウニュウ 1L:牛乳 1L"}},
+		{"SELECT name FROM stores ORDER BY id", []string{"ＳＵＰＥＲ", "SUPER", "mart"}},              // 一意の名前が重複する場合は変更しない
+		{"SELECT product || ':' || store FROM alert_rules ORDER BY id", []string{"牛乳 1L:SUPER"}}, // 別名なら正式な商品名
+		{"SELECT product FROM shopping_list_items ORDER BY id", []string{"牛乳 1L"}},
+		{"SELECT product FROM index_basket_items ORDER BY id", []string{"　"}}, // 正規化で空になる名前は変更しない
+	}
+	for _, v := range tests {
+		pgRows, err := testDB.pool.Query(t.Context(), v.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		for pgRows.Next() {
+			var name string
+			if err := pgRows.Scan(&name); err != nil {
+				t.Fatal(err)
+			}
+			actual = append(actual, name)
+		}
+		pgRows.Close()
+		assert.Equal(t, v.expected, actual, v.query)
+	}
+}
+
+// 商品名で検索や登録をするときの正規化
+func TestNormalizeProductLookups(t *testing.T) {
+	testname := "TestNormalizeProductLookups"
+
+	// セットアップ
+	_, conf, testDB, tx, s, err := setupTestMain(testname, service.NewService)
+	if err != nil {
+		cleanDB(testDB)
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 正規化する設定
+	normalizeConf := *conf
+	normalizeConf.NormalizeText = true
+	normalize := handler.NewEcho(handler.NewHandler(s, &normalizeConf))
+
+	jwt := genToken(conf, 1)
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		var reqBody *string
+		if body != "" {
+			reqBody = &body
+		}
+		rec, err := execHandler(normalize, newRequest(method, target, reqBody, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	// 正規化して登録した価格
+	rec := send(http.MethodPost, "/v1/prices", `{"DateTime":"2024-05-01 10:00:00", "Store":"super", "Product":"ｷﾞｭｳﾆｭｳ １Ｌ", "Price":198}`)
+	if !assert.Equal(t, 201, rec.Code) {
+		t.FailNow()
+	}
+
+	// パスの商品名も正規化して比較
+	rec = send(http.MethodGet, "/v1/products/"+url.PathEscape("ｷﾞｭｳﾆｭｳ　１Ｌ")+"/compare", "")
+	assert.Equal(t, 200, rec.Code)
+	comparison := &api.ProductComparison{}
+	if err := json.Unmarshal(rec.Body.Bytes(), comparison); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "ギュウニュウ 1L", comparison.Product)
+	assert.Len(t, comparison.Prices, 1)
+
+	// 制御文字を含む商品名は存在しない
+	rec = send(http.MethodGet, "/v1/products/"+url.PathEscape("milk\t1L")+"/compare", "")
+	assert.Equal(t, 404, rec.Code)
+
+	// 通知ルールの商品名と店舗
+	rec = send(http.MethodPost, "/v1/alerts", `{"Product":"ｷﾞｭｳﾆｭｳ  1L", "Store":"ＳＵＰＥＲ", "TargetPrice":200}`)
+	if assert.Equal(t, 201, rec.Code) {
+		rule := &api.AlertRule{}
+		if err := json.Unmarshal(rec.Body.Bytes(), rule); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "ギュウニュウ 1L", rule.Product)
+		if assert.NotNil(t, rule.Store) {
+			assert.Equal(t, "SUPER", *rule.Store)
+		}
+	}
+
+	// 別名と正式な商品名
+	rec = send(http.MethodPost, "/v1/products/aliases", `{"Alias":"ｷﾞｭｳﾆｭｳ １Ｌ", "Product":"牛乳　１Ｌ"}`)
+	if assert.Equal(t, 201, rec.Code) {
+		alias := &api.ProductAlias{}
+		if err := json.Unmarshal(rec.Body.Bytes(), alias); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "ギュウニュウ 1L", alias.Alias)
+		assert.Equal(t, "牛乳 1L", alias.Product)
+	}
+}
@@ -0,0 +1,221 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// 過去の価格から外れた価格の判定
+func TestPriceOutlier(t *testing.T) {
+	testname := "TestPriceOutlier"
+
+	// セットアップ
+	e, conf, testDB, tx, s, err := setupTestMain(testname, service.NewService)
+	if err != nil {
+		cleanDB(testDB)
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 外れ値を拒否する設定
+	strictConf := *conf
+	strictConf.StrictOutlier = true
+	strict := handler.NewEcho(handler.NewHandler(s, &strictConf))
+
+	jwt := genToken(conf, 1)
+
+	post := func(e *echo.Echo, target string, price uint) (int, *api.Price, *api.ErrorResponse) {
+		body := fmt.Sprintf(`{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":%d}`, price)
+		rec, err := execHandler(e, newRequest(http.MethodPost, target, &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusCreated {
+			res := &api.ErrorResponse{}
+			if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+				t.Fatal(err)
+			}
+			return rec.Code, nil, res
+		}
+		res := &api.Price{}
+		if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, res, nil
+	}
+
+	// 件数が足りないうちは判定しない
+	for _, v := range []uint{17800, 17500, 18200, 17980, 178000} {
+		code, price, _ := post(e, "/v1/prices", v)
+		assert.Equal(t, 201, code)
+		assert.False(t, price.Suspicious)
+	}
+
+	// 外れ値は疑いを記録して登録
+	code, outlier, _ := post(e, "/v1/prices", 1780)
+	assert.Equal(t, 201, code)
+	assert.True(t, outlier.Suspicious)
+	code, price, _ := post(e, "/v1/prices", 17600)
+	assert.Equal(t, 201, code)
+	assert.False(t, price.Suspicious)
+
+	// 更新で範囲内になれば疑いを解除
+	body := `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":17800}`
+	rec, err := execHandler(e, newRequest(http.MethodPut, fmt.Sprintf("/v1/prices/%d", *outlier.ID), &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	updated := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), updated); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, updated.Suspicious)
+
+	// 厳格モードでは想定の範囲を返して拒否
+	code, _, res := post(strict, "/v1/prices", 1780)
+	assert.Equal(t, 422, code)
+	if assert.NotNil(t, res.ExpectedRange) {
+		assert.Less(t, res.ExpectedRange.Min, uint(17500))
+		assert.Greater(t, res.ExpectedRange.Max, uint(18200))
+		assert.Greater(t, res.ExpectedRange.Min, uint(1780))
+	}
+	code, latest, _ := post(strict, "/v1/prices", 17700)
+	assert.Equal(t, 201, code)
+
+	// 確認済みなら疑いを記録して登録
+	code, confirmed, _ := post(strict, "/v1/prices?confirm=true", 1780)
+	assert.Equal(t, 201, code)
+	assert.True(t, confirmed.Suspicious)
+
+	// 比較では疑いのある価格を除外できる
+	for _, v := range []struct {
+		target   string
+		expected uint
+	}{
+		{"/v1/products/ssd1T/compare?maxAge=3650", *confirmed.ID},
+		{"/v1/products/ssd1T/compare?maxAge=3650&excludeSuspicious=true", *latest.ID},
+	} {
+		rec, err := execHandler(e, newRequest(http.MethodGet, v.target, nil, "", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 200, rec.Code)
+		comparison := &api.ProductComparison{}
+		if err := json.Unmarshal(rec.Body.Bytes(), comparison); err != nil {
+			t.Fatal(err)
+		}
+		if assert.Len(t, comparison.Prices, 1, v.target) {
+			assert.Equal(t, v.expected, *comparison.Prices[0].ID, v.target)
+		}
+	}
+}
+
+// 内容量がある価格は単価で外れ値を判定
+func TestPriceOutlierUnitPrice(t *testing.T) {
+	testname := "TestPriceOutlierUnitPrice"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	post := func(price uint, quantity string) bool {
+		body := fmt.Sprintf(`{"DateTime":"2023-05-19 12:34:56", "Store":"super", "Product":"rice", "Price":%d%s}`, price, quantity)
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 201, rec.Code, body)
+		res := &api.Price{}
+		if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+			t.Fatal(err)
+		}
+		return res.Suspicious
+	}
+
+	for _, v := range []uint{2000, 2080, 1980, 2050, 2100} {
+		assert.False(t, post(v, `, "Quantity":5, "Unit":"kg"`))
+	}
+
+	cases := []struct {
+		price      uint
+		quantity   string
+		suspicious bool
+	}{
+		{800, `, "Quantity":2000, "Unit":"g"`, false}, // 単価は同じ
+		{2000, `, "Quantity":10, "Unit":"kg"`, true},  // 単価が半分
+		{500, "", false}, // 内容量がない価格は同じ単位と内容量の価格がまだない
+	}
+	for _, v := range cases {
+		assert.Equal(t, v.suspicious, post(v.price, v.quantity), v.price)
+	}
+}
+
+// インポートした価格の外れ値の判定
+func TestImportPriceOutlier(t *testing.T) {
+	testname := "TestImportPriceOutlier"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// テストの実行（同じチャンクの価格も含めて判定）
+	body := "DateTime,Store,Product,Price\n"
+	for _, v := range []uint{17800, 17500, 18200, 17980, 17600, 1780} {
+		body += fmt.Sprintf("2023-05-19 12:34:56,pcshop,ssd1T,%d\n", v)
+	}
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices/import", &body, "text/csv", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 201, rec.Code)
+
+	// アサーション
+	rec, err = execHandler(e, newRequest(http.MethodGet, "/v1/prices", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	var prices []api.Price
+	if err := json.Unmarshal(rec.Body.Bytes(), &prices); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, prices, 6)
+	for _, v := range prices {
+		assert.Equal(t, v.Price == 1780, v.Suspicious, v.Price)
+	}
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/entity"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 価格の変更履歴の一覧
+func (h *Handler) findPriceRevisions(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	priceId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	entities, err := h.service.FindPriceRevisions(ctx, uint(priceId), userId)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成（古い順）
+	var lastModified time.Time
+	if len(entities) > 0 {
+		lastModified = entities[len(entities)-1].CreatedAt
+	}
+	if notModified(c, revisionsETag(entities), lastModified) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	revisions := make([]*api.PriceRevision, len(entities))
+	for i, v := range entities {
+		revisions[i] = &api.PriceRevision{
+			ID:        v.ID,
+			PriceID:   v.PriceID,
+			UserID:    v.UserID,
+			Operation: v.Operation,
+			CreatedAt: h.formatDateTime(v.CreatedAt),
+			Before:    h.priceValuesToResponse(v.Before),
+			After:     h.priceValuesToResponse(v.After),
+		}
+	}
+
+	return c.JSONPretty(http.StatusOK, revisions, h.indent)
+}
+
+// 価格を変更履歴の時点に戻す
+func (h *Handler) revertPrice(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	reqRevision := c.Param("revision")
+
+	// 入力チェック
+	priceId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	revisionId, err := strconv.ParseUint(reqRevision, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	ifMatch, err := parseIfMatch(c)
+	if err != nil {
+		return err
+	}
+
+	// サービスの実行
+	price, err := h.service.RevertPrice(ctx, uint(priceId), uint(revisionId), userId, ifMatch)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		if errors.Is(err, service.ErrNotRevertible) {
+			return newHTTPError(http.StatusBadRequest, ErrNotRevertible)
+		}
+		if errors.Is(err, service.ErrPreconditionFailed) {
+			return newHTTPError(http.StatusPreconditionFailed, ErrPreconditionFailed)
+		}
+		if errors.Is(err, service.ErrUnitMismatch) {
+			return newHTTPError(http.StatusBadRequest, ErrUnitMismatch)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	res, err := h.priceResponse(ctx, price)
+	if err != nil {
+		return err
+	}
+	setPriceETag(c, price)
+	return c.JSONPretty(http.StatusOK, res, h.indent)
+}
+
+func (h *Handler) priceValuesToResponse(values *entity.PriceValues) *api.PriceValues {
+	if values == nil {
+		return nil
+	}
+	return &api.PriceValues{
+		DateTime: h.formatDateTime(values.DateTime),
+		Store:    values.Store,
+		Product:  values.Product,
+		GTIN:     values.GTIN,
+		Price:    values.Price,
+		Quantity: values.Quantity,
+		Unit:     values.Unit,
+
+		PriceType: responsePriceType(values.PriceType),
+		ValidFrom: h.formatOptionalDateTime(values.ValidFrom),
+		ValidTo:   h.formatOptionalDateTime(values.ValidTo),
+
+		Tags: values.Tags,
+	}
+}
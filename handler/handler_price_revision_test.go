@@ -0,0 +1,299 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// 価格の変更履歴の一覧と復元の正常系
+func TestPriceRevisions(t *testing.T) {
+	testname := "TestPriceRevisions"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	userId := uint(1)
+	jwt := genToken(conf, userId)
+
+	// 価格の登録
+	body := `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9500, "Tags":["sale"]}`
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), created); err != nil {
+		t.Fatal(err)
+	}
+	priceId := *created.ID
+
+	// 価格の更新
+	body = `{"DateTime":"2023-05-20 10:00:00", "Store":"pcshop", "Product":"ssd1T", "Price":8900, "Tags":["sale"]}`
+	if _, err := execHandler(e, newRequest(http.MethodPut, fmt.Sprintf("/v1/prices/%d", priceId), &body, echo.MIMEApplicationJSON, jwt)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 価格の部分更新
+	body = `{"Store":"pcstore"}`
+	if _, err := execHandler(e, newRequest(http.MethodPatch, fmt.Sprintf("/v1/prices/%d", priceId), &body, "application/merge-patch+json", jwt)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 変更履歴の一覧
+	rec, err = execHandler(e, newRequest(http.MethodGet, fmt.Sprintf("/v1/prices/%d/revisions", priceId), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	revisions := []api.PriceRevision{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &revisions); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, len(revisions))
+	for _, v := range revisions {
+		assert.Equal(t, priceId, v.PriceID)
+		assert.Equal(t, userId, v.UserID)
+	}
+	assert.Equal(t, "create", revisions[0].Operation)
+	assert.Nil(t, revisions[0].Before)
+	assert.Equal(t, &api.PriceValues{DateTime: "2023-05-19 12:34:56", Store: "pcshop", Product: "ssd1T", Price: 9500, Tags: []string{"sale"}}, revisions[0].After)
+	assert.Equal(t, "update", revisions[1].Operation)
+	assert.Equal(t, &api.PriceValues{DateTime: "2023-05-19 12:34:56", Store: "pcshop", Product: "ssd1T", Price: 9500}, revisions[1].Before) // 変更前のタグはタグの変更だけ記録
+	assert.Equal(t, &api.PriceValues{DateTime: "2023-05-20 10:00:00", Store: "pcshop", Product: "ssd1T", Price: 8900, Tags: []string{"sale"}}, revisions[1].After)
+	assert.Equal(t, "update", revisions[2].Operation)
+	assert.Equal(t, &api.PriceValues{DateTime: "2023-05-20 10:00:00", Store: "pcshop", Product: "ssd1T", Price: 8900}, revisions[2].Before)
+	assert.Equal(t, "pcstore", revisions[2].After.Store)
+
+	// 登録時の状態に戻す
+	rec, err = execHandler(e, newRequest(http.MethodPost, fmt.Sprintf("/v1/prices/%d/revisions/%d/revert", priceId, revisions[0].ID), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	reverted := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), reverted); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, *created, *reverted)
+	assert.Equal(t, []string{"sale"}, reverted.Tags)
+
+	// 価格の削除
+	if _, err := execHandler(e, newRequest(http.MethodDelete, fmt.Sprintf("/v1/prices/%d", priceId), nil, "", jwt)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 変更履歴は同じトランザクションで記録
+	var operations []string
+	rows, err := testDB.pool.Query(t.Context(), "SELECT operation FROM price_revisions WHERE price_id = $1 ORDER BY id", priceId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var operation string
+		if err := rows.Scan(&operation); err != nil {
+			t.Fatal(err)
+		}
+		operations = append(operations, operation)
+	}
+	assert.Equal(t, []string{"create", "update", "update", "revert", "delete"}, operations)
+}
+
+// 価格の変更履歴の一覧のバリデーション
+func TestFindPriceRevisionsValidation(t *testing.T) {
+	testname := "TestFindPriceRevisionsValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	userId := uint(1)
+	now := time.Now()
+	priceId, err := insertPrice(tx, &now, &now, nil, userId, now, "store", "product", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, userId)
+	invalidToken := *jwt + "x"
+	priceIdStr := strconv.FormatUint(uint64(priceId), 10)
+	cases := []struct {
+		jwt     *string
+		priceId string
+		code    int
+		err     error
+	}{
+		{nil, priceIdStr, 401, nil},
+		{&invalidToken, priceIdStr, 401, nil},
+		{jwt, priceIdStr, 200, nil},
+		{genToken(conf, userId+1), priceIdStr, 404, handler.ErrNotFound},
+		{jwt, priceIdStr + "1", 404, handler.ErrNotFound},
+		{jwt, "a", 404, handler.ErrNotFound},
+	}
+
+	for _, v := range cases {
+		// リクエストの生成
+		req := newRequest(
+			http.MethodGet,
+			fmt.Sprintf("/v1/prices/%s/revisions", v.priceId),
+			nil,
+			"",
+			v.jwt,
+		)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause)
+		}
+	}
+}
+
+// 価格を変更履歴の時点に戻す操作のバリデーション
+func TestRevertPriceValidation(t *testing.T) {
+	testname := "TestRevertPriceValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	// データの準備
+	userId := uint(1)
+	jwt := genToken(conf, userId)
+	body := `{"Store":"pcshop", "Product":"ssd1T", "Price":9500}`
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	price := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+		t.Fatal(err)
+	}
+	priceIdStr := strconv.FormatUint(uint64(*price.ID), 10)
+	rec, err = execHandler(e, newRequest(http.MethodGet, fmt.Sprintf("/v1/prices/%s/revisions", priceIdStr), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	revisions := []api.PriceRevision{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &revisions); err != nil {
+		t.Fatal(err)
+	}
+	revisionIdStr := strconv.FormatUint(uint64(revisions[0].ID), 10)
+
+	// 単位の系統が異なる時点（gで登録してLに変更した後に同じ商品をLで登録）
+	body = `{"Store":"cafe", "Product":"coffee", "Price":500, "Quantity":200, "Unit":"g"}`
+	if rec, err = execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt)); err != nil {
+		t.Fatal(err)
+	}
+	coffee := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), coffee); err != nil {
+		t.Fatal(err)
+	}
+	coffeeIdStr := strconv.FormatUint(uint64(*coffee.ID), 10)
+	rec, err = execHandler(e, newRequest(http.MethodGet, fmt.Sprintf("/v1/prices/%s/revisions", coffeeIdStr), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &revisions); err != nil {
+		t.Fatal(err)
+	}
+	coffeeRevisionIdStr := strconv.FormatUint(uint64(revisions[0].ID), 10)
+	for _, v := range []struct{ method, target string }{
+		{http.MethodPut, "/v1/prices/" + coffeeIdStr},
+		{http.MethodPost, "/v1/prices"},
+	} {
+		body = `{"Store":"cafe", "Product":"coffee", "Price":500, "Quantity":1, "Unit":"L"}`
+		if _, err := execHandler(e, newRequest(v.method, v.target, &body, echo.MIMEApplicationJSON, jwt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	invalidToken := *jwt + "x"
+	cases := []struct {
+		jwt        *string
+		priceId    string
+		revisionId string
+		ifMatch    string
+		code       int
+		err        error
+	}{
+		{nil, priceIdStr, revisionIdStr, "", 401, nil},
+		{&invalidToken, priceIdStr, revisionIdStr, "", 401, nil},
+		{genToken(conf, userId+1), priceIdStr, revisionIdStr, "", 404, handler.ErrNotFound},
+		{jwt, priceIdStr + "1", revisionIdStr, "", 404, handler.ErrNotFound},
+		{jwt, "a", revisionIdStr, "", 404, handler.ErrNotFound},
+		{jwt, priceIdStr, revisionIdStr + "1", "", 404, handler.ErrNotFound},
+		{jwt, priceIdStr, "a", "", 404, handler.ErrNotFound},
+		{jwt, priceIdStr, revisionIdStr, `"2"`, 412, handler.ErrPreconditionFailed},
+		{jwt, coffeeIdStr, coffeeRevisionIdStr, "", 400, handler.ErrUnitMismatch},
+		{jwt, priceIdStr, revisionIdStr, `"1"`, 200, nil},
+	}
+
+	for _, v := range cases {
+		// リクエストの生成
+		req := newRequest(
+			http.MethodPost,
+			fmt.Sprintf("/v1/prices/%s/revisions/%s/revert", v.priceId, v.revisionId),
+			nil,
+			"",
+			v.jwt,
+		)
+		if v.ifMatch != "" {
+			req.Header.Set("If-Match", v.ifMatch)
+		}
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause)
+		}
+	}
+}
@@ -525,6 +525,207 @@ func TestUpdatePriceValidation(t *testing.T) {
 	}
 }
 
+// 価格の部分更新の正常系（JSON Merge Patch）
+func TestPatchPrice(t *testing.T) {
+	testname := "TestPatchPrice"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	if _, err := insertPrices(tx, &now, somePrices()); err != nil {
+		t.Fatal(err)
+	}
+
+	// リクエストの生成
+	userId := uint(1)
+	priceId := uint(1)
+	store := "pcshop"
+	body := fmt.Sprintf(`{"Store":"%s"}`, store)
+	req := newRequest(
+		http.MethodPatch,
+		fmt.Sprintf("/v1/prices/%d", priceId),
+		&body,
+		"application/merge-patch+json",
+		genToken(conf, userId),
+	)
+
+	// テストの実行
+	rec, diff, before, err := execHandlerTest(e, testDB, tx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 200, rec.Code)
+
+	res := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, priceId, *res.ID)
+	assert.Equal(t, store, res.Store)
+
+	assert.NotNil(t, diff)
+	assert.Zero(t, diff.created.count())
+	assert.Equal(t, 1, diff.updated.count())
+	assert.Zero(t, diff.logicalDeleted.count())
+	assert.Zero(t, diff.physicalDeleted.count())
+
+	assert.Equal(t, 1, len(diff.updated.prices))
+	entity := diff.updated.priceAny() // just one
+	beforeEntity := before.findPrice(priceId)
+	assert.Equal(t, priceId, entity.ID)
+	assert.Equal(t, beforeEntity.CreatedAt, entity.CreatedAt)
+	assert.False(t, entity.DeletedAt.Valid)
+	assert.Equal(t, userId, entity.UserID)
+	assert.Equal(t, beforeEntity.DateTime, entity.DateTime) // 日時は変わらない
+	assert.Equal(t, store, entity.Store)
+	assert.Equal(t, beforeEntity.Product, entity.Product)
+	assert.Equal(t, beforeEntity.Price, entity.Price)
+}
+
+// 価格の部分更新の正常系（JSON Patch）
+func TestPatchPriceJSONPatch(t *testing.T) {
+	testname := "TestPatchPriceJSONPatch"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	if _, err := insertPrices(tx, &now, somePrices()); err != nil {
+		t.Fatal(err)
+	}
+
+	// リクエストの生成
+	userId := uint(1)
+	priceId := uint(1)
+	price := uint(3500)
+	body := fmt.Sprintf(`[{"op":"replace", "path":"/Price", "value":%d}]`, price)
+	req := newRequest(
+		http.MethodPatch,
+		fmt.Sprintf("/v1/prices/%d", priceId),
+		&body,
+		"application/json-patch+json",
+		genToken(conf, userId),
+	)
+
+	// テストの実行
+	rec, diff, before, err := execHandlerTest(e, testDB, tx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 200, rec.Code)
+
+	res := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, price, res.Price)
+
+	assert.NotNil(t, diff)
+	assert.Equal(t, 1, len(diff.updated.prices))
+	entity := diff.updated.priceAny() // just one
+	beforeEntity := before.findPrice(priceId)
+	assert.Equal(t, beforeEntity.DateTime, entity.DateTime)
+	assert.Equal(t, beforeEntity.Store, entity.Store)
+	assert.Equal(t, beforeEntity.Product, entity.Product)
+	assert.Equal(t, price, entity.Price)
+}
+
+// 価格の部分更新のバリデーション
+func TestPatchPriceValidation(t *testing.T) {
+	testname := "TestPatchPriceValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	userId := uint(1)
+	now := time.Now()
+	priceId, err := insertPrice(tx, &now, &now, nil, userId, now, "store", "product", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, userId)
+	invalidToken := *jwt + "x"
+	priceIdStr := strconv.FormatUint(uint64(priceId), 10)
+	const mergePatch, jsonPatch = "application/merge-patch+json", "application/json-patch+json"
+	cases := []struct {
+		jwt         *string
+		priceId     string
+		contentType string
+		body        string
+		code        int
+		err         error
+	}{
+		{nil, priceIdStr, mergePatch, "", 401, nil},
+		{&invalidToken, priceIdStr, mergePatch, "", 401, nil},
+		{jwt, priceIdStr, echo.MIMEApplicationJSON, `{"Store":"pcshop"}`, 415, handler.ErrUnsupportedMediaType},
+		{jwt, priceIdStr, mergePatch, "", 400, nil},
+		{jwt, priceIdStr, mergePatch, "=", 400, nil},
+		{jwt, priceIdStr, mergePatch, `{}`, 200, nil},
+		{jwt, priceIdStr, mergePatch, `{"Store":"pcshop"}`, 200, nil},
+		{jwt, priceIdStr, mergePatch, `{"DateTime":"2023-05-15 12:15:30", "Price":1200}`, 200, nil},
+		{jwt, priceIdStr, mergePatch, `{"DateTime":"2023-05-15"}`, 400, nil},
+		{jwt, priceIdStr, mergePatch, `{"DateTime":null}`, 400, handler.ErrDateTimeRequired},
+		{jwt, priceIdStr, mergePatch, `{"Store":""}`, 400, nil},
+		{jwt, priceIdStr, mergePatch, `{"Product":null}`, 400, nil},
+		{jwt, priceIdStr, mergePatch, fmt.Sprintf(`{"ID":%s1}`, priceIdStr), 400, handler.ErrIDUnchangeable},
+		{jwt, priceIdStr, jsonPatch, `[{"op":"replace", "path":"/Product", "value":"ssd2T"}]`, 200, nil},
+		{jwt, priceIdStr, jsonPatch, `[{"op":"remove", "path":"/Price"}]`, 400, nil},
+		{jwt, priceIdStr, jsonPatch, `[{"op":"test", "path":"/Store", "value":"other"}]`, 400, nil},
+		{jwt, priceIdStr, jsonPatch, `{"Store":"pcshop"}`, 400, nil},
+		{jwt, priceIdStr + "1", mergePatch, `{"Store":"pcshop"}`, 404, handler.ErrNotFound},
+		{jwt, "a", mergePatch, `{"Store":"pcshop"}`, 404, handler.ErrNotFound},
+	}
+
+	for _, v := range cases {
+		// リクエストの生成
+		req := newRequest(
+			http.MethodPatch,
+			fmt.Sprintf("/v1/prices/%s", v.priceId),
+			&v.body,
+			v.contentType,
+			v.jwt,
+		)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause)
+		}
+	}
+}
+
 // 価格の削除の正常系
 func TestDeletePrice(t *testing.T) {
 	testname := "TestDeletePrice"
@@ -0,0 +1,165 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// 内容量と単価
+func TestPriceUnit(t *testing.T) {
+	testname := "TestPriceUnit"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	cases := []struct {
+		body      string
+		unitPrice float64 // 0は単価なし
+		basis     string
+	}{
+		{`{"DateTime":"2023-05-19 12:34:56", "Store":"market", "Product":"coffee", "Price":598, "Quantity":200, "Unit":"g"}`, 299.0, "100g"},
+		{`{"DateTime":"2023-05-20 12:34:56", "Store":"super", "Product":"coffee", "Price":1280, "Quantity":0.5, "Unit":"kg"}`, 256.0, "100g"},
+		{`{"DateTime":"2023-05-21 12:34:56", "Store":"market", "Product":"milk", "Price":238, "Quantity":1000, "Unit":"ml"}`, 238.0, "1L"},
+		{`{"DateTime":"2023-05-22 12:34:56", "Store":"market", "Product":"egg", "Price":250, "Quantity":6, "Unit":"piece"}`, 41.67, "1piece"},
+		{`{"DateTime":"2023-05-23 12:34:56", "Store":"market", "Product":"bread", "Price":180}`, 0, ""},
+	}
+	ids := make([]uint, len(cases))
+	for i, v := range cases {
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &v.body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 201, rec.Code, v.body)
+		price := &api.Price{}
+		if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+			t.Fatal(err)
+		}
+		if v.unitPrice == 0 {
+			assert.Nil(t, price.UnitPrice, v.body)
+		} else if assert.NotNil(t, price.UnitPrice, v.body) {
+			assert.Equal(t, v.unitPrice, *price.UnitPrice, v.body)
+		}
+		assert.Equal(t, v.basis, price.UnitPriceBasis, v.body)
+		ids[i] = *price.ID
+	}
+
+	// 単価の昇順（内容量のない価格は末尾）
+	rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/prices?sort=unitprice", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	var prices []*api.Price
+	if err := json.Unmarshal(rec.Body.Bytes(), &prices); err != nil {
+		t.Fatal(err)
+	}
+	actual := make([]uint, len(prices))
+	for i, v := range prices {
+		actual[i] = *v.ID
+	}
+	assert.Equal(t, []uint{ids[3], ids[2], ids[1], ids[0], ids[4]}, actual)
+
+	// 同じ系統なら単位が異なっても登録できる
+	body := `{"DateTime":"2023-05-24 12:34:56", "Store":"market", "Product":"coffee", "Price":500, "Quantity":150, "Unit":"g"}`
+	rec, err = execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 201, rec.Code)
+
+	// 部分更新で内容量を外す
+	body = `{"Quantity":null, "Unit":null}`
+	rec, err = execHandler(e, newRequest(http.MethodPatch, fmt.Sprintf("/v1/prices/%d", ids[2]), &body, "application/merge-patch+json", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "UnitPrice")
+}
+
+// 内容量と単価のバリデーション
+func TestPriceUnitValidation(t *testing.T) {
+	testname := "TestPriceUnitValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	const SQL = "INSERT INTO prices (created_at, updated_at, user_id, date_time, store, product, price, quantity, unit) VALUES ($1, $1, $2, $1, $3, $4, $5, $6, $7) RETURNING id"
+	if _, err := tx.Exec(t.Context(), SQL, now, 1, "market", "coffee", 598, 200, "g"); err != nil {
+		t.Fatal(err)
+	}
+	var milkId uint
+	if err := tx.QueryRow(t.Context(), SQL, now, 1, "market", "milk", 238, 1000, "ml").Scan(&milkId); err != nil {
+		t.Fatal(err)
+	}
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	cases := []struct {
+		method      string
+		target      string
+		body        string
+		contentType string
+		code        int
+		err         error
+	}{
+		{http.MethodPost, "/v1/prices", `{"Store":"market", "Product":"coffee", "Price":500, "Quantity":1, "Unit":"L"}`, echo.MIMEApplicationJSON, 400, handler.ErrUnitMismatch}, // 単位の系統が異なる
+		{http.MethodPatch, fmt.Sprintf("/v1/prices/%d", milkId), `{"Product":"coffee"}`, "application/merge-patch+json", 400, handler.ErrUnitMismatch},
+		{http.MethodPost, "/v1/prices", `{"Store":"market", "Product":"tea", "Price":300, "Quantity":100}`, echo.MIMEApplicationJSON, 400, nil},
+		{http.MethodPost, "/v1/prices", `{"Store":"market", "Product":"tea", "Price":300, "Unit":"g"}`, echo.MIMEApplicationJSON, 400, nil},
+		{http.MethodPost, "/v1/prices", `{"Store":"market", "Product":"tea", "Price":300, "Quantity":-1, "Unit":"g"}`, echo.MIMEApplicationJSON, 400, nil},
+		{http.MethodPost, "/v1/prices", `{"Store":"market", "Product":"tea", "Price":300, "Quantity":100, "Unit":"oz"}`, echo.MIMEApplicationJSON, 400, nil},
+		{http.MethodGet, "/v1/prices?sort=price", "", "", 400, handler.ErrSort},
+	}
+
+	for _, v := range cases {
+		// リクエストの生成
+		var body *string
+		if v.body != "" {
+			body = &v.body
+		}
+		req := newRequest(v.method, v.target, body, v.contentType, jwt)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.target+" "+v.body)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.target+" "+v.body)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/ystkg/rest-example/api"
+
+	"github.com/labstack/echo/v4"
+)
+
+// バーコードによる商品と価格の履歴の取得
+func (h *Handler) findProductByGTIN(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	gtin := c.Param("gtin")
+
+	// 入力チェック
+	if !validGTIN(gtin) {
+		return newHTTPError(http.StatusBadRequest, ErrGTIN)
+	}
+
+	// サービスの実行
+	entities, err := h.service.FindPricesByGTIN(ctx, userId, gtin)
+	if err != nil {
+		return err
+	}
+	if len(entities) == 0 {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// レスポンスの生成
+	prices, err := h.pricesResponse(ctx, entities)
+	if err != nil {
+		return err
+	}
+
+	return h.revalidateJSON(c, &api.Product{
+		GTIN:    gtin,
+		Product: entities[0].Product,
+		Prices:  prices,
+	})
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/entity"
+	"github.com/ystkg/rest-example/repository"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+const duplicateThreshold = 0.25 // 重複の疑いとする距離のデフォルト
+
+// 商品名の別名の登録
+func (h *Handler) createProductAlias(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	req := &api.ProductAlias{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック（正規化した名前で検証）
+	if err := h.normalizeNames(&req.Alias, &req.Product); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if err := c.Validate(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if req.ID != nil {
+		return newHTTPError(http.StatusBadRequest, ErrIDCannotRequest)
+	}
+
+	// サービスの実行
+	alias, err := h.service.CreateProductAlias(ctx, &entity.ProductAlias{
+		UserID:  userId,
+		Alias:   req.Alias,
+		Product: req.Product,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrProductAliasSelf) {
+			return newHTTPError(http.StatusBadRequest, ErrProductAliasSelf)
+		}
+		if errors.Is(err, repository.ErrDuplicated) {
+			return newHTTPError(http.StatusBadRequest, ErrProductAliasExists)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusCreated, productAliasToResponse(alias), h.indent)
+}
+
+// 商品名の別名の一覧
+func (h *Handler) findProductAliases(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+
+	// サービスの実行
+	aliases, err := h.service.FindProductAliases(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成
+	res := make([]*api.ProductAlias, len(aliases))
+	for i := range aliases {
+		res[i] = productAliasToResponse(&aliases[i])
+	}
+
+	return h.revalidateJSON(c, res)
+}
+
+// 商品名の別名の削除
+func (h *Handler) deleteProductAlias(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	aliasId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	if err = h.service.DeleteProductAlias(ctx, uint(aliasId), userId); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.NoContent(http.StatusNoContent)
+}
+
+// 商品の統合
+func (h *Handler) mergeProduct(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	req := &api.ProductMerge{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック（正規化した名前で検証）
+	if err := h.normalizeNames(&req.Product, &req.Into); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if err := c.Validate(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if req.Product == req.Into {
+		return newHTTPError(http.StatusBadRequest, ErrProductMergeSelf)
+	}
+
+	// サービスの実行
+	into, merged, err := h.service.MergeProduct(ctx, userId, req.Product, req.Into)
+	if err != nil {
+		if errors.Is(err, service.ErrProductMergeSelf) {
+			return newHTTPError(http.StatusBadRequest, ErrProductMergeSelf)
+		}
+		if errors.Is(err, service.ErrUnitMismatch) {
+			return newHTTPError(http.StatusBadRequest, ErrUnitMismatch)
+		}
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusOK, &api.ProductMergeResult{Product: into, Merged: merged}, h.indent)
+}
+
+// 重複の疑いがある商品
+func (h *Handler) findDuplicateProducts(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	threshold := duplicateThreshold
+	if err := echo.QueryParamsBinder(c).Float64("threshold", &threshold).BindError(); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック（NaNも範囲外として扱う）
+	if !(0 <= threshold && threshold < 1) {
+		return newHTTPError(http.StatusBadRequest, ErrDuplicateThreshold)
+	}
+
+	// サービスの実行
+	duplicates, err := h.service.FindDuplicateProducts(ctx, userId, threshold)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成
+	res := make([]api.ProductDuplicate, len(duplicates))
+	for i, v := range duplicates {
+		res[i] = api.ProductDuplicate{
+			Product:   v.Product,
+			Into:      v.Into,
+			Distance:  math.Round(v.Distance*1000) / 1000,
+			Count:     v.Count,
+			IntoCount: v.IntoCount,
+		}
+	}
+
+	return h.revalidateJSON(c, res)
+}
+
+func productAliasToResponse(alias *entity.ProductAlias) *api.ProductAlias {
+	return &api.ProductAlias{
+		ID:      &alias.ID,
+		Alias:   alias.Alias,
+		Product: alias.Product,
+	}
+}
@@ -0,0 +1,331 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// 商品名の別名
+func TestProductAlias(t *testing.T) {
+	testname := "TestProductAlias"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 別名の登録（別名の別名は正式な商品名の別名）
+	createProductAlias(t, e, jwt, "牛乳1L", "牛乳 1L")
+	createProductAlias(t, e, jwt, "ｷﾞｭｳﾆｭｳ", "ギュウニュウ")
+	assert.Equal(t, "牛乳 1L", createProductAlias(t, e, jwt, "ギュウニュウ", "牛乳1L").Product)
+
+	// 別名の一覧（別名を商品名にしていた別名も付け替え）
+	assert.ElementsMatch(t, []string{"牛乳1L->牛乳 1L", "ギュウニュウ->牛乳 1L", "ｷﾞｭｳﾆｭｳ->牛乳 1L"}, findProductAliases(t, e, jwt))
+
+	// 別名で登録すると正式な商品名
+	assert.Equal(t, "牛乳 1L", postProductPrice(t, e, jwt, "ｷﾞｭｳﾆｭｳ", 199).Product)
+
+	// 別名の削除（以降の登録では置き換えない）
+	alias := createProductAlias(t, e, jwt, "brd", "bread")
+	assert.Equal(t, "bread", postProductPrice(t, e, jwt, "brd", 180).Product)
+	rec, err := execHandler(e, newRequest(http.MethodDelete, fmt.Sprintf("/v1/products/aliases/%d", *alias.ID), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 204, rec.Code)
+	assert.Equal(t, "brd", postProductPrice(t, e, jwt, "brd", 180).Product)
+	assert.Len(t, findProductAliases(t, e, jwt), 3)
+}
+
+// 商品の統合
+func TestMergeProduct(t *testing.T) {
+	testname := "TestMergeProduct"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 価格の登録
+	postProductPrice(t, e, jwt, "牛乳 1L", 198)
+	postProductPrice(t, e, jwt, "牛乳 1L", 200)
+	merged := postProductPrice(t, e, jwt, "牛乳1L", 205)
+	postProductPrice(t, e, jwt, "ギュウニュウ", 210)
+	postProductPrice(t, e, jwt, "ギュウニュウ", 208)
+	postProductPrice(t, e, jwt, "ｷﾞｭｳﾆｭｳ", 199)
+
+	// 統合
+	assert.Equal(t, &api.ProductMergeResult{Product: "牛乳 1L", Merged: 1}, mergeProduct(t, e, jwt, "牛乳1L", "牛乳 1L"))
+	rec, err := execHandler(e, newRequest(http.MethodGet, fmt.Sprintf("/v1/prices/%d", *merged.ID), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	price := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "牛乳 1L", price.Product)
+
+	// 統合は更新として変更履歴に記録
+	rec, err = execHandler(e, newRequest(http.MethodGet, fmt.Sprintf("/v1/prices/%d/revisions", *merged.ID), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	revisions := []api.PriceRevision{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &revisions); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Equal(t, 2, len(revisions)) {
+		assert.Equal(t, "update", revisions[1].Operation)
+		assert.Equal(t, "牛乳1L", revisions[1].Before.Product)
+		assert.Equal(t, "牛乳 1L", revisions[1].After.Product)
+	}
+
+	// 統合元の商品名で登録すると統合先の商品名
+	assert.Equal(t, "牛乳 1L", postProductPrice(t, e, jwt, "牛乳1L", 202).Product)
+	assert.Equal(t, []string{"牛乳1L->牛乳 1L"}, findProductAliases(t, e, jwt))
+
+	// 別名を商品名にしている価格の統合（統合先が別名なら正式な商品名）
+	createProductAlias(t, e, jwt, "ｷﾞｭｳﾆｭｳ", "ギュウニュウ")
+	assert.Equal(t, &api.ProductMergeResult{Product: "牛乳 1L", Merged: 2}, mergeProduct(t, e, jwt, "ギュウニュウ", "牛乳1L"))
+	assert.Equal(t, &api.ProductMergeResult{Product: "牛乳 1L", Merged: 1}, mergeProduct(t, e, jwt, "ｷﾞｭｳﾆｭｳ", "牛乳 1L"))
+	assert.ElementsMatch(t, []string{"牛乳1L->牛乳 1L", "ギュウニュウ->牛乳 1L", "ｷﾞｭｳﾆｭｳ->牛乳 1L"}, findProductAliases(t, e, jwt))
+}
+
+// 重複の疑いがある商品
+func TestFindDuplicateProducts(t *testing.T) {
+	testname := "TestFindDuplicateProducts"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 価格の登録
+	postProductPrice(t, e, jwt, "牛乳 1L", 198)
+	postProductPrice(t, e, jwt, "牛乳 1L", 200)
+	postProductPrice(t, e, jwt, "牛乳1L", 205)
+	postProductPrice(t, e, jwt, "ギュウニュウ", 210)
+	postProductPrice(t, e, jwt, "ギュウニュウ", 208)
+	postProductPrice(t, e, jwt, "ｷﾞｭｳﾆｭｳ", 199)
+	postProductPrice(t, e, jwt, "bread", 180)
+	postProductPrice(t, e, jwt, "bread", 185)
+	postProductPrice(t, e, jwt, "breads", 190)
+
+	// 重複の疑い
+	breads := api.ProductDuplicate{Product: "breads", Into: "bread", Distance: 0.167, Count: 1, IntoCount: 2}
+	assert.ElementsMatch(t, []api.ProductDuplicate{
+		{Product: "牛乳1L", Into: "牛乳 1L", Distance: 0, Count: 1, IntoCount: 2},
+		{Product: "ｷﾞｭｳﾆｭｳ", Into: "ギュウニュウ", Distance: 0, Count: 1, IntoCount: 2},
+		breads,
+	}, findProductDuplicates(t, e, jwt, ""))
+	assert.Len(t, findProductDuplicates(t, e, jwt, "?threshold=0"), 2)
+
+	// 統合した商品は対象外
+	mergeProduct(t, e, jwt, "牛乳1L", "牛乳 1L")
+	mergeProduct(t, e, jwt, "ｷﾞｭｳﾆｭｳ", "ギュウニュウ")
+	assert.Equal(t, []api.ProductDuplicate{breads}, findProductDuplicates(t, e, jwt, ""))
+}
+
+// 商品名の別名と商品の統合の入力チェック
+func TestProductAliasValidation(t *testing.T) {
+	testname := "TestProductAliasValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 価格と別名の登録
+	postProductPrice(t, e, jwt, "bread", 180)
+	postProductPrice(t, e, jwt, "牛乳 1L", 198)
+	alias := createProductAlias(t, e, jwt, "牛乳1L", "牛乳 1L")
+	for _, body := range []string{
+		`{"Store":"super", "Product":"flour", "Price":300, "Quantity":1, "Unit":"kg"}`,
+		`{"Store":"super", "Product":"oil", "Price":400, "Quantity":1, "Unit":"L"}`,
+	} {
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assert.Equal(t, 201, rec.Code, body) {
+			t.FailNow()
+		}
+	}
+
+	cases := []struct {
+		method string
+		target string
+		body   string
+		jwt    *string
+		code   int
+		err    error
+	}{
+		{http.MethodPost, "/v1/products/merge", `{"Product":"bread", "Into":"bread"}`, jwt, 400, handler.ErrProductMergeSelf},
+		{http.MethodPost, "/v1/products/merge", `{"Product":"牛乳 1L", "Into":"牛乳1L"}`, jwt, 400, handler.ErrProductMergeSelf},
+		{http.MethodPost, "/v1/products/merge", `{"Product":"tea", "Into":"bread"}`, jwt, 404, handler.ErrNotFound},
+		{http.MethodPost, "/v1/products/merge", `{"Product":"bread", "Into":"tea"}`, genToken(conf, 2), 404, handler.ErrNotFound},
+		{http.MethodPost, "/v1/products/merge", `{"Product":"", "Into":"bread"}`, jwt, 400, nil},
+		{http.MethodPost, "/v1/products/merge", `{"Product":"flour", "Into":"oil"}`, jwt, 400, handler.ErrUnitMismatch}, // 単位の系統が異なる
+		{http.MethodPost, "/v1/products/aliases", `{"Alias":"牛乳1L", "Product":"bread"}`, jwt, 400, handler.ErrProductAliasExists},
+		{http.MethodPost, "/v1/products/aliases", `{"Alias":"tea", "Product":"tea"}`, jwt, 400, handler.ErrProductAliasSelf},
+		{http.MethodPost, "/v1/products/aliases", `{"Alias":"牛乳 1L", "Product":"牛乳1L"}`, jwt, 400, handler.ErrProductAliasSelf},
+		{http.MethodPost, "/v1/products/aliases", `{"ID":1, "Alias":"tea", "Product":"green tea"}`, jwt, 400, handler.ErrIDCannotRequest},
+		{http.MethodGet, "/v1/products/duplicates?threshold=1", "", jwt, 400, handler.ErrDuplicateThreshold},
+		{http.MethodGet, "/v1/products/duplicates?threshold=a", "", jwt, 400, nil},
+		{http.MethodDelete, fmt.Sprintf("/v1/products/aliases/%d", *alias.ID), "", genToken(conf, 2), 404, handler.ErrNotFound},
+		{http.MethodDelete, "/v1/products/aliases/999999", "", jwt, 404, handler.ErrNotFound},
+		{http.MethodDelete, "/v1/products/aliases/a", "", jwt, 404, handler.ErrNotFound},
+	}
+	for _, v := range cases {
+		// リクエストの生成
+		var body *string
+		contentType := ""
+		if v.body != "" {
+			body = &v.body
+			contentType = echo.MIMEApplicationJSON
+		}
+		req := newRequest(v.method, v.target, body, contentType, v.jwt)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.target+" "+v.body)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.target+" "+v.body)
+		}
+	}
+
+	// 拒否した統合は何も変更しない
+	assert.Equal(t, []string{"牛乳1L->牛乳 1L"}, findProductAliases(t, e, jwt))
+	assert.Equal(t, &api.ProductMergeResult{Product: "bread", Merged: 1}, mergeProduct(t, e, jwt, "flour", "bread"))
+}
+
+// 商品名を指定した価格の登録
+func postProductPrice(t *testing.T, e *echo.Echo, jwt *string, product string, price uint) *api.Price {
+	body := fmt.Sprintf(`{"Store":"super", "Product":"%s", "Price":%d}`, product, price)
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 201, rec.Code, body) {
+		t.FailNow()
+	}
+	res := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func createProductAlias(t *testing.T, e *echo.Echo, jwt *string, alias, product string) *api.ProductAlias {
+	body := fmt.Sprintf(`{"Alias":"%s", "Product":"%s"}`, alias, product)
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/products/aliases", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 201, rec.Code, body) {
+		t.FailNow()
+	}
+	res := &api.ProductAlias{}
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// 別名と商品名の組（"別名->商品名"）
+func findProductAliases(t *testing.T, e *echo.Echo, jwt *string) []string {
+	rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/products/aliases", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	var res []*api.ProductAlias
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	pairs := make([]string, len(res))
+	for i, v := range res {
+		pairs[i] = v.Alias + "->" + v.Product
+	}
+	return pairs
+}
+
+func mergeProduct(t *testing.T, e *echo.Echo, jwt *string, product, into string) *api.ProductMergeResult {
+	body := fmt.Sprintf(`{"Product":"%s", "Into":"%s"}`, product, into)
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/products/merge", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 200, rec.Code, body) {
+		t.FailNow()
+	}
+	res := &api.ProductMergeResult{}
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func findProductDuplicates(t *testing.T, e *echo.Echo, jwt *string, query string) []api.ProductDuplicate {
+	rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/products/duplicates"+query, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code, query)
+	var res []api.ProductDuplicate
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
@@ -0,0 +1,132 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// バーコードによる商品と価格の履歴の取得
+func TestFindProductByGTIN(t *testing.T) {
+	testname := "TestFindProductByGTIN"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	bodies := []string{
+		`{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "GTIN":"4901234567894", "Price":9500}`,
+		`{"DateTime":"2023-05-21 12:34:56", "Store":"market", "Product":"SSD 1TB", "GTIN":"4901234567894", "Price":9200}`,
+		`{"DateTime":"2023-05-20 12:34:56", "Store":"pcshop", "Product":"hdd2T", "Price":7000}`,
+		`{"DateTime":"2023-05-20 12:34:56", "Store":"import", "Product":"cable", "GTIN":"036000291452", "Price":500}`,
+		`{"DateTime":"2023-05-21 12:34:56", "Store":"pcshop", "Product":"cable", "GTIN":"0036000291452", "Price":480}`,
+		`{"DateTime":"2023-05-20 12:34:56", "Store":"pcshop", "Product":"gum", "GTIN":"96385074", "Price":100}`,
+	}
+	ids := make([]uint, len(bodies))
+	for i, body := range bodies {
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 201, rec.Code, body)
+		price := &api.Price{}
+		if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = *price.ID
+	}
+
+	cases := []struct {
+		gtin     string
+		product  string
+		expected []uint
+	}{
+		{"4901234567894", "SSD 1TB", []uint{ids[1], ids[0]}},
+		{"036000291452", "cable", []uint{ids[4], ids[3]}}, // UPC-AとJAN/EAN-13の同一視
+		{"0036000291452", "cable", []uint{ids[4], ids[3]}},
+		{"96385074", "gum", []uint{ids[5]}},
+	}
+	for _, v := range cases {
+		rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/products/by-code/"+v.gtin, nil, "", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 200, rec.Code, v.gtin)
+		product := &api.Product{}
+		if err := json.Unmarshal(rec.Body.Bytes(), product); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, v.gtin, product.GTIN)
+		assert.Equal(t, v.product, product.Product)
+		actual := make([]uint, len(product.Prices))
+		for i, p := range product.Prices {
+			actual[i] = *p.ID
+		}
+		assert.Equal(t, v.expected, actual, v.gtin)
+	}
+
+	// 部分更新でバーコードを外す
+	body := `{"GTIN":null}`
+	rec, err := execHandler(e, newRequest(http.MethodPatch, fmt.Sprintf("/v1/prices/%d", ids[5]), &body, "application/merge-patch+json", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "GTIN")
+
+	// 更新でバーコードを外す
+	body = `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9500}`
+	rec, err = execHandler(e, newRequest(http.MethodPut, fmt.Sprintf("/v1/prices/%d", ids[0]), &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "GTIN")
+
+	// 入力チェックと該当なし
+	validations := []struct {
+		jwt  *string
+		gtin string
+		code int
+		err  error
+	}{
+		{jwt, "96385074", 404, handler.ErrNotFound},
+		{genToken(conf, 2), "4901234567894", 404, handler.ErrNotFound},
+		{jwt, "4901234567890", 400, handler.ErrGTIN},
+		{jwt, "490123456789a", 400, handler.ErrGTIN},
+		{jwt, "12345", 400, handler.ErrGTIN},
+	}
+	for _, v := range validations {
+		code, cause, err := execHandlerValidation(e, newRequest(http.MethodGet, "/v1/products/by-code/"+v.gtin, nil, "", v.jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, v.code, code, v.gtin)
+		assert.Equal(t, v.err, cause, v.gtin)
+	}
+
+	// チェックディジットが誤っていれば登録できない
+	body = `{"Store":"pcshop", "Product":"ssd1T", "GTIN":"4901234567890", "Price":9500}`
+	code, _, err := execHandlerValidation(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 400, code)
+}
@@ -0,0 +1,326 @@
+package handler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/entity"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+const monthLayout = "2006-01"
+
+// 支出の集計のCSVの先頭の列名
+var spendingCSVKeyColumns = map[string]string{
+	service.SpendingByMonth:    "Month",
+	service.SpendingByStore:    "Store",
+	service.SpendingByCategory: "Category",
+}
+
+// 購入の登録
+func (h *Handler) createPurchase(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	var recordPrices bool
+	if err := echo.QueryParamsBinder(c).Bool("recordPrices", &recordPrices).BindError(); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	req := &api.Purchase{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック（価格として記録できるよう正規化した名前で検証）
+	if err := h.normalizeNames(&req.Store); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	for i := range req.Items {
+		if err := h.normalizeNames(&req.Items[i].Product); err != nil {
+			return newHTTPError(http.StatusBadRequest, err)
+		}
+	}
+	if err := c.Validate(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if req.ID != nil {
+		return newHTTPError(http.StatusBadRequest, ErrIDCannotRequest)
+	}
+	dateTime, err := h.parseDateTime(req.DateTime)
+	if err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// サービスの実行
+	purchase, err := h.service.CreatePurchase(ctx, purchaseToEntity(req, userId, dateTime), recordPrices)
+	if err != nil {
+		if errors.Is(err, service.ErrUnitMismatch) {
+			return newHTTPError(http.StatusBadRequest, ErrUnitMismatch)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusCreated, h.purchaseToResponse(purchase), h.indent)
+}
+
+// 購入の一覧
+func (h *Handler) findPurchases(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	from, to, err := h.monthRange(c)
+	if err != nil {
+		return err
+	}
+
+	// サービスの実行
+	entities, err := h.service.FindPurchases(ctx, userId, from, to)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成（日時の降順）
+	purchases := make([]*api.Purchase, len(entities))
+	for i, v := range entities {
+		purchases[i] = h.purchaseToResponse(&v)
+	}
+
+	return h.revalidateJSON(c, purchases)
+}
+
+// 購入の取得
+func (h *Handler) findPurchase(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	purchaseId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	purchase, err := h.service.FindPurchase(ctx, uint(purchaseId), userId)
+	if err != nil {
+		return err
+	}
+	if purchase == nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// レスポンスの生成
+	return h.revalidateJSON(c, h.purchaseToResponse(purchase))
+}
+
+// 購入の削除
+func (h *Handler) deletePurchase(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	purchaseId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	if err = h.service.DeletePurchase(ctx, uint(purchaseId), userId); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.NoContent(http.StatusNoContent)
+}
+
+// 支出の集計
+func (h *Handler) spendingReport(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// サービスの実行
+	by, rows, err := h.execSpendingReport(c)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成
+	res := &api.SpendingReport{By: by, Rows: make([]api.SpendingRow, len(rows))}
+	for i, v := range rows {
+		res.Rows[i] = api.SpendingRow{Key: v.Key, Total: v.Total, Purchases: v.Purchases}
+		res.Total += v.Total
+	}
+
+	return h.revalidateJSON(c, res)
+}
+
+// 支出の集計のCSV
+func (h *Handler) exportSpendingReport(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// サービスの実行
+	by, rows, err := h.execSpendingReport(c)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, mimeTextCSV+"; charset=UTF-8")
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="spending-by-%s.csv"`, by))
+	res.WriteHeader(http.StatusOK)
+	w := csv.NewWriter(res)
+	if err := w.Write([]string{spendingCSVKeyColumns[by], "Total", "Purchases"}); err != nil {
+		return err
+	}
+	for _, v := range rows {
+		if err := w.Write([]string{
+			v.Key,
+			strconv.FormatUint(uint64(v.Total), 10),
+			strconv.Itoa(v.Purchases),
+		}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+
+	return w.Error()
+}
+
+func (h *Handler) execSpendingReport(c echo.Context) (string, []service.SpendingRow, error) {
+	ctx := c.Request().Context()
+
+	// リクエストの取得
+	userId := h.userId(c)
+	by := service.SpendingByMonth
+	if err := echo.QueryParamsBinder(c).String("by", &by).BindError(); err != nil {
+		return "", nil, newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック
+	if _, found := spendingCSVKeyColumns[by]; !found {
+		return "", nil, newHTTPError(http.StatusBadRequest, ErrSpendingBy)
+	}
+	from, to, err := h.monthRange(c)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// サービスの実行
+	rows, err := h.service.SpendingReport(ctx, userId, by, from, to, h.location)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return by, rows, nil
+}
+
+// fromとtoの月（YYYY-MM、toの月を含む）を日時の範囲に変換（toは翌月の初め）
+func (h *Handler) monthRange(c echo.Context) (*time.Time, *time.Time, error) {
+	var reqFrom, reqTo string
+	if err := echo.QueryParamsBinder(c).String("from", &reqFrom).String("to", &reqTo).BindError(); err != nil {
+		return nil, nil, newHTTPError(http.StatusBadRequest, err)
+	}
+	from, err := h.parseMonth(reqFrom)
+	if err != nil {
+		return nil, nil, err
+	}
+	to, err := h.parseMonth(reqTo)
+	if err != nil {
+		return nil, nil, err
+	}
+	if to != nil {
+		t := to.AddDate(0, 1, 0)
+		to = &t
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, nil, newHTTPError(http.StatusBadRequest, ErrMonthRange)
+	}
+	return from, to, nil
+}
+
+// 月（YYYY-MM）の初め（空はnil）
+func (h *Handler) parseMonth(month string) (*time.Time, error) {
+	if month == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation(monthLayout, month, h.location)
+	if err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, ErrMonth)
+	}
+	return &t, nil
+}
+
+func purchaseToEntity(purchase *api.Purchase, userId uint, dateTime time.Time) *entity.Purchase {
+	items := make([]entity.PurchaseItem, len(purchase.Items))
+	for i, v := range purchase.Items {
+		quantity := v.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		items[i] = entity.PurchaseItem{
+			Product:  v.Product,
+			Category: v.Category,
+			Quantity: quantity,
+			Price:    v.Price,
+		}
+	}
+	return &entity.Purchase{
+		UserID:   userId,
+		DateTime: dateTime,
+		Store:    purchase.Store,
+		Items:    items,
+	}
+}
+
+func (h *Handler) purchaseToResponse(purchase *entity.Purchase) *api.Purchase {
+	items := make([]api.PurchaseItem, len(purchase.Items))
+	for i, v := range purchase.Items {
+		items[i] = api.PurchaseItem{
+			Product:  v.Product,
+			Category: v.Category,
+			Quantity: v.Quantity,
+			Price:    v.Price,
+			Amount:   v.Amount,
+			PriceID:  v.PriceID,
+		}
+	}
+	dateTime := h.formatDateTime(purchase.DateTime)
+	return &api.Purchase{
+		ID:       &purchase.ID,
+		DateTime: &dateTime,
+		Store:    purchase.Store,
+		Items:    items,
+		Total:    purchase.Total,
+	}
+}
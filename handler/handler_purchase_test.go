@@ -0,0 +1,296 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// 購入の登録、一覧、削除
+func TestPurchase(t *testing.T) {
+	testname := "TestPurchase"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 登録
+	created := createPurchases(t, e, jwt)
+	assert.Equal(t, uint(1), created[0].Items[1].Quantity)
+	assert.Equal(t, uint(238*2), created[0].Items[0].Amount)
+	assert.Nil(t, created[0].Items[0].PriceID)
+
+	// 明細の単価を価格として記録
+	for _, v := range created[1].Items {
+		if !assert.NotNil(t, v.PriceID, v.Product) {
+			continue
+		}
+		rec, err := execHandler(e, newRequest(http.MethodGet, fmt.Sprintf("/v1/prices/%d", *v.PriceID), nil, "", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 200, rec.Code)
+		price := &api.Price{}
+		if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "super", price.Store)
+		assert.Equal(t, v.Product, price.Product)
+		assert.Equal(t, v.Price, price.Price)
+		assert.Equal(t, "2024-05-03 18:30:00", *price.DateTime)
+	}
+
+	// 一覧（日時の降順）
+	list := func(target string) []uint {
+		rec, err := execHandler(e, newRequest(http.MethodGet, target, nil, "", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 200, rec.Code, target)
+		var purchases []*api.Purchase
+		if err := json.Unmarshal(rec.Body.Bytes(), &purchases); err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]uint, len(purchases))
+		for i, v := range purchases {
+			ids[i] = *v.ID
+		}
+		return ids
+	}
+	assert.Equal(t, []uint{*created[2].ID, *created[1].ID, *created[0].ID}, list("/v1/purchases"))
+	assert.Equal(t, []uint{*created[2].ID, *created[1].ID}, list("/v1/purchases?from=2024-05&to=2024-05"))
+	assert.Equal(t, []uint{*created[0].ID}, list("/v1/purchases?to=2024-04"))
+
+	// 削除しても記録した価格は残る
+	rec, err := execHandler(e, newRequest(http.MethodDelete, fmt.Sprintf("/v1/purchases/%d", *created[1].ID), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 204, rec.Code)
+	assert.Equal(t, []uint{*created[2].ID, *created[0].ID}, list("/v1/purchases"))
+	rec, err = execHandler(e, newRequest(http.MethodGet, fmt.Sprintf("/v1/prices/%d", *created[1].Items[0].PriceID), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+}
+
+// 購入のバリデーション
+func TestPurchaseValidation(t *testing.T) {
+	testname := "TestPurchaseValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	created := createPurchases(t, e, jwt)
+	purchasePath := fmt.Sprintf("/v1/purchases/%d", *created[0].ID)
+
+	cases := []struct {
+		jwt    *string
+		method string
+		target string
+		body   string
+		code   int
+		err    error
+	}{
+		{jwt, http.MethodGet, "/v1/purchases?from=2024-06&to=2024-05", "", 400, handler.ErrMonthRange},
+		{jwt, http.MethodGet, "/v1/purchases?from=2024-5-1", "", 400, handler.ErrMonth},
+		{jwt, http.MethodPost, "/v1/purchases", `{"Store":"market", "Items":[]}`, 400, nil},
+		{jwt, http.MethodPost, "/v1/purchases", `{"Store":"market", "Items":[{"Product":"milk"}]}`, 400, nil},
+		{jwt, http.MethodPost, "/v1/purchases", `{"Store":"", "Items":[{"Product":"milk", "Price":198}]}`, 400, nil},
+		{jwt, http.MethodPost, "/v1/purchases", `{"ID":1, "Store":"market", "Items":[{"Product":"milk", "Price":198}]}`, 400, handler.ErrIDCannotRequest},
+		{jwt, http.MethodGet, "/v1/purchases/999999", "", 404, handler.ErrNotFound},
+		{jwt, http.MethodDelete, "/v1/purchases/a", "", 404, handler.ErrNotFound},
+		{genToken(conf, 2), http.MethodGet, purchasePath, "", 404, handler.ErrNotFound}, // 他のユーザの購入は見えない
+	}
+
+	for _, v := range cases {
+		// リクエストの生成
+		var body *string
+		contentType := ""
+		if v.body != "" {
+			body = &v.body
+			contentType = echo.MIMEApplicationJSON
+		}
+		req := newRequest(v.method, v.target, body, contentType, v.jwt)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.target)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.target)
+		}
+	}
+}
+
+// 支出の集計
+func TestSpendingReport(t *testing.T) {
+	testname := "TestSpendingReport"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	createPurchases(t, e, jwt)
+
+	// 集計
+	reports := []struct {
+		target string
+		total  uint
+		rows   []api.SpendingRow
+	}{
+		{"/v1/reports/spending", 2614, []api.SpendingRow{{Key: "2024-04", Total: 656, Purchases: 1}, {Key: "2024-05", Total: 1958, Purchases: 2}}},
+		{"/v1/reports/spending?by=store", 2614, []api.SpendingRow{{Key: "market", Total: 2156, Purchases: 2}, {Key: "super", Total: 458, Purchases: 1}}},
+		{"/v1/reports/spending?by=category", 2614, []api.SpendingRow{{Key: "meat", Total: 1500, Purchases: 1}, {Key: "dairy", Total: 934, Purchases: 2}, {Key: "", Total: 180, Purchases: 1}}},
+		{"/v1/reports/spending?by=category&from=2024-05", 1958, []api.SpendingRow{{Key: "meat", Total: 1500, Purchases: 1}, {Key: "dairy", Total: 458, Purchases: 1}}},
+	}
+	for _, v := range reports {
+		rec, err := execHandler(e, newRequest(http.MethodGet, v.target, nil, "", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 200, rec.Code, v.target)
+		report := &api.SpendingReport{}
+		if err := json.Unmarshal(rec.Body.Bytes(), report); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, v.total, report.Total, v.target)
+		assert.Equal(t, v.rows, report.Rows, v.target)
+	}
+
+	// CSV
+	rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/reports/spending.csv?by=store", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "text/csv; charset=UTF-8", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "Store,Total,Purchases\nmarket,2156,2\nsuper,458,1\n", rec.Body.String())
+}
+
+// 支出の集計のバリデーション
+func TestSpendingReportValidation(t *testing.T) {
+	testname := "TestSpendingReportValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	cases := []struct {
+		jwt    *string
+		method string
+		target string
+		body   string
+		code   int
+		err    error
+	}{
+		{jwt, http.MethodGet, "/v1/reports/spending?by=product", "", 400, handler.ErrSpendingBy},
+		{jwt, http.MethodGet, "/v1/reports/spending?from=2024-5-1", "", 400, handler.ErrMonth},
+		{jwt, http.MethodGet, "/v1/reports/spending?from=2024-06&to=2024-05", "", 400, handler.ErrMonthRange},
+		{jwt, http.MethodGet, "/v1/reports/spending.csv?by=product", "", 400, handler.ErrSpendingBy},
+		{jwt, http.MethodGet, "/v1/reports/spending?by=category", "", 200, nil},
+	}
+
+	for _, v := range cases {
+		// リクエストの生成
+		var body *string
+		contentType := ""
+		if v.body != "" {
+			body = &v.body
+			contentType = echo.MIMEApplicationJSON
+		}
+		req := newRequest(v.method, v.target, body, contentType, v.jwt)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.target)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.target)
+		}
+	}
+}
+
+// 一覧と集計に使う購入の登録
+func createPurchases(t *testing.T, e *echo.Echo, jwt *string) []*api.Purchase {
+	purchases := []struct {
+		target string
+		body   string
+		total  uint
+	}{
+		{"/v1/purchases", `{"DateTime":"2024-04-28 10:00:00", "Store":"market", "Items":[{"Product":"milk", "Category":"dairy", "Quantity":2, "Price":238}, {"Product":"bread", "Price":180}]}`, 238*2 + 180},
+		{"/v1/purchases?recordPrices=true", `{"DateTime":"2024-05-03 18:30:00", "Store":"super", "Items":[{"Product":"milk", "Category":"dairy", "Price":198}, {"Product":"egg", "Category":"dairy", "Price":260}]}`, 198 + 260},
+		{"/v1/purchases", `{"DateTime":"2024-05-20 12:00:00", "Store":"market", "Items":[{"Product":"beef", "Category":"meat", "Quantity":3, "Price":500}]}`, 500 * 3},
+	}
+	created := make([]*api.Purchase, len(purchases))
+	for i, v := range purchases {
+		rec, err := execHandler(e, newRequest(http.MethodPost, v.target, &v.body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assert.Equal(t, 201, rec.Code, v.body) {
+			t.FailNow()
+		}
+		purchase := &api.Purchase{}
+		if err := json.Unmarshal(rec.Body.Bytes(), purchase); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, v.total, purchase.Total, v.body)
+		created[i] = purchase
+	}
+	return created
+}
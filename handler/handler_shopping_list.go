@@ -0,0 +1,509 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/entity"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 買い物リストの登録
+func (h *Handler) createShoppingList(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	req := &api.ShoppingList{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック（正規化した名前で検証）
+	if err := h.normalizeListItems(req.Items); err != nil {
+		return err
+	}
+	if err := c.Validate(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if req.ID != nil {
+		return newHTTPError(http.StatusBadRequest, ErrIDCannotRequest)
+	}
+
+	// サービスの実行
+	list, err := h.service.CreateShoppingList(ctx, shoppingListToEntity(req, 0, userId))
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusCreated, h.shoppingListToResponse(list), h.indent)
+}
+
+// 買い物リストの一覧
+func (h *Handler) findShoppingLists(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+
+	// サービスの実行
+	entities, err := h.service.FindShoppingLists(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成（登録順）
+	lists := make([]*api.ShoppingList, len(entities))
+	for i, v := range entities {
+		lists[i] = h.shoppingListToResponse(&v)
+	}
+
+	return h.revalidateJSON(c, lists)
+}
+
+// 買い物リストの取得
+func (h *Handler) findShoppingList(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	listId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	list, err := h.service.FindShoppingList(ctx, uint(listId), userId)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// レスポンスの生成
+	return h.revalidateJSON(c, h.shoppingListToResponse(list))
+}
+
+// 買い物リストの更新（品目は置き換え）
+func (h *Handler) updateShoppingList(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	req := &api.ShoppingList{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック
+	listId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	if err = h.normalizeListItems(req.Items); err != nil {
+		return err
+	}
+	if err = c.Validate(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if req.ID != nil && *req.ID != uint(listId) {
+		return newHTTPError(http.StatusBadRequest, ErrIDUnchangeable)
+	}
+
+	// サービスの実行
+	list, err := h.service.UpdateShoppingList(ctx, shoppingListToEntity(req, uint(listId), userId))
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusOK, h.shoppingListToResponse(list), h.indent)
+}
+
+// 買い物リストの削除
+func (h *Handler) deleteShoppingList(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	listId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	if err = h.service.DeleteShoppingList(ctx, uint(listId), userId); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.NoContent(http.StatusNoContent)
+}
+
+// 品目の並べ替え
+func (h *Handler) reorderShoppingList(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	req := &api.ShoppingListOrder{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック
+	listId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	if err = c.Validate(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// サービスの実行
+	list, err := h.service.ReorderShoppingList(ctx, uint(listId), userId, req.Items)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		if errors.Is(err, service.ErrListOrder) {
+			return newHTTPError(http.StatusBadRequest, ErrListOrder)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusOK, h.shoppingListToResponse(list), h.indent)
+}
+
+// 品目の追加
+func (h *Handler) addShoppingListItem(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	req := &api.ShoppingListItem{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック
+	listId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	if err = h.normalizeNames(&req.Product); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if err = c.Validate(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if req.ID != nil {
+		return newHTTPError(http.StatusBadRequest, ErrIDCannotRequest)
+	}
+
+	// サービスの実行
+	item, err := h.service.AddShoppingListItem(ctx, shoppingListItemToEntity(req, 0, uint(listId), userId))
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		if errors.Is(err, service.ErrTooManyListItems) {
+			return newHTTPError(http.StatusBadRequest, ErrTooManyListItems)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusCreated, shoppingListItemToResponse(item), h.indent)
+}
+
+// 品目の更新
+func (h *Handler) updateShoppingListItem(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	reqItem := c.Param("item")
+	req := &api.ShoppingListItem{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック
+	listId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	itemId, err := strconv.ParseUint(reqItem, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	if err = h.normalizeNames(&req.Product); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if err = c.Validate(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if req.ID != nil && *req.ID != uint(itemId) {
+		return newHTTPError(http.StatusBadRequest, ErrIDUnchangeable)
+	}
+
+	// サービスの実行
+	item, err := h.service.UpdateShoppingListItem(ctx, shoppingListItemToEntity(req, uint(itemId), uint(listId), userId))
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusOK, shoppingListItemToResponse(item), h.indent)
+}
+
+// 品目をチェック済みにする
+func (h *Handler) checkShoppingListItem(c echo.Context) error {
+	return h.markShoppingListItem(c, true)
+}
+
+// 品目のチェックを外す
+func (h *Handler) uncheckShoppingListItem(c echo.Context) error {
+	return h.markShoppingListItem(c, false)
+}
+
+func (h *Handler) markShoppingListItem(c echo.Context, checked bool) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	reqItem := c.Param("item")
+
+	// 入力チェック
+	listId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	itemId, err := strconv.ParseUint(reqItem, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	item, err := h.service.CheckShoppingListItem(ctx, uint(itemId), uint(listId), userId, checked)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusOK, shoppingListItemToResponse(item), h.indent)
+}
+
+// 品目の削除
+func (h *Handler) deleteShoppingListItem(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	reqItem := c.Param("item")
+
+	// 入力チェック
+	listId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	itemId, err := strconv.ParseUint(reqItem, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	if err = h.service.DeleteShoppingListItem(ctx, uint(itemId), uint(listId), userId); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.NoContent(http.StatusNoContent)
+}
+
+// 買い物リストの見積もり
+func (h *Handler) estimateShoppingList(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	strategy := service.EstimateByLatest
+	var excludeChecked bool
+	if err := echo.QueryParamsBinder(c).String("strategy", &strategy).Bool("excludeChecked", &excludeChecked).BindError(); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック
+	listId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	if strategy != service.EstimateByLatest && strategy != service.EstimateByCheapest {
+		return newHTTPError(http.StatusBadRequest, ErrEstimateStrategy)
+	}
+	now := time.Now()
+	since, excludeSuspicious, err := h.compareOptions(c, now)
+	if err != nil {
+		return err
+	}
+
+	// サービスの実行
+	estimate, err := h.service.EstimateShoppingList(ctx, uint(listId), userId, strategy, since, now, excludeChecked, excludeSuspicious)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	res := &api.ShoppingListEstimate{
+		ListID:   uint(listId),
+		Strategy: strategy,
+		Total:    estimate.Total,
+		Stores:   make([]*api.StoreEstimate, len(estimate.Stores)),
+	}
+	for i, store := range estimate.Stores {
+		prices := make([]entity.Price, len(store.Items))
+		for j, v := range store.Items {
+			prices[j] = v.Price
+		}
+		compared, err := h.comparedPricesResponse(ctx, prices, now)
+		if err != nil {
+			return err
+		}
+		storeRes := &api.StoreEstimate{Store: store.Store, Total: store.Total, Items: make([]*api.EstimatedItem, len(store.Items))}
+		for j, v := range store.Items {
+			storeRes.Items[j] = &api.EstimatedItem{Item: *shoppingListItemToResponse(&v.Item), Price: *compared[j], Subtotal: v.Subtotal}
+			storeRes.Stale = storeRes.Stale || compared[j].Stale
+		}
+		res.Stores[i] = storeRes
+		res.Stale = res.Stale || storeRes.Stale
+	}
+	for _, v := range estimate.Missing {
+		res.Missing = append(res.Missing, shoppingListItemToResponse(&v))
+	}
+
+	return h.revalidateJSON(c, res)
+}
+
+func shoppingListToEntity(list *api.ShoppingList, listId, userId uint) *entity.ShoppingList {
+	items := make([]entity.ShoppingListItem, len(list.Items))
+	for i, v := range list.Items {
+		items[i] = *shoppingListItemToEntity(&v, 0, listId, userId)
+	}
+	return &entity.ShoppingList{
+		ID:     listId,
+		UserID: userId,
+		Name:   list.Name,
+		Items:  items,
+	}
+}
+
+func shoppingListItemToEntity(item *api.ShoppingListItem, itemId, listId, userId uint) *entity.ShoppingListItem {
+	quantity := item.Quantity
+	if quantity == 0 {
+		quantity = 1
+	}
+	return &entity.ShoppingListItem{
+		ID:       itemId,
+		ListID:   listId,
+		UserID:   userId,
+		Product:  item.Product,
+		Quantity: quantity,
+		Checked:  item.Checked,
+	}
+}
+
+func (h *Handler) shoppingListToResponse(list *entity.ShoppingList) *api.ShoppingList {
+	items := make([]api.ShoppingListItem, len(list.Items))
+	for i, v := range list.Items {
+		items[i] = *shoppingListItemToResponse(&v)
+	}
+	updatedAt := h.formatDateTime(list.UpdatedAt)
+	return &api.ShoppingList{
+		ID:        &list.ID,
+		Name:      list.Name,
+		Items:     items,
+		UpdatedAt: &updatedAt,
+	}
+}
+
+func shoppingListItemToResponse(item *entity.ShoppingListItem) *api.ShoppingListItem {
+	return &api.ShoppingListItem{
+		ID:       &item.ID,
+		Product:  item.Product,
+		Quantity: item.Quantity,
+		Checked:  item.Checked,
+	}
+}
+
+// 品目の商品名の正規化
+func (h *Handler) normalizeListItems(items []api.ShoppingListItem) error {
+	for i := range items {
+		if err := h.normalizeNames(&items[i].Product); err != nil {
+			return newHTTPError(http.StatusBadRequest, err)
+		}
+	}
+	return nil
+}
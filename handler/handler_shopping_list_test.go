@@ -0,0 +1,371 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// 買い物リストと品目の操作
+func TestShoppingList(t *testing.T) {
+	testname := "TestShoppingList"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 登録
+	body := `{"Name":"weekend", "Items":[{"Product":"milk", "Quantity":2}, {"Product":"egg"}, {"Product":"bread"}]}`
+	list := execShoppingList(t, e, jwt, http.MethodPost, "/v1/lists", &body)
+	assert.Equal(t, "weekend", list.Name)
+	assert.Equal(t, []string{"milk", "egg", "bread"}, shoppingListProducts(list))
+	assert.Equal(t, uint(2), list.Items[0].Quantity)
+	assert.Equal(t, uint(1), list.Items[1].Quantity)
+	listPath := fmt.Sprintf("/v1/lists/%d", *list.ID)
+
+	// 品目の追加は末尾
+	body = `{"Product":"tofu", "Quantity":3}`
+	rec, err := execHandler(e, newRequest(http.MethodPost, listPath+"/items", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 201, rec.Code)
+	item := &api.ShoppingListItem{}
+	if err := json.Unmarshal(rec.Body.Bytes(), item); err != nil {
+		t.Fatal(err)
+	}
+	list = execShoppingList(t, e, jwt, http.MethodGet, listPath, nil)
+	assert.Equal(t, []string{"milk", "egg", "bread", "tofu"}, shoppingListProducts(list))
+	assert.Equal(t, *item.ID, *list.Items[3].ID)
+
+	// 並べ替え
+	order := fmt.Sprintf(`{"Items":[%d, %d, %d, %d]}`, *list.Items[3].ID, *list.Items[1].ID, *list.Items[0].ID, *list.Items[2].ID)
+	list = execShoppingList(t, e, jwt, http.MethodPut, listPath+"/order", &order)
+	assert.Equal(t, []string{"tofu", "egg", "milk", "bread"}, shoppingListProducts(list))
+	eggId := *list.Items[1].ID
+
+	// チェック
+	rec, err = execHandler(e, newRequest(http.MethodPost, fmt.Sprintf("%s/items/%d/check", listPath, eggId), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	list = execShoppingList(t, e, jwt, http.MethodGet, listPath, nil)
+	assert.True(t, list.Items[1].Checked)
+	assert.False(t, list.Items[0].Checked)
+
+	// 更新は品目を置き換え
+	body = `{"Name":"weekday", "Items":[{"Product":"egg", "Checked":true}]}`
+	list = execShoppingList(t, e, jwt, http.MethodPut, listPath, &body)
+	assert.Equal(t, "weekday", list.Name)
+	assert.Equal(t, []string{"egg"}, shoppingListProducts(list))
+	assert.True(t, list.Items[0].Checked)
+
+	// 品目の更新とチェックを外す
+	itemPath := fmt.Sprintf("%s/items/%d", listPath, *list.Items[0].ID)
+	body = `{"Product":"egg", "Quantity":2, "Checked":true}`
+	rec, err = execHandler(e, newRequest(http.MethodPut, itemPath, &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	rec, err = execHandler(e, newRequest(http.MethodPost, itemPath+"/uncheck", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	item = &api.ShoppingListItem{}
+	if err := json.Unmarshal(rec.Body.Bytes(), item); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint(2), item.Quantity)
+	assert.False(t, item.Checked)
+
+	// 削除
+	rec, err = execHandler(e, newRequest(http.MethodDelete, itemPath, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 204, rec.Code)
+	rec, err = execHandler(e, newRequest(http.MethodDelete, listPath, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 204, rec.Code)
+	rec, err = execHandler(e, newRequest(http.MethodGet, "/v1/lists", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.JSONEq(t, "[]", rec.Body.String())
+}
+
+// 買い物リストのバリデーション
+func TestShoppingListValidation(t *testing.T) {
+	testname := "TestShoppingListValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	body := `{"Name":"weekend", "Items":[{"Product":"milk"}, {"Product":"egg"}]}`
+	list := execShoppingList(t, e, jwt, http.MethodPost, "/v1/lists", &body)
+	listPath := fmt.Sprintf("/v1/lists/%d", *list.ID)
+	itemPath := fmt.Sprintf("%s/items/%d", listPath, *list.Items[0].ID)
+	other := genToken(conf, 2)
+
+	cases := []struct {
+		jwt    *string
+		method string
+		target string
+		body   string
+		code   int
+		err    error
+	}{
+		{jwt, http.MethodPut, listPath + "/order", `{"Items":[1, 2]}`, 400, handler.ErrListOrder},
+		{jwt, http.MethodPut, listPath + "/order", fmt.Sprintf(`{"Items":[%d, %d]}`, *list.Items[0].ID, *list.Items[0].ID), 400, handler.ErrListOrder},
+		{jwt, http.MethodPost, "/v1/lists", `{"Name":"", "Items":[]}`, 400, nil},
+		{jwt, http.MethodPost, "/v1/lists", `{"Name":"a", "Items":[{"Product":""}]}`, 400, nil},
+		{jwt, http.MethodPost, "/v1/lists", `{"ID":1, "Name":"a"}`, 400, handler.ErrIDCannotRequest},
+		{jwt, http.MethodPost, listPath + "/items", `{"Product":"milk", "Quantity":1001}`, 400, nil},
+		{jwt, http.MethodPut, itemPath, `{"ID":999999, "Product":"milk"}`, 400, handler.ErrIDUnchangeable},
+		{jwt, http.MethodGet, "/v1/lists/999999", "", 404, handler.ErrNotFound},
+		{jwt, http.MethodGet, "/v1/lists/a", "", 404, handler.ErrNotFound},
+		// 他のユーザのリストは見えない
+		{other, http.MethodGet, listPath, "", 404, handler.ErrNotFound},
+		{other, http.MethodPost, itemPath + "/check", "", 404, handler.ErrNotFound},
+		{other, http.MethodDelete, listPath, "", 404, handler.ErrNotFound},
+	}
+
+	for _, v := range cases {
+		// リクエストの生成
+		var body *string
+		contentType := ""
+		if v.body != "" {
+			body = &v.body
+			contentType = echo.MIMEApplicationJSON
+		}
+		req := newRequest(v.method, v.target, body, contentType, v.jwt)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.target)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.target)
+		}
+	}
+}
+
+// 買い物リストの見積もり
+func TestEstimateShoppingList(t *testing.T) {
+	testname := "TestEstimateShoppingList"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 価格の登録
+	daysAgo := func(days int) string {
+		return time.Now().AddDate(0, 0, -days).In(conf.Location).Format(conf.DateTimeLayout)
+	}
+	prices := []struct {
+		days    int
+		store   string
+		product string
+		price   uint
+	}{
+		{1, "market", "milk", 238},
+		{3, "super", "milk", 198},
+		{2, "market", "egg", 250},
+		{1, "super", "egg", 260},
+		{100, "corner", "tofu", 80}, // 比較の対象外の古い価格
+	}
+	for _, v := range prices {
+		body := fmt.Sprintf(`{"DateTime":"%s", "Store":"%s", "Product":"%s", "Price":%d}`, daysAgo(v.days), v.store, v.product, v.price)
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 201, rec.Code, body)
+	}
+
+	// 買い物リスト（卵はチェック済み）
+	body := `{"Name":"weekend", "Items":[{"Product":"tofu", "Quantity":3}, {"Product":"egg", "Checked":true}, {"Product":"milk", "Quantity":2}, {"Product":"bread"}]}`
+	list := execShoppingList(t, e, jwt, http.MethodPost, "/v1/lists", &body)
+	listPath := fmt.Sprintf("/v1/lists/%d", *list.ID)
+
+	// 見積もり
+	estimates := []struct {
+		target  string
+		total   uint
+		stores  map[string]uint
+		missing []string
+		stale   bool
+	}{
+		// 最新の価格
+		{listPath + "/estimate", 238*2 + 260, map[string]uint{"market": 238 * 2, "super": 260}, []string{"tofu", "bread"}, false},
+		// 最安の価格
+		{listPath + "/estimate?strategy=cheapest", 198*2 + 250, map[string]uint{"super": 198 * 2, "market": 250}, []string{"tofu", "bread"}, false},
+		// チェック済みを除く
+		{listPath + "/estimate?strategy=cheapest&excludeChecked=true", 198 * 2, map[string]uint{"super": 198 * 2}, []string{"tofu", "bread"}, false},
+		// 古い価格を含める
+		{listPath + "/estimate?maxAge=120", 80*3 + 238*2 + 260, map[string]uint{"corner": 80 * 3, "market": 238 * 2, "super": 260}, []string{"bread"}, true},
+	}
+	for _, v := range estimates {
+		rec, err := execHandler(e, newRequest(http.MethodGet, v.target, nil, "", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 200, rec.Code, v.target)
+		estimate := &api.ShoppingListEstimate{}
+		if err := json.Unmarshal(rec.Body.Bytes(), estimate); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, v.total, estimate.Total, v.target)
+		assert.Equal(t, v.stale, estimate.Stale, v.target)
+		stores := make(map[string]uint, len(estimate.Stores))
+		for _, s := range estimate.Stores {
+			stores[s.Store] = s.Total
+			for _, i := range s.Items {
+				assert.Equal(t, s.Store, i.Price.Store, v.target)
+				assert.Equal(t, i.Price.Price.Price*i.Item.Quantity, i.Subtotal, v.target)
+			}
+		}
+		assert.Equal(t, v.stores, stores, v.target)
+		missing := make([]string, len(estimate.Missing))
+		for i, m := range estimate.Missing {
+			missing[i] = m.Product
+		}
+		assert.Equal(t, v.missing, missing, v.target)
+	}
+}
+
+// 買い物リストの見積もりのバリデーション
+func TestEstimateShoppingListValidation(t *testing.T) {
+	testname := "TestEstimateShoppingListValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	body := `{"Name":"weekend", "Items":[{"Product":"milk"}]}`
+	list := execShoppingList(t, e, jwt, http.MethodPost, "/v1/lists", &body)
+	listPath := fmt.Sprintf("/v1/lists/%d", *list.ID)
+
+	cases := []struct {
+		jwt    *string
+		method string
+		target string
+		body   string
+		code   int
+		err    error
+	}{
+		{jwt, http.MethodGet, listPath + "/estimate?strategy=average", "", 400, handler.ErrEstimateStrategy},
+		{jwt, http.MethodGet, listPath + "/estimate?maxAge=0", "", 400, handler.ErrMaxAge},
+		{jwt, http.MethodGet, listPath + "/estimate?strategy=cheapest", "", 200, nil},
+		{jwt, http.MethodGet, "/v1/lists/999999/estimate", "", 404, handler.ErrNotFound},
+		{genToken(conf, 2), http.MethodGet, listPath + "/estimate", "", 404, handler.ErrNotFound}, // 他のユーザのリストは見えない
+	}
+
+	for _, v := range cases {
+		// リクエストの生成
+		var body *string
+		contentType := ""
+		if v.body != "" {
+			body = &v.body
+			contentType = echo.MIMEApplicationJSON
+		}
+		req := newRequest(v.method, v.target, body, contentType, v.jwt)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.target)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.target)
+		}
+	}
+}
+
+func execShoppingList(t *testing.T, e *echo.Echo, jwt *string, method, target string, body *string) *api.ShoppingList {
+	contentType := ""
+	if body != nil {
+		contentType = echo.MIMEApplicationJSON
+	}
+	rec, err := execHandler(e, newRequest(method, target, body, contentType, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Contains(t, []int{200, 201}, rec.Code, target) {
+		t.FailNow()
+	}
+	list := &api.ShoppingList{}
+	if err := json.Unmarshal(rec.Body.Bytes(), list); err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func shoppingListProducts(list *api.ShoppingList) []string {
+	products := make([]string, len(list.Items))
+	for i, v := range list.Items {
+		products[i] = v.Product
+	}
+	return products
+}
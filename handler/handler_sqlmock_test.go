@@ -31,7 +31,7 @@ func setupSqlMockTest(testname string) (*echo.Echo, *handler.HandlerConfig, *sql
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
-	r, err := repository.NewRepository("pgx", sqlDB)
+	r, err := repository.NewRepository("pgx", sqlDB, nil)
 	if err != nil {
 		sqlDB.Close()
 		return nil, nil, nil, nil, err
@@ -77,7 +77,7 @@ func TestDriverError(t *testing.T) {
 	}
 
 	// テストの実行
-	r, act := repository.NewRepository(testname, sqlDB)
+	r, act := repository.NewRepository(testname, sqlDB, nil)
 
 	// アサーション
 	assert.Nil(t, r)
@@ -99,7 +99,7 @@ func TestPingError(t *testing.T) {
 	mock.ExpectPing().WillReturnError(mockerr)
 
 	// テストの実行
-	r, act := repository.NewRepository("pgx", sqlDB)
+	r, act := repository.NewRepository("pgx", sqlDB, nil)
 
 	// アサーション
 	assert.Nil(t, r)
@@ -123,7 +123,7 @@ func TestPgOwnerError(t *testing.T) {
 	)).WillReturnError(mockerr)
 
 	// テストの実行
-	r, err := repository.NewRepository("pgx", sqlDB)
+	r, err := repository.NewRepository("pgx", sqlDB, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -152,7 +152,7 @@ func TestMySQLOwnerError(t *testing.T) {
 	)).WillReturnError(mockerr)
 
 	// テストの実行
-	r, err := repository.NewRepository("mysql", sqlDB)
+	r, err := repository.NewRepository("mysql", sqlDB, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -323,9 +323,12 @@ func TestCreatePriceError(t *testing.T) {
 
 	// mockの挙動設定
 	mock.ExpectBegin()
+	// 別名の商品名の確認
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "product_aliases" `)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "alias", "product"}))
 	mockerr := errors.New(testname)
 	// PostgreSQLの場合はINSERTでもRETURNがあるのでExpectQueryを使う
-	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "prices" ("created_at","updated_at","deleted_at","user_id","date_time","store","product","price") `)).
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "prices" ("created_at","updated_at","deleted_at","user_id","date_time","store","product","gtin","price","quantity","unit","suspicious","version","price_type") `)).
 		WillReturnError(mockerr)
 	mock.ExpectRollback()
 
@@ -361,6 +364,9 @@ func TestFindPricesError(t *testing.T) {
 
 	// mockの挙動設定
 	userId := uint(1)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT MAX(COALESCE(deleted_at, updated_at)) FROM "prices" `)).
+		WithArgs(userId).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(time.Now()))
 	mockerr := errors.New(testname)
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "prices" `)).
 		WithArgs(userId).
@@ -430,15 +436,22 @@ func TestUpdatePriceError(t *testing.T) {
 	defer sqlDB.Close()
 
 	// mockの挙動設定
+	userId := uint(1)
+	priceId := uint(2)
+	version := uint(1)
 	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "prices" `)).
+		WithArgs(userId, priceId, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "version"}).AddRow(priceId, userId, version))
+	// 別名の商品名の確認
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "product_aliases" `)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "alias", "product"}))
 	mockerr := errors.New(testname)
 	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "prices" SET `)).
 		WillReturnError(mockerr)
 	mock.ExpectRollback()
 
 	// リクエストの生成
-	userId := uint(1)
-	priceId := uint(2)
 	dateTime, store, product, price := "2023-05-19 12:34:56", "pcshop", "ssd1T", uint(9500)
 	body := fmt.Sprintf(`{"DateTime":"%s", "Store":"%s", "Product":"%s", "Price":%d}`, dateTime, store, product, price)
 	req := newRequest(
@@ -471,9 +484,13 @@ func TestDeletePriceError(t *testing.T) {
 	mock.ExpectBegin()
 	userId := uint(1)
 	priceId := uint(2)
+	version := uint(1)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "prices" `)).
+		WithArgs(userId, priceId, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "version"}).AddRow(priceId, userId, version))
 	mockerr := errors.New(testname)
 	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "prices" SET `)).
-		WithArgs(anyTime{}, userId, priceId).
+		WithArgs(anyTime{}, userId, version, priceId).
 		WillReturnError(mockerr)
 	mock.ExpectRollback()
 
@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/entity"
+	"github.com/ystkg/rest-example/repository"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultNearbyRadius = 1000  // メートル
+	maxNearbyRadius     = 50000 // メートル
+)
+
+// 店舗の登録
+func (h *Handler) createStore(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	req := &api.Store{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック（価格の店舗と照合するよう正規化した名前で検証）
+	if err := h.normalizeNames(&req.Name); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if err := c.Validate(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if req.ID != nil {
+		return newHTTPError(http.StatusBadRequest, ErrIDCannotRequest)
+	}
+
+	// サービスの実行
+	store, err := h.service.CreateStore(ctx, storeToEntity(req, 0, userId))
+	if err != nil {
+		if errors.Is(err, repository.ErrDuplicated) {
+			return newHTTPError(http.StatusBadRequest, ErrStoreAlreadyExists)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusCreated, storeToResponse(store), h.indent)
+}
+
+// 店舗の一覧
+func (h *Handler) findStores(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+
+	// サービスの実行
+	entities, err := h.service.FindStores(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成（名前順）
+	stores := make([]*api.Store, len(entities))
+	for i, v := range entities {
+		stores[i] = storeToResponse(&v)
+	}
+
+	return h.revalidateJSON(c, stores)
+}
+
+// 店舗の取得
+func (h *Handler) findStore(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	storeId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	store, err := h.service.FindStore(ctx, uint(storeId), userId)
+	if err != nil {
+		return err
+	}
+	if store == nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// レスポンスの生成
+	return h.revalidateJSON(c, storeToResponse(store))
+}
+
+// 店舗の更新
+func (h *Handler) updateStore(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	req := &api.Store{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック（価格の店舗と照合するよう正規化した名前で検証）
+	storeId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	if err = h.normalizeNames(&req.Name); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if err = c.Validate(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	if req.ID != nil && *req.ID != uint(storeId) {
+		return newHTTPError(http.StatusBadRequest, ErrIDUnchangeable)
+	}
+
+	// サービスの実行
+	store, err := h.service.UpdateStore(ctx, storeToEntity(req, uint(storeId), userId))
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		if errors.Is(err, repository.ErrDuplicated) {
+			return newHTTPError(http.StatusBadRequest, ErrStoreAlreadyExists)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusOK, storeToResponse(store), h.indent)
+}
+
+// 店舗の削除
+func (h *Handler) deleteStore(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	storeId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	if err = h.service.DeleteStore(ctx, uint(storeId), userId); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.NoContent(http.StatusNoContent)
+}
+
+// 近隣の店舗の最新の価格
+func (h *Handler) findNearbyPrices(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	var latitude, longitude float64
+	radius := float64(defaultNearbyRadius)
+	var excludeSuspicious bool
+	if err := echo.QueryParamsBinder(c).
+		MustFloat64("lat", &latitude).
+		MustFloat64("lng", &longitude).
+		Float64("radius", &radius).
+		Bool("excludeSuspicious", &excludeSuspicious).
+		BindError(); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック（NaNも範囲外として扱う）
+	if !(-90 <= latitude && latitude <= 90) {
+		return newHTTPError(http.StatusBadRequest, ErrLatitude)
+	}
+	if !(-180 <= longitude && longitude <= 180) {
+		return newHTTPError(http.StatusBadRequest, ErrLongitude)
+	}
+	if !(0 < radius && radius <= maxNearbyRadius) {
+		return newHTTPError(http.StatusBadRequest, ErrRadius)
+	}
+
+	// サービスの実行
+	nearby, err := h.service.FindNearbyPrices(ctx, userId, latitude, longitude, radius, time.Now(), excludeSuspicious)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成（距離順）
+	entities := make([]entity.Price, len(nearby))
+	for i, v := range nearby {
+		entities[i] = v.Price
+	}
+	priceList, err := h.pricesResponse(ctx, entities)
+	if err != nil {
+		return err
+	}
+	res := make([]*api.NearbyPrice, len(nearby))
+	for i, v := range nearby {
+		res[i] = &api.NearbyPrice{
+			Price:     *priceList[i],
+			Distance:  uint(math.Round(v.Store.Distance)),
+			Latitude:  v.Store.Latitude,
+			Longitude: v.Store.Longitude,
+			Address:   v.Store.Address,
+		}
+	}
+
+	return h.revalidateJSON(c, res)
+}
+
+func storeToEntity(store *api.Store, storeId, userId uint) *entity.Store {
+	return &entity.Store{
+		ID:        storeId,
+		UserID:    userId,
+		Name:      store.Name,
+		Latitude:  *store.Latitude,
+		Longitude: *store.Longitude,
+		Address:   store.Address,
+	}
+}
+
+func storeToResponse(store *entity.Store) *api.Store {
+	return &api.Store{
+		ID:        &store.ID,
+		Name:      store.Name,
+		Latitude:  &store.Latitude,
+		Longitude: &store.Longitude,
+		Address:   store.Address,
+	}
+}
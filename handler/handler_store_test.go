@@ -0,0 +1,361 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// 店舗の登録、一覧、更新、削除
+func TestStores(t *testing.T) {
+	testname := "TestStores"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 登録
+	body := `{"Name":"station", "Latitude":35.681236, "Longitude":139.767125, "Address":"東京都千代田区丸の内1丁目"}`
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/stores", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 201, rec.Code)
+	store := &api.Store{}
+	if err := json.Unmarshal(rec.Body.Bytes(), store); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "station", store.Name)
+	assert.Equal(t, 35.681236, *store.Latitude)
+	assert.Equal(t, 139.767125, *store.Longitude)
+	target := fmt.Sprintf("/v1/stores/%d", *store.ID)
+
+	// 同じ名前は登録できない
+	code, cause, err := execHandlerValidation(e, newRequest(http.MethodPost, "/v1/stores", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 400, code)
+	assert.Equal(t, handler.ErrStoreAlreadyExists, cause)
+
+	// 赤道と本初子午線の交点も登録できる
+	body = `{"Name":"null island", "Latitude":0, "Longitude":0}`
+	rec, err = execHandler(e, newRequest(http.MethodPost, "/v1/stores", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 201, rec.Code)
+
+	// 一覧（名前順）
+	rec, err = execHandler(e, newRequest(http.MethodGet, "/v1/stores", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := []api.Store{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &stores); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, stores, 2)
+	assert.Equal(t, "null island", stores[0].Name)
+	assert.Equal(t, "station", stores[1].Name)
+
+	// 更新
+	body = `{"Name":"station", "Latitude":35.6812, "Longitude":139.7671}`
+	rec, err = execHandler(e, newRequest(http.MethodPut, target, &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	updated := &api.Store{}
+	if err := json.Unmarshal(rec.Body.Bytes(), updated); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 35.6812, *updated.Latitude)
+	assert.Empty(t, updated.Address)
+
+	// 他のユーザからは見えない
+	code, cause, err = execHandlerValidation(e, newRequest(http.MethodGet, target, nil, "", genToken(conf, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 404, code)
+	assert.Equal(t, handler.ErrNotFound, cause)
+
+	// 削除
+	rec, err = execHandler(e, newRequest(http.MethodDelete, target, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 204, rec.Code)
+	code, _, err = execHandlerValidation(e, newRequest(http.MethodGet, target, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 404, code)
+}
+
+func TestStoresValidation(t *testing.T) {
+	testname := "TestStoresValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	for _, body := range []string{
+		`{"Name":"station", "Latitude":35.681236, "Longitude":139.767125}`,
+		`{"Name":"shibuya", "Latitude":35.658034, "Longitude":139.701636}`,
+	} {
+		if _, err := execHandler(e, newRequest(http.MethodPost, "/v1/stores", &body, echo.MIMEApplicationJSON, jwt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		method string
+		target string
+		body   string
+		code   int
+		err    error
+	}{
+		{http.MethodPost, "/v1/stores", `{"Latitude":35.6, "Longitude":139.7}`, 400, nil},
+		{http.MethodPost, "/v1/stores", `{"Name":"market", "Longitude":139.7}`, 400, nil},
+		{http.MethodPost, "/v1/stores", `{"Name":"market", "Latitude":35.6}`, 400, nil},
+		{http.MethodPost, "/v1/stores", `{"Name":"market", "Latitude":90.1, "Longitude":139.7}`, 400, nil},
+		{http.MethodPost, "/v1/stores", `{"Name":"market", "Latitude":35.6, "Longitude":-180.1}`, 400, nil},
+		{http.MethodPost, "/v1/stores", `{"ID":3, "Name":"market", "Latitude":35.6, "Longitude":139.7}`, 400, handler.ErrIDCannotRequest},
+		{http.MethodPut, "/v1/stores/2", `{"Name":"station", "Latitude":35.6, "Longitude":139.7}`, 400, handler.ErrStoreAlreadyExists},
+		{http.MethodPost, "/v1/stores", `{"Name":"ｓｔａｔｉｏｎ", "Latitude":35.6, "Longitude":139.7}`, 400, handler.ErrStoreAlreadyExists}, // 正規化した名前で重複
+		{http.MethodPut, "/v1/stores/2", `{"Name":" station ", "Latitude":35.6, "Longitude":139.7}`, 400, handler.ErrStoreAlreadyExists},
+		{http.MethodPut, "/v1/stores/2", `{"ID":1, "Name":"shibuya", "Latitude":35.6, "Longitude":139.7}`, 400, handler.ErrIDUnchangeable},
+		{http.MethodPut, "/v1/stores/3", `{"Name":"market", "Latitude":35.6, "Longitude":139.7}`, 404, handler.ErrNotFound},
+		{http.MethodDelete, "/v1/stores/3", "", 404, handler.ErrNotFound},
+	}
+
+	for _, v := range cases {
+		var body *string
+		if v.body != "" {
+			body = &v.body
+		}
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, newRequest(v.method, v.target, body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.target+" "+v.body)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.target+" "+v.body)
+		}
+	}
+
+	// 他のユーザの店舗は操作できない
+	code, cause, err := execHandlerValidation(e, newRequest(http.MethodDelete, "/v1/stores/1", nil, "", genToken(conf, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 404, code)
+	assert.Equal(t, handler.ErrNotFound, cause)
+}
+
+// 近隣の店舗の最新の価格
+func TestNearbyPrices(t *testing.T) {
+	testname := "TestNearbyPrices"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	requests := []struct {
+		target string
+		body   string
+	}{
+		{"/v1/stores", `{"Name":"station", "Latitude":35.681236, "Longitude":139.767125}`},
+		{"/v1/stores", `{"Name":"shibuya", "Latitude":35.658034, "Longitude":139.701636}`},
+		{"/v1/stores", `{"Name":"osaka", "Latitude":34.702485, "Longitude":135.495951}`},
+		{"/v1/prices", `{"DateTime":"2023-05-19 12:34:56", "Store":"station", "Product":"ssd1T", "Price":9500}`},
+		{"/v1/prices", `{"DateTime":"2023-05-20 12:34:56", "Store":"station", "Product":"ssd1T", "Price":9000}`},
+		{"/v1/prices", `{"DateTime":"2023-05-18 12:34:56", "Store":"station", "Product":"hdd2T", "Price":7000}`},
+		{"/v1/prices", `{"DateTime":"2023-05-19 12:34:56", "Store":"shibuya", "Product":"ssd1T", "Price":9800}`},
+		{"/v1/prices", `{"DateTime":"2023-05-19 12:34:56", "Store":"osaka", "Product":"ssd1T", "Price":8000}`},
+		{"/v1/prices", `{"DateTime":"2023-05-19 12:34:56", "Store":"unknown", "Product":"ssd1T", "Price":100}`},
+	}
+	for _, v := range requests {
+		rec, err := execHandler(e, newRequest(http.MethodPost, v.target, &v.body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 201, rec.Code, v.body)
+	}
+
+	// 距離順で、店舗と商品ごとに最新の価格
+	nearby := findNearbyPrices(t, e, jwt, "?lat=35.681&lng=139.767&radius=10000")
+	assert.Len(t, nearby, 3)
+	assert.Equal(t, "station", nearby[0].Store)
+	assert.Equal(t, "hdd2T", nearby[0].Product)
+	assert.Equal(t, uint(7000), nearby[0].Price.Price)
+	assert.Equal(t, "station", nearby[1].Store)
+	assert.Equal(t, "ssd1T", nearby[1].Product)
+	assert.Equal(t, uint(9000), nearby[1].Price.Price)
+	assert.Less(t, nearby[1].Distance, uint(100))
+	assert.Equal(t, "shibuya", nearby[2].Store)
+	assert.InDelta(t, 6455, nearby[2].Distance, 100)
+	assert.Equal(t, 35.658034, nearby[2].Latitude)
+
+	// 半径の省略は1000m
+	nearby = findNearbyPrices(t, e, jwt, "?lat=35.681&lng=139.767")
+	assert.Len(t, nearby, 2)
+
+	// 遠方
+	nearby = findNearbyPrices(t, e, jwt, "?lat=35.681&lng=139.767&radius=50000")
+	assert.Len(t, nearby, 3)
+	nearby = findNearbyPrices(t, e, jwt, "?lat=34.7&lng=135.5&radius=1000")
+	assert.Len(t, nearby, 1)
+	assert.Equal(t, uint(8000), nearby[0].Price.Price)
+
+	// 他のユーザの店舗は対象外
+	rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/prices/nearby?lat=35.681&lng=139.767", nil, "", genToken(conf, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.JSONEq(t, `[]`, rec.Body.String())
+
+	// 入力チェック
+	cases := []struct {
+		query string
+		err   error
+	}{
+		{"?lat=91&lng=139.767", handler.ErrLatitude},
+		{"?lat=NaN&lng=139.767", handler.ErrLatitude},
+		{"?lat=35.681&lng=-181", handler.ErrLongitude},
+		{"?lat=35.681&lng=139.767&radius=0", handler.ErrRadius},
+		{"?lat=35.681&lng=139.767&radius=50001", handler.ErrRadius},
+		{"?lng=139.767", nil},
+		{"?lat=35.681&lng=east", nil},
+	}
+	for _, v := range cases {
+		code, cause, err := execHandlerValidation(e, newRequest(http.MethodGet, "/v1/prices/nearby"+v.query, nil, "", jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 400, code, v.query)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.query)
+		}
+	}
+}
+
+// 有効期間外の価格と外れ値の疑いがある価格は近隣の店舗の最新の価格の対象外
+func TestNearbyPricesValidity(t *testing.T) {
+	testname := "TestNearbyPricesValidity"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	now := time.Now().In(conf.Location)
+	days := func(n int) string {
+		return now.AddDate(0, 0, n).Format(time.DateTime)
+	}
+
+	requests := []struct {
+		target string
+		body   string
+	}{
+		{"/v1/stores", `{"Name":"station", "Latitude":35.681236, "Longitude":139.767125}`},
+		{"/v1/prices", fmt.Sprintf(`{"DateTime":"%s", "Store":"station", "Product":"milk", "Price":200}`, days(-10))},
+		{"/v1/prices", fmt.Sprintf(`{"DateTime":"%s", "Store":"station", "Product":"milk", "Price":150, "PriceType":"sale", "ValidTo":"%s"}`, days(-2), days(-1))},  // 期限切れ
+		{"/v1/prices", fmt.Sprintf(`{"DateTime":"%s", "Store":"station", "Product":"milk", "Price":120, "PriceType":"sale", "ValidFrom":"%s"}`, days(-1), days(1))}, // 開始前
+	}
+	for i, v := range []uint{200, 205, 198, 210, 202, 20} {
+		requests = append(requests, struct {
+			target string
+			body   string
+		}{"/v1/prices", fmt.Sprintf(`{"DateTime":"%s", "Store":"station", "Product":"egg", "Price":%d}`, days(i-10), v)}) // 最後は外れ値
+	}
+	for _, v := range requests {
+		rec, err := execHandler(e, newRequest(http.MethodPost, v.target, &v.body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assert.Equal(t, 201, rec.Code, v.body) {
+			t.FailNow()
+		}
+	}
+
+	// 期限切れの新しい価格より前の価格が有効で、外れ値の疑いがある価格は除外できる
+	for _, v := range []struct {
+		query    string
+		expected []uint
+	}{
+		{"?lat=35.681&lng=139.767", []uint{20, 200}},
+		{"?lat=35.681&lng=139.767&excludeSuspicious=true", []uint{202, 200}},
+	} {
+		nearby := findNearbyPrices(t, e, jwt, v.query)
+		actual := make([]uint, len(nearby))
+		for i, p := range nearby {
+			actual[i] = p.Price.Price
+		}
+		assert.Equal(t, v.expected, actual, v.query)
+	}
+}
+
+func findNearbyPrices(t *testing.T, e *echo.Echo, jwt *string, query string) []api.NearbyPrice {
+	rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/prices/nearby"+query, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code, query)
+	nearby := []api.NearbyPrice{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &nearby); err != nil {
+		t.Fatal(err)
+	}
+	return nearby
+}
@@ -0,0 +1,344 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// タグの付与と絞り込み
+func TestPriceTags(t *testing.T) {
+	testname := "TestPriceTags"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 登録（重複は除く）
+	bodies := []string{
+		`{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9500, "Tags":["sale", "online", "sale"]}`,
+		`{"DateTime":"2023-05-20 12:34:56", "Store":"pcshop", "Product":"hdd2T", "Price":7000, "Tags":["sale"]}`,
+		`{"DateTime":"2023-05-21 12:34:56", "Store":"pcshop", "Product":"ssd2T", "Price":15000}`,
+	}
+	ids := make([]uint, len(bodies))
+	for i, body := range bodies {
+		rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 201, rec.Code)
+		price := &api.Price{}
+		if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = *price.ID
+	}
+
+	// 絞り込み
+	cases := []struct {
+		query    string
+		expected []uint
+	}{
+		{"", []uint{ids[2], ids[1], ids[0]}},
+		{"?tag=sale", []uint{ids[1], ids[0]}},
+		{"?tag=online", []uint{ids[0]}},
+		{"?tag=sale&tag=online", []uint{ids[1], ids[0]}},
+		{"?tag=sale&tag=online&match=any", []uint{ids[1], ids[0]}},
+		{"?tag=sale&tag=online&match=all", []uint{ids[0]}},
+		{"?tag=sale&tag=sale&match=all", []uint{ids[1], ids[0]}},
+		{"?tag=member-price", []uint{}},
+	}
+	for _, v := range cases {
+		prices := findPricesByQuery(t, e, jwt, v.query)
+		actual := make([]uint, len(prices))
+		for i, p := range prices {
+			actual[i] = *p.ID
+		}
+		assert.Equal(t, v.expected, actual, v.query)
+	}
+	prices := findPricesByQuery(t, e, jwt, "?tag=online")
+	assert.Equal(t, []string{"online", "sale"}, prices[0].Tags) // 名前順
+
+	// 更新（Tagsの省略はタグを外す）
+	body := `{"DateTime":"2023-05-20 12:34:56", "Store":"pcshop", "Product":"hdd2T", "Price":6800}`
+	rec, err := execHandler(e, newRequest(http.MethodPut, fmt.Sprintf("/v1/prices/%d", ids[1]), &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "Tags")
+
+	// 部分更新（タグだけの変更でもバージョンが上がる）
+	body = `{"Tags":["bulk"]}`
+	rec, err = execHandler(e, newRequest(http.MethodPatch, fmt.Sprintf("/v1/prices/%d", ids[2]), &body, "application/merge-patch+json", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	patched := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), patched); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"bulk"}, patched.Tags)
+
+	prices = findPricesByQuery(t, e, jwt, "?tag=sale")
+	assert.Len(t, prices, 1)
+
+	// 他のユーザのタグでは絞り込めない
+	rec, err = execHandler(e, newRequest(http.MethodGet, "/v1/prices?tag=sale", nil, "", genToken(conf, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.JSONEq(t, `[]`, rec.Body.String())
+}
+
+// タグの登録、一覧、名前の変更、統合、削除
+func TestTags(t *testing.T) {
+	testname := "TestTags"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	body := `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9500, "Tags":["sale", "bargain"]}`
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	price := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+		t.Fatal(err)
+	}
+	priceTarget := fmt.Sprintf("/v1/prices/%d", *price.ID)
+
+	// 登録
+	body = `{"Name":"discount"}`
+	rec, err = execHandler(e, newRequest(http.MethodPost, "/v1/tags", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 201, rec.Code)
+	discount := &api.Tag{}
+	if err := json.Unmarshal(rec.Body.Bytes(), discount); err != nil {
+		t.Fatal(err)
+	}
+
+	// 一覧
+	tags := findTags(t, e, jwt)
+	assert.Len(t, tags, 3)
+	assert.Equal(t, "bargain", tags[0].Name)
+	assert.Equal(t, uint(1), tags[0].Count)
+	assert.Equal(t, "discount", tags[1].Name)
+	assert.Equal(t, uint(0), tags[1].Count)
+	assert.Equal(t, "sale", tags[2].Name)
+	bargain, sale := tags[0], tags[2]
+
+	// 名前の変更
+	body = `{"Name":"on-sale"}`
+	rec, err = execHandler(e, newRequest(http.MethodPut, fmt.Sprintf("/v1/tags/%d", *sale.ID), &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	assert.JSONEq(t, fmt.Sprintf(`{"ID":%d, "Name":"on-sale", "Count":1}`, *sale.ID), rec.Body.String())
+
+	// 統合
+	body = fmt.Sprintf(`{"Into":%d}`, *discount.ID)
+	for _, v := range []*api.Tag{bargain, sale} {
+		rec, err = execHandler(e, newRequest(http.MethodPost, fmt.Sprintf("/v1/tags/%d/merge", *v.ID), &body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 200, rec.Code)
+	}
+	assert.JSONEq(t, fmt.Sprintf(`{"ID":%d, "Name":"discount", "Count":1}`, *discount.ID), rec.Body.String())
+	tags = findTags(t, e, jwt)
+	assert.Len(t, tags, 1)
+
+	// 価格のタグとバージョンへの反映
+	rec, err = execHandler(e, newRequest(http.MethodGet, priceTarget, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `"4"`, rec.Header().Get("ETag"))
+	if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"discount"}, price.Tags)
+
+	// 削除
+	rec, err = execHandler(e, newRequest(http.MethodDelete, fmt.Sprintf("/v1/tags/%d", *discount.ID), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 204, rec.Code)
+	assert.Empty(t, findTags(t, e, jwt))
+
+	rec, err = execHandler(e, newRequest(http.MethodGet, priceTarget, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, rec.Body.String(), "Tags")
+
+	// 名前の変更、統合、削除は価格の変更履歴に記録
+	rec, err = execHandler(e, newRequest(http.MethodGet, priceTarget+"/revisions", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	revisions := []api.PriceRevision{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &revisions); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, revisions, 5)
+	expected := [][2][]string{
+		{[]string{"bargain", "sale"}, []string{"bargain", "on-sale"}},
+		{[]string{"bargain", "on-sale"}, []string{"discount", "on-sale"}},
+		{[]string{"discount", "on-sale"}, []string{"discount"}},
+		{[]string{"discount"}, nil},
+	}
+	for i, v := range expected {
+		revision := revisions[i+1]
+		assert.Equal(t, "update", revision.Operation)
+		assert.Equal(t, v[0], revision.Before.Tags)
+		assert.Equal(t, v[1], revision.After.Tags)
+	}
+
+	// 名前の変更の時点に戻すとタグも戻す
+	rec, err = execHandler(e, newRequest(http.MethodPost, fmt.Sprintf("%s/revisions/%d/revert", priceTarget, revisions[1].ID), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	if err := json.Unmarshal(rec.Body.Bytes(), price); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"bargain", "on-sale"}, price.Tags)
+}
+
+func TestTagsValidation(t *testing.T) {
+	testname := "TestTagsValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	body := `{"Name":"sale"}`
+	if _, err := execHandler(e, newRequest(http.MethodPost, "/v1/tags", &body, echo.MIMEApplicationJSON, jwt)); err != nil {
+		t.Fatal(err)
+	}
+	body = `{"Name":"online"}`
+	if _, err := execHandler(e, newRequest(http.MethodPost, "/v1/tags", &body, echo.MIMEApplicationJSON, jwt)); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		method string
+		target string
+		body   string
+		code   int
+		err    error
+	}{
+		{http.MethodPost, "/v1/prices", `{"Store":"pcshop", "Product":"ssd1T", "Price":9500, "Tags":["1","2","3","4","5","6","7","8","9","10","11"]}`, 400, nil},
+		{http.MethodPost, "/v1/prices", `{"Store":"pcshop", "Product":"ssd1T", "Price":9500, "Tags":[""]}`, 400, nil},
+		{http.MethodPost, "/v1/prices", `{"Store":"pcshop", "Product":"ssd1T", "Price":9500, "Tags":["0123456789012345678901234567890"]}`, 400, nil},
+		{http.MethodGet, "/v1/prices?tag=sale&match=none", "", 400, handler.ErrTagMatch},
+		{http.MethodPost, "/v1/tags", `{"Name":""}`, 400, nil},
+		{http.MethodPost, "/v1/tags", `{"Name":"sale"}`, 400, handler.ErrTagAlreadyExists},
+		{http.MethodPut, "/v1/tags/2", `{"Name":"sale"}`, 400, handler.ErrTagAlreadyExists},
+		{http.MethodPut, "/v1/tags/3", `{"Name":"bulk"}`, 404, handler.ErrNotFound},
+		{http.MethodPost, "/v1/tags/1/merge", `{"Into":1}`, 400, handler.ErrTagMergeSelf},
+		{http.MethodPost, "/v1/tags/1/merge", `{"Into":3}`, 404, handler.ErrNotFound},
+		{http.MethodPost, "/v1/tags/1/merge", `{}`, 400, nil},
+		{http.MethodDelete, "/v1/tags/3", "", 404, handler.ErrNotFound},
+	}
+
+	for _, v := range cases {
+		var body *string
+		if v.body != "" {
+			body = &v.body
+		}
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, newRequest(v.method, v.target, body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.target+" "+v.body)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.target+" "+v.body)
+		}
+	}
+
+	// 他のユーザのタグは操作できない
+	body = `{"Into":2}`
+	code, cause, err := execHandlerValidation(e, newRequest(http.MethodPost, "/v1/tags/1/merge", &body, echo.MIMEApplicationJSON, genToken(conf, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 404, code)
+	assert.Equal(t, handler.ErrNotFound, cause)
+}
+
+func findPricesByQuery(t *testing.T, e *echo.Echo, jwt *string, query string) []api.Price {
+	rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/prices"+query, nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prices := []api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &prices); err != nil {
+		t.Fatal(err)
+	}
+	return prices
+}
+
+func findTags(t *testing.T, e *echo.Echo, jwt *string) []*api.Tag {
+	rec, err := execHandler(e, newRequest(http.MethodGet, "/v1/tags", nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := []*api.Tag{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &tags); err != nil {
+		t.Fatal(err)
+	}
+	return tags
+}
@@ -28,10 +28,11 @@ import (
 )
 
 type testPgDB struct {
-	dbname string
-	sqlDB  *sql.DB
-	pool   *pgxpool.Pool
-	conn   *pgxpool.Conn
+	dbname  string
+	sqlDB   *sql.DB
+	pool    *pgxpool.Pool
+	conn    *pgxpool.Conn
+	blobDir string
 }
 
 var pgimage, mysqlimage *string
@@ -184,8 +185,11 @@ func setupTestMain(testname string, newService func(repository.Repository) servi
 	}
 	sqlDB := stdlib.OpenDBFromPool(pool)
 	testDB := &testPgDB{dbname: dbname, sqlDB: sqlDB, pool: pool}
+	if testDB.blobDir, err = os.MkdirTemp("", dbname); err != nil {
+		return nil, nil, testDB, nil, nil, err
+	}
 	driverName := "pgx"
-	r, err := repository.NewRepository(driverName, sqlDB)
+	r, err := repository.NewRepository(driverName, sqlDB, repository.NewLocalBlobStore(testDB.blobDir))
 	if err != nil {
 		return nil, nil, testDB, nil, nil, err
 	}
@@ -207,14 +211,15 @@ func setupTestMain(testname string, newService func(repository.Repository) servi
 		return nil, nil, testDB, nil, nil, err
 	}
 	conf := &handler.HandlerConfig{
-		JwtKey:           jwtkey,
-		ValidityMin:      validityMin,
-		DateTimeLayout:   time.DateTime,
-		Location:         location,
-		Indent:           "  ",
-		TimeoutSec:       60,
-		RequestBodyLimit: "1K",
-		RateLimit:        10,
+		JwtKey:                jwtkey,
+		ValidityMin:           validityMin,
+		DateTimeLayout:        time.DateTime,
+		Location:              location,
+		Indent:                "  ",
+		TimeoutSec:            60,
+		RequestBodyLimit:      "1K",
+		BatchRequestBodyLimit: "64K",
+		RateLimit:             10,
 	}
 	h := handler.NewHandler(s, conf)
 
@@ -242,7 +247,7 @@ func setupMySQLTest(testname string) (*echo.Echo, error) {
 	if err != nil {
 		return nil, err
 	}
-	r, err := repository.NewRepository(driverName, sqlDB)
+	r, err := repository.NewRepository(driverName, sqlDB, repository.NewLocalBlobStore(os.TempDir()))
 	if err != nil {
 		sqlDB.Close()
 		return nil, err
@@ -302,6 +307,9 @@ func cleanDB(testDB *testPgDB) error {
 		return nil
 	}
 	testDB.sqlDB.Close()
+	if testDB.blobDir != "" {
+		os.RemoveAll(testDB.blobDir)
+	}
 
 	ctx := context.Background()
 
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 削除済み価格の一覧
+func (h *Handler) findDeletedPrices(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+
+	// サービスの実行
+	entities, err := h.service.FindDeletedPrices(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成
+	sort.SliceStable(entities, func(i, j int) bool {
+		// 削除日時の降順
+		iDeletedAt := entities[i].DeletedAt.Time.UnixNano()
+		jDeletedAt := entities[j].DeletedAt.Time.UnixNano()
+		if iDeletedAt == jDeletedAt {
+			return entities[i].ID > entities[j].ID // 第二ソートキー
+		}
+		return iDeletedAt > jDeletedAt // 第一ソートキー
+	})
+	var lastModified time.Time
+	for _, v := range entities {
+		if v.DeletedAt.Time.After(lastModified) {
+			lastModified = v.DeletedAt.Time
+		}
+	}
+	if notModified(c, pricesETag(entities), lastModified) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	priceList := make([]*api.DeletedPrice, len(entities))
+	for i, v := range entities {
+		priceList[i] = &api.DeletedPrice{
+			Price:     *h.entityToResponse(&v),
+			DeletedAt: h.formatDateTime(v.DeletedAt.Time),
+		}
+	}
+
+	return c.JSONPretty(http.StatusOK, priceList, h.indent)
+}
+
+// 削除済み価格の復元
+func (h *Handler) restorePrice(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	priceId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	price, err := h.service.RestorePrice(ctx, uint(priceId), userId)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusOK, h.entityToResponse(price), h.indent)
+}
+
+// 削除済み価格の完全削除
+func (h *Handler) purgePrice(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	priceId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	if err = h.service.PurgePrice(ctx, uint(priceId), userId); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.NoContent(http.StatusNoContent)
+}
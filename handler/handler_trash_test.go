@@ -0,0 +1,422 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 削除済み価格の一覧の正常系
+func TestFindDeletedPrices(t *testing.T) {
+	testname := "TestFindDeletedPrices"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	if _, err := insertPrices(tx, &now, somePrices()); err != nil {
+		t.Fatal(err)
+	}
+	userId := uint(1)
+	deletedAt := now.Add(-time.Hour)
+	priceId, err := insertPrice(tx, &now, &now, &deletedAt, userId, now, "pcshop", "ssd1T", 9500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := insertPrice(tx, &now, &now, &deletedAt, 2, now, "pcshop", "ssd2T", 15800); err != nil {
+		t.Fatal(err)
+	}
+
+	// リクエストの生成
+	req := newRequest(
+		http.MethodGet,
+		"/v1/trash/prices",
+		nil,
+		"",
+		genToken(conf, userId),
+	)
+
+	// テストの実行
+	rec, diff, before, err := execHandlerTest(e, testDB, tx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 200, rec.Code)
+
+	res := []api.DeletedPrice{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, diff)
+
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, priceId, *res[0].ID)
+	entity := before.findPrice(priceId)
+	assert.Equal(t, res[0].DeletedAt, entity.DeletedAt.Time.In(conf.Location).Format(conf.DateTimeLayout))
+	assert.Equal(t, res[0].Store, entity.Store)
+	assert.Equal(t, res[0].Product, entity.Product)
+	assert.Equal(t, res[0].Price.Price, entity.Price)
+}
+
+// 削除済み価格の復元の正常系
+func TestRestorePrice(t *testing.T) {
+	testname := "TestRestorePrice"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	if _, err := insertPrices(tx, &now, somePrices()); err != nil {
+		t.Fatal(err)
+	}
+	userId := uint(1)
+	deletedAt := now.Add(-time.Hour)
+	priceId, err := insertPrice(tx, &now, &now, &deletedAt, userId, now, "pcshop", "ssd1T", 9500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// リクエストの生成
+	req := newRequest(
+		http.MethodPost,
+		fmt.Sprintf("/v1/trash/prices/%d/restore", priceId),
+		nil,
+		"",
+		genToken(conf, userId),
+	)
+
+	// テストの実行
+	rec, diff, before, err := execHandlerTest(e, testDB, tx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 200, rec.Code)
+
+	res := &api.Price{}
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, priceId, *res.ID)
+
+	assert.NotNil(t, diff)
+	assert.Zero(t, diff.created.count())
+	assert.Equal(t, 1, diff.updated.count())
+	assert.Zero(t, diff.logicalDeleted.count())
+	assert.Zero(t, diff.physicalDeleted.count())
+
+	entity := diff.updated.priceAny() // just one
+	beforeEntity := before.findPrice(priceId)
+	assert.Equal(t, priceId, entity.ID)
+	assert.True(t, beforeEntity.DeletedAt.Valid)
+	assert.False(t, entity.DeletedAt.Valid)
+	assert.Equal(t, beforeEntity.Version+1, entity.Version)
+	assert.Equal(t, beforeEntity.Store, entity.Store)
+	assert.Equal(t, beforeEntity.Product, entity.Product)
+	assert.Equal(t, beforeEntity.Price, entity.Price)
+}
+
+// 削除済み価格の復元のバリデーション
+func TestRestorePriceValidation(t *testing.T) {
+	testname := "TestRestorePriceValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	userId := uint(1)
+	now := time.Now()
+	priceId, err := insertPrice(tx, &now, &now, &now, userId, now, "store", "product", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeId, err := insertPrice(tx, &now, &now, nil, userId, now, "store", "product", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, userId)
+	invalidToken := *jwt + "x"
+	priceIdStr := strconv.FormatUint(uint64(priceId), 10)
+	cases := []struct {
+		jwt     *string
+		priceId string
+		code    int
+		err     error
+	}{
+		{nil, priceIdStr, 401, nil},
+		{&invalidToken, priceIdStr, 401, nil},
+		{genToken(conf, userId+1), priceIdStr, 404, handler.ErrNotFound},
+		{jwt, strconv.FormatUint(uint64(activeId), 10), 404, handler.ErrNotFound},
+		{jwt, "a", 404, handler.ErrNotFound},
+		{jwt, priceIdStr, 200, nil},
+		{jwt, priceIdStr, 404, handler.ErrNotFound},
+	}
+
+	for _, v := range cases {
+		// リクエストの生成
+		req := newRequest(
+			http.MethodPost,
+			fmt.Sprintf("/v1/trash/prices/%s/restore", v.priceId),
+			nil,
+			"",
+			v.jwt,
+		)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause)
+		}
+	}
+}
+
+// 削除済み価格の完全削除の正常系
+func TestPurgePrice(t *testing.T) {
+	testname := "TestPurgePrice"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	if _, err := insertPrices(tx, &now, somePrices()); err != nil {
+		t.Fatal(err)
+	}
+	userId := uint(1)
+	priceId, err := insertPrice(tx, &now, &now, &now, userId, now, "pcshop", "ssd1T", 9500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const notificationSQL = "INSERT INTO notifications (created_at, user_id, alert_rule_id, price_id, date_time, store, product, price) VALUES ($1, $2, 1, $3, $1, 'pcshop', 'ssd1T', 9500)"
+	if _, err := tx.Exec(t.Context(), notificationSQL, now, userId, priceId); err != nil {
+		t.Fatal(err)
+	}
+
+	// リクエストの生成
+	req := newRequest(
+		http.MethodDelete,
+		fmt.Sprintf("/v1/trash/prices/%d", priceId),
+		nil,
+		"",
+		genToken(conf, userId),
+	)
+
+	// テストの実行
+	rec, diff, _, err := execHandlerTest(e, testDB, tx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 204, rec.Code)
+
+	assert.NotNil(t, diff)
+	assert.Zero(t, diff.created.count())
+	assert.Zero(t, diff.updated.count())
+	assert.Zero(t, diff.logicalDeleted.count())
+	assert.Equal(t, 1, diff.physicalDeleted.count())
+
+	assert.Equal(t, 1, len(diff.physicalDeleted.prices))
+	assert.Equal(t, priceId, diff.physicalDeleted.priceAny().ID)
+
+	// 価格を参照する通知も削除
+	var count int
+	if err := testDB.pool.QueryRow(t.Context(), "SELECT count(*) FROM notifications WHERE price_id = $1", priceId).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	assert.Zero(t, count)
+
+	// 変更履歴は残して完全削除を記録
+	if err := testDB.pool.QueryRow(t.Context(), "SELECT count(*) FROM price_revisions WHERE price_id = $1 AND operation = 'purge' AND user_id = $2", priceId, userId).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, count)
+}
+
+// 削除済み価格の完全削除のバリデーション
+func TestPurgePriceValidation(t *testing.T) {
+	testname := "TestPurgePriceValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	userId := uint(1)
+	now := time.Now()
+	priceId, err := insertPrice(tx, &now, &now, &now, userId, now, "store", "product", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeId, err := insertPrice(tx, &now, &now, nil, userId, now, "store", "product", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, userId)
+	invalidToken := *jwt + "x"
+	priceIdStr := strconv.FormatUint(uint64(priceId), 10)
+	cases := []struct {
+		jwt     *string
+		priceId string
+		code    int
+		err     error
+	}{
+		{nil, priceIdStr, 401, nil},
+		{&invalidToken, priceIdStr, 401, nil},
+		{genToken(conf, userId+1), priceIdStr, 404, handler.ErrNotFound},
+		{jwt, strconv.FormatUint(uint64(activeId), 10), 404, handler.ErrNotFound}, // 削除されていない価格は対象外
+		{jwt, "a", 404, handler.ErrNotFound},
+		{jwt, priceIdStr, 204, nil},
+		{jwt, priceIdStr, 404, handler.ErrNotFound},
+	}
+
+	for _, v := range cases {
+		// リクエストの生成
+		req := newRequest(
+			http.MethodDelete,
+			fmt.Sprintf("/v1/trash/prices/%s", v.priceId),
+			nil,
+			"",
+			v.jwt,
+		)
+
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause)
+		}
+	}
+}
+
+// 保持期間を過ぎた削除済み価格の完全削除
+func TestPurgeDeletedPrices(t *testing.T) {
+	testname := "TestPurgeDeletedPrices"
+
+	// セットアップ
+	_, _, testDB, tx, mock, err := setupMockTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// データベースの初期データ生成
+	now := time.Now()
+	if _, err := insertPrices(tx, &now, somePrices()); err != nil {
+		t.Fatal(err)
+	}
+	expired := now.AddDate(0, 0, -31)
+	expiredId, err := insertPrice(tx, &now, &now, &expired, 1, now, "pcshop", "ssd1T", 9500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retained := now.AddDate(0, 0, -29)
+	retainedId, err := insertPrice(tx, &now, &now, &retained, 2, now, "pcshop", "ssd2T", 15800)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const notificationSQL = "INSERT INTO notifications (created_at, user_id, alert_rule_id, price_id, date_time, store, product, price) VALUES ($1, $2, 1, $3, $1, 'pcshop', 'ssd1T', 9500)"
+	for userId, priceId := range map[uint]uint{1: expiredId, 2: retainedId} {
+		if _, err := tx.Exec(t.Context(), notificationSQL, now, userId, priceId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	testDB.conn.Release()
+
+	// テストの実行
+	rows, err := mock.PurgeDeletedPrices(t.Context(), now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, int64(1), rows)
+
+	var count int
+	if err := testDB.pool.QueryRow(t.Context(), "SELECT count(*) FROM prices WHERE id = $1", expiredId).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	assert.Zero(t, count)
+	if err := testDB.pool.QueryRow(t.Context(), "SELECT count(*) FROM prices WHERE id = $1", retainedId).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, count)
+
+	// 完全削除した価格を参照する通知だけ削除
+	for priceId, expected := range map[uint]int{expiredId: 0, retainedId: 1} {
+		if err := testDB.pool.QueryRow(t.Context(), "SELECT count(*) FROM notifications WHERE price_id = $1", priceId).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expected, count, priceId)
+	}
+
+	// 完全削除した価格だけ所有者の変更として完全削除を記録
+	for _, v := range []struct {
+		priceId, userId uint
+		expected        int
+	}{{expiredId, 1, 1}, {retainedId, 2, 0}} {
+		if err := testDB.pool.QueryRow(t.Context(), "SELECT count(*) FROM price_revisions WHERE price_id = $1 AND operation = 'purge' AND user_id = $2", v.priceId, v.userId).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, v.expected, count, v.priceId)
+	}
+}
@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/entity"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Webhookの購読の登録
+func (h *Handler) createWebhook(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	req := &api.Webhook{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック
+	if err := h.validateWebhook(c, req); err != nil {
+		return err
+	}
+	if req.ID != nil {
+		return newHTTPError(http.StatusBadRequest, ErrIDCannotRequest)
+	}
+
+	// サービスの実行
+	webhook, err := h.service.CreateWebhook(ctx, webhookToEntity(req, 0, userId))
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusCreated, webhookToResponse(webhook), h.indent)
+}
+
+// Webhookの購読の一覧
+func (h *Handler) findWebhooks(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+
+	// サービスの実行
+	entities, err := h.service.FindWebhooks(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	// レスポンスの生成（登録順）
+	webhooks := make([]*api.Webhook, len(entities))
+	for i, v := range entities {
+		webhooks[i] = webhookToResponse(&v)
+	}
+
+	return h.revalidateJSON(c, webhooks)
+}
+
+// Webhookの購読の取得
+func (h *Handler) findWebhook(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	webhookId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	webhook, err := h.service.FindWebhook(ctx, uint(webhookId), userId)
+	if err != nil {
+		return err
+	}
+	if webhook == nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// レスポンスの生成
+	return h.revalidateJSON(c, webhookToResponse(webhook))
+}
+
+// Webhookの購読の更新
+func (h *Handler) updateWebhook(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+	req := &api.Webhook{}
+	if err := c.Bind(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+
+	// 入力チェック
+	webhookId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+	if err = h.validateWebhook(c, req); err != nil {
+		return err
+	}
+	if req.ID != nil && *req.ID != uint(webhookId) {
+		return newHTTPError(http.StatusBadRequest, ErrIDUnchangeable)
+	}
+
+	// サービスの実行
+	webhook, err := h.service.UpdateWebhook(ctx, webhookToEntity(req, uint(webhookId), userId))
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.JSONPretty(http.StatusOK, webhookToResponse(webhook), h.indent)
+}
+
+// Webhookの購読の削除
+func (h *Handler) deleteWebhook(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	webhookId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	if err = h.service.DeleteWebhook(ctx, uint(webhookId), userId); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Webhookの配信の一覧
+func (h *Handler) findWebhookDeliveries(c echo.Context) error {
+	ctx := c.Request().Context()
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// リクエストの取得
+	userId := h.userId(c)
+	reqId := c.Param("id")
+
+	// 入力チェック
+	webhookId, err := strconv.ParseUint(reqId, 10, 0)
+	if err != nil {
+		return newHTTPError(http.StatusNotFound, ErrNotFound)
+	}
+
+	// サービスの実行
+	entities, err := h.service.FindWebhookDeliveries(ctx, uint(webhookId), userId)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return newHTTPError(http.StatusNotFound, ErrNotFound)
+		}
+		return err
+	}
+
+	// レスポンスの生成（新しい順）
+	deliveries := make([]*api.WebhookDelivery, len(entities))
+	for i, v := range entities {
+		delivery := &api.WebhookDelivery{
+			ID:             v.ID,
+			Event:          v.Event,
+			Payload:        []byte(v.Payload),
+			Status:         v.Status,
+			Attempts:       v.Attempts,
+			LastStatusCode: v.LastStatusCode,
+			LastError:      v.LastError,
+			CreatedAt:      h.formatDateTime(v.CreatedAt),
+		}
+		if v.Status == service.DeliveryPending {
+			nextAttemptAt := h.formatDateTime(v.NextAttemptAt)
+			delivery.NextAttemptAt = &nextAttemptAt
+		}
+		if v.DeliveredAt != nil {
+			deliveredAt := h.formatDateTime(*v.DeliveredAt)
+			delivery.DeliveredAt = &deliveredAt
+		}
+		deliveries[i] = delivery
+	}
+
+	return h.revalidateJSON(c, deliveries)
+}
+
+func (h *Handler) validateWebhook(c echo.Context, req *api.Webhook) error {
+	if err := c.Validate(req); err != nil {
+		return newHTTPError(http.StatusBadRequest, err)
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return newHTTPError(http.StatusBadRequest, ErrWebhookURLScheme)
+	}
+	if err = service.CheckWebhookHost(c.Request().Context(), u.Hostname(), h.allowPrivateWebhook); err != nil {
+		if errors.Is(err, service.ErrWebhookHost) {
+			return newHTTPError(http.StatusBadRequest, ErrWebhookURLHost)
+		}
+		return err
+	}
+	return nil
+}
+
+func webhookToEntity(req *api.Webhook, webhookId, userId uint) *entity.Webhook {
+	webhook := &entity.Webhook{
+		UserID: userId,
+		URL:    req.URL,
+		Events: req.Events,
+		Secret: req.Secret,
+	}
+	webhook.ID = webhookId
+	return webhook
+}
+
+// 署名の鍵はレスポンスに含めない
+func webhookToResponse(webhook *entity.Webhook) *api.Webhook {
+	return &api.Webhook{
+		ID:     &webhook.ID,
+		URL:    webhook.URL,
+		Events: webhook.Events,
+	}
+}
@@ -0,0 +1,298 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ystkg/rest-example/api"
+	"github.com/ystkg/rest-example/handler"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// Webhookの受信側
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*receivedWebhook
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, &receivedWebhook{req.Header.Clone(), body})
+	w.WriteHeader(r.status)
+}
+
+func (r *webhookReceiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *webhookReceiver) received() []*receivedWebhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*receivedWebhook{}, r.requests...)
+}
+
+// Webhookの配信、再試行、デッドレター
+func TestWebhookDeliveries(t *testing.T) {
+	testname := "TestWebhookDeliveries"
+
+	// セットアップ
+	e, conf, testDB, tx, mock, err := setupMockTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+	receiver := &webhookReceiver{status: http.StatusNoContent}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	// テストの受信側はループバックアドレス
+	privateConf := *conf
+	privateConf.AllowPrivateWebhook = true
+	e = handler.NewEcho(handler.NewHandler(mock, &privateConf))
+
+	jwt := genToken(conf, 1)
+	secret := "0123456789abcdef"
+
+	// Webhookの購読の登録（更新は購読しない）
+	body := fmt.Sprintf(`{"URL":"%s/hook", "Events":["price.created", "price.deleted"], "Secret":"%s"}`, server.URL, secret)
+	rec, err := execHandler(e, newRequest(http.MethodPost, "/v1/webhooks", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 201, rec.Code)
+	webhook := &api.Webhook{}
+	if err := json.Unmarshal(rec.Body.Bytes(), webhook); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, webhook.Secret)
+
+	// 価格の登録と更新
+	body = `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9500}`
+	if _, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt)); err != nil {
+		t.Fatal(err)
+	}
+	body = `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":8900}`
+	if _, err := execHandler(e, newRequest(http.MethodPut, "/v1/prices/1", &body, echo.MIMEApplicationJSON, jwt)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 配信
+	now := time.Now()
+	delivered, err := mock.DeliverWebhooks(t.Context(), now, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, delivered)
+	received := receiver.received()
+	assert.Equal(t, 1, len(received))
+	header := received[0].header
+	assert.Equal(t, "price.created", header.Get(service.HeaderWebhookEvent))
+	assert.Equal(t, strconv.FormatInt(now.Unix(), 10), header.Get(service.HeaderWebhookTimestamp))
+	assert.Equal(t, "sha256="+service.SignWebhook(secret, header.Get(service.HeaderWebhookTimestamp), received[0].body), header.Get(service.HeaderWebhookSignature))
+	payload := struct {
+		Event string
+		Price struct {
+			ID    uint
+			Price uint
+		}
+	}{}
+	if err := json.Unmarshal(received[0].body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "price.created", payload.Event)
+	assert.Equal(t, uint(1), payload.Price.ID)
+	assert.Equal(t, uint(9500), payload.Price.Price)
+
+	// 配信済みは再配信しない
+	delivered, err = mock.DeliverWebhooks(t.Context(), now.Add(time.Hour), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, delivered)
+
+	// 受信側のエラーで再試行して上限でデッドレター
+	receiver.setStatus(http.StatusInternalServerError)
+	if _, err := execHandler(e, newRequest(http.MethodDelete, "/v1/prices/1", nil, "", jwt)); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		// 再試行の間隔を過ぎた日時で配信
+		if _, err := mock.DeliverWebhooks(t.Context(), now.AddDate(0, 0, i+1), true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, 1+8, len(receiver.received()))
+
+	// 配信の一覧
+	rec, err = execHandler(e, newRequest(http.MethodGet, fmt.Sprintf("/v1/webhooks/%d/deliveries", *webhook.ID), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, rec.Code)
+	deliveries := []api.WebhookDelivery{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &deliveries); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(deliveries))
+	assert.Equal(t, "price.deleted", deliveries[0].Event)
+	assert.Equal(t, "dead", deliveries[0].Status)
+	assert.Equal(t, 8, deliveries[0].Attempts)
+	assert.Equal(t, 500, *deliveries[0].LastStatusCode)
+	assert.NotNil(t, deliveries[0].LastError)
+	assert.Nil(t, deliveries[0].NextAttemptAt)
+	assert.Equal(t, "price.created", deliveries[1].Event)
+	assert.Equal(t, "succeeded", deliveries[1].Status)
+	assert.Equal(t, 1, deliveries[1].Attempts)
+	assert.NotNil(t, deliveries[1].DeliveredAt)
+}
+
+// 登録後に内部ネットワークになった配信先には接続しない
+func TestWebhookDeliveriesPrivateHost(t *testing.T) {
+	testname := "TestWebhookDeliveriesPrivateHost"
+
+	// セットアップ
+	e, conf, testDB, tx, mock, err := setupMockTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+	receiver := &webhookReceiver{status: http.StatusNoContent}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	// 複数のリクエストを実行するため事前にコミット
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+
+	// 許可した設定で登録
+	privateConf := *conf
+	privateConf.AllowPrivateWebhook = true
+	private := handler.NewEcho(handler.NewHandler(mock, &privateConf))
+	body := fmt.Sprintf(`{"URL":"%s/hook", "Events":["price.created"], "Secret":"0123456789abcdef"}`, server.URL)
+	rec, err := execHandler(private, newRequest(http.MethodPost, "/v1/webhooks", &body, echo.MIMEApplicationJSON, jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 201, rec.Code) {
+		t.FailNow()
+	}
+	webhook := &api.Webhook{}
+	if err := json.Unmarshal(rec.Body.Bytes(), webhook); err != nil {
+		t.Fatal(err)
+	}
+	body = `{"DateTime":"2023-05-19 12:34:56", "Store":"pcshop", "Product":"ssd1T", "Price":9500}`
+	if _, err := execHandler(e, newRequest(http.MethodPost, "/v1/prices", &body, echo.MIMEApplicationJSON, jwt)); err != nil {
+		t.Fatal(err)
+	}
+
+	// テストの実行
+	delivered, err := mock.DeliverWebhooks(t.Context(), time.Now(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// アサーション
+	assert.Equal(t, 0, delivered)
+	assert.Empty(t, receiver.received())
+	rec, err = execHandler(e, newRequest(http.MethodGet, fmt.Sprintf("/v1/webhooks/%d/deliveries", *webhook.ID), nil, "", jwt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveries := []api.WebhookDelivery{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &deliveries); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Equal(t, 1, len(deliveries)) && assert.NotNil(t, deliveries[0].LastError) {
+		assert.Contains(t, *deliveries[0].LastError, service.ErrWebhookHost.Error())
+	}
+}
+
+// Webhookの購読のバリデーション
+func TestCreateWebhookValidation(t *testing.T) {
+	testname := "TestCreateWebhookValidation"
+
+	// セットアップ
+	e, conf, testDB, tx, err := setupTest(testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanIfSuccess(t, testDB)
+
+	// バリデーションのテストは事前にコミットしてテーブル駆動
+	if err := tx.Commit(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	jwt := genToken(conf, 1)
+	cases := []struct {
+		body string
+		code int
+		err  error
+	}{
+		{`{"URL":"ftp://example.com/", "Events":["price.created"], "Secret":"0123456789abcdef"}`, 400, handler.ErrWebhookURLScheme},
+		{`{"URL":"example.com", "Events":["price.created"], "Secret":"0123456789abcdef"}`, 400, nil},
+		{`{"URL":"https://example.com/", "Events":[], "Secret":"0123456789abcdef"}`, 400, nil},
+		{`{"URL":"https://example.com/", "Events":["price.read"], "Secret":"0123456789abcdef"}`, 400, nil},
+		{`{"URL":"https://example.com/", "Events":["price.created"], "Secret":"short"}`, 400, nil},
+		{`{"ID":1, "URL":"https://example.com/", "Events":["price.created"], "Secret":"0123456789abcdef"}`, 400, handler.ErrIDCannotRequest},
+		{`{"URL":"http://127.0.0.1:8080/", "Events":["price.created"], "Secret":"0123456789abcdef"}`, 400, handler.ErrWebhookURLHost},
+		{`{"URL":"http://localhost/", "Events":["price.created"], "Secret":"0123456789abcdef"}`, 400, handler.ErrWebhookURLHost},
+		{`{"URL":"http://[::1]/", "Events":["price.created"], "Secret":"0123456789abcdef"}`, 400, handler.ErrWebhookURLHost},
+		{`{"URL":"http://10.0.0.1/", "Events":["price.created"], "Secret":"0123456789abcdef"}`, 400, handler.ErrWebhookURLHost},
+		{`{"URL":"http://192.168.1.1/", "Events":["price.created"], "Secret":"0123456789abcdef"}`, 400, handler.ErrWebhookURLHost},
+		{`{"URL":"http://169.254.169.254/latest/meta-data/", "Events":["price.created"], "Secret":"0123456789abcdef"}`, 400, handler.ErrWebhookURLHost},
+		{`{"URL":"http://[::ffff:10.0.0.1]/", "Events":["price.created"], "Secret":"0123456789abcdef"}`, 400, handler.ErrWebhookURLHost},
+		{`{"URL":"http://0.0.0.0/", "Events":["price.created"], "Secret":"0123456789abcdef"}`, 400, handler.ErrWebhookURLHost},
+		{`{"URL":"https://example.com/", "Events":["price.created"], "Secret":"0123456789abcdef"}`, 201, nil},
+	}
+
+	for _, v := range cases {
+		// テストの実行
+		code, cause, err := execHandlerValidation(e, newRequest(http.MethodPost, "/v1/webhooks", &v.body, echo.MIMEApplicationJSON, jwt))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// アサーション
+		assert.Equal(t, v.code, code, v.body)
+		if v.err != nil {
+			assert.Equal(t, v.err, cause, v.body)
+		}
+	}
+
+	// 他のユーザの配信は見えない
+	code, cause, err := execHandlerValidation(e, newRequest(http.MethodGet, "/v1/webhooks/1/deliveries", nil, "", genToken(conf, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 404, code)
+	assert.Equal(t, handler.ErrNotFound, cause)
+}
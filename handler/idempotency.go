@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+	"github.com/ystkg/rest-example/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerIdempotencyKey      = "Idempotency-Key"
+	headerIdempotentReplayed  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencyStatusReserved = 0 // 処理中
+)
+
+// 再送時に返すレスポンスヘッダ
+var idempotentHeaders = []string{echo.HeaderContentType, echo.HeaderLocation, "ETag"}
+
+// Idempotency-Keyヘッダがあれば同じキーの再送に保存したレスポンスを返す（POSTのルートに適用）
+//
+// リクエストボディを読むのでボディの上限のミドルウェアより後に適用する
+func (h *Handler) idempotency(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.Request().Header.Get(headerIdempotencyKey)
+		if key == "" {
+			return next(c)
+		}
+		if maxIdempotencyKeyLength < len(key) {
+			return newHTTPError(http.StatusBadRequest, ErrIdempotencyKey)
+		}
+		ctx := c.Request().Context()
+		userId := h.userId(c)
+
+		// リクエストの指紋（ボディはハンドラで読めるよう戻す）
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		c.Request().Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(c.Request(), body)
+
+		// キーの予約
+		existing, err := h.service.ReserveIdempotencyKey(ctx, userId, key, fingerprint, time.Now().Add(-h.idempotencyKeyRetention))
+		if err != nil {
+			if errors.Is(err, service.ErrAborted) {
+				return newHTTPError(http.StatusConflict, ErrIdempotencyKeyInProgress)
+			}
+			return err
+		}
+		if existing != nil {
+			return h.replayResponse(c, existing, fingerprint)
+		}
+
+		// 保存せずに終わった場合は同じキーで再び実行できるよう解放
+		ctx = context.WithoutCancel(ctx)
+		completed := false
+		defer func() {
+			if !completed {
+				if err := h.service.ReleaseIdempotencyKey(ctx, userId, key); err != nil {
+					slog.ErrorContext(ctx, err.Error())
+				}
+			}
+		}()
+
+		// レスポンスを記録しながら実行（エラーはここでレスポンスにする）
+		writer := &responseRecorder{ResponseWriter: c.Response().Writer}
+		c.Response().Writer = writer
+		if err := next(c); err != nil {
+			c.Error(err)
+		}
+		c.Response().Writer = writer.ResponseWriter
+
+		// サーバエラーは再試行できるよう保存しない
+		status := c.Response().Status
+		if http.StatusInternalServerError <= status {
+			return nil
+		}
+
+		// レスポンスの保存
+		header := make(http.Header, len(idempotentHeaders))
+		for _, name := range idempotentHeaders {
+			if values := c.Response().Header().Values(name); len(values) != 0 {
+				header[name] = values
+			}
+		}
+		b, err := json.Marshal(header)
+		if err != nil {
+			return err
+		}
+		if err := h.service.CompleteIdempotencyKey(ctx, userId, key, status, string(b), writer.body.Bytes()); err != nil {
+			slog.ErrorContext(ctx, err.Error())
+			return nil
+		}
+		completed = true
+
+		return nil
+	}
+}
+
+// 保存したレスポンスの再送
+func (h *Handler) replayResponse(c echo.Context, existing *entity.IdempotencyKey, fingerprint string) error {
+	if existing.Fingerprint != fingerprint {
+		return newHTTPError(http.StatusConflict, ErrIdempotencyKeyReused)
+	}
+	if existing.Status == idempotencyStatusReserved {
+		return newHTTPError(http.StatusConflict, ErrIdempotencyKeyInProgress)
+	}
+
+	var header http.Header
+	if err := json.Unmarshal([]byte(existing.Header), &header); err != nil {
+		return err
+	}
+	for name, values := range header {
+		c.Response().Header()[name] = values
+	}
+	c.Response().Header().Set(headerIdempotentReplayed, "true")
+	c.Response().WriteHeader(existing.Status)
+	_, err := c.Response().Write(existing.Body)
+	return err
+}
+
+// メソッド、パス、リクエストボディのSHA-256
+func requestFingerprint(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// 書き込んだレスポンスボディの記録
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
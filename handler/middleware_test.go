@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -32,7 +33,7 @@ func TestMiddlewareTimeout(t *testing.T) {
 
 	// テストの実行
 	before := time.Now()
-	ret := timeout(h.timeoutSec)(next)(c)
+	ret := timeout(h.timeoutSec, middleware.DefaultSkipper)(next)(c)
 	after := time.Now()
 
 	// アサーション
@@ -45,6 +46,36 @@ func TestMiddlewareTimeout(t *testing.T) {
 	assert.Equal(t, ret, err)
 }
 
+func TestMiddlewareTimeoutSkipped(t *testing.T) {
+	testname := "TestMiddlewareTimeoutSkipped"
+
+	// セットアップ
+	h := NewHandler(nil, &HandlerConfig{TimeoutSec: 60, RequestBodyLimit: "1K"})
+	e := NewEcho(h)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/events", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/v1/events")
+
+	err := errors.New(testname)
+	called := false
+	next := func(c echo.Context) error {
+		called = true
+		return err
+	}
+
+	// テストの実行
+	ret := timeout(h.timeoutSec, skipPaths("/v1/events"))(next)(c)
+
+	// アサーション
+	_, ok := c.Request().Context().Deadline()
+	assert.False(t, ok)
+
+	assert.True(t, called)
+	assert.Equal(t, ret, err)
+}
+
 func TestTraceRequestWithReqHeader(t *testing.T) {
 	testname := "TestTraceRequestWithReqHeader"
 
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"slices"
+
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -11,6 +13,7 @@ func NewEcho(h *Handler) *echo.Echo {
 	e := echo.New()
 
 	e.HideBanner = true
+	e.Server.RegisterOnShutdown(h.shutdownCancel)
 
 	e.HTTPErrorHandler = h.errorHandler
 
@@ -18,7 +21,10 @@ func NewEcho(h *Handler) *echo.Echo {
 
 	e.Use(middleware.RequestLogger())
 	e.Use(middleware.Recover())
-	e.Use(middleware.BodyLimit(h.requestBodyLimit))
+	e.Use(middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{
+		Skipper: skipPaths("/v1/prices\\:batch", "/v1/prices/import", "/v1/prices/:id/attachments", "/v1/lists", "/v1/lists/:id", "/v1/lists/:id/order", "/v1/purchases", "/v1/baskets", "/v1/baskets/:id"), // 個別の上限を適用するパス
+		Limit:   h.requestBodyLimit,
+	}))
 	e.Use(middleware.RateLimiter(middleware.NewRateLimiterMemoryStore(rate.Limit(h.rateLimit))))
 	e.Use(middleware.SecureWithConfig(middleware.SecureConfig{
 		XSSProtection:         middleware.DefaultSecureConfig.XSSProtection,
@@ -29,7 +35,7 @@ func NewEcho(h *Handler) *echo.Echo {
 		HSTSPreloadEnabled:    true,
 	}))
 	e.Use(noCache)
-	e.Use(timeout(h.timeoutSec))
+	e.Use(timeout(h.timeoutSec, skipPaths("/v1/events"))) // ストリームは時間で打ち切らない
 	e.Use(traceRequest)
 
 	e.POST("/users", h.createUser)
@@ -38,11 +44,104 @@ func NewEcho(h *Handler) *echo.Echo {
 	g := e.Group("/v1")
 	g.Use(echojwt.WithConfig(h.jwtConfig))
 
-	g.POST("/prices", h.createPrice)
+	// POSTはIdempotency-Keyによる再送の重複防止を適用
+	g.POST("/prices", h.createPrice, h.idempotency)
 	g.GET("/prices", h.findPrices)
+	g.GET("/prices/export.csv", h.exportPrices)
+	g.GET("/prices/nearby", h.findNearbyPrices)
+	g.GET("/prices/effective", h.findEffectivePrices)
+	g.POST("/prices/import", h.importPrices, middleware.BodyLimit(h.batchRequestBodyLimit), h.idempotency)
 	g.GET("/prices/:id", h.findPrice)
 	g.PUT("/prices/:id", h.updatePrice)
+	g.PATCH("/prices/:id", h.patchPrice)
 	g.DELETE("/prices/:id", h.deletePrice)
+	g.GET("/prices/:id/revisions", h.findPriceRevisions)
+	g.POST("/prices/:id/revisions/:revision/revert", h.revertPrice, h.idempotency)
+	g.POST("/prices\\:batch", h.batchPrices, middleware.BodyLimit(h.batchRequestBodyLimit), h.idempotency) // コロンはエスケープ
+	g.POST("/prices/:id/attachments", h.createAttachment, middleware.BodyLimit(h.attachmentRequestBodyLimit), h.idempotency)
+	g.GET("/prices/:id/attachments", h.findAttachments)
+	g.GET("/prices/:id/attachments/:attachment", h.downloadAttachment)
+	g.DELETE("/prices/:id/attachments/:attachment", h.deleteAttachment)
+
+	g.GET("/trash/prices", h.findDeletedPrices)
+	g.POST("/trash/prices/:id/restore", h.restorePrice, h.idempotency)
+	g.DELETE("/trash/prices/:id", h.purgePrice)
+
+	g.POST("/alerts", h.createAlertRule, h.idempotency)
+	g.GET("/alerts", h.findAlertRules)
+	g.GET("/alerts/:id", h.findAlertRule)
+	g.PUT("/alerts/:id", h.updateAlertRule)
+	g.DELETE("/alerts/:id", h.deleteAlertRule)
+
+	g.GET("/notifications", h.findNotifications)
+	g.POST("/notifications/:id/read", h.readNotification, h.idempotency)
+	g.POST("/notifications/:id/unread", h.unreadNotification, h.idempotency)
+
+	g.POST("/webhooks", h.createWebhook, h.idempotency)
+	g.GET("/webhooks", h.findWebhooks)
+	g.GET("/webhooks/:id", h.findWebhook)
+	g.PUT("/webhooks/:id", h.updateWebhook)
+	g.DELETE("/webhooks/:id", h.deleteWebhook)
+	g.GET("/webhooks/:id/deliveries", h.findWebhookDeliveries)
+
+	g.GET("/events", h.streamEvents)
+
+	g.POST("/tags", h.createTag, h.idempotency)
+	g.GET("/tags", h.findTags)
+	g.GET("/tags/:id", h.findTag)
+	g.PUT("/tags/:id", h.renameTag)
+	g.DELETE("/tags/:id", h.deleteTag)
+	g.POST("/tags/:id/merge", h.mergeTag, h.idempotency)
+
+	g.GET("/products/by-code/:gtin", h.findProductByGTIN)
+	g.POST("/products/aliases", h.createProductAlias, h.idempotency)
+	g.GET("/products/aliases", h.findProductAliases)
+	g.DELETE("/products/aliases/:id", h.deleteProductAlias)
+	g.POST("/products/merge", h.mergeProduct, h.idempotency)
+	g.GET("/products/duplicates", h.findDuplicateProducts)
+	g.GET("/products/:product/compare", h.compareProductPrices)
+	g.GET("/products/:product/forecast", h.forecastProductPrice)
+	g.POST("/compare", h.compareBasket, h.idempotency)
+
+	g.POST("/stores", h.createStore, h.idempotency)
+	g.GET("/stores", h.findStores)
+	g.GET("/stores/:id", h.findStore)
+	g.PUT("/stores/:id", h.updateStore)
+	g.DELETE("/stores/:id", h.deleteStore)
+
+	g.POST("/lists", h.createShoppingList, middleware.BodyLimit(h.batchRequestBodyLimit), h.idempotency) // 品目を含むので一括処理の上限
+	g.GET("/lists", h.findShoppingLists)
+	g.GET("/lists/:id", h.findShoppingList)
+	g.PUT("/lists/:id", h.updateShoppingList, middleware.BodyLimit(h.batchRequestBodyLimit))
+	g.DELETE("/lists/:id", h.deleteShoppingList)
+	g.PUT("/lists/:id/order", h.reorderShoppingList, middleware.BodyLimit(h.batchRequestBodyLimit))
+	g.GET("/lists/:id/estimate", h.estimateShoppingList)
+	g.POST("/lists/:id/items", h.addShoppingListItem, h.idempotency)
+	g.PUT("/lists/:id/items/:item", h.updateShoppingListItem)
+	g.DELETE("/lists/:id/items/:item", h.deleteShoppingListItem)
+	g.POST("/lists/:id/items/:item/check", h.checkShoppingListItem, h.idempotency)
+	g.POST("/lists/:id/items/:item/uncheck", h.uncheckShoppingListItem, h.idempotency)
+
+	g.POST("/purchases", h.createPurchase, middleware.BodyLimit(h.batchRequestBodyLimit), h.idempotency) // 明細を含むので一括処理の上限
+	g.GET("/purchases", h.findPurchases)
+	g.GET("/purchases/:id", h.findPurchase)
+	g.DELETE("/purchases/:id", h.deletePurchase)
+
+	g.GET("/reports/spending", h.spendingReport)
+	g.GET("/reports/spending.csv", h.exportSpendingReport)
+
+	g.POST("/baskets", h.createIndexBasket, middleware.BodyLimit(h.batchRequestBodyLimit), h.idempotency) // 商品を含むので一括処理の上限
+	g.GET("/baskets", h.findIndexBaskets)
+	g.GET("/baskets/:id", h.findIndexBasket)
+	g.PUT("/baskets/:id", h.updateIndexBasket, middleware.BodyLimit(h.batchRequestBodyLimit))
+	g.DELETE("/baskets/:id", h.deleteIndexBasket)
+	g.GET("/baskets/:id/index", h.priceIndex)
 
 	return e
 }
+
+func skipPaths(paths ...string) middleware.Skipper {
+	return func(c echo.Context) bool {
+		return slices.Contains(paths, c.Path())
+	}
+}
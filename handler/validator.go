@@ -14,6 +14,16 @@ type customValidator struct {
 	translator ut.Translator
 }
 
+// 独自のタグと翻訳のメッセージ
+var customValidations = []struct {
+	tag string
+	fn  validator.Func
+	en  string
+	ja  string
+}{
+	{"gtin", validateGTIN, "{0} must be a valid GTIN (JAN/EAN-8, JAN/EAN-13 or UPC-A)", "{0}は正しいGTIN（JAN/EAN-8、JAN/EAN-13、UPC-A）でなければなりません"},
+}
+
 func newValidator(locale string) *customValidator {
 	v := validator.New()
 
@@ -26,9 +36,61 @@ func newValidator(locale string) *customValidator {
 		en_translations.RegisterDefaultTranslations(v, trans)
 	}
 
+	for _, c := range customValidations {
+		v.RegisterValidation(c.tag, c.fn)
+		text := c.en
+		if locale == "ja" {
+			text = c.ja
+		}
+		v.RegisterTranslation(c.tag, trans, func(ut ut.Translator) error {
+			return ut.Add(c.tag, text, false)
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			t, _ := ut.T(fe.Tag(), fe.Field())
+			return t
+		})
+	}
+
 	return &customValidator{validator: v, translator: trans}
 }
 
+// タグの翻訳のメッセージ（構造体の入力チェック以外で同じメッセージを使う）
+func (cv *customValidator) translate(tag string, params ...string) string {
+	t, _ := cv.translator.T(tag, params...)
+	return t
+}
+
 func (cv *customValidator) Validate(i interface{}) error {
 	return cv.validator.Struct(i)
 }
+
+func validateGTIN(fl validator.FieldLevel) bool {
+	return validGTIN(fl.Field().String())
+}
+
+// 8桁（JAN/EAN-8）、12桁（UPC-A）、13桁（JAN/EAN-13）でチェックディジットが正しいこと
+func validGTIN(code string) bool {
+	switch len(code) {
+	case 8, 12, 13:
+	default:
+		return false
+	}
+
+	// 右端のチェックディジットを除き、右から奇数桁を3倍、偶数桁を1倍して合計
+	sum := 0
+	for i := len(code) - 1; 0 <= i; i-- {
+		c := code[i]
+		if c < '0' || '9' < c {
+			return false
+		}
+		if i == len(code)-1 {
+			continue
+		}
+		d := int(c - '0')
+		if (len(code)-1-i)%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+
+	return int(code[len(code)-1]-'0') == (10-sum%10)%10
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/signal"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -55,7 +56,11 @@ func main() {
 		log.Fatal(err)
 	}
 	defer sqlDB.Close()
-	r, err := repository.NewRepository(driverName, sqlDB)
+	blob, err := newBlobStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	r, err := repository.NewRepository(driverName, sqlDB, blob)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -71,6 +76,27 @@ func main() {
 
 	// Service
 	s := service.NewService(r)
+	retentionDays := 30 // 削除済み価格の保持期間（0は無期限）
+	if v := os.Getenv("TRASHRETENTIONDAYS"); v != "" {
+		if retentionDays, err = strconv.Atoi(v); err != nil || retentionDays < 0 {
+			log.Fatal("TRASHRETENTIONDAYS is invalid")
+		}
+	}
+
+	// 登録済みの価格の店舗と商品名の正規化（normalizeサブコマンド）
+	if len(os.Args) > 1 && os.Args[1] == "normalize" {
+		// 価格の商品名の置き換えに使う別名も含めて、価格と照合する名前を先に正規化
+		names, err := s.NormalizeRelatedNames(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+		rows, err := s.NormalizePriceNames(context.Background(), 1000)
+		if err != nil {
+			log.Fatal(err)
+		}
+		slog.Info("normalized", "rows", rows, "names", names)
+		return
+	}
 
 	// Handler
 	jwtkeyStr := os.Getenv("JWTKEY")
@@ -88,17 +114,60 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	compareMaxAgeDays := 90 // 比較の対象にする価格の経過日数の上限
+	if v := os.Getenv("COMPAREMAXAGEDAYS"); v != "" {
+		if compareMaxAgeDays, err = strconv.Atoi(v); err != nil || compareMaxAgeDays <= 0 {
+			log.Fatal("COMPAREMAXAGEDAYS is invalid")
+		}
+	}
+	staleDays := 30 // 比較で古い価格とみなす経過日数
+	if v := os.Getenv("STALEDAYS"); v != "" {
+		if staleDays, err = strconv.Atoi(v); err != nil || staleDays <= 0 {
+			log.Fatal("STALEDAYS is invalid")
+		}
+	}
+	idempotencyKeyRetentionHours := 24 // Idempotency-Keyの保持期間
+	if v := os.Getenv("IDEMPOTENCYKEYRETENTIONHOURS"); v != "" {
+		if idempotencyKeyRetentionHours, err = strconv.Atoi(v); err != nil || idempotencyKeyRetentionHours <= 0 {
+			log.Fatal("IDEMPOTENCYKEYRETENTIONHOURS is invalid")
+		}
+	}
+	strictOutlier := false // 外れ値の価格をconfirmの指定なしでは登録しない
+	if v := os.Getenv("STRICTOUTLIER"); v != "" {
+		if strictOutlier, err = strconv.ParseBool(v); err != nil {
+			log.Fatal("STRICTOUTLIER is invalid")
+		}
+	}
+	normalizeText := true // 価格の店舗と商品名の正規化
+	if v := os.Getenv("NORMALIZETEXT"); v != "" {
+		if normalizeText, err = strconv.ParseBool(v); err != nil {
+			log.Fatal("NORMALIZETEXT is invalid")
+		}
+	}
+	allowPrivateWebhook := false // 内部ネットワークへのWebhookの配信を許可（開発用）
+	if v := os.Getenv("WEBHOOKALLOWPRIVATE"); v != "" {
+		if allowPrivateWebhook, err = strconv.ParseBool(v); err != nil {
+			log.Fatal("WEBHOOKALLOWPRIVATE is invalid")
+		}
+	}
 	const timeoutSec = 60
 	h := handler.NewHandler(s, &handler.HandlerConfig{
-		JwtKey:           jwtkey,
-		ValidityMin:      120, // JWTのexp
-		DateTimeLayout:   time.DateTime,
-		Location:         location,
-		Locale:           "en",
-		Indent:           "  ", // レスポンスのJSONのインデント
-		TimeoutSec:       timeoutSec,
-		RequestBodyLimit: "1K",
-		RateLimit:        10,
+		JwtKey:                       jwtkey,
+		ValidityMin:                  120, // JWTのexp
+		DateTimeLayout:               time.DateTime,
+		Location:                     location,
+		Locale:                       "en",
+		Indent:                       "  ", // レスポンスのJSONのインデント
+		TimeoutSec:                   timeoutSec,
+		RequestBodyLimit:             "1K",
+		BatchRequestBodyLimit:        "64K",
+		RateLimit:                    10,
+		CompareMaxAgeDays:            compareMaxAgeDays,
+		StaleDays:                    staleDays,
+		IdempotencyKeyRetentionHours: idempotencyKeyRetentionHours,
+		StrictOutlier:                strictOutlier,
+		NormalizeText:                normalizeText,
+		AllowPrivateWebhook:          allowPrivateWebhook,
 	})
 
 	// Echo(Graceful Shutdown)
@@ -109,6 +178,11 @@ func main() {
 	e := handler.NewEcho(h)
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
+	if retentionDays > 0 {
+		go purgeTrash(ctx, s, retentionDays)
+	}
+	go deliverWebhooks(ctx, s, allowPrivateWebhook)
+	go purgeIdempotencyKeys(ctx, s, idempotencyKeyRetentionHours)
 	go func() {
 		if err := e.Start(address); err != nil && err != http.ErrServerClosed {
 			log.Fatal("shutting down the server")
@@ -120,4 +194,90 @@ func main() {
 	if err := e.Shutdown(ctx); err != nil {
 		log.Fatal(err)
 	}
+	if err := s.Shutdown(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// 添付ファイルの保存先（S3BUCKETの指定があればS3互換のオブジェクトストレージ）
+func newBlobStore() (repository.BlobStore, error) {
+	if bucket := os.Getenv("S3BUCKET"); bucket != "" {
+		region := os.Getenv("S3REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		endpoint := os.Getenv("S3ENDPOINT")
+		if endpoint == "" {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+		}
+		return repository.NewS3BlobStore(&repository.S3Config{
+			Endpoint:  endpoint,
+			Region:    region,
+			Bucket:    bucket,
+			AccessKey: os.Getenv("S3ACCESSKEY"),
+			SecretKey: os.Getenv("S3SECRETKEY"),
+		})
+	}
+	dir := os.Getenv("BLOBDIR")
+	if dir == "" {
+		dir = "blobs"
+	}
+	return repository.NewLocalBlobStore(dir), nil
+}
+
+// 保持期間を過ぎた削除済み価格を定期的に完全削除
+func purgeTrash(ctx context.Context, s service.Service, retentionDays int) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		rows, err := s.PurgeDeletedPrices(ctx, time.Now().AddDate(0, 0, -retentionDays))
+		if err != nil {
+			slog.ErrorContext(ctx, err.Error())
+		} else if rows != 0 {
+			slog.InfoContext(ctx, "purged", "rows", rows)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 保持期間を過ぎたIdempotency-Keyを定期的に削除
+func purgeIdempotencyKeys(ctx context.Context, s service.Service, retentionHours int) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		rows, err := s.PurgeIdempotencyKeys(ctx, time.Now().Add(-time.Duration(retentionHours)*time.Hour))
+		if err != nil {
+			slog.ErrorContext(ctx, err.Error())
+		} else if rows != 0 {
+			slog.InfoContext(ctx, "purged idempotency keys", "rows", rows)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 配信予定日時を過ぎたWebhookを定期的に配信
+func deliverWebhooks(ctx context.Context, s service.Service, allowPrivate bool) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		delivered, err := s.DeliverWebhooks(ctx, time.Now(), allowPrivate)
+		if err != nil {
+			slog.ErrorContext(ctx, err.Error())
+		} else if delivered != 0 {
+			slog.InfoContext(ctx, "delivered", "webhooks", delivered)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+	"gorm.io/gorm"
+)
+
+// アラートの条件テーブル操作
+type AlertRuleRepository interface {
+	Create(ctx context.Context, rule *entity.AlertRule) error
+	Find(ctx context.Context, id, userId uint) (*entity.AlertRule, error)
+	FindByUserId(ctx context.Context, userId uint) ([]entity.AlertRule, error)
+	FindByProduct(ctx context.Context, userId uint, product, store string) ([]entity.AlertRule, error)
+	Update(ctx context.Context, rule *entity.AlertRule) (int64, error)
+	Delete(ctx context.Context, id, userId uint) (int64, error)
+}
+
+type alertRuleRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewAlertRuleRepository(db *gorm.DB) AlertRuleRepository {
+	return &alertRuleRepositoryGorm{db}
+}
+
+func (r *alertRuleRepositoryGorm) Create(ctx context.Context, rule *entity.AlertRule) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Create(rule).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+func (r *alertRuleRepositoryGorm) Find(ctx context.Context, id, userId uint) (*entity.AlertRule, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	rule := &entity.AlertRule{
+		Model: gorm.Model{
+			ID: id,
+		},
+	}
+
+	if err := tx.Where("user_id = ?", userId).First(rule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return rule, nil
+}
+
+func (r *alertRuleRepositoryGorm) FindByUserId(ctx context.Context, userId uint) ([]entity.AlertRule, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.AlertRule
+	if err := tx.Where("user_id = ?", userId).Order("id").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+// 商品と店舗に該当する条件（店舗の指定がない条件を含む）
+func (r *alertRuleRepositoryGorm) FindByProduct(ctx context.Context, userId uint, product, store string) ([]entity.AlertRule, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.AlertRule
+	if err := tx.Where("user_id = ? AND product = ? AND (store IS NULL OR store = ?)", userId, product, store).Order("id").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *alertRuleRepositoryGorm) Update(ctx context.Context, rule *entity.AlertRule) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	// nilへの変更も反映するため全カラムを更新
+	db := tx.Model(rule).Where("user_id = ?", rule.UserID).Select("product", "store", "target_price", "drop_percent").Updates(rule)
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *alertRuleRepositoryGorm) Delete(ctx context.Context, id, userId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	rule := &entity.AlertRule{
+		Model: gorm.Model{
+			ID: id,
+		},
+	}
+
+	db := tx.Where("user_id = ?", userId).Delete(rule)
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+// 通知テーブル操作
+type NotificationRepository interface {
+	CreateAll(ctx context.Context, notifications []entity.Notification) error
+	Find(ctx context.Context, id, userId uint) (*entity.Notification, error)
+	FindByUserId(ctx context.Context, userId uint, read *bool) ([]entity.Notification, error)
+	UpdateReadAt(ctx context.Context, id, userId uint, readAt *time.Time) (int64, error)
+	DeleteByPriceId(ctx context.Context, priceId uint) (int64, error)
+	DeleteOfPricesDeletedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type notificationRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepositoryGorm{db}
+}
+
+func (r *notificationRepositoryGorm) CreateAll(ctx context.Context, notifications []entity.Notification) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Create(&notifications).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+func (r *notificationRepositoryGorm) Find(ctx context.Context, id, userId uint) (*entity.Notification, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	notification := &entity.Notification{ID: id}
+	if err := tx.Where("user_id = ?", userId).First(notification).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return notification, nil
+}
+
+// 新しい順。readがnilなら既読と未読の両方
+func (r *notificationRepositoryGorm) FindByUserId(ctx context.Context, userId uint, read *bool) ([]entity.Notification, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	tx = tx.Where("user_id = ?", userId)
+	if read != nil {
+		if *read {
+			tx = tx.Where("read_at IS NOT NULL")
+		} else {
+			tx = tx.Where("read_at IS NULL")
+		}
+	}
+
+	var entities []entity.Notification
+	if err := tx.Order("id DESC").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *notificationRepositoryGorm) UpdateReadAt(ctx context.Context, id, userId uint, readAt *time.Time) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Model(&entity.Notification{}).Where("id = ? AND user_id = ?", id, userId).Update("read_at", readAt)
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *notificationRepositoryGorm) DeleteByPriceId(ctx context.Context, priceId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Where("price_id = ?", priceId).Delete(&entity.Notification{})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *notificationRepositoryGorm) DeleteOfPricesDeletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Where("price_id IN (?)", tx.Unscoped().Model(&entity.Price{}).Select("id").Where("deleted_at < ?", before)).Delete(&entity.Notification{})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
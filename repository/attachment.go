@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+	"gorm.io/gorm"
+)
+
+// 添付ファイルテーブル操作
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment *entity.Attachment) error
+	Find(ctx context.Context, id, priceId, userId uint) (*entity.Attachment, error)
+	FindByPriceId(ctx context.Context, priceId, userId uint) ([]entity.Attachment, error)
+	CountByPriceId(ctx context.Context, priceId uint) (int64, error)
+	Delete(ctx context.Context, id, priceId, userId uint) (int64, error)
+	DeleteByPriceId(ctx context.Context, priceId uint) ([]string, error)
+	DeleteOfPricesDeletedBefore(ctx context.Context, before time.Time) ([]string, error)
+}
+
+type attachmentRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) AttachmentRepository {
+	return &attachmentRepositoryGorm{db}
+}
+
+func (r *attachmentRepositoryGorm) Create(ctx context.Context, attachment *entity.Attachment) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Create(attachment).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+func (r *attachmentRepositoryGorm) Find(ctx context.Context, id, priceId, userId uint) (*entity.Attachment, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	attachment := &entity.Attachment{ID: id}
+	if err := tx.Where("price_id = ? AND user_id = ?", priceId, userId).First(attachment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return attachment, nil
+}
+
+// 登録順
+func (r *attachmentRepositoryGorm) FindByPriceId(ctx context.Context, priceId, userId uint) ([]entity.Attachment, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.Attachment
+	if err := tx.Where("price_id = ? AND user_id = ?", priceId, userId).Order("id").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *attachmentRepositoryGorm) CountByPriceId(ctx context.Context, priceId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	var count int64
+	if err := tx.Model(&entity.Attachment{}).Where("price_id = ?", priceId).Count(&count).Error; err != nil {
+		return 0, wrap(err)
+	}
+
+	return count, nil
+}
+
+func (r *attachmentRepositoryGorm) Delete(ctx context.Context, id, priceId, userId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Where("price_id = ? AND user_id = ?", priceId, userId).Delete(&entity.Attachment{ID: id})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+// 削除した添付ファイルのBlobのキーを返す
+func (r *attachmentRepositoryGorm) DeleteByPriceId(ctx context.Context, priceId uint) ([]string, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return r.deleteWhere(ctx, "price_id = ?", priceId)
+}
+
+// 削除した添付ファイルのBlobのキーを返す
+func (r *attachmentRepositoryGorm) DeleteOfPricesDeletedBefore(ctx context.Context, before time.Time) ([]string, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return r.deleteWhere(ctx, "price_id IN (SELECT id FROM prices WHERE deleted_at < ?)", before)
+}
+
+func (r *attachmentRepositoryGorm) deleteWhere(ctx context.Context, query string, args ...any) ([]string, error) {
+	tx := tx(ctx)
+
+	var keys []string
+	if err := tx.Model(&entity.Attachment{}).Where(query, args...).Pluck("blob_key", &keys).Error; err != nil {
+		return nil, wrap(err)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if err := tx.Where("blob_key IN ?", keys).Delete(&entity.Attachment{}).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return keys, nil
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 
 	plyerrors "github.com/go-playground/errors/v5"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 var (
@@ -13,3 +15,16 @@ var (
 func wrap(err error) error {
 	return plyerrors.WrapSkipFrames(err, "", 1)
 }
+
+// 一意制約違反
+func isDuplicated(err error) bool {
+	var pgerr *pgconn.PgError
+	if errors.As(err, &pgerr) && pgerr.Code == "23505" { // unique_violation
+		return true
+	}
+	var mysqlerr *mysql.MySQLError
+	if errors.As(err, &mysqlerr) && mysqlerr.Number == 1062 {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+	"gorm.io/gorm"
+)
+
+// 冪等キーテーブル操作
+type IdempotencyKeyRepository interface {
+	Create(ctx context.Context, key *entity.IdempotencyKey) error
+	Find(ctx context.Context, userId uint, key string) (*entity.IdempotencyKey, error)
+	Complete(ctx context.Context, userId uint, key string, status int, header string, body []byte) (int64, error)
+	Delete(ctx context.Context, userId uint, key string) (int64, error)
+	DeleteCreatedBefore(ctx context.Context, userId uint, key string, before time.Time) (int64, error)
+	PurgeCreatedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type idempotencyKeyRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyKeyRepository(db *gorm.DB) IdempotencyKeyRepository {
+	return &idempotencyKeyRepositoryGorm{db}
+}
+
+// ユーザとキーの組み合わせの一意制約で同時の重複を防ぐ
+func (r *idempotencyKeyRepositoryGorm) Create(ctx context.Context, key *entity.IdempotencyKey) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Create(key).Error; err != nil {
+		if isDuplicated(err) {
+			return errors.Join(wrap(ErrDuplicated), err)
+		}
+		return wrap(err)
+	}
+
+	return nil
+}
+
+func (r *idempotencyKeyRepositoryGorm) Find(ctx context.Context, userId uint, key string) (*entity.IdempotencyKey, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	idempotencyKey := &entity.IdempotencyKey{}
+	if err := tx.Where("user_id = ? AND idempotency_key = ?", userId, key).First(idempotencyKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return idempotencyKey, nil
+}
+
+// レスポンスの保存
+func (r *idempotencyKeyRepositoryGorm) Complete(ctx context.Context, userId uint, key string, status int, header string, body []byte) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Model(&entity.IdempotencyKey{}).Where("user_id = ? AND idempotency_key = ?", userId, key).
+		Updates(map[string]any{"status": status, "header": header, "body": body})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *idempotencyKeyRepositoryGorm) Delete(ctx context.Context, userId uint, key string) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Where("user_id = ? AND idempotency_key = ?", userId, key).Delete(&entity.IdempotencyKey{})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+// 保持期間を過ぎたキーの削除（同じキーを再び使えるようにする）
+func (r *idempotencyKeyRepositoryGorm) DeleteCreatedBefore(ctx context.Context, userId uint, key string, before time.Time) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Where("user_id = ? AND idempotency_key = ? AND created_at < ?", userId, key, before).Delete(&entity.IdempotencyKey{})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+// 保持期間を過ぎた全てのキーの削除
+func (r *idempotencyKeyRepositoryGorm) PurgeCreatedBefore(ctx context.Context, before time.Time) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Where("created_at < ?", before).Delete(&entity.IdempotencyKey{})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
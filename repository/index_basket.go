@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/ystkg/rest-example/entity"
+	"gorm.io/gorm"
+)
+
+// 物価指数の買い物かごテーブル操作（商品はIndexBasketItemRepository）
+type IndexBasketRepository interface {
+	Create(ctx context.Context, basket *entity.IndexBasket) error
+	Find(ctx context.Context, id, userId uint) (*entity.IndexBasket, error)
+	FindByUserId(ctx context.Context, userId uint) ([]entity.IndexBasket, error)
+	Update(ctx context.Context, basket *entity.IndexBasket) (int64, error)
+	Delete(ctx context.Context, id, userId uint) (int64, error)
+}
+
+type indexBasketRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewIndexBasketRepository(db *gorm.DB) IndexBasketRepository {
+	return &indexBasketRepositoryGorm{db}
+}
+
+func (r *indexBasketRepositoryGorm) Create(ctx context.Context, basket *entity.IndexBasket) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Create(basket).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+func (r *indexBasketRepositoryGorm) Find(ctx context.Context, id, userId uint) (*entity.IndexBasket, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	basket := &entity.IndexBasket{ID: id}
+	if err := tx.Where("user_id = ?", userId).First(basket).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return basket, nil
+}
+
+// 登録順
+func (r *indexBasketRepositoryGorm) FindByUserId(ctx context.Context, userId uint) ([]entity.IndexBasket, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.IndexBasket
+	if err := tx.Where("user_id = ?", userId).Order("id").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *indexBasketRepositoryGorm) Update(ctx context.Context, basket *entity.IndexBasket) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Model(&entity.IndexBasket{ID: basket.ID}).Where("user_id = ?", basket.UserID).Update("name", basket.Name)
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *indexBasketRepositoryGorm) Delete(ctx context.Context, id, userId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Where("user_id = ?", userId).Delete(&entity.IndexBasket{ID: id})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+// 物価指数の買い物かごの商品テーブル操作
+type IndexBasketItemRepository interface {
+	Create(ctx context.Context, items []entity.IndexBasketItem) error
+	FindByBasketIds(ctx context.Context, basketIds []uint) ([]entity.IndexBasketItem, error)
+	DeleteByBasketId(ctx context.Context, basketId uint) error
+}
+
+type indexBasketItemRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewIndexBasketItemRepository(db *gorm.DB) IndexBasketItemRepository {
+	return &indexBasketItemRepositoryGorm{db}
+}
+
+func (r *indexBasketItemRepositoryGorm) Create(ctx context.Context, items []entity.IndexBasketItem) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	tx := tx(ctx)
+
+	if err := tx.Create(&items).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+// 買い物かごごとに登録順
+func (r *indexBasketItemRepositoryGorm) FindByBasketIds(ctx context.Context, basketIds []uint) ([]entity.IndexBasketItem, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	if len(basketIds) == 0 {
+		return nil, nil
+	}
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.IndexBasketItem
+	if err := tx.Where("basket_id IN ?", basketIds).Order("basket_id, id").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *indexBasketItemRepositoryGorm) DeleteByBasketId(ctx context.Context, basketId uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Where("basket_id = ?", basketId).Delete(&entity.IndexBasketItem{}).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/ystkg/rest-example/entity"
+	"gorm.io/gorm"
+)
+
+// 価格の店舗や商品名と照合する名前の列
+type NameColumn struct {
+	Table  string
+	Column string
+}
+
+var (
+	NameColumnProductAliasAlias   = NameColumn{"product_aliases", "alias"}
+	NameColumnProductAliasProduct = NameColumn{"product_aliases", "product"}
+	NameColumnStoreName           = NameColumn{"stores", "name"}
+	NameColumnAlertRuleStore      = NameColumn{"alert_rules", "store"}
+	NameColumnAlertRuleProduct    = NameColumn{"alert_rules", "product"}
+	NameColumnShoppingListProduct = NameColumn{"shopping_list_items", "product"}
+	NameColumnPurchaseStore       = NameColumn{"purchases", "store"}
+	NameColumnPurchaseProduct     = NameColumn{"purchase_items", "product"}
+	NameColumnIndexBasketProduct  = NameColumn{"index_basket_items", "product"}
+)
+
+// 名前の列の操作（登録済みの名前の正規化）
+type NameRepository interface {
+	FindDistinct(ctx context.Context, column NameColumn) ([]entity.UserName, error)
+	Rename(ctx context.Context, column NameColumn, userId uint, from, to string) (int64, error)
+}
+
+type nameRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewNameRepository(db *gorm.DB) NameRepository {
+	return &nameRepositoryGorm{db}
+}
+
+// ユーザごとの重複を除いた名前（論理削除済みを含む、ユーザと名前の順）
+func (r *nameRepositoryGorm) FindDistinct(ctx context.Context, column NameColumn) ([]entity.UserName, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var names []entity.UserName
+	if err := tx.Table(column.Table).
+		Select("DISTINCT user_id, " + column.Column + " AS name").
+		Where(column.Column + " IS NOT NULL").
+		Order("user_id, name").
+		Scan(&names).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return names, nil
+}
+
+// 論理削除済みを含めてユーザの名前を変更（一意の列で重複する場合はErrDuplicated）
+func (r *nameRepositoryGorm) Rename(ctx context.Context, column NameColumn, userId uint, from, to string) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Table(column.Table).Where("user_id = ? AND "+column.Column+" = ?", userId, from).Update(column.Column, to)
+	if db.Error != nil {
+		if isDuplicated(db.Error) {
+			return 0, errors.Join(wrap(ErrDuplicated), db.Error)
+		}
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
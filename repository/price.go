@@ -2,21 +2,55 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"log/slog"
 	"time"
 
 	"github.com/ystkg/rest-example/entity"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // 価格テーブル操作
 type PriceRepository interface {
-	Create(ctx context.Context, userId uint, dateTime time.Time, store, product string, price uint) (*entity.Price, error)
+	Create(ctx context.Context, userId uint, dateTime time.Time, store, product, gtin string, price uint, quantity float64, unit, priceType string, validFrom, validTo *time.Time) (*entity.Price, error)
+	CreateAll(ctx context.Context, prices []entity.Price) error
 	Find(ctx context.Context, id, userId uint) (*entity.Price, error)
+	FindForUpdate(ctx context.Context, id, userId uint) (*entity.Price, error)
+	FindByVersions(ctx context.Context, id, userId uint, versions []uint) (*entity.Price, error)
 	FindByUserId(ctx context.Context, userId uint) ([]entity.Price, error)
-	Update(ctx context.Context, id, userId uint, dateTime time.Time, store, product string, price uint) (*entity.Price, int64, error)
-	Delete(ctx context.Context, id, userId uint) (int64, error)
+	FindByUserIdAndTags(ctx context.Context, userId uint, tags []string, matchAll bool) ([]entity.Price, error)
+	FindByUserIdInBatches(ctx context.Context, userId uint, batchSize int, fn func([]entity.Price) error) error
+	FindLatestByStores(ctx context.Context, userId uint, stores []string, now time.Time, excludeSuspicious bool) ([]entity.Price, error)
+	FindLatestByProducts(ctx context.Context, userId uint, products []string, since, now time.Time, excludeSuspicious bool) ([]entity.Price, error)
+	FindByGTIN(ctx context.Context, userId uint, gtins []string) ([]entity.Price, error)
+	FindHistoryByProduct(ctx context.Context, userId uint, product string, since time.Time) ([]entity.Price, error)
+	FindHistoryByProducts(ctx context.Context, userId uint, products []string, before time.Time) ([]entity.Price, error)
+	FindEffective(ctx context.Context, userId uint, at time.Time, priceTypes []string) ([]entity.Price, error)
+	FindUnitsByProduct(ctx context.Context, userId uint, product string, excludeId uint) ([]string, error)
+	FindRecentPricesByProduct(ctx context.Context, userId uint, product string, excludeId uint, limit int) ([]entity.Price, error)
+	SetSuspicious(ctx context.Context, id uint, suspicious bool) error
+	LastModifiedByUserId(ctx context.Context, userId uint) (time.Time, error)
+	AverageByProduct(ctx context.Context, userId uint, product string, store *string, excludeId uint) (*float64, error)
+	Update(ctx context.Context, id, userId uint, versions []uint, dateTime time.Time, store, product, gtin string, price uint, quantity float64, unit, priceType string, validFrom, validTo *time.Time) (*entity.Price, int64, error)
+	Patch(ctx context.Context, id, userId uint, versions []uint, dateTime *time.Time, store, product, gtin *string, price *uint, quantity *float64, unit, priceType *string, validity *entity.PriceValidity) (int64, error)
+	Delete(ctx context.Context, id, userId uint, versions []uint) (int64, error)
+	TouchByTagId(ctx context.Context, tagId uint) (int64, error)
+	FindByTagIdForUpdate(ctx context.Context, tagId uint) ([]entity.Price, error)
+	FindByIds(ctx context.Context, ids []uint) ([]entity.Price, error)
+	CountByProduct(ctx context.Context, userId uint) ([]entity.ProductCount, error)
+	FindByProductForUpdate(ctx context.Context, userId uint, product string) ([]entity.Price, error)
+	FindAfterId(ctx context.Context, afterId uint, limit int) ([]entity.Price, error)
+	Rename(ctx context.Context, id uint, versions []uint, store, product string) (*entity.Price, int64, error)
+
+	// 論理削除済み
+	FindDeletedByUserId(ctx context.Context, userId uint) ([]entity.Price, error)
+	FindDeletedForUpdate(ctx context.Context, id, userId uint) (*entity.Price, error)
+	FindDeletedBeforeForUpdate(ctx context.Context, before time.Time) ([]entity.Price, error)
+	Restore(ctx context.Context, id, userId uint) (int64, error)
+	Purge(ctx context.Context, id, userId uint) (int64, error)
+	PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error)
 }
 
 type priceRepositoryGorm struct {
@@ -33,7 +67,13 @@ func (r *priceRepositoryGorm) Create(
 	dateTime time.Time,
 	store string,
 	product string,
+	gtin string,
 	price uint,
+	quantity float64,
+	unit string,
+	priceType string,
+	validFrom *time.Time,
+	validTo *time.Time,
 ) (*entity.Price, error) {
 	slog.DebugContext(ctx, "start")
 	defer slog.DebugContext(ctx, "end")
@@ -41,11 +81,18 @@ func (r *priceRepositoryGorm) Create(
 	tx := tx(ctx)
 
 	priceEntity := &entity.Price{
-		UserID:   userId,
-		DateTime: dateTime,
-		Store:    store,
-		Product:  product,
-		Price:    price,
+		UserID:    userId,
+		DateTime:  dateTime,
+		Store:     store,
+		Product:   product,
+		GTIN:      gtin,
+		Price:     price,
+		Quantity:  quantity,
+		Unit:      unit,
+		Version:   1,
+		PriceType: priceType,
+		ValidFrom: validFrom,
+		ValidTo:   validTo,
 	}
 
 	if err := tx.Create(priceEntity).Error; err != nil {
@@ -55,6 +102,26 @@ func (r *priceRepositoryGorm) Create(
 	return priceEntity, nil
 }
 
+func (r *priceRepositoryGorm) CreateAll(ctx context.Context, prices []entity.Price) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	for i := range prices {
+		prices[i].Version = 1
+		if prices[i].PriceType == "" {
+			prices[i].PriceType = entity.PriceTypeRegular
+		}
+	}
+
+	if err := tx.Create(&prices).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
 func (r *priceRepositoryGorm) Find(ctx context.Context, id, userId uint) (*entity.Price, error) {
 	slog.DebugContext(ctx, "start")
 	defer slog.DebugContext(ctx, "end")
@@ -80,6 +147,54 @@ func (r *priceRepositoryGorm) Find(ctx context.Context, id, userId uint) (*entit
 	return price, nil
 }
 
+func (r *priceRepositoryGorm) FindForUpdate(ctx context.Context, id, userId uint) (*entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	price := &entity.Price{
+		Model: gorm.Model{
+			ID: id,
+		},
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Where("user_id = ?", userId).First(price).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return price, nil
+}
+
+// バージョンがいずれかに一致する場合だけ取得
+func (r *priceRepositoryGorm) FindByVersions(ctx context.Context, id, userId uint, versions []uint) (*entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	price := &entity.Price{
+		Model: gorm.Model{
+			ID: id,
+		},
+	}
+
+	if err := tx.Where("user_id = ? AND version IN ?", userId, versions).First(price).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return price, nil
+}
+
 func (r *priceRepositoryGorm) FindByUserId(ctx context.Context, userId uint) ([]entity.Price, error) {
 	slog.DebugContext(ctx, "start")
 	defer slog.DebugContext(ctx, "end")
@@ -97,14 +212,303 @@ func (r *priceRepositoryGorm) FindByUserId(ctx context.Context, userId uint) ([]
 	return entities, nil
 }
 
+// タグが付与されている価格（matchAllなら全てのタグ、そうでなければいずれかのタグ）
+func (r *priceRepositoryGorm) FindByUserIdAndTags(ctx context.Context, userId uint, tags []string, matchAll bool) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	tx = tx.Select("prices.*").
+		Joins("JOIN price_tags ON price_tags.price_id = prices.id").
+		Joins("JOIN tags ON tags.id = price_tags.tag_id").
+		Where("prices.user_id = ? AND tags.user_id = ? AND tags.name IN ?", userId, userId, tags).
+		Group("prices.id")
+	if matchAll {
+		tx = tx.Having("COUNT(tags.id) = ?", len(tags)) // tagsは重複なし
+	}
+
+	var entities []entity.Price
+	if err := tx.Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *priceRepositoryGorm) FindByUserIdInBatches(ctx context.Context, userId uint, batchSize int, fn func([]entity.Price) error) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	// 主キーの昇順
+	var entities []entity.Price
+	if err := tx.Where("user_id = ?", userId).FindInBatches(&entities, batchSize, func(_ *gorm.DB, _ int) error {
+		return fn(entities)
+	}).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+// 店舗と商品ごとの最新の価格（同じ日時なら後から登録した価格、nowの時点で有効期間外の価格は対象外）
+//
+// excludeSuspiciousなら外れ値の疑いがある価格を除いた中で最新の価格
+func (r *priceRepositoryGorm) FindLatestByStores(ctx context.Context, userId uint, stores []string, now time.Time, excludeSuspicious bool) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	if len(stores) == 0 {
+		return nil, nil
+	}
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	// 有効期間外の価格は新しくても対象外
+	newer := "SELECT 1 FROM prices newer WHERE newer.user_id = prices.user_id AND newer.store = prices.store AND newer.product = prices.product AND newer.deleted_at IS NULL" +
+		" AND (newer.date_time > prices.date_time OR (newer.date_time = prices.date_time AND newer.id > prices.id))" +
+		" AND COALESCE(newer.valid_from, newer.date_time) <= ? AND (newer.valid_to IS NULL OR newer.valid_to > ?)"
+	tx = tx.Where("user_id = ? AND store IN ?", userId, stores).
+		Where("COALESCE(valid_from, date_time) <= ? AND (valid_to IS NULL OR valid_to > ?)", now, now)
+	if excludeSuspicious {
+		tx = tx.Where("suspicious = ?", false).Where("NOT EXISTS ("+newer+" AND newer.suspicious = ?)", now, now, false)
+	} else {
+		tx = tx.Where("NOT EXISTS ("+newer+")", now, now)
+	}
+
+	var entities []entity.Price
+	if err := tx.Order("store, product").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+// 商品ごと店舗ごとの最新の価格（sinceより前の価格とnowの時点で有効期間外の価格は対象外、価格の昇順）
+//
+// excludeSuspiciousなら外れ値の疑いがある価格を除いた中で最新の価格
+func (r *priceRepositoryGorm) FindLatestByProducts(ctx context.Context, userId uint, products []string, since, now time.Time, excludeSuspicious bool) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	if len(products) == 0 {
+		return nil, nil
+	}
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	// 有効期間外の価格は新しくても対象外
+	newer := "SELECT 1 FROM prices newer WHERE newer.user_id = prices.user_id AND newer.store = prices.store AND newer.product = prices.product AND newer.deleted_at IS NULL" +
+		" AND (newer.date_time > prices.date_time OR (newer.date_time = prices.date_time AND newer.id > prices.id))" +
+		" AND COALESCE(newer.valid_from, newer.date_time) <= ? AND (newer.valid_to IS NULL OR newer.valid_to > ?)"
+	tx = tx.Where("user_id = ? AND product IN ? AND date_time >= ?", userId, products, since).
+		Where("COALESCE(valid_from, date_time) <= ? AND (valid_to IS NULL OR valid_to > ?)", now, now)
+	if excludeSuspicious {
+		tx = tx.Where("suspicious = ?", false).Where("NOT EXISTS ("+newer+" AND newer.suspicious = ?)", now, now, false)
+	} else {
+		tx = tx.Where("NOT EXISTS ("+newer+")", now, now)
+	}
+
+	var entities []entity.Price
+	if err := tx.Order("price, date_time DESC, store").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+// バーコードに該当する価格（日時の降順）
+func (r *priceRepositoryGorm) FindByGTIN(ctx context.Context, userId uint, gtins []string) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.Price
+	if err := tx.Where("user_id = ? AND gtin IN ?", userId, gtins).Order("date_time DESC, id DESC").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+// 商品の価格の履歴（sinceより前と外れ値の疑いがある価格は除く、日時の昇順）
+func (r *priceRepositoryGorm) FindHistoryByProduct(ctx context.Context, userId uint, product string, since time.Time) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.Price
+	if err := tx.Where("user_id = ? AND product = ? AND date_time >= ? AND suspicious = ?", userId, product, since, false).
+		Order("date_time, id").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+// 複数の商品の価格の履歴（before以降と外れ値の疑いがある価格は除く、日時の昇順）
+func (r *priceRepositoryGorm) FindHistoryByProducts(ctx context.Context, userId uint, products []string, before time.Time) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.Price
+	if err := tx.Where("user_id = ? AND product IN ? AND date_time < ? AND suspicious = ?", userId, products, before, false).
+		Order("date_time, id").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+// atの時点で有効な価格（priceTypesの指定があれば該当する種類のみ）
+//
+// 有効期間の始まりは指定がなければ日時、終わりは含まない。
+// 店舗、商品の順に、同じ店舗と商品では優先する順（終わりのある価格、始まりの降順、日時の降順、IDの降順）
+func (r *priceRepositoryGorm) FindEffective(ctx context.Context, userId uint, at time.Time, priceTypes []string) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	tx = tx.Where("user_id = ? AND COALESCE(valid_from, date_time) <= ? AND (valid_to IS NULL OR valid_to > ?)", userId, at, at)
+	if len(priceTypes) != 0 {
+		tx = tx.Where("price_type IN ?", priceTypes)
+	}
+	var entities []entity.Price
+	if err := tx.Order("store, product, valid_to IS NULL, COALESCE(valid_from, date_time) DESC, date_time DESC, id DESC").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+// 商品の価格で使われている単位（重複なし）
+func (r *priceRepositoryGorm) FindUnitsByProduct(ctx context.Context, userId uint, product string, excludeId uint) ([]string, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var units []string
+	if err := tx.Model(&entity.Price{}).Distinct("unit").Where("user_id = ? AND product = ? AND unit <> '' AND id <> ?", userId, product, excludeId).Pluck("unit", &units).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return units, nil
+}
+
+// 商品の最近の価格（外れ値の疑いがある価格は除く、日時の降順）
+func (r *priceRepositoryGorm) FindRecentPricesByProduct(ctx context.Context, userId uint, product string, excludeId uint, limit int) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var prices []entity.Price
+	if err := tx.Select("price", "quantity", "unit").Where("user_id = ? AND product = ? AND id <> ? AND suspicious = ?", userId, product, excludeId, false).
+		Order("date_time DESC, id DESC").Limit(limit).Find(&prices).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return prices, nil
+}
+
+// 論理削除も含めた最終更新日時（データがなければゼロ値）
+func (r *priceRepositoryGorm) LastModifiedByUserId(ctx context.Context, userId uint) (time.Time, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var lastModified sql.NullTime
+	if err := tx.Unscoped().Model(&entity.Price{}).Select("MAX(COALESCE(deleted_at, updated_at))").Where("user_id = ?", userId).Scan(&lastModified).Error; err != nil {
+		return time.Time{}, wrap(err)
+	}
+
+	return lastModified.Time, nil
+}
+
+// 商品の平均価格（storeがnilなら全店舗、データがなければnil）
+func (r *priceRepositoryGorm) AverageByProduct(ctx context.Context, userId uint, product string, store *string, excludeId uint) (*float64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	// 外れ値の疑いがある価格は除く
+	tx = tx.Model(&entity.Price{}).Select("AVG(price)").Where("user_id = ? AND product = ? AND id <> ? AND suspicious = ?", userId, product, excludeId, false)
+	if store != nil {
+		tx = tx.Where("store = ?", *store)
+	}
+
+	var average sql.NullFloat64
+	if err := tx.Scan(&average).Error; err != nil {
+		return nil, wrap(err)
+	}
+	if !average.Valid {
+		return nil, nil
+	}
+
+	return &average.Float64, nil
+}
+
 func (r *priceRepositoryGorm) Update(
 	ctx context.Context,
 	id uint,
 	userId uint,
+	versions []uint,
 	dateTime time.Time,
 	store string,
 	product string,
+	gtin string,
 	price uint,
+	quantity float64,
+	unit string,
+	priceType string,
+	validFrom *time.Time,
+	validTo *time.Time,
 ) (*entity.Price, int64, error) {
 	slog.DebugContext(ctx, "start")
 	defer slog.DebugContext(ctx, "end")
@@ -115,22 +519,114 @@ func (r *priceRepositoryGorm) Update(
 		Model: gorm.Model{
 			ID: id,
 		},
-		UserID:   userId,
-		DateTime: dateTime,
-		Store:    store,
-		Product:  product,
-		Price:    price,
 	}
 
-	db := tx.Where("user_id = ? and deleted_at is null", userId).Updates(priceEntity)
+	// バージョンがいずれかに一致する場合だけ更新（任意の項目は空にできるよう全ての列を指定）
+	db := tx.Model(priceEntity).Where("user_id = ? AND deleted_at IS NULL AND version IN ?", userId, versions).Updates(map[string]any{
+		"date_time":  dateTime,
+		"store":      store,
+		"product":    product,
+		"gtin":       gtin,
+		"price":      price,
+		"quantity":   quantity,
+		"unit":       unit,
+		"version":    gorm.Expr("version + 1"),
+		"price_type": priceType,
+		"valid_from": validFrom,
+		"valid_to":   validTo,
+	})
 	if db.Error != nil {
 		return nil, 0, wrap(db.Error)
 	}
+	if db.RowsAffected != 1 {
+		return nil, db.RowsAffected, nil
+	}
+
+	// 更新後の行の取得（RETURNINGに対応しないDBもあるため取得し直す）
+	after, err := findUnscoped(tx, id)
+	if err != nil {
+		return nil, 0, err
+	}
 
-	return priceEntity, db.RowsAffected, nil
+	return after, db.RowsAffected, nil
 }
 
-func (r *priceRepositoryGorm) Delete(ctx context.Context, id, userId uint) (int64, error) {
+func (r *priceRepositoryGorm) Patch(
+	ctx context.Context,
+	id uint,
+	userId uint,
+	versions []uint,
+	dateTime *time.Time,
+	store *string,
+	product *string,
+	gtin *string,
+	price *uint,
+	quantity *float64,
+	unit *string,
+	priceType *string,
+	validity *entity.PriceValidity,
+) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	// 変更のあるカラムだけを更新
+	columns := make(map[string]any, 11)
+	if dateTime != nil {
+		columns["date_time"] = *dateTime
+	}
+	if store != nil {
+		columns["store"] = *store
+	}
+	if product != nil {
+		columns["product"] = *product
+	}
+	if gtin != nil {
+		columns["gtin"] = *gtin
+	}
+	if price != nil {
+		columns["price"] = *price
+	}
+	if quantity != nil {
+		columns["quantity"] = *quantity
+	}
+	if unit != nil {
+		columns["unit"] = *unit
+	}
+	if priceType != nil {
+		columns["price_type"] = *priceType
+	}
+	if validity != nil {
+		columns["valid_from"] = validity.From
+		columns["valid_to"] = validity.To
+	}
+	columns["version"] = gorm.Expr("version + 1")
+
+	// バージョンがいずれかに一致する場合だけ更新
+	db := tx.Model(&entity.Price{}).Where("id = ? AND user_id = ? AND deleted_at IS NULL AND version IN ?", id, userId, versions).Updates(columns)
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+// 外れ値の疑いの設定（価格の登録や更新に続けて実行するためバージョンは変えない）
+func (r *priceRepositoryGorm) SetSuspicious(ctx context.Context, id uint, suspicious bool) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Model(&entity.Price{}).Where("id = ?", id).Update("suspicious", suspicious).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+func (r *priceRepositoryGorm) Delete(ctx context.Context, id, userId uint, versions []uint) (int64, error) {
 	slog.DebugContext(ctx, "start")
 	defer slog.DebugContext(ctx, "end")
 
@@ -142,7 +638,274 @@ func (r *priceRepositoryGorm) Delete(ctx context.Context, id, userId uint) (int6
 		},
 	}
 
-	db := tx.Where("user_id = ?", userId).Delete(price)
+	// バージョンがいずれかに一致する場合だけ削除
+	db := tx.Where("user_id = ? AND version IN ?", userId, versions).Delete(price)
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+// タグの変更を表現の変更として扱うため、タグが付与されている価格のバージョンを上げる
+func (r *priceRepositoryGorm) TouchByTagId(ctx context.Context, tagId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Model(&entity.Price{}).
+		Where("id IN (SELECT price_id FROM price_tags WHERE tag_id = ?)", tagId).
+		Updates(map[string]any{"version": gorm.Expr("version + 1")})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+// タグが付与されている価格（論理削除済みを除く、IDの昇順）
+func (r *priceRepositoryGorm) FindByTagIdForUpdate(ctx context.Context, tagId uint) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	var prices []entity.Price
+	if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+		Where("id IN (SELECT price_id FROM price_tags WHERE tag_id = ?)", tagId).
+		Order("id").
+		Find(&prices).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return prices, nil
+}
+
+// IDを指定した価格（論理削除済みを除く、IDの昇順）
+func (r *priceRepositoryGorm) FindByIds(ctx context.Context, ids []uint) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var prices []entity.Price
+	if err := tx.Where("id IN ?", ids).Order("id").Find(&prices).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return prices, nil
+}
+
+// 商品ごとの価格の件数（論理削除済みを除く、商品名の順）
+func (r *priceRepositoryGorm) CountByProduct(ctx context.Context, userId uint) ([]entity.ProductCount, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var counts []entity.ProductCount
+	if err := tx.Model(&entity.Price{}).
+		Select("product, COUNT(*) AS count").
+		Where("user_id = ?", userId).
+		Group("product").
+		Order("product").
+		Scan(&counts).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return counts, nil
+}
+
+// 商品名が一致する価格（論理削除済みを含む、IDの昇順）
+func (r *priceRepositoryGorm) FindByProductForUpdate(ctx context.Context, userId uint, product string) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	var entities []entity.Price
+	if err := tx.Unscoped().Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Where("user_id = ? AND product = ?", userId, product).Order("id").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+// IDがafterIdより大きい価格（論理削除済みを含む、IDの昇順）
+func (r *priceRepositoryGorm) FindAfterId(ctx context.Context, afterId uint, limit int) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.Price
+	if err := tx.Unscoped().Where("id > ?", afterId).Order("id").Limit(limit).Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+// 店舗と商品名の変更（論理削除済みも含め、バージョンがいずれかに一致する場合だけ更新して更新後の行を取得）
+func (r *priceRepositoryGorm) Rename(ctx context.Context, id uint, versions []uint, store, product string) (*entity.Price, int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Unscoped().Model(&entity.Price{}).
+		Where("id = ? AND version IN ?", id, versions).
+		Updates(map[string]any{"store": store, "product": product, "version": gorm.Expr("version + 1")})
+	if db.Error != nil {
+		return nil, 0, wrap(db.Error)
+	}
+	if db.RowsAffected != 1 {
+		return nil, db.RowsAffected, nil
+	}
+
+	// 更新後の行の取得（RETURNINGに対応しないDBもあるため取得し直す）
+	after, err := findUnscoped(tx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return after, db.RowsAffected, nil
+}
+
+// 論理削除済みを含むIDでの取得（同じトランザクションで更新した直後の行の取得に使う）
+func findUnscoped(tx *gorm.DB, id uint) (*entity.Price, error) {
+	price := &entity.Price{
+		Model: gorm.Model{
+			ID: id,
+		},
+	}
+	if err := tx.Unscoped().First(price).Error; err != nil {
+		return nil, wrap(err)
+	}
+	return price, nil
+}
+
+func (r *priceRepositoryGorm) FindDeletedByUserId(ctx context.Context, userId uint) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.Price
+	if err := tx.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", userId).Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *priceRepositoryGorm) FindDeletedForUpdate(ctx context.Context, id, userId uint) (*entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	price := &entity.Price{
+		Model: gorm.Model{
+			ID: id,
+		},
+	}
+
+	if err := tx.Unscoped().Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Where("user_id = ? AND deleted_at IS NOT NULL", userId).First(price).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return price, nil
+}
+
+// 削除日時がbeforeより前の価格（全ユーザ、IDの昇順）
+func (r *priceRepositoryGorm) FindDeletedBeforeForUpdate(ctx context.Context, before time.Time) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	var entities []entity.Price
+	if err := tx.Unscoped().Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Where("deleted_at < ?", before).Order("id").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *priceRepositoryGorm) Restore(ctx context.Context, id, userId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Unscoped().Model(&entity.Price{}).
+		Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", id, userId).
+		Updates(map[string]any{"deleted_at": nil, "version": gorm.Expr("version + 1")}) // 削除前のETagでの更新を防ぐ
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *priceRepositoryGorm) Purge(ctx context.Context, id, userId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	price := &entity.Price{
+		Model: gorm.Model{
+			ID: id,
+		},
+	}
+
+	// タグとの関連の削除
+	if err := tx.Where("price_id IN (SELECT id FROM prices WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL)", id, userId).Delete(&entity.PriceTag{}).Error; err != nil {
+		return 0, wrap(err)
+	}
+
+	db := tx.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", userId).Delete(price)
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *priceRepositoryGorm) PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	// タグとの関連の削除
+	if err := tx.Where("price_id IN (SELECT id FROM prices WHERE deleted_at < ?)", before).Delete(&entity.PriceTag{}).Error; err != nil {
+		return 0, wrap(err)
+	}
+
+	db := tx.Unscoped().Where("deleted_at < ?", before).Delete(&entity.Price{})
 	if db.Error != nil {
 		return 0, wrap(db.Error)
 	}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/ystkg/rest-example/entity"
+	"gorm.io/gorm"
+)
+
+// 価格の変更履歴テーブル操作
+type PriceRevisionRepository interface {
+	Create(ctx context.Context, revision *entity.PriceRevision) error
+	CreateAll(ctx context.Context, revisions []entity.PriceRevision) error
+	Find(ctx context.Context, id, priceId uint) (*entity.PriceRevision, error)
+	FindByPriceId(ctx context.Context, priceId uint) ([]entity.PriceRevision, error)
+}
+
+type priceRevisionRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewPriceRevisionRepository(db *gorm.DB) PriceRevisionRepository {
+	return &priceRevisionRepositoryGorm{db}
+}
+
+func (r *priceRevisionRepositoryGorm) Create(ctx context.Context, revision *entity.PriceRevision) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Create(revision).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+func (r *priceRevisionRepositoryGorm) CreateAll(ctx context.Context, revisions []entity.PriceRevision) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Create(&revisions).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+func (r *priceRevisionRepositoryGorm) Find(ctx context.Context, id, priceId uint) (*entity.PriceRevision, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	revision := &entity.PriceRevision{ID: id}
+	if err := tx.Where("price_id = ?", priceId).First(revision).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return revision, nil
+}
+
+func (r *priceRevisionRepositoryGorm) FindByPriceId(ctx context.Context, priceId uint) ([]entity.PriceRevision, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.PriceRevision
+	if err := tx.Where("price_id = ?", priceId).Order("id").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/ystkg/rest-example/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 商品名の別名テーブル操作
+type ProductAliasRepository interface {
+	Create(ctx context.Context, alias *entity.ProductAlias) error
+	Save(ctx context.Context, userId uint, alias, product string) error
+	Find(ctx context.Context, id, userId uint) (*entity.ProductAlias, error)
+	FindByUserId(ctx context.Context, userId uint) ([]entity.ProductAlias, error)
+	FindByAliases(ctx context.Context, userId uint, aliases []string) ([]entity.ProductAlias, error)
+	Repoint(ctx context.Context, userId uint, product, into string) (int64, error)
+	Delete(ctx context.Context, id, userId uint) (int64, error)
+}
+
+type productAliasRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewProductAliasRepository(db *gorm.DB) ProductAliasRepository {
+	return &productAliasRepositoryGorm{db}
+}
+
+func (r *productAliasRepositoryGorm) Create(ctx context.Context, alias *entity.ProductAlias) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Create(alias).Error; err != nil {
+		if isDuplicated(err) {
+			return errors.Join(wrap(ErrDuplicated), err)
+		}
+		return wrap(err)
+	}
+
+	return nil
+}
+
+// 別名の登録（既にあれば商品名を置き換え）
+func (r *productAliasRepositoryGorm) Save(ctx context.Context, userId uint, alias, product string) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	productAlias := &entity.ProductAlias{UserID: userId, Alias: alias, Product: product}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "alias"}},
+		DoUpdates: clause.AssignmentColumns([]string{"product", "updated_at"}),
+	}).Create(productAlias).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+func (r *productAliasRepositoryGorm) Find(ctx context.Context, id, userId uint) (*entity.ProductAlias, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	alias := &entity.ProductAlias{ID: id}
+	if err := tx.Where("user_id = ?", userId).First(alias).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return alias, nil
+}
+
+// 商品名、別名の順
+func (r *productAliasRepositoryGorm) FindByUserId(ctx context.Context, userId uint) ([]entity.ProductAlias, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.ProductAlias
+	if err := tx.Where("user_id = ?", userId).Order("product, alias").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *productAliasRepositoryGorm) FindByAliases(ctx context.Context, userId uint, aliases []string) ([]entity.ProductAlias, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.ProductAlias
+	if err := tx.Where("user_id = ? AND alias IN ?", userId, aliases).Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+// 商品名がproductの別名をintoの別名にする
+func (r *productAliasRepositoryGorm) Repoint(ctx context.Context, userId uint, product, into string) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Model(&entity.ProductAlias{}).Where("user_id = ? AND product = ?", userId, product).Update("product", into)
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *productAliasRepositoryGorm) Delete(ctx context.Context, id, userId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Where("user_id = ?", userId).Delete(&entity.ProductAlias{ID: id})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+	"gorm.io/gorm"
+)
+
+// 購入テーブル操作（明細はPurchaseItemRepository）
+type PurchaseRepository interface {
+	Create(ctx context.Context, purchase *entity.Purchase) error
+	Find(ctx context.Context, id, userId uint) (*entity.Purchase, error)
+	FindByUserId(ctx context.Context, userId uint, from, to *time.Time) ([]entity.Purchase, error)
+	Delete(ctx context.Context, id, userId uint) (int64, error)
+}
+
+type purchaseRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewPurchaseRepository(db *gorm.DB) PurchaseRepository {
+	return &purchaseRepositoryGorm{db}
+}
+
+func (r *purchaseRepositoryGorm) Create(ctx context.Context, purchase *entity.Purchase) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Create(purchase).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+func (r *purchaseRepositoryGorm) Find(ctx context.Context, id, userId uint) (*entity.Purchase, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	purchase := &entity.Purchase{ID: id}
+	if err := tx.Where("user_id = ?", userId).First(purchase).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return purchase, nil
+}
+
+// 日時の降順（fromとtoはnilなら制限なし、toは含まない）
+func (r *purchaseRepositoryGorm) FindByUserId(ctx context.Context, userId uint, from, to *time.Time) ([]entity.Purchase, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	tx = tx.Where("user_id = ?", userId)
+	if from != nil {
+		tx = tx.Where("date_time >= ?", *from)
+	}
+	if to != nil {
+		tx = tx.Where("date_time < ?", *to)
+	}
+
+	var entities []entity.Purchase
+	if err := tx.Order("date_time DESC, id DESC").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *purchaseRepositoryGorm) Delete(ctx context.Context, id, userId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Where("user_id = ?", userId).Delete(&entity.Purchase{ID: id})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+// 購入の明細テーブル操作
+type PurchaseItemRepository interface {
+	Create(ctx context.Context, items []entity.PurchaseItem) error
+	FindByPurchaseIds(ctx context.Context, purchaseIds []uint) ([]entity.PurchaseItem, error)
+	DeleteByPurchaseId(ctx context.Context, purchaseId uint) error
+}
+
+type purchaseItemRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewPurchaseItemRepository(db *gorm.DB) PurchaseItemRepository {
+	return &purchaseItemRepositoryGorm{db}
+}
+
+func (r *purchaseItemRepositoryGorm) Create(ctx context.Context, items []entity.PurchaseItem) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	tx := tx(ctx)
+
+	if err := tx.Create(&items).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+// 購入ごとに登録順
+func (r *purchaseItemRepositoryGorm) FindByPurchaseIds(ctx context.Context, purchaseIds []uint) ([]entity.PurchaseItem, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	if len(purchaseIds) == 0 {
+		return nil, nil
+	}
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.PurchaseItem
+	if err := tx.Where("purchase_id IN ?", purchaseIds).Order("purchase_id, id").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *purchaseItemRepositoryGorm) DeleteByPurchaseId(ctx context.Context, purchaseId uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Where("purchase_id = ?", purchaseId).Delete(&entity.PurchaseItem{}).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
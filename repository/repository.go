@@ -27,17 +27,53 @@ type Repository interface {
 
 	User() UserRepository
 	Price() PriceRepository
+	PriceRevision() PriceRevisionRepository
+	AlertRule() AlertRuleRepository
+	Notification() NotificationRepository
+	Webhook() WebhookRepository
+	WebhookDelivery() WebhookDeliveryRepository
+	Tag() TagRepository
+	Attachment() AttachmentRepository
+	Blob() BlobStore
+	Store() StoreRepository
+	IdempotencyKey() IdempotencyKeyRepository
+	ShoppingList() ShoppingListRepository
+	ShoppingListItem() ShoppingListItemRepository
+	Purchase() PurchaseRepository
+	PurchaseItem() PurchaseItemRepository
+	IndexBasket() IndexBasketRepository
+	IndexBasketItem() IndexBasketItemRepository
+	ProductAlias() ProductAliasRepository
+	Name() NameRepository
 }
 
 type repositoryGorm struct {
 	db    *gorm.DB
 	owner func(context.Context) (bool, error)
 
-	user  UserRepository
-	price PriceRepository
+	user            UserRepository
+	price           PriceRepository
+	priceRevision   PriceRevisionRepository
+	alertRule       AlertRuleRepository
+	notification    NotificationRepository
+	webhook         WebhookRepository
+	delivery        WebhookDeliveryRepository
+	tag             TagRepository
+	attachment      AttachmentRepository
+	blob            BlobStore
+	store           StoreRepository
+	idempotencyKey  IdempotencyKeyRepository
+	shoppingList    ShoppingListRepository
+	listItem        ShoppingListItemRepository
+	purchase        PurchaseRepository
+	purchaseItem    PurchaseItemRepository
+	indexBasket     IndexBasketRepository
+	indexBasketItem IndexBasketItemRepository
+	productAlias    ProductAliasRepository
+	name            NameRepository
 }
 
-func NewRepository(driverName string, sqlDB *sql.DB) (Repository, error) {
+func NewRepository(driverName string, sqlDB *sql.DB, blob BlobStore) (Repository, error) {
 	var dialector gorm.Dialector
 	var owner func(context.Context) (bool, error)
 	switch driverName {
@@ -66,19 +102,37 @@ func NewRepository(driverName string, sqlDB *sql.DB) (Repository, error) {
 	default:
 		return nil, fmt.Errorf("unsupported:%s", driverName)
 	}
-	return newRepositoryByDialector(dialector, owner)
+	return newRepositoryByDialector(dialector, owner, blob)
 }
 
-func newRepositoryByDialector(dialector gorm.Dialector, owner func(context.Context) (bool, error)) (Repository, error) {
+func newRepositoryByDialector(dialector gorm.Dialector, owner func(context.Context) (bool, error), blob BlobStore) (Repository, error) {
 	db, err := gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
 		return nil, wrap(err)
 	}
 	return &repositoryGorm{
-		db:    db,
-		owner: owner,
-		user:  NewUserRepository(db),
-		price: NewPriceRepository(db),
+		db:              db,
+		owner:           owner,
+		user:            NewUserRepository(db),
+		price:           NewPriceRepository(db),
+		priceRevision:   NewPriceRevisionRepository(db),
+		alertRule:       NewAlertRuleRepository(db),
+		notification:    NewNotificationRepository(db),
+		webhook:         NewWebhookRepository(db),
+		delivery:        NewWebhookDeliveryRepository(db),
+		tag:             NewTagRepository(db),
+		attachment:      NewAttachmentRepository(db),
+		blob:            blob,
+		store:           NewStoreRepository(db),
+		idempotencyKey:  NewIdempotencyKeyRepository(db),
+		shoppingList:    NewShoppingListRepository(db),
+		listItem:        NewShoppingListItemRepository(db),
+		purchase:        NewPurchaseRepository(db),
+		purchaseItem:    NewPurchaseItemRepository(db),
+		indexBasket:     NewIndexBasketRepository(db),
+		indexBasketItem: NewIndexBasketItemRepository(db),
+		productAlias:    NewProductAliasRepository(db),
+		name:            NewNameRepository(db),
 	}, nil
 }
 
@@ -90,6 +144,23 @@ func (r *repositoryGorm) InitDb(ctx context.Context) error {
 	return r.db.WithContext(ctx).AutoMigrate(
 		&entity.User{},
 		&entity.Price{},
+		&entity.PriceRevision{},
+		&entity.AlertRule{},
+		&entity.Notification{},
+		&entity.Webhook{},
+		&entity.WebhookDelivery{},
+		&entity.Tag{},
+		&entity.PriceTag{},
+		&entity.Attachment{},
+		&entity.Store{},
+		&entity.IdempotencyKey{},
+		&entity.ShoppingList{},
+		&entity.ShoppingListItem{},
+		&entity.Purchase{},
+		&entity.PurchaseItem{},
+		&entity.IndexBasket{},
+		&entity.IndexBasketItem{},
+		&entity.ProductAlias{},
 	)
 }
 
@@ -140,3 +211,75 @@ func (r *repositoryGorm) User() UserRepository {
 func (r *repositoryGorm) Price() PriceRepository {
 	return r.price
 }
+
+func (r *repositoryGorm) PriceRevision() PriceRevisionRepository {
+	return r.priceRevision
+}
+
+func (r *repositoryGorm) AlertRule() AlertRuleRepository {
+	return r.alertRule
+}
+
+func (r *repositoryGorm) Notification() NotificationRepository {
+	return r.notification
+}
+
+func (r *repositoryGorm) Webhook() WebhookRepository {
+	return r.webhook
+}
+
+func (r *repositoryGorm) WebhookDelivery() WebhookDeliveryRepository {
+	return r.delivery
+}
+
+func (r *repositoryGorm) Tag() TagRepository {
+	return r.tag
+}
+
+func (r *repositoryGorm) Attachment() AttachmentRepository {
+	return r.attachment
+}
+
+func (r *repositoryGorm) Blob() BlobStore {
+	return r.blob
+}
+
+func (r *repositoryGorm) Store() StoreRepository {
+	return r.store
+}
+
+func (r *repositoryGorm) IdempotencyKey() IdempotencyKeyRepository {
+	return r.idempotencyKey
+}
+
+func (r *repositoryGorm) ShoppingList() ShoppingListRepository {
+	return r.shoppingList
+}
+
+func (r *repositoryGorm) ShoppingListItem() ShoppingListItemRepository {
+	return r.listItem
+}
+
+func (r *repositoryGorm) Purchase() PurchaseRepository {
+	return r.purchase
+}
+
+func (r *repositoryGorm) PurchaseItem() PurchaseItemRepository {
+	return r.purchaseItem
+}
+
+func (r *repositoryGorm) IndexBasket() IndexBasketRepository {
+	return r.indexBasket
+}
+
+func (r *repositoryGorm) IndexBasketItem() IndexBasketItemRepository {
+	return r.indexBasketItem
+}
+
+func (r *repositoryGorm) ProductAlias() ProductAliasRepository {
+	return r.productAlias
+}
+
+func (r *repositoryGorm) Name() NameRepository {
+	return r.name
+}
@@ -0,0 +1,330 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 買い物リストテーブル操作（品目はShoppingListItemRepository）
+type ShoppingListRepository interface {
+	Create(ctx context.Context, list *entity.ShoppingList) error
+	Find(ctx context.Context, id, userId uint) (*entity.ShoppingList, error)
+	FindForUpdate(ctx context.Context, id, userId uint) (*entity.ShoppingList, error)
+	FindByUserId(ctx context.Context, userId uint) ([]entity.ShoppingList, error)
+	Update(ctx context.Context, list *entity.ShoppingList) (int64, error)
+	Touch(ctx context.Context, id uint) error
+	Delete(ctx context.Context, id, userId uint) (int64, error)
+}
+
+type shoppingListRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewShoppingListRepository(db *gorm.DB) ShoppingListRepository {
+	return &shoppingListRepositoryGorm{db}
+}
+
+func (r *shoppingListRepositoryGorm) Create(ctx context.Context, list *entity.ShoppingList) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Create(list).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+func (r *shoppingListRepositoryGorm) Find(ctx context.Context, id, userId uint) (*entity.ShoppingList, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	list := &entity.ShoppingList{ID: id}
+	if err := tx.Where("user_id = ?", userId).First(list).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return list, nil
+}
+
+func (r *shoppingListRepositoryGorm) FindForUpdate(ctx context.Context, id, userId uint) (*entity.ShoppingList, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	list := &entity.ShoppingList{ID: id}
+	if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Where("user_id = ?", userId).First(list).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return list, nil
+}
+
+// 登録順
+func (r *shoppingListRepositoryGorm) FindByUserId(ctx context.Context, userId uint) ([]entity.ShoppingList, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.ShoppingList
+	if err := tx.Where("user_id = ?", userId).Order("id").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *shoppingListRepositoryGorm) Update(ctx context.Context, list *entity.ShoppingList) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Model(&entity.ShoppingList{ID: list.ID}).Where("user_id = ?", list.UserID).Update("name", list.Name)
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+// 品目の変更でリストの更新日時を更新
+func (r *shoppingListRepositoryGorm) Touch(ctx context.Context, id uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Model(&entity.ShoppingList{ID: id}).Update("updated_at", time.Now()).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+func (r *shoppingListRepositoryGorm) Delete(ctx context.Context, id, userId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Where("user_id = ?", userId).Delete(&entity.ShoppingList{ID: id})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+// 買い物リストの品目テーブル操作
+type ShoppingListItemRepository interface {
+	Create(ctx context.Context, items []entity.ShoppingListItem) error
+	Find(ctx context.Context, id, listId, userId uint) (*entity.ShoppingListItem, error)
+	FindByListIds(ctx context.Context, listIds []uint) ([]entity.ShoppingListItem, error)
+	CountByListId(ctx context.Context, listId uint) (int64, error)
+	MaxPosition(ctx context.Context, listId uint) (int, error)
+	Update(ctx context.Context, item *entity.ShoppingListItem) (int64, error)
+	UpdateChecked(ctx context.Context, id, listId, userId uint, checked bool) (int64, error)
+	UpdatePosition(ctx context.Context, id, listId uint, position int) (int64, error)
+	Delete(ctx context.Context, id, listId, userId uint) (int64, error)
+	DeleteByListId(ctx context.Context, listId uint) error
+}
+
+type shoppingListItemRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewShoppingListItemRepository(db *gorm.DB) ShoppingListItemRepository {
+	return &shoppingListItemRepositoryGorm{db}
+}
+
+func (r *shoppingListItemRepositoryGorm) Create(ctx context.Context, items []entity.ShoppingListItem) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	tx := tx(ctx)
+
+	if err := tx.Create(&items).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+func (r *shoppingListItemRepositoryGorm) Find(ctx context.Context, id, listId, userId uint) (*entity.ShoppingListItem, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	item := &entity.ShoppingListItem{ID: id}
+	if err := tx.Where("list_id = ? AND user_id = ?", listId, userId).First(item).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return item, nil
+}
+
+// リストごとに並び順
+func (r *shoppingListItemRepositoryGorm) FindByListIds(ctx context.Context, listIds []uint) ([]entity.ShoppingListItem, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	if len(listIds) == 0 {
+		return nil, nil
+	}
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.ShoppingListItem
+	if err := tx.Where("list_id IN ?", listIds).Order("list_id, position, id").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *shoppingListItemRepositoryGorm) CountByListId(ctx context.Context, listId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var count int64
+	if err := tx.Model(&entity.ShoppingListItem{}).Where("list_id = ?", listId).Count(&count).Error; err != nil {
+		return 0, wrap(err)
+	}
+
+	return count, nil
+}
+
+// 品目がなければ-1
+func (r *shoppingListItemRepositoryGorm) MaxPosition(ctx context.Context, listId uint) (int, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var position int
+	if err := tx.Model(&entity.ShoppingListItem{}).Select("COALESCE(MAX(position), -1)").Where("list_id = ?", listId).Scan(&position).Error; err != nil {
+		return 0, wrap(err)
+	}
+
+	return position, nil
+}
+
+func (r *shoppingListItemRepositoryGorm) Update(ctx context.Context, item *entity.ShoppingListItem) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Model(&entity.ShoppingListItem{ID: item.ID}).Where("list_id = ? AND user_id = ?", item.ListID, item.UserID).Updates(map[string]any{
+		"product":  item.Product,
+		"quantity": item.Quantity,
+		"checked":  item.Checked,
+	})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *shoppingListItemRepositoryGorm) UpdateChecked(ctx context.Context, id, listId, userId uint, checked bool) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Model(&entity.ShoppingListItem{ID: id}).Where("list_id = ? AND user_id = ?", listId, userId).Update("checked", checked)
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *shoppingListItemRepositoryGorm) UpdatePosition(ctx context.Context, id, listId uint, position int) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Model(&entity.ShoppingListItem{ID: id}).Where("list_id = ?", listId).Update("position", position)
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *shoppingListItemRepositoryGorm) Delete(ctx context.Context, id, listId, userId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Where("list_id = ? AND user_id = ?", listId, userId).Delete(&entity.ShoppingListItem{ID: id})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *shoppingListItemRepositoryGorm) DeleteByListId(ctx context.Context, listId uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Where("list_id = ?", listId).Delete(&entity.ShoppingListItem{}).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+
+	"github.com/ystkg/rest-example/entity"
+	"gorm.io/gorm"
+)
+
+const (
+	earthRadius     = 6371008.8                   // 地球の平均半径（メートル）
+	metersPerDegree = earthRadius * math.Pi / 180 // 緯度1度あたりの距離
+)
+
+// 店舗テーブル操作
+type StoreRepository interface {
+	Create(ctx context.Context, store *entity.Store) error
+	Find(ctx context.Context, id, userId uint) (*entity.Store, error)
+	FindByUserId(ctx context.Context, userId uint) ([]entity.Store, error)
+	FindNearby(ctx context.Context, userId uint, latitude, longitude, radius float64) ([]entity.Store, error)
+	Update(ctx context.Context, store *entity.Store) (int64, error)
+	Delete(ctx context.Context, id, userId uint) (int64, error)
+}
+
+type storeRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewStoreRepository(db *gorm.DB) StoreRepository {
+	return &storeRepositoryGorm{db}
+}
+
+func (r *storeRepositoryGorm) Create(ctx context.Context, store *entity.Store) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Create(store).Error; err != nil {
+		if isDuplicated(err) {
+			return errors.Join(wrap(ErrDuplicated), err)
+		}
+		return wrap(err)
+	}
+
+	return nil
+}
+
+func (r *storeRepositoryGorm) Find(ctx context.Context, id, userId uint) (*entity.Store, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	store := &entity.Store{ID: id}
+	if err := tx.Where("user_id = ?", userId).First(store).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return store, nil
+}
+
+// 名前の昇順
+func (r *storeRepositoryGorm) FindByUserId(ctx context.Context, userId uint) ([]entity.Store, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.Store
+	if err := tx.Where("user_id = ?", userId).Order("name").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+// 半径（メートル）以内の店舗（距離の昇順）
+//
+// PostGISなどの拡張に依存しないよう、緯度と経度の範囲で候補を絞り込んでから
+// ハーバサインの公式で距離を求める
+func (r *storeRepositoryGorm) FindNearby(ctx context.Context, userId uint, latitude, longitude, radius float64) ([]entity.Store, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	// 候補の絞り込み（極付近や日付変更線をまたぐ場合は経度で絞り込まない）
+	deltaLat := radius / metersPerDegree
+	candidates := tx.Model(&entity.Store{}).
+		Select("stores.*, ? * ASIN(LEAST(1, SQRT("+
+			"POWER(SIN(RADIANS(latitude - ?) / 2), 2) + "+
+			"COS(RADIANS(?)) * COS(RADIANS(latitude)) * POWER(SIN(RADIANS(longitude - ?) / 2), 2)"+
+			"))) AS distance", 2*earthRadius, latitude, latitude, longitude).
+		Where("user_id = ? AND latitude BETWEEN ? AND ?", userId, latitude-deltaLat, latitude+deltaLat)
+	if cos := math.Cos(latitude * math.Pi / 180); latitude-deltaLat > -90 && latitude+deltaLat < 90 && 0 < cos {
+		deltaLng := deltaLat / cos
+		if -180 <= longitude-deltaLng && longitude+deltaLng <= 180 {
+			candidates = candidates.Where("longitude BETWEEN ? AND ?", longitude-deltaLng, longitude+deltaLng)
+		}
+	}
+
+	// 別名の距離で絞り込むため副問い合わせ
+	var entities []entity.Store
+	if err := tx.Table("(?) AS stores", candidates).Where("distance <= ?", radius).Order("distance, name").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *storeRepositoryGorm) Update(ctx context.Context, store *entity.Store) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Model(&entity.Store{ID: store.ID}).Where("user_id = ?", store.UserID).Updates(map[string]any{
+		"name":      store.Name,
+		"latitude":  store.Latitude,
+		"longitude": store.Longitude,
+		"address":   store.Address,
+	})
+	if db.Error != nil {
+		if isDuplicated(db.Error) {
+			return 0, errors.Join(wrap(ErrDuplicated), db.Error)
+		}
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *storeRepositoryGorm) Delete(ctx context.Context, id, userId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Where("user_id = ?", userId).Delete(&entity.Store{ID: id})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
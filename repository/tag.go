@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/ystkg/rest-example/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// タグテーブルと価格との関連テーブル操作
+type TagRepository interface {
+	Create(ctx context.Context, userId uint, name string) (*entity.Tag, error)
+	FindOrCreate(ctx context.Context, userId uint, names []string) ([]entity.Tag, error)
+	Find(ctx context.Context, id, userId uint) (*entity.Tag, error)
+	FindByUserId(ctx context.Context, userId uint) ([]entity.Tag, error)
+	Rename(ctx context.Context, id, userId uint, name string) (int64, error)
+	Merge(ctx context.Context, id, intoId, userId uint) (int64, error)
+	Delete(ctx context.Context, id, userId uint) (int64, error)
+
+	// 価格との関連
+	FindNamesByPriceIds(ctx context.Context, priceIds []uint) (map[uint][]string, error)
+	ReplacePriceTags(ctx context.Context, priceId uint, tagIds []uint) error
+}
+
+type tagRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewTagRepository(db *gorm.DB) TagRepository {
+	return &tagRepositoryGorm{db}
+}
+
+func (r *tagRepositoryGorm) Create(ctx context.Context, userId uint, name string) (*entity.Tag, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	tag := &entity.Tag{
+		UserID: userId,
+		Name:   name,
+	}
+
+	if err := tx.Create(tag).Error; err != nil {
+		if isDuplicated(err) {
+			return nil, errors.Join(wrap(ErrDuplicated), err)
+		}
+		return nil, wrap(err)
+	}
+
+	return tag, nil
+}
+
+// 名前に該当するタグ（存在しなければ登録）
+func (r *tagRepositoryGorm) FindOrCreate(ctx context.Context, userId uint, names []string) ([]entity.Tag, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	tx := tx(ctx)
+
+	// 同時に登録された場合も一意制約違反にしない
+	tags := make([]entity.Tag, len(names))
+	for i, v := range names {
+		tags[i] = entity.Tag{UserID: userId, Name: v}
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	var entities []entity.Tag
+	if err := tx.Where("user_id = ? AND name IN ?", userId, names).Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *tagRepositoryGorm) Find(ctx context.Context, id, userId uint) (*entity.Tag, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.Tag
+	if err := r.withCount(tx).Where("tags.id = ? AND tags.user_id = ?", id, userId).Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+	if len(entities) == 0 {
+		return nil, nil
+	}
+
+	return &entities[0], nil
+}
+
+// 名前の昇順
+func (r *tagRepositoryGorm) FindByUserId(ctx context.Context, userId uint) ([]entity.Tag, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.Tag
+	if err := r.withCount(tx).Where("tags.user_id = ?", userId).Order("tags.name").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+// 付与されている価格の件数を含める
+func (r *tagRepositoryGorm) withCount(tx *gorm.DB) *gorm.DB {
+	return tx.Model(&entity.Tag{}).
+		Select("tags.*, COUNT(prices.id) AS count").
+		Joins("LEFT JOIN price_tags ON price_tags.tag_id = tags.id").
+		Joins("LEFT JOIN prices ON prices.id = price_tags.price_id AND prices.deleted_at IS NULL").
+		Group("tags.id")
+}
+
+func (r *tagRepositoryGorm) Rename(ctx context.Context, id, userId uint, name string) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Model(&entity.Tag{ID: id}).Where("user_id = ?", userId).Update("name", name)
+	if db.Error != nil {
+		if isDuplicated(db.Error) {
+			return 0, errors.Join(wrap(ErrDuplicated), db.Error)
+		}
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+// タグの付け替えと統合元のタグの削除
+func (r *tagRepositoryGorm) Merge(ctx context.Context, id, intoId, userId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	var priceIds []uint
+	if err := tx.Model(&entity.PriceTag{}).Where("tag_id = ?", id).Pluck("price_id", &priceIds).Error; err != nil {
+		return 0, wrap(err)
+	}
+	if len(priceIds) != 0 {
+		links := make([]entity.PriceTag, len(priceIds))
+		for i, v := range priceIds {
+			links[i] = entity.PriceTag{PriceID: v, TagID: intoId}
+		}
+		// 両方のタグが付与されている価格は統合先の関連をそのまま使う
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+			return 0, wrap(err)
+		}
+	}
+
+	return r.Delete(ctx, id, userId)
+}
+
+// 価格との関連も削除
+func (r *tagRepositoryGorm) Delete(ctx context.Context, id, userId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Where("tag_id IN (SELECT id FROM tags WHERE id = ? AND user_id = ?)", id, userId).Delete(&entity.PriceTag{}).Error; err != nil {
+		return 0, wrap(err)
+	}
+
+	db := tx.Where("user_id = ?", userId).Delete(&entity.Tag{ID: id})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+// 価格ごとのタグの名前（名前の昇順）
+func (r *tagRepositoryGorm) FindNamesByPriceIds(ctx context.Context, priceIds []uint) (map[uint][]string, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	names := make(map[uint][]string)
+	if len(priceIds) == 0 {
+		return names, nil
+	}
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var rows []struct {
+		PriceID uint
+		Name    string
+	}
+	if err := tx.Model(&entity.PriceTag{}).
+		Select("price_tags.price_id, tags.name").
+		Joins("JOIN tags ON tags.id = price_tags.tag_id").
+		Where("price_tags.price_id IN ?", priceIds).
+		Order("price_tags.price_id, tags.name").
+		Scan(&rows).Error; err != nil {
+		return nil, wrap(err)
+	}
+	for _, v := range rows {
+		names[v.PriceID] = append(names[v.PriceID], v.Name)
+	}
+
+	return names, nil
+}
+
+// 価格のタグを置き換え
+func (r *tagRepositoryGorm) ReplacePriceTags(ctx context.Context, priceId uint, tagIds []uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Where("price_id = ?", priceId).Delete(&entity.PriceTag{}).Error; err != nil {
+		return wrap(err)
+	}
+	if len(tagIds) == 0 {
+		return nil
+	}
+
+	links := make([]entity.PriceTag, len(tagIds))
+	for i, v := range tagIds {
+		links[i] = entity.PriceTag{PriceID: priceId, TagID: v}
+	}
+	if err := tx.Create(&links).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
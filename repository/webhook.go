@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+	"gorm.io/gorm"
+)
+
+// Webhookの購読テーブル操作
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *entity.Webhook) error
+	Find(ctx context.Context, id, userId uint) (*entity.Webhook, error)
+	FindByUserId(ctx context.Context, userId uint) ([]entity.Webhook, error)
+	Update(ctx context.Context, webhook *entity.Webhook) (int64, error)
+	Delete(ctx context.Context, id, userId uint) (int64, error)
+}
+
+type webhookRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepositoryGorm{db}
+}
+
+func (r *webhookRepositoryGorm) Create(ctx context.Context, webhook *entity.Webhook) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Create(webhook).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+func (r *webhookRepositoryGorm) Find(ctx context.Context, id, userId uint) (*entity.Webhook, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	webhook := &entity.Webhook{
+		Model: gorm.Model{
+			ID: id,
+		},
+	}
+
+	if err := tx.Where("user_id = ?", userId).First(webhook).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, wrap(err)
+	}
+
+	return webhook, nil
+}
+
+func (r *webhookRepositoryGorm) FindByUserId(ctx context.Context, userId uint) ([]entity.Webhook, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.Webhook
+	if err := tx.Where("user_id = ?", userId).Order("id").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+func (r *webhookRepositoryGorm) Update(ctx context.Context, webhook *entity.Webhook) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Model(webhook).Where("user_id = ?", webhook.UserID).Select("url", "events", "secret").Updates(webhook)
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *webhookRepositoryGorm) Delete(ctx context.Context, id, userId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	webhook := &entity.Webhook{
+		Model: gorm.Model{
+			ID: id,
+		},
+	}
+
+	db := tx.Where("user_id = ?", userId).Delete(webhook)
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+// Webhookの配信テーブル操作
+type WebhookDeliveryRepository interface {
+	CreateAll(ctx context.Context, deliveries []entity.WebhookDelivery) error
+	FindByWebhookId(ctx context.Context, webhookId uint) ([]entity.WebhookDelivery, error)
+	FindDue(ctx context.Context, status string, now time.Time, limit int) ([]entity.WebhookDelivery, error)
+	Claim(ctx context.Context, id uint, nextAttemptAt, leaseUntil time.Time) (int64, error)
+	Update(ctx context.Context, delivery *entity.WebhookDelivery) (int64, error)
+	DeleteByWebhookId(ctx context.Context, webhookId uint) (int64, error)
+}
+
+type webhookDeliveryRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func NewWebhookDeliveryRepository(db *gorm.DB) WebhookDeliveryRepository {
+	return &webhookDeliveryRepositoryGorm{db}
+}
+
+func (r *webhookDeliveryRepositoryGorm) CreateAll(ctx context.Context, deliveries []entity.WebhookDelivery) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	if err := tx.Create(&deliveries).Error; err != nil {
+		return wrap(err)
+	}
+
+	return nil
+}
+
+// 新しい順
+func (r *webhookDeliveryRepositoryGorm) FindByWebhookId(ctx context.Context, webhookId uint) ([]entity.WebhookDelivery, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.WebhookDelivery
+	if err := tx.Where("webhook_id = ?", webhookId).Order("id DESC").Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+// 配信予定日時を過ぎた配信（古い順）
+func (r *webhookDeliveryRepositoryGorm) FindDue(ctx context.Context, status string, now time.Time, limit int) ([]entity.WebhookDelivery, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	var entities []entity.WebhookDelivery
+	if err := tx.Where("status = ? AND next_attempt_at <= ?", status, now).Order("next_attempt_at, id").Limit(limit).Find(&entities).Error; err != nil {
+		return nil, wrap(err)
+	}
+
+	return entities, nil
+}
+
+// 配信予定日時を進めて配信を確保（他の配信処理と重複しないよう読み込み時の配信予定日時を条件にする）
+func (r *webhookDeliveryRepositoryGorm) Claim(ctx context.Context, id uint, nextAttemptAt, leaseUntil time.Time) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	db := tx.Model(&entity.WebhookDelivery{}).Where("id = ? AND next_attempt_at = ?", id, nextAttemptAt).Update("next_attempt_at", leaseUntil)
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *webhookDeliveryRepositoryGorm) Update(ctx context.Context, delivery *entity.WebhookDelivery) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+	if tx == nil {
+		tx = r.db.WithContext(ctx)
+	}
+
+	db := tx.Model(delivery).Select("status", "attempts", "next_attempt_at", "last_status_code", "last_error", "delivered_at").Updates(delivery)
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
+
+func (r *webhookDeliveryRepositoryGorm) DeleteByWebhookId(ctx context.Context, webhookId uint) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	tx := tx(ctx)
+
+	db := tx.Where("webhook_id = ?", webhookId).Delete(&entity.WebhookDelivery{})
+	if db.Error != nil {
+		return 0, wrap(db.Error)
+	}
+
+	return db.RowsAffected, nil
+}
@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+// アラートの条件の登録
+func (s *serviceImpl) CreateAlertRule(ctx context.Context, rule *entity.AlertRule) (*entity.AlertRule, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// アラートの条件の登録
+	if err = s.repository.AlertRule().Create(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// アラートの条件の一覧
+func (s *serviceImpl) FindAlertRules(ctx context.Context, userId uint) ([]entity.AlertRule, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.repository.AlertRule().FindByUserId(ctx, userId)
+}
+
+// アラートの条件の取得
+func (s *serviceImpl) FindAlertRule(ctx context.Context, ruleId, userId uint) (*entity.AlertRule, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.repository.AlertRule().Find(ctx, ruleId, userId)
+}
+
+// アラートの条件の更新
+func (s *serviceImpl) UpdateAlertRule(ctx context.Context, rule *entity.AlertRule) (*entity.AlertRule, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// アラートの条件の更新
+	rows, err := s.repository.AlertRule().Update(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return nil, wrap(ErrNotFound)
+		}
+		return nil, wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 更新後のアラートの条件の取得
+	ruleEntity, err := s.repository.AlertRule().Find(ctx, rule.ID, rule.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if ruleEntity == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return ruleEntity, nil
+}
+
+// アラートの条件の削除
+func (s *serviceImpl) DeleteAlertRule(ctx context.Context, ruleId, userId uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.rollback(ctx)
+
+	// アラートの条件の削除
+	rows, err := s.repository.AlertRule().Delete(ctx, ruleId, userId)
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return wrap(ErrNotFound)
+		}
+		return wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// 通知の一覧（readがnilなら既読と未読の両方）
+func (s *serviceImpl) FindNotifications(ctx context.Context, userId uint, read *bool) ([]entity.Notification, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.repository.Notification().FindByUserId(ctx, userId, read)
+}
+
+// 通知の既読と未読の変更
+func (s *serviceImpl) MarkNotification(ctx context.Context, notificationId, userId uint, read bool) (*entity.Notification, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 既読日時の更新
+	var readAt *time.Time
+	if read {
+		now := time.Now()
+		readAt = &now
+	}
+	rows, err := s.repository.Notification().UpdateReadAt(ctx, notificationId, userId, readAt)
+	if err != nil {
+		return nil, err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return nil, wrap(ErrNotFound)
+		}
+		return nil, wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 更新後の通知の取得
+	notification, err := s.repository.Notification().Find(ctx, notificationId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if notification == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+const alertQueueSize = 1000 // 評価待ちの価格の書き込みの上限
+
+// 価格の書き込みを遅らせないよう非同期でアラートの条件を評価（エラーはログのみで書き込みには影響しない）
+func (s *serviceImpl) evaluateAlertsAsync(ctx context.Context, prices ...*entity.Price) {
+	if len(prices) == 0 {
+		return
+	}
+	values := make([]entity.Price, len(prices))
+	for i, v := range prices {
+		values[i] = *v
+	}
+	s.alerts.enqueue(context.WithoutCancel(ctx), values) // レスポンス後も評価を続ける
+}
+
+type alertJob struct {
+	ctx    context.Context
+	prices []entity.Price
+}
+
+// アラートの条件の評価を価格の書き込みとは別のゴルーチンで順に実行
+type alertWorker struct {
+	mu     sync.RWMutex
+	closed bool
+	jobs   chan alertJob // 上限付きの評価待ち
+	done   chan struct{} // 評価待ちを全て終えたらクローズ
+}
+
+func newAlertWorker(size int, evaluate func(context.Context, *entity.Price) error) *alertWorker {
+	w := &alertWorker{jobs: make(chan alertJob, size), done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		for job := range w.jobs {
+			for i := range job.prices {
+				if err := evaluate(job.ctx, &job.prices[i]); err != nil {
+					slog.ErrorContext(job.ctx, err.Error())
+				}
+			}
+		}
+	}()
+	return w
+}
+
+// 評価待ちへの追加（上限を超えた場合とシャットダウン後は評価せずにログを出力）
+func (w *alertWorker) enqueue(ctx context.Context, prices []entity.Price) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		slog.WarnContext(ctx, "alert evaluation skipped: shutting down", "prices", len(prices))
+		return
+	}
+	select {
+	case w.jobs <- alertJob{ctx, prices}:
+	default:
+		slog.ErrorContext(ctx, "alert evaluation skipped: queue is full", "prices", len(prices))
+	}
+}
+
+// 評価待ちの受付を終了して、評価を終えるまで待つ
+func (w *alertWorker) close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.jobs)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *serviceImpl) evaluateAlerts(ctx context.Context, price *entity.Price) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始（価格の書き込みとは別のトランザクション）
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.rollback(ctx)
+
+	// 該当するアラートの条件の取得
+	rules, err := s.repository.AlertRule().FindByProduct(ctx, price.UserID, price.Product, price.Store)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	// 条件の評価
+	averages := map[bool]*float64{} // 店舗を限定するかどうか別の平均価格
+	var notifications []entity.Notification
+	for _, rule := range rules {
+		var average *uint
+		switch {
+		case rule.TargetPrice != nil:
+			if price.Price > *rule.TargetPrice {
+				continue
+			}
+		case rule.DropPercent != nil:
+			byStore := rule.Store != nil
+			avg, ok := averages[byStore]
+			if !ok {
+				if avg, err = s.repository.Price().AverageByProduct(ctx, price.UserID, price.Product, rule.Store, price.ID); err != nil {
+					return err
+				}
+				averages[byStore] = avg
+			}
+			if avg == nil {
+				continue // 比較対象がない
+			}
+			if float64(price.Price) > *avg*float64(100-*rule.DropPercent)/100 {
+				continue
+			}
+			v := uint(math.Round(*avg))
+			average = &v
+		default:
+			continue
+		}
+		notifications = append(notifications, entity.Notification{
+			UserID:      price.UserID,
+			AlertRuleID: rule.ID,
+			PriceID:     price.ID,
+			DateTime:    price.DateTime,
+			Store:       price.Store,
+			Product:     price.Product,
+			Price:       price.Price,
+			Average:     average,
+		})
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	// 通知の登録
+	if err = s.repository.Notification().CreateAll(ctx, notifications); err != nil {
+		return err
+	}
+
+	// コミット
+	return s.commit(ctx)
+}
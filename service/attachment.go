@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/ystkg/rest-example/entity"
+	"github.com/ystkg/rest-example/repository"
+)
+
+const maxAttachmentsPerPrice = 10
+
+// 添付ファイルの登録
+func (s *serviceImpl) CreateAttachment(ctx context.Context, priceId, userId uint, fileName, contentType string, data []byte) (*entity.Attachment, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 価格の存在確認（添付ファイルの件数を数える間に削除されないようロック）
+	price, err := s.repository.Price().FindForUpdate(ctx, priceId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if price == nil {
+		return nil, wrap(ErrNotFound)
+	}
+	count, err := s.repository.Attachment().CountByPriceId(ctx, priceId)
+	if err != nil {
+		return nil, err
+	}
+	if maxAttachmentsPerPrice <= count {
+		return nil, wrap(ErrTooManyAttachments)
+	}
+
+	// 本体の保存
+	key, err := attachmentBlobKey(userId, priceId)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.repository.Blob().Put(ctx, key, data, contentType); err != nil {
+		return nil, err
+	}
+
+	// 添付ファイルの登録
+	attachment := &entity.Attachment{
+		PriceID:     priceId,
+		UserID:      userId,
+		FileName:    fileName,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		BlobKey:     key,
+	}
+	if err = s.repository.Attachment().Create(ctx, attachment); err != nil {
+		s.deleteBlobs(ctx, []string{key})
+		return nil, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		s.deleteBlobs(ctx, []string{key})
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// 添付ファイルの一覧
+func (s *serviceImpl) FindAttachments(ctx context.Context, priceId, userId uint) ([]entity.Attachment, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// 価格の存在確認
+	price, err := s.repository.Price().Find(ctx, priceId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if price == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	return s.repository.Attachment().FindByPriceId(ctx, priceId, userId)
+}
+
+// 添付ファイルの取得（本体は呼び出し側でクローズ）
+func (s *serviceImpl) FindAttachment(ctx context.Context, attachmentId, priceId, userId uint) (*entity.Attachment, io.ReadCloser, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// 価格の存在確認（削除済みの価格の添付ファイルは取得できない）
+	price, err := s.repository.Price().Find(ctx, priceId, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if price == nil {
+		return nil, nil, wrap(ErrNotFound)
+	}
+
+	attachment, err := s.repository.Attachment().Find(ctx, attachmentId, priceId, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if attachment == nil {
+		return nil, nil, wrap(ErrNotFound)
+	}
+
+	body, err := s.repository.Blob().Get(ctx, attachment.BlobKey)
+	if err != nil {
+		if errors.Is(err, repository.ErrBlobNotFound) {
+			return nil, nil, errors.Join(wrap(ErrNotFound), err)
+		}
+		return nil, nil, err
+	}
+
+	return attachment, body, nil
+}
+
+// 添付ファイルの削除
+func (s *serviceImpl) DeleteAttachment(ctx context.Context, attachmentId, priceId, userId uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.rollback(ctx)
+
+	// 価格の存在確認
+	price, err := s.repository.Price().Find(ctx, priceId, userId)
+	if err != nil {
+		return err
+	}
+	if price == nil {
+		return wrap(ErrNotFound)
+	}
+	attachment, err := s.repository.Attachment().Find(ctx, attachmentId, priceId, userId)
+	if err != nil {
+		return err
+	}
+	if attachment == nil {
+		return wrap(ErrNotFound)
+	}
+
+	// 添付ファイルの削除
+	rows, err := s.repository.Attachment().Delete(ctx, attachmentId, priceId, userId)
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return wrap(ErrNotFound)
+		}
+		return wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return err
+	}
+
+	// 本体の削除（コミット後）
+	s.deleteBlobs(ctx, []string{attachment.BlobKey})
+
+	return nil
+}
+
+// Blobの削除（失敗しても参照されないためログだけ出力）
+func (s *serviceImpl) deleteBlobs(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := s.repository.Blob().Delete(ctx, key); err != nil {
+			slog.ErrorContext(ctx, err.Error(), "key", key)
+		}
+	}
+}
+
+// ユーザと価格ごとに推測できないキー
+func attachmentBlobKey(userId, priceId uint) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", wrap(err)
+	}
+	return fmt.Sprintf("attachments/%d/%d/%s", userId, priceId, hex.EncodeToString(b)), nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+// 買い物かごの商品
+type BasketItem struct {
+	Product  string
+	Count    uint    // 個数（Unitの指定があれば使わない）
+	Quantity float64 // 必要な量（Unitの指定があれば単価から価格を求める）
+	Unit     string
+}
+
+// 買い物かごの商品の価格と合計（Pricesは買い物かごの商品の順）
+type BasketPrices struct {
+	Store  string // 店舗を分けて買う場合は空
+	Total  uint
+	Prices []entity.Price
+}
+
+// 買い物かごの比較の結果
+type BasketComparison struct {
+	SingleStore *BasketPrices // Missing以外の全ての商品がある店舗のうち合計が最安（該当なしはnil）
+	Split       *BasketPrices // 商品ごとに最安の店舗で買う場合
+	Missing     []string      // どの店舗にも価格がない商品
+}
+
+// 商品の店舗ごとの最新の価格（sinceより前の価格とnowの時点で有効期間外の価格は対象外、比較の順）
+//
+// excludeSuspiciousなら外れ値の疑いがある価格を除く
+func (s *serviceImpl) CompareProductPrices(ctx context.Context, userId uint, product string, since, now time.Time, excludeSuspicious bool) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	prices, err := s.repository.Price().FindLatestByProducts(ctx, userId, []string{product}, since, now, excludeSuspicious)
+	if err != nil {
+		return nil, err
+	}
+	sortByComparablePrice(prices)
+
+	return prices, nil
+}
+
+// 買い物かごの最安の店舗と店舗を分けた場合の最安（sinceより前の価格とnowの時点で有効期間外の価格は対象外）
+//
+// 同じ商品が複数あれば個数または量を合算する（同じ商品で個数と量、系統が異なる単位の量が混在する場合はErrUnitMismatch）。
+// 量の指定がある商品は単位の系統が同じ内容量の価格だけを比較する。excludeSuspiciousなら外れ値の疑いがある価格を除く
+func (s *serviceImpl) CompareBasket(ctx context.Context, userId uint, items []BasketItem, since, now time.Time, excludeSuspicious bool) (*BasketComparison, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// 同じ商品の個数または量の合算
+	products := make([]string, 0, len(items))
+	demands := make(map[string]*basketDemand, len(items))
+	for _, v := range items {
+		demand := demands[v.Product]
+		if demand == nil {
+			demand = &basketDemand{family: units[v.Unit].family}
+			demands[v.Product] = demand
+			products = append(products, v.Product)
+		}
+		def := units[v.Unit]
+		if def.family != demand.family {
+			return nil, wrap(ErrUnitMismatch)
+		}
+		demand.count += v.Count
+		demand.amount += v.Quantity * def.base
+	}
+
+	latest, err := s.repository.Price().FindLatestByProducts(ctx, userId, products, since, now, excludeSuspicious)
+	if err != nil {
+		return nil, err
+	}
+	sortByComparablePrice(latest)
+
+	// 店舗ごと商品ごとの価格と商品ごとの最安（比較できない価格は除く）
+	byStore := make(map[string]map[string]basketPrice)
+	cheapest := make(map[string]basketPrice, len(products))
+	for _, v := range latest {
+		cost, ok := demands[v.Product].cost(&v)
+		if !ok {
+			continue
+		}
+		if byStore[v.Store] == nil {
+			byStore[v.Store] = make(map[string]basketPrice, len(products))
+		}
+		price := basketPrice{v, cost}
+		byStore[v.Store][v.Product] = price
+		if current, found := cheapest[v.Product]; !found || cost < current.cost {
+			cheapest[v.Product] = price
+		}
+	}
+
+	result := &BasketComparison{}
+
+	// 商品ごとに最安の店舗
+	split := &BasketPrices{}
+	for _, product := range products {
+		price, found := cheapest[product]
+		if !found {
+			result.Missing = append(result.Missing, product)
+			continue
+		}
+		split.Total += price.cost
+		split.Prices = append(split.Prices, price.price)
+	}
+	if len(split.Prices) != 0 {
+		result.Split = split
+	}
+
+	// どの店舗にもない商品を除いて全ての商品がある店舗のうち合計が最安（同額なら店舗名の順）
+	stores := make([]string, 0, len(byStore))
+	for store := range byStore {
+		stores = append(stores, store)
+	}
+	sort.Strings(stores)
+	for _, store := range stores {
+		if len(byStore[store]) != len(cheapest) {
+			continue
+		}
+		single := &BasketPrices{Store: store}
+		for _, product := range products {
+			if _, found := cheapest[product]; !found {
+				continue
+			}
+			price := byStore[store][product]
+			single.Total += price.cost
+			single.Prices = append(single.Prices, price.price)
+		}
+		if result.SingleStore == nil || single.Total < result.SingleStore.Total {
+			result.SingleStore = single
+		}
+	}
+
+	return result, nil
+}
+
+// 買い物かごの商品ごとに必要な個数または量
+type basketDemand struct {
+	family string  // 量の単位の系統（空なら個数）
+	count  uint    // 個数
+	amount float64 // 系統の基準単位での量
+}
+
+// 比較に使う価格と買い物かごの商品の価格
+type basketPrice struct {
+	price entity.Price
+	cost  uint
+}
+
+// 価格で買う場合の買い物かごの商品の価格（量の指定があれば単価から求め、内容量がないか系統が異なる価格はok=false）
+func (d *basketDemand) cost(price *entity.Price) (cost uint, ok bool) {
+	if d.family == "" {
+		return price.Price * d.count, true
+	}
+	amount, family, ok := baseQuantity(price)
+	if !ok || family != d.family {
+		return 0, false
+	}
+	return uint(math.Round(float64(price.Price) / amount * d.amount)), true
+}
+
+// 単位の系統の比較の順（内容量がない価格は最後）
+var unitFamilyOrder = map[string]int{
+	unitFamilyMass:   0,
+	unitFamilyVolume: 1,
+	unitFamilyCount:  2,
+}
+
+// 比較の順に並べ替え（内容量がある価格は単位の系統ごとに単価の昇順、内容量がない価格はその後に価格の昇順）
+//
+// 系統が異なる価格どうしや内容量がない価格とは単価で比べない。同じ順位なら元の順（価格の昇順、日時の降順）
+func sortByComparablePrice(prices []entity.Price) {
+	sort.SliceStable(prices, func(i, j int) bool {
+		gi, vi := comparableKey(&prices[i])
+		gj, vj := comparableKey(&prices[j])
+		if gi != gj {
+			return gi < gj
+		}
+		return vi < vj
+	})
+}
+
+func comparableKey(price *entity.Price) (int, float64) {
+	if unitPrice, _, ok := UnitPrice(price); ok {
+		return unitFamilyOrder[units[price.Unit].family], unitPrice
+	}
+	return len(unitFamilyOrder), float64(price.Price)
+}
@@ -8,6 +8,27 @@ import (
 
 var (
 	ErrNotFound = errors.New("not found")
+	ErrAborted  = errors.New("aborted")
+
+	ErrNotRevertible = errors.New("not revertible")
+
+	ErrPreconditionFailed = errors.New("precondition failed")
+
+	ErrWebhookHost = errors.New("webhook host is not allowed")
+
+	ErrTooManyAttachments = errors.New("too many attachments")
+
+	ErrUnitMismatch = errors.New("unit mismatch")
+
+	ErrOutlier = errors.New("price is out of the expected range")
+
+	ErrTooManyListItems = errors.New("too many items")
+	ErrListOrder        = errors.New("order does not match items")
+
+	ErrInsufficientHistory = errors.New("insufficient price history")
+
+	ErrProductAliasSelf = errors.New("alias refers to itself")
+	ErrProductMergeSelf = errors.New("product cannot be merged into itself")
 )
 
 func wrap(err error) error {
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+const (
+	eventBufferSize     = 1000 // 再開用に保持するイベント数（全ユーザ合計）
+	eventSubscriberSize = 64   // 購読者ごとの未送信イベント数の上限
+)
+
+type contextKey string
+
+const contextKeyEvents = contextKey("EVENTS")
+
+// 価格の変更イベント
+type PriceEvent struct {
+	ID     uint64
+	UserID uint
+	Type   string // EventPriceCreated, EventPriceUpdated, EventPriceDeleted
+	Price  entity.Price
+}
+
+// 価格の変更イベントの購読
+type PriceEventSubscription struct {
+	Backlog []PriceEvent      // 再開位置より後のイベント
+	Reset   bool              // 再開位置のイベントがバッファから消えているため取りこぼしがある
+	Events  <-chan PriceEvent // 購読が遅れて溢れた場合はクローズする
+
+	broker     *eventBroker
+	subscriber *eventSubscriber
+}
+
+func (s *PriceEventSubscription) Close() {
+	s.broker.unsubscribe(s.subscriber)
+}
+
+type eventSubscriber struct {
+	userId uint
+	ch     chan PriceEvent
+}
+
+// プロセス内のイベントの配信（直近のイベントを上限付きのリングバッファで保持）
+type eventBroker struct {
+	mu          sync.Mutex
+	seq         uint64
+	buffer      []PriceEvent
+	next        int // bufferの次の書き込み位置
+	subscribers map[*eventSubscriber]struct{}
+}
+
+func newEventBroker(size int) *eventBroker {
+	return &eventBroker{
+		seq:         uint64(time.Now().UnixMicro()), // 再起動前のIDと重複しないよう時刻から採番
+		buffer:      make([]PriceEvent, 0, size),
+		subscribers: map[*eventSubscriber]struct{}{},
+	}
+}
+
+func (b *eventBroker) publish(events []PriceEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, event := range events {
+		b.seq++
+		event.ID = b.seq
+		if len(b.buffer) < cap(b.buffer) {
+			b.buffer = append(b.buffer, event)
+		} else {
+			b.buffer[b.next] = event
+		}
+		b.next = (b.next + 1) % cap(b.buffer)
+
+		for subscriber := range b.subscribers {
+			if subscriber.userId != event.UserID {
+				continue
+			}
+			select {
+			case subscriber.ch <- event:
+			default:
+				// 溢れた購読者は切断して再開してもらう
+				close(subscriber.ch)
+				delete(b.subscribers, subscriber)
+			}
+		}
+	}
+}
+
+func (b *eventBroker) subscribe(userId uint, lastEventId *uint64) *PriceEventSubscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subscription := &PriceEventSubscription{
+		broker:     b,
+		subscriber: &eventSubscriber{userId, make(chan PriceEvent, eventSubscriberSize)},
+	}
+	subscription.Events = subscription.subscriber.ch
+	b.subscribers[subscription.subscriber] = struct{}{}
+
+	if lastEventId == nil {
+		return subscription
+	}
+
+	// 古い順にバッファを走査
+	oldest := b.seq + 1
+	for i := range len(b.buffer) {
+		event := b.buffer[(b.next+i)%len(b.buffer)]
+		oldest = min(oldest, event.ID)
+		if event.ID > *lastEventId && event.UserID == userId {
+			subscription.Backlog = append(subscription.Backlog, event)
+		}
+	}
+	subscription.Reset = *lastEventId+1 < oldest || *lastEventId > b.seq
+
+	return subscription
+}
+
+func (b *eventBroker) unsubscribe(subscriber *eventSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[subscriber]; ok {
+		close(subscriber.ch)
+		delete(b.subscribers, subscriber)
+	}
+}
+
+// 価格の変更イベントの購読（lastEventIdの指定があればその後から再開）
+func (s *serviceImpl) SubscribePriceEvents(ctx context.Context, userId uint, lastEventId *uint64) *PriceEventSubscription {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.events.subscribe(userId, lastEventId)
+}
+
+// コミット後に配信するイベントをトランザクションに積む
+func queuePriceEvents(ctx context.Context, userId uint, eventType string, prices []entity.Price) {
+	queued, ok := ctx.Value(contextKeyEvents).(*[]PriceEvent)
+	if !ok {
+		return
+	}
+	for _, price := range prices {
+		*queued = append(*queued, PriceEvent{UserID: userId, Type: eventType, Price: price})
+	}
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// 価格の予測（曜日と月の季節性と指数平滑法）
+const (
+	forecastMinSamples       = 5    // 予測に必要な価格の件数
+	forecastMinSeasonSamples = 2    // 季節性の係数を求める曜日や月ごとの価格の件数
+	forecastAlpha            = 0.3  // 指数平滑法の平滑化係数
+	forecastZ                = 1.96 // 予測の範囲（95%）
+	forecastWaitRatio        = 0.03 // 今日より安くなる見込みがこの割合を超えれば待つ
+)
+
+// 買い時の判断
+const (
+	ForecastBuy  = "buy"
+	ForecastWait = "wait"
+)
+
+// 季節性の係数（平均に対する比）
+type SeasonalFactor struct {
+	Period  int // 曜日（0が日曜日）または月（1〜12）
+	Factor  float64
+	Samples int
+}
+
+// 日ごとの予測
+type ForecastPoint struct {
+	Date     time.Time // locationの日の初め
+	Expected uint
+	Min      uint
+	Max      uint
+}
+
+// 商品の価格の予測
+type PriceForecast struct {
+	Quantity       float64 // 予測の価格の内容量（最新の価格の内容量）
+	Unit           string
+	Samples        int
+	Level          uint             // 季節性を除いて平滑化した価格
+	Weekdays       []SeasonalFactor // 件数が足りる曜日のみ
+	Months         []SeasonalFactor // 件数が足りる月のみ
+	Points         []ForecastPoint  // 今日から日付の順
+	Recommendation string
+	BestDate       time.Time // 予測の期間で最も安い日（同じなら早い日）
+	Reasons        []string
+}
+
+// 商品の価格の履歴から今日からdays日間の価格を予測（sinceより前と外れ値の疑いがある価格は使わない）
+//
+// 履歴は最新の価格の内容量に換算し、比較できない価格は使わない。価格がなければnil、件数が足りなければErrInsufficientHistory
+func (s *serviceImpl) ForecastProductPrice(ctx context.Context, userId uint, product string, since, now time.Time, days int, location *time.Location) (*PriceForecast, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	history, err := s.repository.Price().FindHistoryByProduct(ctx, userId, product, since)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, nil
+	}
+
+	// 最新の価格の内容量に換算
+	basis := &history[len(history)-1]
+	var dates []time.Time
+	var values []float64
+	for i := range history {
+		if v, ok := priceAtQuantity(&history[i], basis); ok {
+			dates = append(dates, history[i].DateTime)
+			values = append(values, v)
+		}
+	}
+	if len(values) < forecastMinSamples {
+		return nil, wrap(ErrInsufficientHistory)
+	}
+
+	forecast := forecastPrices(dates, values, now, days, location)
+	forecast.Quantity, forecast.Unit = basis.Quantity, basis.Unit
+	return forecast, nil
+}
+
+// 日時の昇順の価格から予測
+func forecastPrices(dates []time.Time, values []float64, now time.Time, days int, location *time.Location) *PriceForecast {
+	n := len(values)
+	weekdayKeys := make([]int, n)
+	monthKeys := make([]int, n)
+	var mean float64
+	for i, v := range values {
+		t := dates[i].In(location)
+		weekdayKeys[i] = int(t.Weekday())
+		monthKeys[i] = int(t.Month())
+		mean += v
+	}
+	mean /= float64(n)
+
+	// 季節性の係数
+	weekdays, weekdayFactors := seasonalFactors(values, weekdayKeys, mean, 7)
+	months, monthFactors := seasonalFactors(values, monthKeys, mean, 13)
+
+	// 季節性を除いた価格の指数平滑法（一期先の誤差から予測の範囲を求める）
+	level := values[0] / (weekdayFactors[weekdayKeys[0]] * monthFactors[monthKeys[0]])
+	var sumSquares float64
+	for i := 1; i < n; i++ {
+		e := values[i]/(weekdayFactors[weekdayKeys[i]]*monthFactors[monthKeys[i]]) - level
+		sumSquares += e * e
+		level += forecastAlpha * e
+	}
+	sigma := math.Sqrt(sumSquares / float64(n-1))
+
+	// 日ごとの予測
+	y, m, d := now.In(location).Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, location)
+	points := make([]ForecastPoint, days)
+	best := 0
+	for i := range points {
+		date := today.AddDate(0, 0, i)
+		factor := weekdayFactors[date.Weekday()] * monthFactors[date.Month()]
+		expected := level * factor
+		spread := forecastZ * sigma * factor
+		points[i] = ForecastPoint{
+			Date:     date,
+			Expected: uint(math.Round(expected)),
+			Min:      uint(math.Max(0, math.Floor(expected-spread))),
+			Max:      uint(math.Ceil(expected + spread)),
+		}
+		if points[i].Expected < points[best].Expected {
+			best = i
+		}
+	}
+
+	forecast := &PriceForecast{
+		Samples:  n,
+		Level:    uint(math.Round(level)),
+		Weekdays: weekdays,
+		Months:   months,
+		Points:   points,
+		BestDate: points[best].Date,
+	}
+
+	// 買い時の判断
+	expectedToday := float64(points[0].Expected)
+	if drop := 1 - float64(points[best].Expected)/expectedToday; best > 0 && forecastWaitRatio < drop {
+		forecast.Recommendation = ForecastWait
+		forecast.Reasons = append(forecast.Reasons, fmt.Sprintf("expected to drop to %d on %s (%s), %.0f%% below today's %d",
+			points[best].Expected, points[best].Date.Format(time.DateOnly), points[best].Date.Weekday(), drop*100, points[0].Expected))
+	} else {
+		forecast.Recommendation = ForecastBuy
+		forecast.Reasons = append(forecast.Reasons, fmt.Sprintf("today's expected price %d is within %.0f%% of the lowest expected in the next %d days",
+			points[0].Expected, forecastWaitRatio*100, days))
+	}
+	if lowest := lowestFactor(weekdays); lowest != nil {
+		forecast.Reasons = append(forecast.Reasons, fmt.Sprintf("prices tend to be lowest on %s (%.0f%% below average)",
+			time.Weekday(lowest.Period), (1-lowest.Factor)*100))
+	}
+	if lowest := lowestFactor(months); lowest != nil {
+		forecast.Reasons = append(forecast.Reasons, fmt.Sprintf("prices tend to be lowest in %s (%.0f%% below average)",
+			time.Month(lowest.Period), (1-lowest.Factor)*100))
+	}
+
+	return forecast
+}
+
+// 期間（曜日や月）ごとの平均の全体の平均に対する比（件数が足りない期間の係数は1）
+func seasonalFactors(values []float64, keys []int, mean float64, size int) ([]SeasonalFactor, []float64) {
+	sums := make([]float64, size)
+	counts := make([]int, size)
+	for i, v := range values {
+		sums[keys[i]] += v
+		counts[keys[i]]++
+	}
+
+	var seasonal []SeasonalFactor
+	factors := make([]float64, size)
+	for i := range factors {
+		factors[i] = 1
+		if counts[i] < forecastMinSeasonSamples || mean == 0 {
+			continue
+		}
+		factors[i] = sums[i] / float64(counts[i]) / mean
+		seasonal = append(seasonal, SeasonalFactor{Period: i, Factor: factors[i], Samples: counts[i]})
+	}
+
+	return seasonal, factors
+}
+
+// 係数が最も小さい期間（平均よりforecastWaitRatioを超えて安い期間がなければnil）
+func lowestFactor(factors []SeasonalFactor) *SeasonalFactor {
+	var lowest *SeasonalFactor
+	for i := range factors {
+		if factors[i].Factor < 1-forecastWaitRatio && (lowest == nil || factors[i].Factor < lowest.Factor) {
+			lowest = &factors[i]
+		}
+	}
+	return lowest
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+	"github.com/ystkg/rest-example/repository"
+)
+
+// 冪等キーの予約（予約できなければ登録済みのキーを返す）
+//
+// expiredBeforeより前に登録された同じキーは期限切れとして削除してから予約する
+func (s *serviceImpl) ReserveIdempotencyKey(ctx context.Context, userId uint, key, fingerprint string, expiredBefore time.Time) (*entity.IdempotencyKey, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	txCtx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(txCtx)
+
+	// 期限切れのキーの削除
+	if _, err = s.repository.IdempotencyKey().DeleteCreatedBefore(txCtx, userId, key, expiredBefore); err != nil {
+		return nil, err
+	}
+
+	// キーの予約（同時に同じキーで予約した場合は一意制約で片方が失敗）
+	err = s.repository.IdempotencyKey().Create(txCtx, &entity.IdempotencyKey{
+		UserID:      userId,
+		Key:         key,
+		Fingerprint: fingerprint,
+	})
+	if err != nil {
+		if !errors.Is(err, repository.ErrDuplicated) {
+			return nil, err
+		}
+		s.rollback(txCtx)
+
+		// 登録済みのキーの取得（トランザクションの外で取得）
+		existing, err := s.repository.IdempotencyKey().Find(ctx, userId, key)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			return nil, wrap(ErrAborted) // 取得までの間に削除された
+		}
+		return existing, nil
+	}
+
+	// コミット
+	if err = s.commit(txCtx); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// 冪等キーにレスポンスを保存
+func (s *serviceImpl) CompleteIdempotencyKey(ctx context.Context, userId uint, key string, status int, header string, body []byte) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.rollback(ctx)
+
+	// レスポンスの保存
+	if _, err = s.repository.IdempotencyKey().Complete(ctx, userId, key, status, header, body); err != nil {
+		return err
+	}
+
+	// コミット
+	return s.commit(ctx)
+}
+
+// 冪等キーの解放（同じキーで再び実行できるようにする）
+func (s *serviceImpl) ReleaseIdempotencyKey(ctx context.Context, userId uint, key string) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.rollback(ctx)
+
+	// キーの削除
+	if _, err = s.repository.IdempotencyKey().Delete(ctx, userId, key); err != nil {
+		return err
+	}
+
+	// コミット
+	return s.commit(ctx)
+}
+
+// 保持期間を過ぎた冪等キーの削除
+func (s *serviceImpl) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer s.rollback(ctx)
+
+	// キーの削除
+	rows, err := s.repository.IdempotencyKey().PurgeCreatedBefore(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,341 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+const maxIndexPeriods = 120 // 物価指数を求める月数の上限
+
+// 月ごとの物価指数
+type IndexPeriod struct {
+	Month    time.Time // locationの月の初め
+	Index    float64   // 基準月を100とした連鎖指数
+	Coverage float64   // 当月に価格がある商品の重みの割合
+	Observed int       // 当月に価格がある商品の数
+	Carried  []string  // 前月以前の価格を繰り越した商品
+	Missing  []string  // まだ価格がない商品
+}
+
+// 買い物かごの物価指数
+type PriceIndex struct {
+	Base    time.Time
+	Periods []IndexPeriod // 基準月から月の順
+}
+
+// 物価指数の買い物かごの登録
+func (s *serviceImpl) CreateIndexBasket(ctx context.Context, basket *entity.IndexBasket) (*entity.IndexBasket, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 買い物かごの登録
+	items := basket.Items
+	if err = s.repository.IndexBasket().Create(ctx, basket); err != nil {
+		return nil, err
+	}
+
+	// 商品の登録
+	if err = s.createIndexBasketItems(ctx, basket, items); err != nil {
+		return nil, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return basket, nil
+}
+
+// 物価指数の買い物かごの一覧
+func (s *serviceImpl) FindIndexBaskets(ctx context.Context, userId uint) ([]entity.IndexBasket, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	baskets, err := s.repository.IndexBasket().FindByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	basketIds := make([]uint, len(baskets))
+	for i, v := range baskets {
+		basketIds[i] = v.ID
+	}
+	items, err := s.repository.IndexBasketItem().FindByBasketIds(ctx, basketIds)
+	if err != nil {
+		return nil, err
+	}
+	byBasket := make(map[uint][]entity.IndexBasketItem, len(baskets))
+	for _, v := range items {
+		byBasket[v.BasketID] = append(byBasket[v.BasketID], v)
+	}
+	for i := range baskets {
+		baskets[i].Items = byBasket[baskets[i].ID]
+	}
+
+	return baskets, nil
+}
+
+// 物価指数の買い物かごの取得
+func (s *serviceImpl) FindIndexBasket(ctx context.Context, basketId, userId uint) (*entity.IndexBasket, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	basket, err := s.repository.IndexBasket().Find(ctx, basketId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if basket == nil {
+		return nil, nil
+	}
+	if basket.Items, err = s.repository.IndexBasketItem().FindByBasketIds(ctx, []uint{basket.ID}); err != nil {
+		return nil, err
+	}
+
+	return basket, nil
+}
+
+// 物価指数の買い物かごの更新（商品はItemsで置き換え）
+func (s *serviceImpl) UpdateIndexBasket(ctx context.Context, basket *entity.IndexBasket) (*entity.IndexBasket, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 買い物かごの更新
+	rows, err := s.repository.IndexBasket().Update(ctx, basket)
+	if err != nil {
+		return nil, err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return nil, wrap(ErrNotFound)
+		}
+		return nil, wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 商品の置き換え
+	if err = s.repository.IndexBasketItem().DeleteByBasketId(ctx, basket.ID); err != nil {
+		return nil, err
+	}
+	if err = s.createIndexBasketItems(ctx, basket, basket.Items); err != nil {
+		return nil, err
+	}
+
+	// 更新後の買い物かごの取得
+	updated, err := s.FindIndexBasket(ctx, basket.ID, basket.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// 物価指数の買い物かごの削除（商品も削除）
+func (s *serviceImpl) DeleteIndexBasket(ctx context.Context, basketId, userId uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.rollback(ctx)
+
+	// 買い物かごの削除
+	rows, err := s.repository.IndexBasket().Delete(ctx, basketId, userId)
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return wrap(ErrNotFound)
+		}
+		return wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 商品の削除
+	if err = s.repository.IndexBasketItem().DeleteByBasketId(ctx, basketId); err != nil {
+		return err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// 買い物かごの月ごとの物価指数（連鎖方式のラスパイレス指数）
+//
+// baseは基準月の初め（nilなら最初に価格がある月、ただし最大でmaxIndexPeriods月）、toは最後の月の翌月の初め。
+// 商品の月の価格は月の平均で、価格がない月は前月以前の価格を繰り越す。
+// 内容量の異なる価格を比べないよう、商品の価格は最新の価格の内容量に換算した価格を使い、比較できない価格は使わない。
+// 重みを基準月（基準月に価格がなければ最初に価格がある月）の価格で数量に換算し、
+// 前月と当月の両方に価格がある商品で前月比を求めて連鎖する。
+// 買い物かごがなければnil
+func (s *serviceImpl) PriceIndex(ctx context.Context, basketId, userId uint, base *time.Time, to time.Time, location *time.Location) (*PriceIndex, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	basket, err := s.FindIndexBasket(ctx, basketId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if basket == nil {
+		return nil, nil
+	}
+	products := make([]string, len(basket.Items))
+	for i, v := range basket.Items {
+		products[i] = v.Product
+	}
+	prices, err := s.repository.Price().FindHistoryByProducts(ctx, userId, products, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// 商品ごとの換算の基準（日時の昇順なので最後が最新）
+	bases := make(map[string]*entity.Price, len(products))
+	for i := range prices {
+		bases[prices[i].Product] = &prices[i]
+	}
+
+	// 商品ごとの月の平均
+	type monthly struct {
+		sum   float64
+		count int
+	}
+	observations := make(map[string]map[int]*monthly, len(products))
+	for _, v := range products {
+		observations[v] = make(map[int]*monthly)
+	}
+	end := monthIndex(to, location) - 1
+	first := end + 1
+	for _, v := range prices {
+		price, ok := priceAtQuantity(&v, bases[v.Product])
+		if !ok {
+			continue
+		}
+		month := monthIndex(v.DateTime, location)
+		first = min(first, month)
+		m := observations[v.Product][month]
+		if m == nil {
+			m = &monthly{}
+			observations[v.Product][month] = m
+		}
+		m.sum += price
+		m.count++
+	}
+	var baseMonth int
+	if base != nil {
+		baseMonth = monthIndex(*base, location)
+	} else {
+		baseMonth = max(min(first, end), end-maxIndexPeriods+1)
+	}
+	index := &PriceIndex{Base: monthStart(baseMonth, location)}
+
+	var totalWeight float64
+	for _, v := range basket.Items {
+		totalWeight += v.Weight
+	}
+
+	// 連鎖指数
+	current := make([]float64, len(basket.Items))  // 当月の価格（0は価格なし）
+	previous := make([]float64, len(basket.Items)) // 前月の価格
+	quantities := make([]float64, len(basket.Items))
+	level := 100.0
+	for month := min(first, baseMonth); month <= end; month++ {
+		period := IndexPeriod{Month: monthStart(month, location)}
+		var observedWeight float64
+		for i, v := range basket.Items {
+			if m := observations[v.Product][month]; m != nil {
+				current[i] = m.sum / float64(m.count)
+				period.Observed++
+				observedWeight += v.Weight
+			} else if previous[i] != 0 {
+				current[i] = previous[i]
+				period.Carried = append(period.Carried, v.Product)
+			} else {
+				current[i] = 0
+				period.Missing = append(period.Missing, v.Product)
+			}
+		}
+
+		if baseMonth <= month {
+			var numerator, denominator float64
+			for i, v := range basket.Items {
+				if current[i] == 0 {
+					continue
+				}
+				if quantities[i] == 0 {
+					// 基準月以降で最初に価格がある月の価格で数量に換算（当月は比較に使わない）
+					quantities[i] = v.Weight / current[i]
+					continue
+				}
+				numerator += quantities[i] * current[i]
+				denominator += quantities[i] * previous[i]
+			}
+			if baseMonth < month && denominator != 0 {
+				level *= numerator / denominator
+			}
+			period.Index = level
+			if totalWeight != 0 {
+				period.Coverage = observedWeight / totalWeight
+			}
+			index.Periods = append(index.Periods, period)
+		}
+
+		copy(previous, current)
+	}
+
+	return index, nil
+}
+
+func (s *serviceImpl) createIndexBasketItems(ctx context.Context, basket *entity.IndexBasket, items []entity.IndexBasketItem) error {
+	for i := range items {
+		items[i].BasketID = basket.ID
+		items[i].UserID = basket.UserID
+	}
+	if err := s.repository.IndexBasketItem().Create(ctx, items); err != nil {
+		return err
+	}
+	basket.Items = items
+	return nil
+}
+
+// 西暦0年1月からの月数
+func monthIndex(t time.Time, location *time.Location) int {
+	t = t.In(location)
+	return t.Year()*12 + int(t.Month()) - 1
+}
+
+func monthStart(index int, location *time.Location) time.Time {
+	return time.Date(index/12, time.Month(index%12+1), 1, 0, 0, 0, 0, location)
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/ystkg/rest-example/entity"
+	"github.com/ystkg/rest-example/repository"
+	"golang.org/x/text/unicode/norm"
+)
+
+// 名前の正規化（NFKCで全角英数字を半角、半角カナを全角にし、空白の連続を1つの半角空白にして前後の空白を除く）
+func NormalizeText(text string) string {
+	return strings.Join(strings.Fields(norm.NFKC.String(text)), " ")
+}
+
+// 登録済みの価格の店舗と商品名の正規化（論理削除済みを含む、chunkSize件ごとにコミット）
+//
+// 正規化した商品名が別名なら正式な商品名にする。正規化で空になる名前は変更しない。変更した件数を返す
+func (s *serviceImpl) NormalizePriceNames(ctx context.Context, chunkSize int) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	var normalized int64
+	var lastId uint
+	for {
+		prices, err := s.repository.Price().FindAfterId(ctx, lastId, chunkSize)
+		if err != nil {
+			return normalized, err
+		}
+		if len(prices) == 0 {
+			return normalized, nil
+		}
+		lastId = prices[len(prices)-1].ID
+
+		rows, err := s.normalizePriceChunk(ctx, prices)
+		if err != nil {
+			return normalized, err
+		}
+		normalized += rows
+	}
+}
+
+func (s *serviceImpl) normalizePriceChunk(ctx context.Context, prices []entity.Price) (int64, error) {
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer s.rollback(ctx)
+
+	var normalized int64
+	for i := range prices {
+		before := &prices[i]
+		store, product := NormalizeText(before.Store), NormalizeText(before.Product)
+		if store == "" {
+			store = before.Store
+		}
+		if product == "" {
+			product = before.Product
+		} else if product, err = s.resolveProduct(ctx, before.UserID, product); err != nil {
+			return 0, err
+		}
+		if store == before.Store && product == before.Product {
+			continue
+		}
+
+		// 取得後に更新された価格は変更しない（次回の実行で正規化する）
+		after, rows, err := s.repository.Price().Rename(ctx, before.ID, []uint{before.Version}, store, product)
+		if err != nil {
+			return 0, err
+		}
+		if rows == 0 {
+			continue
+		}
+
+		// 変更履歴の記録とWebhookの配信キューへの登録
+		if err = s.recordPriceRename(ctx, before, after); err != nil {
+			return 0, err
+		}
+		normalized += rows
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return normalized, nil
+}
+
+// 価格の店舗や商品名と照合する名前の列（価格の商品名の置き換えに使う別名を先に正規化）
+var relatedNameColumns = []struct {
+	column  repository.NameColumn
+	resolve bool // 正規化した商品名が別名なら正式な商品名にする
+}{
+	{repository.NameColumnProductAliasAlias, false},
+	{repository.NameColumnProductAliasProduct, true},
+	{repository.NameColumnStoreName, false},
+	{repository.NameColumnAlertRuleStore, false},
+	{repository.NameColumnAlertRuleProduct, true},
+	{repository.NameColumnShoppingListProduct, true},
+	{repository.NameColumnPurchaseStore, false},
+	{repository.NameColumnPurchaseProduct, true},
+	{repository.NameColumnIndexBasketProduct, true},
+}
+
+// 価格の店舗や商品名と照合する登録済みの名前の正規化（店舗、別名、通知ルール、買い物リスト、購入、指数の買い物かご）
+//
+// 価格と同じく正規化した商品名が別名なら正式な商品名にし、正規化で空になる名前は変更しない。
+// 名前ごとにコミットし、店舗や別名のように一意の名前が正規化で重複する場合は変更しない。変更した件数を返す
+func (s *serviceImpl) NormalizeRelatedNames(ctx context.Context) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	var normalized int64
+	for _, v := range relatedNameColumns {
+		names, err := s.repository.Name().FindDistinct(ctx, v.column)
+		if err != nil {
+			return normalized, err
+		}
+		for _, name := range names {
+			rows, err := s.normalizeName(ctx, v.column, v.resolve, &name)
+			if err != nil {
+				return normalized, err
+			}
+			normalized += rows
+		}
+	}
+
+	return normalized, nil
+}
+
+func (s *serviceImpl) normalizeName(ctx context.Context, column repository.NameColumn, resolve bool, name *entity.UserName) (int64, error) {
+	normalized := NormalizeText(name.Name)
+	if normalized == "" {
+		return 0, nil
+	}
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer s.rollback(ctx)
+
+	if resolve {
+		if normalized, err = s.resolveProduct(ctx, name.UserID, normalized); err != nil {
+			return 0, err
+		}
+	}
+	if normalized == name.Name {
+		return 0, nil
+	}
+
+	// 名前の変更
+	rows, err := s.repository.Name().Rename(ctx, column, name.UserID, name.Name, normalized)
+	if err != nil {
+		if errors.Is(err, repository.ErrDuplicated) {
+			slog.WarnContext(ctx, "duplicated", "table", column.Table, "column", column.Column, "userId", name.UserID, "name", name.Name)
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
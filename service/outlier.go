@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+// 外れ値の判定（中央値絶対偏差）
+const (
+	outlierHistory    = 100    // 比較に使う最近の価格の件数
+	outlierMinSamples = 5      // 判定に必要な価格の件数
+	outlierThreshold  = 3.5    // 修正zスコアの閾値
+	madScale          = 1.4826 // 正規分布の標準偏差に換算する係数
+	minSpreadRatio    = 0.05   // 価格が揃っていてMADが小さすぎる場合の下限（中央値に対する割合）
+)
+
+// 価格が商品の過去の価格から想定される範囲外
+type OutlierError struct {
+	Min uint
+	Max uint
+}
+
+func (e *OutlierError) Error() string {
+	return fmt.Sprintf("%s: expected %d to %d", ErrOutlier, e.Min, e.Max)
+}
+
+func (e *OutlierError) Unwrap() error {
+	return ErrOutlier
+}
+
+// 外れ値の疑いの判定と記録（rejectなら外れ値はエラー）
+//
+// 登録や更新の後に判定するので、同じ価格の過去の値は含めない
+func (s *serviceImpl) flagOutlier(ctx context.Context, price *entity.Price, reject bool) error {
+	history, err := s.repository.Price().FindRecentPricesByProduct(ctx, price.UserID, price.Product, price.ID, outlierHistory)
+	if err != nil {
+		return err
+	}
+
+	suspicious := false
+	if lower, upper, ok := expectedRange(price, history); ok && (price.Price < lower || upper < price.Price) {
+		if reject {
+			return wrap(&OutlierError{Min: lower, Max: upper})
+		}
+		suspicious = true
+	}
+
+	if suspicious != price.Suspicious {
+		if err = s.repository.Price().SetSuspicious(ctx, price.ID, suspicious); err != nil {
+			return err
+		}
+		price.Suspicious = suspicious
+	}
+
+	return nil
+}
+
+// 中央値から修正zスコアが閾値以内の価格の範囲（件数が足りなければok=false）
+//
+// 過去の価格は価格の内容量に換算し、比較できない過去の価格は使わない
+func expectedRange(price *entity.Price, history []entity.Price) (lower, upper uint, ok bool) {
+	values := make([]float64, 0, len(history))
+	for i := range history {
+		if v, ok := priceAtQuantity(&history[i], price); ok {
+			values = append(values, v)
+		}
+	}
+	if len(values) < outlierMinSamples {
+		return 0, 0, false
+	}
+
+	m := median(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - m)
+	}
+	spread := max(median(deviations)*madScale, m*minSpreadRatio)
+
+	return uint(math.Max(0, math.Ceil(m-outlierThreshold*spread))), uint(math.Floor(m + outlierThreshold*spread)), true
+}
+
+func median(values []float64) float64 {
+	sorted := slices.Sorted(slices.Values(values))
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
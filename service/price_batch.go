@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+const (
+	PriceOpCreate = "create"
+	PriceOpUpdate = "update"
+	PriceOpDelete = "delete"
+)
+
+// 一括処理の個々の操作
+type PriceOperation struct {
+	Op       string
+	PriceId  uint // update, delete
+	DateTime time.Time
+	Store    string
+	Product  string
+	GTIN     string
+	Price    uint
+	Quantity float64
+	Unit     string
+	Tags     []string // create, update
+
+	PriceType string // 空はregular
+	ValidFrom *time.Time
+	ValidTo   *time.Time
+
+	RejectOutlier bool // create, update
+}
+
+// 一括処理の個々の結果
+type PriceOperationResult struct {
+	Price *entity.Price // deleteの場合はnil
+	Err   error
+}
+
+// 価格の一括処理
+func (s *serviceImpl) BatchPrices(ctx context.Context, userId uint, ops []PriceOperation, atomic bool) ([]PriceOperationResult, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	if atomic {
+		return s.batchPricesAtomic(ctx, userId, ops)
+	}
+
+	// 操作ごとにトランザクションを分ける
+	results := make([]PriceOperationResult, len(ops))
+	for i, op := range ops {
+		results[i].Price, results[i].Err = s.execPriceOperationTx(ctx, userId, &op)
+	}
+
+	// アラートの条件の評価
+	s.evaluateAlertsAsync(ctx, resultPrices(results)...)
+
+	return results, nil
+}
+
+// 全ての操作を1つのトランザクションで実行
+func (s *serviceImpl) batchPricesAtomic(ctx context.Context, userId uint, ops []PriceOperation) ([]PriceOperationResult, error) {
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	results := make([]PriceOperationResult, len(ops))
+	for i, op := range ops {
+		price, err := s.execPriceOperation(ctx, userId, &op)
+		if err != nil {
+			if !isOperationError(err) {
+				return nil, err
+			}
+
+			// 1件でも失敗すれば全体を取り消す
+			s.rollback(ctx)
+			for j := range results {
+				results[j] = PriceOperationResult{Err: wrap(ErrAborted)}
+			}
+			results[i].Err = err
+			return results, nil
+		}
+		results[i].Price = price
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	// アラートの条件の評価
+	s.evaluateAlertsAsync(ctx, resultPrices(results)...)
+
+	return results, nil
+}
+
+// 登録と更新に成功した操作の価格
+func resultPrices(results []PriceOperationResult) []*entity.Price {
+	var prices []*entity.Price
+	for _, v := range results {
+		if v.Err == nil && v.Price != nil {
+			prices = append(prices, v.Price)
+		}
+	}
+	return prices
+}
+
+// 操作ごとの結果として返すエラー（それ以外は一括処理全体のエラー）
+func isOperationError(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrUnitMismatch) || errors.Is(err, ErrOutlier)
+}
+
+func (s *serviceImpl) execPriceOperationTx(ctx context.Context, userId uint, op *PriceOperation) (*entity.Price, error) {
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	price, err := s.execPriceOperation(ctx, userId, op)
+	if err != nil {
+		return nil, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return price, nil
+}
+
+func (s *serviceImpl) execPriceOperation(ctx context.Context, userId uint, op *PriceOperation) (*entity.Price, error) {
+	if op.Op == PriceOpCreate || op.Op == PriceOpUpdate {
+		product, err := s.resolveProduct(ctx, userId, op.Product)
+		if err != nil {
+			return nil, err
+		}
+		op.Product = product
+	}
+
+	if op.Op == PriceOpCreate {
+		if err := s.checkUnitFamily(ctx, userId, op.Product, op.Unit, 0); err != nil {
+			return nil, err
+		}
+		price, err := s.repository.Price().Create(ctx, userId, op.DateTime, op.Store, op.Product, op.GTIN, op.Price, op.Quantity, op.Unit, priceTypeOrRegular(op.PriceType), op.ValidFrom, op.ValidTo)
+		if err != nil {
+			return nil, err
+		}
+		if err = s.flagOutlier(ctx, price, op.RejectOutlier); err != nil {
+			return nil, err
+		}
+		if len(op.Tags) != 0 {
+			if err = s.setPriceTags(ctx, userId, price.ID, op.Tags); err != nil {
+				return nil, err
+			}
+		}
+		if err = s.recordPriceChange(ctx, userId, RevisionCreate, nil, price); err != nil {
+			return nil, err
+		}
+		return price, nil
+	}
+
+	// 変更前の価格の取得
+	before, err := s.repository.Price().FindForUpdate(ctx, op.PriceId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	var price *entity.Price
+	var rows int64
+	var revision string
+	switch op.Op {
+	case PriceOpUpdate:
+		if err = s.checkUnitFamily(ctx, userId, op.Product, op.Unit, op.PriceId); err != nil {
+			return nil, err
+		}
+		price, rows, err = s.repository.Price().Update(ctx, op.PriceId, userId, []uint{before.Version}, op.DateTime, op.Store, op.Product, op.GTIN, op.Price, op.Quantity, op.Unit, priceTypeOrRegular(op.PriceType), op.ValidFrom, op.ValidTo)
+		revision = RevisionUpdate
+	case PriceOpDelete:
+		rows, err = s.repository.Price().Delete(ctx, op.PriceId, userId, []uint{before.Version})
+		revision = RevisionDelete
+	default:
+		return nil, wrap(fmt.Errorf("unsupported:%s", op.Op))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rows != 1 {
+		if rows == 0 {
+			return nil, wrap(ErrNotFound)
+		}
+		return nil, wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 外れ値の判定とタグの置き換え
+	if op.Op == PriceOpUpdate {
+		price.Suspicious = before.Suspicious
+		if err = s.flagOutlier(ctx, price, op.RejectOutlier); err != nil {
+			return nil, err
+		}
+		if err = s.setPriceTags(ctx, userId, op.PriceId, op.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	// 変更履歴の記録とWebhookの配信キューへの登録
+	if err = s.recordPriceChange(ctx, userId, revision, before, price); err != nil {
+		return nil, err
+	}
+
+	return price, nil
+}
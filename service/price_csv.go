@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+// 価格のインポート
+//
+// tagsはpricesと同じ順の各価格のタグ。チャンク単位でコミットし、失敗した場合はそれまでに登録した件数を返す
+func (s *serviceImpl) ImportPrices(ctx context.Context, userId uint, prices []entity.Price, tags [][]string, chunkSize int) (int, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// チャンク単位でコミット
+	imported := 0
+	for start := 0; start < len(prices); start += chunkSize {
+		end := min(start+chunkSize, len(prices))
+		chunk := prices[start:end]
+		for i := range chunk {
+			chunk[i].UserID = userId
+		}
+		if err := s.importPriceChunk(ctx, chunk, tags[start:end]); err != nil {
+			return imported, err
+		}
+		imported += len(chunk)
+	}
+
+	return imported, nil
+}
+
+func (s *serviceImpl) importPriceChunk(ctx context.Context, chunk []entity.Price, tags [][]string) error {
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.rollback(ctx)
+
+	// 別名の商品名の置き換え
+	if err = s.resolvePriceProducts(ctx, chunk[0].UserID, chunk); err != nil {
+		return err
+	}
+
+	// 価格の登録
+	if err = s.repository.Price().CreateAll(ctx, chunk); err != nil {
+		return err
+	}
+
+	// 単位の系統の確認（同じチャンクの価格も含めて比較するため登録後に確認）
+	for i := range chunk {
+		if err = s.checkUnitFamily(ctx, chunk[i].UserID, chunk[i].Product, chunk[i].Unit, chunk[i].ID); err != nil {
+			return err
+		}
+	}
+
+	// 外れ値の判定（同じチャンクの価格も含めて比較）
+	for i := range chunk {
+		if err = s.flagOutlier(ctx, &chunk[i], false); err != nil {
+			return err
+		}
+	}
+
+	// タグの付与
+	for i := range chunk {
+		if len(tags[i]) != 0 {
+			if err = s.setPriceTags(ctx, chunk[i].UserID, chunk[i].ID, tags[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 変更履歴の記録
+	revisions := make([]entity.PriceRevision, len(chunk))
+	for i := range chunk {
+		revisions[i] = *newRevision(chunk[i].UserID, RevisionCreate, nil, &chunk[i])
+		revisions[i].After.Tags = uniqueTags(tags[i])
+	}
+	if err = s.repository.PriceRevision().CreateAll(ctx, revisions); err != nil {
+		return err
+	}
+
+	// Webhookの配信キューとコミット後に配信するイベントへの登録
+	queuePriceEvents(ctx, chunk[0].UserID, EventPriceCreated, chunk)
+	if err = s.enqueueWebhooks(ctx, chunk[0].UserID, EventPriceCreated, chunk); err != nil {
+		return err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return err
+	}
+
+	// アラートの条件の評価
+	prices := make([]*entity.Price, len(chunk))
+	for i := range chunk {
+		prices[i] = &chunk[i]
+	}
+	s.evaluateAlertsAsync(ctx, prices...)
+
+	return nil
+}
+
+// 価格のエクスポート
+func (s *serviceImpl) ExportPrices(ctx context.Context, userId uint, chunkSize int, fn func([]entity.Price) error) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.repository.Price().FindByUserIdInBatches(ctx, userId, chunkSize, fn)
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+// atの時点で店舗と商品ごとに有効な価格（店舗、商品の順）
+//
+// 有効期間が重なる場合は、終わりのある価格（セールなどの期間限定）、始まりが遅い価格、日時が遅い価格、IDが大きい価格の順に優先する。
+// priceTypesの指定があれば該当する種類の価格のみ
+func (s *serviceImpl) FindEffectivePrices(ctx context.Context, userId uint, at time.Time, priceTypes []string) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	candidates, err := s.repository.Price().FindEffective(ctx, userId, at, priceTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	// 店舗と商品ごとに優先する順の先頭
+	var prices []entity.Price
+	for i, v := range candidates {
+		if i == 0 || v.Store != candidates[i-1].Store || v.Product != candidates[i-1].Product {
+			prices = append(prices, v)
+		}
+	}
+
+	return prices, nil
+}
+
+// 種類の指定がなければ通常価格
+func priceTypeOrRegular(priceType string) string {
+	if priceType == "" {
+		return entity.PriceTypeRegular
+	}
+	return priceType
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+// 変更履歴の操作種別
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+	RevisionRevert  = "revert"
+	RevisionPurge   = "purge"
+)
+
+// 価格の変更履歴の一覧
+func (s *serviceImpl) FindPriceRevisions(ctx context.Context, priceId, userId uint) ([]entity.PriceRevision, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// 価格の所有者の確認
+	price, err := s.repository.Price().Find(ctx, priceId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if price == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	return s.repository.PriceRevision().FindByPriceId(ctx, priceId)
+}
+
+// 価格を指定した変更履歴の時点に戻す（ifMatchはnilならバージョンを確認しない）
+func (s *serviceImpl) RevertPrice(ctx context.Context, priceId, revisionId, userId uint, ifMatch []uint) (*entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 更新前の価格の取得
+	before, err := s.repository.Price().FindForUpdate(ctx, priceId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// 変更履歴の取得
+	revision, err := s.repository.PriceRevision().Find(ctx, revisionId, priceId)
+	if err != nil {
+		return nil, err
+	}
+	if revision == nil {
+		return nil, wrap(ErrNotFound)
+	}
+	values := revision.After
+	if values == nil {
+		return nil, wrap(ErrNotRevertible) // 削除の時点には戻せない
+	}
+
+	// 別名の商品名の置き換え（変更履歴の記録後に統合した商品など）
+	product, err := s.resolveProduct(ctx, userId, values.Product)
+	if err != nil {
+		return nil, err
+	}
+
+	// 単位の系統の確認（変更履歴の記録後に登録した価格と異なる場合がある）
+	if err = s.checkUnitFamily(ctx, userId, product, values.Unit, priceId); err != nil {
+		return nil, err
+	}
+
+	// 価格の更新
+	priceEntity, rows, err := s.repository.Price().Update(
+		ctx,
+		priceId,
+		userId,
+		matchVersions(before.Version, ifMatch),
+		values.DateTime,
+		values.Store,
+		product,
+		values.GTIN,
+		values.Price,
+		values.Quantity,
+		values.Unit,
+		priceTypeOrRegular(values.PriceType),
+		values.ValidFrom,
+		values.ValidTo,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if rows != 1 {
+		if rows == 0 {
+			return nil, s.notUpdatedError(ctx, priceId, userId)
+		}
+		s.rollback(ctx)
+		return nil, wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// タグの置き換え（タグの記録前の変更履歴はタグを変更しない）
+	if values.Tags != nil {
+		if err = s.setPriceTags(ctx, userId, priceId, values.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	// 外れ値の判定（戻した値は拒否しない）
+	priceEntity.Suspicious = before.Suspicious
+	if err = s.flagOutlier(ctx, priceEntity, false); err != nil {
+		return nil, err
+	}
+
+	// 変更履歴の記録とWebhookの配信キューへの登録
+	if err = s.recordPriceChange(ctx, userId, RevisionRevert, before, priceEntity); err != nil {
+		return nil, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	// アラートの条件の評価
+	s.evaluateAlertsAsync(ctx, priceEntity)
+
+	return priceEntity, nil
+}
+
+// 価格の変更を変更履歴に記録して、購読しているWebhookの配信キューとコミット後に配信するイベントに登録
+func (s *serviceImpl) recordPriceChange(ctx context.Context, userId uint, operation string, before, after *entity.Price) error {
+	revision, err := s.newTaggedRevision(ctx, userId, operation, before, after)
+	if err != nil {
+		return err
+	}
+	if err = s.repository.PriceRevision().Create(ctx, revision); err != nil {
+		return err
+	}
+
+	var eventType string
+	var price *entity.Price
+	switch operation {
+	case RevisionCreate, RevisionRestore:
+		eventType, price = EventPriceCreated, after
+	case RevisionUpdate, RevisionRevert:
+		eventType, price = EventPriceUpdated, after
+	case RevisionDelete:
+		eventType, price = EventPriceDeleted, before
+	default:
+		return nil
+	}
+	prices := []entity.Price{*price}
+	queuePriceEvents(ctx, userId, eventType, prices)
+	return s.enqueueWebhooks(ctx, userId, eventType, prices)
+}
+
+// 変更後のタグを含む変更履歴（変更履歴の時点に戻す場合にタグも戻すため）
+func (s *serviceImpl) newTaggedRevision(ctx context.Context, userId uint, operation string, before, after *entity.Price) (*entity.PriceRevision, error) {
+	revision := newRevision(userId, operation, before, after)
+	if after == nil {
+		return revision, nil
+	}
+	tags, err := s.repository.Tag().FindNamesByPriceIds(ctx, []uint{after.ID})
+	if err != nil {
+		return nil, err
+	}
+	revision.After.Tags = revisionTags(tags[after.ID])
+	return revision, nil
+}
+
+// 変更履歴のタグ（タグの記録前の変更履歴と区別するため、タグがなければ空）
+func revisionTags(names []string) []string {
+	if names == nil {
+		return []string{}
+	}
+	return names
+}
+
+func newRevision(userId uint, operation string, before, after *entity.Price) *entity.PriceRevision {
+	revision := &entity.PriceRevision{
+		UserID:    userId,
+		Operation: operation,
+		Before:    priceValues(before),
+		After:     priceValues(after),
+	}
+	if before != nil {
+		revision.PriceID = before.ID
+	} else {
+		revision.PriceID = after.ID
+	}
+	return revision
+}
+
+func priceValues(price *entity.Price) *entity.PriceValues {
+	if price == nil {
+		return nil
+	}
+	return &entity.PriceValues{
+		DateTime: price.DateTime,
+		Store:    price.Store,
+		Product:  price.Product,
+		GTIN:     price.GTIN,
+		Price:    price.Price,
+		Quantity: price.Quantity,
+		Unit:     price.Unit,
+
+		PriceType: price.PriceType,
+		ValidFrom: price.ValidFrom,
+		ValidTo:   price.ValidTo,
+	}
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+// 削除済み価格の一覧
+func (s *serviceImpl) FindDeletedPrices(ctx context.Context, userId uint) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.repository.Price().FindDeletedByUserId(ctx, userId)
+}
+
+// 削除済み価格の復元
+func (s *serviceImpl) RestorePrice(ctx context.Context, priceId, userId uint) (*entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 価格の復元
+	rows, err := s.repository.Price().Restore(ctx, priceId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return nil, wrap(ErrNotFound)
+		}
+		return nil, wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 復元後の価格の取得
+	priceEntity, err := s.repository.Price().Find(ctx, priceId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if priceEntity == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// 変更履歴の記録とWebhookの配信キューへの登録
+	if err = s.recordPriceChange(ctx, userId, RevisionRestore, nil, priceEntity); err != nil {
+		return nil, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return priceEntity, nil
+}
+
+// 削除済み価格の完全削除
+func (s *serviceImpl) PurgePrice(ctx context.Context, priceId, userId uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.rollback(ctx)
+
+	// 削除済み価格の取得
+	price, err := s.repository.Price().FindDeletedForUpdate(ctx, priceId, userId)
+	if err != nil {
+		return err
+	}
+	if price == nil {
+		return wrap(ErrNotFound)
+	}
+
+	// 価格の完全削除
+	rows, err := s.repository.Price().Purge(ctx, priceId, userId)
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return wrap(ErrNotFound)
+		}
+		return wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 完全削除の記録（変更履歴は追記のみのため削除しない）
+	if err = s.recordPriceChange(ctx, userId, RevisionPurge, price, nil); err != nil {
+		return err
+	}
+
+	// 通知の削除
+	if _, err = s.repository.Notification().DeleteByPriceId(ctx, priceId); err != nil {
+		return err
+	}
+
+	// 添付ファイルの削除
+	keys, err := s.repository.Attachment().DeleteByPriceId(ctx, priceId)
+	if err != nil {
+		return err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return err
+	}
+
+	// 添付ファイルの本体の削除（コミット後）
+	s.deleteBlobs(ctx, keys)
+
+	return nil
+}
+
+// 保持期間を過ぎた削除済み価格の完全削除（全ユーザ）
+func (s *serviceImpl) PurgeDeletedPrices(ctx context.Context, before time.Time) (int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer s.rollback(ctx)
+
+	// 完全削除する価格の取得
+	prices, err := s.repository.Price().FindDeletedBeforeForUpdate(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+	if len(prices) == 0 {
+		return 0, nil
+	}
+
+	// 通知の削除（価格より先）
+	if _, err = s.repository.Notification().DeleteOfPricesDeletedBefore(ctx, before); err != nil {
+		return 0, err
+	}
+
+	// 添付ファイルの削除（価格より先）
+	keys, err := s.repository.Attachment().DeleteOfPricesDeletedBefore(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	// 価格の完全削除
+	rows, err := s.repository.Price().PurgeDeletedBefore(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	// 完全削除の記録（変更履歴は追記のみのため削除せず、変更したユーザは価格の所有者）
+	revisions := make([]entity.PriceRevision, len(prices))
+	for i := range prices {
+		revisions[i] = *newRevision(prices[i].UserID, RevisionPurge, &prices[i], nil)
+	}
+	if err = s.repository.PriceRevision().CreateAll(ctx, revisions); err != nil {
+		return 0, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return 0, err
+	}
+
+	// 添付ファイルの本体の削除（コミット後）
+	s.deleteBlobs(ctx, keys)
+
+	return rows, nil
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+// バーコードに該当する価格の履歴（日時の降順）
+//
+// UPC-Aは先頭に0を付けたJAN/EAN-13と同じ商品として扱う
+func (s *serviceImpl) FindPricesByGTIN(ctx context.Context, userId uint, gtin string) ([]entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.repository.Price().FindByGTIN(ctx, userId, gtinVariants(gtin))
+}
+
+func gtinVariants(gtin string) []string {
+	switch {
+	case len(gtin) == 12:
+		return []string{gtin, "0" + gtin}
+	case len(gtin) == 13 && gtin[0] == '0':
+		return []string{gtin, gtin[1:]}
+	}
+	return []string{gtin}
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ystkg/rest-example/entity"
+	"golang.org/x/text/unicode/norm"
+)
+
+// 重複の疑いがある商品
+type ProductDuplicate struct {
+	Product   string
+	Into      string  // 統合先の候補（価格の件数が多い商品）
+	Distance  float64 // 正規化した商品名の編集距離を長い方の文字数で割った値
+	Count     uint
+	IntoCount uint
+}
+
+// 商品名の別名の登録（別名の別名は正式な商品名の別名にする）
+func (s *serviceImpl) CreateProductAlias(ctx context.Context, alias *entity.ProductAlias) (*entity.ProductAlias, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 正式な商品名の取得
+	if alias.Product, err = s.resolveProduct(ctx, alias.UserID, alias.Product); err != nil {
+		return nil, err
+	}
+	if alias.Product == alias.Alias {
+		return nil, wrap(ErrProductAliasSelf)
+	}
+
+	// 別名の登録（別名を商品名にしていた別名も付け替え）
+	if _, err = s.repository.ProductAlias().Repoint(ctx, alias.UserID, alias.Alias, alias.Product); err != nil {
+		return nil, err
+	}
+	if err = s.repository.ProductAlias().Create(ctx, alias); err != nil {
+		return nil, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return alias, nil
+}
+
+// 商品名の別名の一覧
+func (s *serviceImpl) FindProductAliases(ctx context.Context, userId uint) ([]entity.ProductAlias, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.repository.ProductAlias().FindByUserId(ctx, userId)
+}
+
+// 商品名の別名の削除（登録済みの価格の商品名は戻さない）
+func (s *serviceImpl) DeleteProductAlias(ctx context.Context, aliasId, userId uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.rollback(ctx)
+
+	// 別名の削除
+	rows, err := s.repository.ProductAlias().Delete(ctx, aliasId, userId)
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return wrap(ErrNotFound)
+		}
+		return wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// 商品の統合（論理削除済みを含む全ての価格の商品名をintoに変更し、productをintoの別名にする）
+//
+// intoが別名なら正式な商品名に統合する。統合した価格の件数を返す
+func (s *serviceImpl) MergeProduct(ctx context.Context, userId uint, product, into string) (string, int64, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	defer s.rollback(ctx)
+
+	// 統合先の正式な商品名の取得
+	if into, err = s.resolveProduct(ctx, userId, into); err != nil {
+		return "", 0, err
+	}
+	if into == product {
+		return "", 0, wrap(ErrProductMergeSelf)
+	}
+
+	// 統合元の価格の取得
+	prices, err := s.repository.Price().FindByProductForUpdate(ctx, userId, product)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(prices) == 0 {
+		s.rollback(ctx)
+		return "", 0, wrap(ErrNotFound)
+	}
+
+	// 価格の商品名の変更と変更履歴の記録（統合先の価格と単位の系統が一致すること）
+	for i := range prices {
+		before := &prices[i]
+		if err = s.checkUnitFamily(ctx, userId, into, before.Unit, before.ID); err != nil {
+			return "", 0, err
+		}
+		after, rows, err := s.repository.Price().Rename(ctx, before.ID, []uint{before.Version}, before.Store, into)
+		if err != nil {
+			return "", 0, err
+		}
+		if rows != 1 {
+			s.rollback(ctx)
+			return "", 0, wrap(fmt.Errorf("RowsAffected:%d", rows))
+		}
+		if err = s.recordPriceRename(ctx, before, after); err != nil {
+			return "", 0, err
+		}
+	}
+
+	// 統合元を別名にする（統合元を商品名にしていた別名も付け替え）
+	if _, err = s.repository.ProductAlias().Repoint(ctx, userId, product, into); err != nil {
+		return "", 0, err
+	}
+	if err = s.repository.ProductAlias().Save(ctx, userId, product, into); err != nil {
+		return "", 0, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return "", 0, err
+	}
+
+	return into, int64(len(prices)), nil
+}
+
+// 重複の疑いがある商品（距離の昇順）
+//
+// 商品名はNFKCで正規化し、小文字にして空白を除いてから比較する。距離がthreshold以下の組を返す
+func (s *serviceImpl) FindDuplicateProducts(ctx context.Context, userId uint, threshold float64) ([]ProductDuplicate, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	counts, err := s.repository.Price().CountByProduct(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([][]rune, len(counts))
+	for i, v := range counts {
+		keys[i] = []rune(productKey(v.Product))
+	}
+
+	duplicates := []ProductDuplicate{}
+	for i := range counts {
+		for j := i + 1; j < len(counts); j++ {
+			longer := max(len(keys[i]), len(keys[j]))
+			if longer == 0 {
+				continue
+			}
+			// 文字数の差だけで閾値を超える組は計算しない
+			if float64(abs(len(keys[i])-len(keys[j])))/float64(longer) > threshold {
+				continue
+			}
+			distance := float64(editDistance(keys[i], keys[j])) / float64(longer)
+			if distance > threshold {
+				continue
+			}
+			product, into := counts[i], counts[j]
+			if product.Count > into.Count {
+				product, into = into, product
+			}
+			duplicates = append(duplicates, ProductDuplicate{
+				Product:   product.Product,
+				Into:      into.Product,
+				Distance:  distance,
+				Count:     product.Count,
+				IntoCount: into.Count,
+			})
+		}
+	}
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return duplicates[i].Distance < duplicates[j].Distance
+	})
+
+	return duplicates, nil
+}
+
+// 名前の変更の変更履歴の記録とWebhookの配信キューへの登録
+//
+// 論理削除済みの価格は購読者から見えないため変更履歴だけを記録する
+func (s *serviceImpl) recordPriceRename(ctx context.Context, before, after *entity.Price) error {
+	if before.DeletedAt.Valid {
+		revision, err := s.newTaggedRevision(ctx, before.UserID, RevisionUpdate, before, after)
+		if err != nil {
+			return err
+		}
+		return s.repository.PriceRevision().Create(ctx, revision)
+	}
+	return s.recordPriceChange(ctx, before.UserID, RevisionUpdate, before, after)
+}
+
+// 別名なら正式な商品名
+func (s *serviceImpl) resolveProduct(ctx context.Context, userId uint, product string) (string, error) {
+	aliases, err := s.repository.ProductAlias().FindByAliases(ctx, userId, []string{product})
+	if err != nil {
+		return "", err
+	}
+	if len(aliases) != 0 {
+		return aliases[0].Product, nil
+	}
+	return product, nil
+}
+
+// 価格の商品名が別名なら正式な商品名に置き換え
+func (s *serviceImpl) resolvePriceProducts(ctx context.Context, userId uint, prices []entity.Price) error {
+	products := make([]string, 0, len(prices))
+	for _, v := range prices {
+		products = append(products, v.Product)
+	}
+	aliases, err := s.repository.ProductAlias().FindByAliases(ctx, userId, uniqueTags(products))
+	if err != nil {
+		return err
+	}
+	resolved := make(map[string]string, len(aliases))
+	for _, v := range aliases {
+		resolved[v.Alias] = v.Product
+	}
+	for i := range prices {
+		if product, ok := resolved[prices[i].Product]; ok {
+			prices[i].Product = product
+		}
+	}
+	return nil
+}
+
+// 重複の判定に使う商品名
+func productKey(product string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, norm.NFKC.String(product))
+}
+
+// レーベンシュタイン距離
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
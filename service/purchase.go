@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+// 支出の集計の単位
+const (
+	SpendingByMonth    = "month"
+	SpendingByStore    = "store"
+	SpendingByCategory = "category"
+)
+
+// 支出の集計の行
+type SpendingRow struct {
+	Key       string // 月（YYYY-MM）、店舗名、カテゴリ（空は未分類）
+	Total     uint
+	Purchases int // 購入の件数
+}
+
+// 購入の登録（明細の金額と合計を求める）
+//
+// recordPricesなら明細の単価を購入の日時と店舗の価格として記録する
+func (s *serviceImpl) CreatePurchase(ctx context.Context, purchase *entity.Purchase, recordPrices bool) (*entity.Purchase, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 購入の登録
+	items := purchase.Items
+	purchase.Total = 0
+	for i := range items {
+		items[i].Amount = items[i].Price * items[i].Quantity
+		purchase.Total += items[i].Amount
+	}
+	if err = s.repository.Purchase().Create(ctx, purchase); err != nil {
+		return nil, err
+	}
+
+	// 価格の記録
+	var prices []*entity.Price
+	if recordPrices {
+		for i := range items {
+			price, err := s.execPriceOperation(ctx, purchase.UserID, &PriceOperation{
+				Op:       PriceOpCreate,
+				DateTime: purchase.DateTime,
+				Store:    purchase.Store,
+				Product:  items[i].Product,
+				Price:    items[i].Price,
+			})
+			if err != nil {
+				return nil, err
+			}
+			items[i].PriceID = &price.ID
+			prices = append(prices, price)
+		}
+	}
+
+	// 明細の登録
+	for i := range items {
+		items[i].PurchaseID = purchase.ID
+		items[i].UserID = purchase.UserID
+	}
+	if err = s.repository.PurchaseItem().Create(ctx, items); err != nil {
+		return nil, err
+	}
+	purchase.Items = items
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	// アラートの条件の評価
+	s.evaluateAlertsAsync(ctx, prices...)
+
+	return purchase, nil
+}
+
+// 購入の一覧（日時の降順、fromとtoはnilなら制限なし、toは含まない）
+func (s *serviceImpl) FindPurchases(ctx context.Context, userId uint, from, to *time.Time) ([]entity.Purchase, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	purchases, err := s.repository.Purchase().FindByUserId(ctx, userId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	purchaseIds := make([]uint, len(purchases))
+	for i, v := range purchases {
+		purchaseIds[i] = v.ID
+	}
+	items, err := s.repository.PurchaseItem().FindByPurchaseIds(ctx, purchaseIds)
+	if err != nil {
+		return nil, err
+	}
+	byPurchase := make(map[uint][]entity.PurchaseItem, len(purchases))
+	for _, v := range items {
+		byPurchase[v.PurchaseID] = append(byPurchase[v.PurchaseID], v)
+	}
+	for i := range purchases {
+		purchases[i].Items = byPurchase[purchases[i].ID]
+	}
+
+	return purchases, nil
+}
+
+// 購入の取得
+func (s *serviceImpl) FindPurchase(ctx context.Context, purchaseId, userId uint) (*entity.Purchase, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	purchase, err := s.repository.Purchase().Find(ctx, purchaseId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if purchase == nil {
+		return nil, nil
+	}
+	if purchase.Items, err = s.repository.PurchaseItem().FindByPurchaseIds(ctx, []uint{purchase.ID}); err != nil {
+		return nil, err
+	}
+
+	return purchase, nil
+}
+
+// 購入の削除（記録した価格は削除しない）
+func (s *serviceImpl) DeletePurchase(ctx context.Context, purchaseId, userId uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.rollback(ctx)
+
+	// 購入の削除
+	rows, err := s.repository.Purchase().Delete(ctx, purchaseId, userId)
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return wrap(ErrNotFound)
+		}
+		return wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 明細の削除
+	if err = s.repository.PurchaseItem().DeleteByPurchaseId(ctx, purchaseId); err != nil {
+		return err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// 支出の集計（byは月、店舗、カテゴリのいずれか、月はlocationの暦で区切る）
+//
+// 月は昇順、店舗とカテゴリは合計の降順（同額ならキーの順）
+func (s *serviceImpl) SpendingReport(ctx context.Context, userId uint, by string, from, to *time.Time, location *time.Location) ([]SpendingRow, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	purchases, err := s.FindPurchases(ctx, userId, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make(map[string]*SpendingRow)
+	counted := make(map[string]uint) // キーごとに最後に数えた購入
+	add := func(key string, amount, purchaseId uint) {
+		row := rows[key]
+		if row == nil {
+			row = &SpendingRow{Key: key}
+			rows[key] = row
+		}
+		row.Total += amount
+		if counted[key] != purchaseId {
+			counted[key] = purchaseId
+			row.Purchases++
+		}
+	}
+	for _, purchase := range purchases {
+		switch by {
+		case SpendingByMonth:
+			add(purchase.DateTime.In(location).Format("2006-01"), purchase.Total, purchase.ID)
+		case SpendingByStore:
+			add(purchase.Store, purchase.Total, purchase.ID)
+		case SpendingByCategory:
+			for _, v := range purchase.Items {
+				add(v.Category, v.Amount, purchase.ID)
+			}
+		default:
+			return nil, wrap(fmt.Errorf("unsupported:%s", by))
+		}
+	}
+
+	report := make([]SpendingRow, 0, len(rows))
+	for _, v := range rows {
+		report = append(report, *v)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if by != SpendingByMonth && report[i].Total != report[j].Total {
+			return report[i].Total > report[j].Total
+		}
+		return report[i].Key < report[j].Key
+	})
+
+	return report, nil
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"log/slog"
 	"time"
 
@@ -16,31 +17,147 @@ type Service interface {
 	CreateUser(ctx context.Context, name, password string) (*uint, error)
 	FindUser(ctx context.Context, name, password string) (*uint, error)
 
-	CreatePrice(ctx context.Context, userId uint, dateTime time.Time, store, product string, price uint) (*entity.Price, error)
-	FindPrices(ctx context.Context, userId uint) ([]entity.Price, error)
+	CreatePrice(ctx context.Context, userId uint, dateTime time.Time, store, product, gtin string, price uint, quantity float64, unit, priceType string, validFrom, validTo *time.Time, tags []string, rejectOutlier bool) (*entity.Price, error)
+	FindPrices(ctx context.Context, userId uint, tags []string, matchAll bool) ([]entity.Price, error)
+	FindPricesLastModified(ctx context.Context, userId uint) (time.Time, error)
 	FindPrice(ctx context.Context, priceId, userId uint) (*entity.Price, error)
-	UpdatePrice(ctx context.Context, priceId, userId uint, dateTime time.Time, store, product string, price uint) (*entity.Price, error)
-	DeletePrice(ctx context.Context, priceId, userId uint) error
+	FindEffectivePrices(ctx context.Context, userId uint, at time.Time, priceTypes []string) ([]entity.Price, error)
+	FindPricesByGTIN(ctx context.Context, userId uint, gtin string) ([]entity.Price, error)
+	CompareProductPrices(ctx context.Context, userId uint, product string, since, now time.Time, excludeSuspicious bool) ([]entity.Price, error)
+	ForecastProductPrice(ctx context.Context, userId uint, product string, since, now time.Time, days int, location *time.Location) (*PriceForecast, error)
+	CompareBasket(ctx context.Context, userId uint, items []BasketItem, since, now time.Time, excludeSuspicious bool) (*BasketComparison, error)
+	UpdatePrice(ctx context.Context, priceId, userId uint, ifMatch []uint, dateTime time.Time, store, product, gtin string, price uint, quantity float64, unit, priceType string, validFrom, validTo *time.Time, tags []string, rejectOutlier bool) (*entity.Price, error)
+	PatchPrice(ctx context.Context, priceId, userId uint, ifMatch []uint, dateTime *time.Time, store, product, gtin *string, price *uint, quantity *float64, unit, priceType *string, validity *entity.PriceValidity, tags *[]string, rejectOutlier bool) (*entity.Price, error)
+	DeletePrice(ctx context.Context, priceId, userId uint, ifMatch []uint) error
+	FindPriceRevisions(ctx context.Context, priceId, userId uint) ([]entity.PriceRevision, error)
+	RevertPrice(ctx context.Context, priceId, revisionId, userId uint, ifMatch []uint) (*entity.Price, error)
+	BatchPrices(ctx context.Context, userId uint, ops []PriceOperation, atomic bool) ([]PriceOperationResult, error)
+	ImportPrices(ctx context.Context, userId uint, prices []entity.Price, tags [][]string, chunkSize int) (int, error)
+	ExportPrices(ctx context.Context, userId uint, chunkSize int, fn func([]entity.Price) error) error
+
+	FindDeletedPrices(ctx context.Context, userId uint) ([]entity.Price, error)
+	RestorePrice(ctx context.Context, priceId, userId uint) (*entity.Price, error)
+	PurgePrice(ctx context.Context, priceId, userId uint) error
+	PurgeDeletedPrices(ctx context.Context, before time.Time) (int64, error)
+	NormalizePriceNames(ctx context.Context, chunkSize int) (int64, error)
+	NormalizeRelatedNames(ctx context.Context) (int64, error)
+
+	CreateAlertRule(ctx context.Context, rule *entity.AlertRule) (*entity.AlertRule, error)
+	FindAlertRules(ctx context.Context, userId uint) ([]entity.AlertRule, error)
+	FindAlertRule(ctx context.Context, ruleId, userId uint) (*entity.AlertRule, error)
+	UpdateAlertRule(ctx context.Context, rule *entity.AlertRule) (*entity.AlertRule, error)
+	DeleteAlertRule(ctx context.Context, ruleId, userId uint) error
+	FindNotifications(ctx context.Context, userId uint, read *bool) ([]entity.Notification, error)
+	MarkNotification(ctx context.Context, notificationId, userId uint, read bool) (*entity.Notification, error)
+
+	CreateWebhook(ctx context.Context, webhook *entity.Webhook) (*entity.Webhook, error)
+	FindWebhooks(ctx context.Context, userId uint) ([]entity.Webhook, error)
+	FindWebhook(ctx context.Context, webhookId, userId uint) (*entity.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *entity.Webhook) (*entity.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookId, userId uint) error
+	FindWebhookDeliveries(ctx context.Context, webhookId, userId uint) ([]entity.WebhookDelivery, error)
+	DeliverWebhooks(ctx context.Context, now time.Time, allowPrivate bool) (int, error)
+
+	SubscribePriceEvents(ctx context.Context, userId uint, lastEventId *uint64) *PriceEventSubscription
+
+	CreateTag(ctx context.Context, userId uint, name string) (*entity.Tag, error)
+	FindTags(ctx context.Context, userId uint) ([]entity.Tag, error)
+	FindTag(ctx context.Context, tagId, userId uint) (*entity.Tag, error)
+	RenameTag(ctx context.Context, tagId, userId uint, name string) (*entity.Tag, error)
+	MergeTag(ctx context.Context, tagId, intoId, userId uint) (*entity.Tag, error)
+	DeleteTag(ctx context.Context, tagId, userId uint) error
+	FindPriceTags(ctx context.Context, priceIds []uint) (map[uint][]string, error)
+
+	CreateAttachment(ctx context.Context, priceId, userId uint, fileName, contentType string, data []byte) (*entity.Attachment, error)
+	FindAttachments(ctx context.Context, priceId, userId uint) ([]entity.Attachment, error)
+	FindAttachment(ctx context.Context, attachmentId, priceId, userId uint) (*entity.Attachment, io.ReadCloser, error)
+	DeleteAttachment(ctx context.Context, attachmentId, priceId, userId uint) error
+
+	CreateStore(ctx context.Context, store *entity.Store) (*entity.Store, error)
+	FindStores(ctx context.Context, userId uint) ([]entity.Store, error)
+	FindStore(ctx context.Context, storeId, userId uint) (*entity.Store, error)
+	UpdateStore(ctx context.Context, store *entity.Store) (*entity.Store, error)
+	DeleteStore(ctx context.Context, storeId, userId uint) error
+	FindNearbyPrices(ctx context.Context, userId uint, latitude, longitude, radius float64, now time.Time, excludeSuspicious bool) ([]NearbyPrice, error)
+
+	CreateShoppingList(ctx context.Context, list *entity.ShoppingList) (*entity.ShoppingList, error)
+	FindShoppingLists(ctx context.Context, userId uint) ([]entity.ShoppingList, error)
+	FindShoppingList(ctx context.Context, listId, userId uint) (*entity.ShoppingList, error)
+	UpdateShoppingList(ctx context.Context, list *entity.ShoppingList) (*entity.ShoppingList, error)
+	DeleteShoppingList(ctx context.Context, listId, userId uint) error
+	AddShoppingListItem(ctx context.Context, item *entity.ShoppingListItem) (*entity.ShoppingListItem, error)
+	UpdateShoppingListItem(ctx context.Context, item *entity.ShoppingListItem) (*entity.ShoppingListItem, error)
+	CheckShoppingListItem(ctx context.Context, itemId, listId, userId uint, checked bool) (*entity.ShoppingListItem, error)
+	DeleteShoppingListItem(ctx context.Context, itemId, listId, userId uint) error
+	ReorderShoppingList(ctx context.Context, listId, userId uint, itemIds []uint) (*entity.ShoppingList, error)
+	EstimateShoppingList(ctx context.Context, listId, userId uint, strategy string, since, now time.Time, excludeChecked, excludeSuspicious bool) (*ShoppingListEstimate, error)
+
+	CreatePurchase(ctx context.Context, purchase *entity.Purchase, recordPrices bool) (*entity.Purchase, error)
+	FindPurchases(ctx context.Context, userId uint, from, to *time.Time) ([]entity.Purchase, error)
+	FindPurchase(ctx context.Context, purchaseId, userId uint) (*entity.Purchase, error)
+	DeletePurchase(ctx context.Context, purchaseId, userId uint) error
+	SpendingReport(ctx context.Context, userId uint, by string, from, to *time.Time, location *time.Location) ([]SpendingRow, error)
+
+	CreateIndexBasket(ctx context.Context, basket *entity.IndexBasket) (*entity.IndexBasket, error)
+	FindIndexBaskets(ctx context.Context, userId uint) ([]entity.IndexBasket, error)
+	FindIndexBasket(ctx context.Context, basketId, userId uint) (*entity.IndexBasket, error)
+	UpdateIndexBasket(ctx context.Context, basket *entity.IndexBasket) (*entity.IndexBasket, error)
+	DeleteIndexBasket(ctx context.Context, basketId, userId uint) error
+	PriceIndex(ctx context.Context, basketId, userId uint, base *time.Time, to time.Time, location *time.Location) (*PriceIndex, error)
+
+	CreateProductAlias(ctx context.Context, alias *entity.ProductAlias) (*entity.ProductAlias, error)
+	FindProductAliases(ctx context.Context, userId uint) ([]entity.ProductAlias, error)
+	DeleteProductAlias(ctx context.Context, aliasId, userId uint) error
+	MergeProduct(ctx context.Context, userId uint, product, into string) (string, int64, error)
+	FindDuplicateProducts(ctx context.Context, userId uint, threshold float64) ([]ProductDuplicate, error)
+
+	ReserveIdempotencyKey(ctx context.Context, userId uint, key, fingerprint string, expiredBefore time.Time) (*entity.IdempotencyKey, error)
+	CompleteIdempotencyKey(ctx context.Context, userId uint, key string, status int, header string, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, userId uint, key string) error
+	PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int64, error)
+
+	Shutdown(ctx context.Context) error
 }
 
 type serviceImpl struct {
 	repository repository.Repository
+
+	events *eventBroker
+	alerts *alertWorker
 }
 
 func NewService(r repository.Repository) Service {
-	return &serviceImpl{r}
+	s := &serviceImpl{repository: r, events: newEventBroker(eventBufferSize)}
+	s.alerts = newAlertWorker(alertQueueSize, s.evaluateAlerts)
+	return s
+}
+
+// シャットダウン（評価待ちのアラートの条件の評価を終えるまで待つ）
+func (s *serviceImpl) Shutdown(ctx context.Context) error {
+	return s.alerts.close(ctx)
 }
 
 func (s *serviceImpl) beginTx(ctx context.Context) (context.Context, error) {
-	return s.repository.BeginTx(ctx)
+	ctx, err := s.repository.BeginTx(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, contextKeyEvents, &[]PriceEvent{}), nil
 }
 
 func (s *serviceImpl) rollback(ctx context.Context) error {
 	return s.repository.Rollback(ctx)
 }
 
+// コミットに成功したらトランザクションで積んだイベントを配信
 func (s *serviceImpl) commit(ctx context.Context) error {
-	return s.repository.Commit(ctx)
+	if err := s.repository.Commit(ctx); err != nil {
+		return err
+	}
+	if queued, ok := ctx.Value(contextKeyEvents).(*[]PriceEvent); ok && len(*queued) != 0 {
+		s.events.publish(*queued)
+	}
+	return nil
 }
 
 // ユーザの登録
@@ -93,7 +210,9 @@ func encodePassword(user, password string) string {
 }
 
 // 価格の登録
-func (s *serviceImpl) CreatePrice(ctx context.Context, userId uint, dateTime time.Time, store, product string, price uint) (*entity.Price, error) {
+//
+// rejectOutlierなら過去の価格から外れた価格はエラー、そうでなければ外れ値の疑いを記録
+func (s *serviceImpl) CreatePrice(ctx context.Context, userId uint, dateTime time.Time, store, product, gtin string, price uint, quantity float64, unit, priceType string, validFrom, validTo *time.Time, tags []string, rejectOutlier bool) (*entity.Price, error) {
 	slog.DebugContext(ctx, "start")
 	defer slog.DebugContext(ctx, "end")
 
@@ -104,28 +223,69 @@ func (s *serviceImpl) CreatePrice(ctx context.Context, userId uint, dateTime tim
 	}
 	defer s.rollback(ctx)
 
+	// 別名の商品名の置き換え
+	if product, err = s.resolveProduct(ctx, userId, product); err != nil {
+		return nil, err
+	}
+
+	// 単位の系統の確認
+	if err = s.checkUnitFamily(ctx, userId, product, unit, 0); err != nil {
+		return nil, err
+	}
+
 	// 価格の登録
-	priceEntity, err := s.repository.Price().Create(ctx, userId, dateTime, store, product, price)
+	priceEntity, err := s.repository.Price().Create(ctx, userId, dateTime, store, product, gtin, price, quantity, unit, priceTypeOrRegular(priceType), validFrom, validTo)
 	if err != nil {
 		return nil, err
 	}
 
+	// 外れ値の判定
+	if err = s.flagOutlier(ctx, priceEntity, rejectOutlier); err != nil {
+		return nil, err
+	}
+
+	// タグの付与
+	if len(tags) != 0 {
+		if err = s.setPriceTags(ctx, userId, priceEntity.ID, tags); err != nil {
+			return nil, err
+		}
+	}
+
+	// 変更履歴の記録とWebhookの配信キューへの登録
+	if err = s.recordPriceChange(ctx, userId, RevisionCreate, nil, priceEntity); err != nil {
+		return nil, err
+	}
+
 	// コミット
 	if err = s.commit(ctx); err != nil {
 		return nil, err
 	}
 
+	// アラートの条件の評価
+	s.evaluateAlertsAsync(ctx, priceEntity)
+
 	return priceEntity, nil
 }
 
-// 価格の一覧
-func (s *serviceImpl) FindPrices(ctx context.Context, userId uint) ([]entity.Price, error) {
+// 価格の一覧（tagsの指定があれば、matchAllなら全てのタグ、そうでなければいずれかのタグが付与されている価格）
+func (s *serviceImpl) FindPrices(ctx context.Context, userId uint, tags []string, matchAll bool) ([]entity.Price, error) {
 	slog.DebugContext(ctx, "start")
 	defer slog.DebugContext(ctx, "end")
 
+	if len(tags) != 0 {
+		return s.repository.Price().FindByUserIdAndTags(ctx, userId, uniqueTags(tags), matchAll)
+	}
 	return s.repository.Price().FindByUserId(ctx, userId)
 }
 
+// 価格の一覧の最終更新日時（削除も更新として扱う）
+func (s *serviceImpl) FindPricesLastModified(ctx context.Context, userId uint) (time.Time, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.repository.Price().LastModifiedByUserId(ctx, userId)
+}
+
 // 価格の取得
 func (s *serviceImpl) FindPrice(ctx context.Context, priceId, userId uint) (*entity.Price, error) {
 	slog.DebugContext(ctx, "start")
@@ -135,7 +295,9 @@ func (s *serviceImpl) FindPrice(ctx context.Context, priceId, userId uint) (*ent
 }
 
 // 価格の更新
-func (s *serviceImpl) UpdatePrice(ctx context.Context, priceId, userId uint, dateTime time.Time, store, product string, price uint) (*entity.Price, error) {
+//
+// rejectOutlierなら過去の価格から外れた価格はエラー、そうでなければ外れ値の疑いを記録
+func (s *serviceImpl) UpdatePrice(ctx context.Context, priceId, userId uint, ifMatch []uint, dateTime time.Time, store, product, gtin string, price uint, quantity float64, unit, priceType string, validFrom, validTo *time.Time, tags []string, rejectOutlier bool) (*entity.Price, error) {
 	slog.DebugContext(ctx, "start")
 	defer slog.DebugContext(ctx, "end")
 
@@ -146,37 +308,195 @@ func (s *serviceImpl) UpdatePrice(ctx context.Context, priceId, userId uint, dat
 	}
 	defer s.rollback(ctx)
 
+	// 更新前の価格の取得
+	before, err := s.repository.Price().FindForUpdate(ctx, priceId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// 別名の商品名の置き換え
+	if product, err = s.resolveProduct(ctx, userId, product); err != nil {
+		return nil, err
+	}
+
+	// 単位の系統の確認
+	if err = s.checkUnitFamily(ctx, userId, product, unit, priceId); err != nil {
+		return nil, err
+	}
+
 	// 価格の更新
 	priceEntity, rows, err := s.repository.Price().Update(
 		ctx,
 		priceId,
 		userId,
+		matchVersions(before.Version, ifMatch),
 		dateTime,
 		store,
 		product,
+		gtin,
 		price,
+		quantity,
+		unit,
+		priceTypeOrRegular(priceType),
+		validFrom,
+		validTo,
 	)
 	if err != nil {
 		return nil, err
 	}
 	if rows != 1 {
+		if rows == 0 {
+			return nil, s.notUpdatedError(ctx, priceId, userId)
+		}
 		s.rollback(ctx)
+		return nil, wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 外れ値の判定
+	priceEntity.Suspicious = before.Suspicious
+	if err = s.flagOutlier(ctx, priceEntity, rejectOutlier); err != nil {
+		return nil, err
+	}
+
+	// タグの置き換え
+	if err = s.setPriceTags(ctx, userId, priceId, tags); err != nil {
+		return nil, err
+	}
+
+	// 変更履歴の記録とWebhookの配信キューへの登録
+	if err = s.recordPriceChange(ctx, userId, RevisionUpdate, before, priceEntity); err != nil {
+		return nil, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	// アラートの条件の評価
+	s.evaluateAlertsAsync(ctx, priceEntity)
+
+	return priceEntity, nil
+}
+
+// 価格の部分更新
+//
+// tagsがnilならタグを変更しない。商品か価格が変わる場合は外れ値を判定
+func (s *serviceImpl) PatchPrice(ctx context.Context, priceId, userId uint, ifMatch []uint, dateTime *time.Time, store, product, gtin *string, price *uint, quantity *float64, unit, priceType *string, validity *entity.PriceValidity, tags *[]string, rejectOutlier bool) (*entity.Price, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 更新前の価格の取得
+	before, err := s.repository.Price().FindForUpdate(ctx, priceId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// 変更がなければ更新しない
+	if dateTime == nil && store == nil && product == nil && gtin == nil && price == nil && quantity == nil && unit == nil && priceType == nil && validity == nil && tags == nil {
+		if ifMatch == nil {
+			return before, nil
+		}
+		matched, err := s.repository.Price().FindByVersions(ctx, priceId, userId, ifMatch)
+		if err != nil {
+			return nil, err
+		}
+		if matched == nil {
+			return nil, s.notUpdatedError(ctx, priceId, userId)
+		}
+		return matched, nil
+	}
+
+	// 別名の商品名の置き換え
+	if product != nil {
+		resolved, err := s.resolveProduct(ctx, userId, *product)
+		if err != nil {
+			return nil, err
+		}
+		product = &resolved
+	}
+
+	// 単位の系統の確認（商品か単位が変わる場合）
+	if product != nil || unit != nil {
+		afterProduct, afterUnit := before.Product, before.Unit
+		if product != nil {
+			afterProduct = *product
+		}
+		if unit != nil {
+			afterUnit = *unit
+		}
+		if err = s.checkUnitFamily(ctx, userId, afterProduct, afterUnit, priceId); err != nil {
+			return nil, err
+		}
+	}
+
+	// 価格の部分更新
+	rows, err := s.repository.Price().Patch(ctx, priceId, userId, matchVersions(before.Version, ifMatch), dateTime, store, product, gtin, price, quantity, unit, priceType, validity)
+	if err != nil {
+		return nil, err
+	}
+	if rows != 1 {
 		if rows == 0 {
-			return nil, wrap(ErrNotFound)
+			return nil, s.notUpdatedError(ctx, priceId, userId)
 		}
+		s.rollback(ctx)
 		return nil, wrap(fmt.Errorf("RowsAffected:%d", rows))
 	}
 
+	// タグの置き換え
+	if tags != nil {
+		if err = s.setPriceTags(ctx, userId, priceId, *tags); err != nil {
+			return nil, err
+		}
+	}
+
+	// 更新後の価格の取得
+	priceEntity, err := s.repository.Price().Find(ctx, priceId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if priceEntity == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// 外れ値の判定
+	if product != nil || price != nil {
+		if err = s.flagOutlier(ctx, priceEntity, rejectOutlier); err != nil {
+			return nil, err
+		}
+	}
+
+	// 変更履歴の記録とWebhookの配信キューへの登録
+	if err = s.recordPriceChange(ctx, userId, RevisionUpdate, before, priceEntity); err != nil {
+		return nil, err
+	}
+
 	// コミット
 	if err = s.commit(ctx); err != nil {
 		return nil, err
 	}
 
+	// アラートの条件の評価
+	s.evaluateAlertsAsync(ctx, priceEntity)
+
 	return priceEntity, nil
 }
 
-// 価格の削除
-func (s *serviceImpl) DeletePrice(ctx context.Context, priceId, userId uint) error {
+// 価格の削除（論理削除なので添付ファイルは復元できるよう残し、完全削除で削除）
+func (s *serviceImpl) DeletePrice(ctx context.Context, priceId, userId uint, ifMatch []uint) error {
 	slog.DebugContext(ctx, "start")
 	defer slog.DebugContext(ctx, "end")
 
@@ -187,19 +507,33 @@ func (s *serviceImpl) DeletePrice(ctx context.Context, priceId, userId uint) err
 	}
 	defer s.rollback(ctx)
 
+	// 削除前の価格の取得
+	before, err := s.repository.Price().FindForUpdate(ctx, priceId, userId)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return wrap(ErrNotFound)
+	}
+
 	// 価格の削除
-	rows, err := s.repository.Price().Delete(ctx, priceId, userId)
+	rows, err := s.repository.Price().Delete(ctx, priceId, userId, matchVersions(before.Version, ifMatch))
 	if err != nil {
 		return err
 	}
 	if rows != 1 {
-		s.rollback(ctx)
 		if rows == 0 {
-			return wrap(ErrNotFound)
+			return s.notUpdatedError(ctx, priceId, userId)
 		}
+		s.rollback(ctx)
 		return wrap(fmt.Errorf("RowsAffected:%d", rows))
 	}
 
+	// 変更履歴の記録とWebhookの配信キューへの登録
+	if err = s.recordPriceChange(ctx, userId, RevisionDelete, before, nil); err != nil {
+		return err
+	}
+
 	// コミット
 	if err = s.commit(ctx); err != nil {
 		return err
@@ -207,3 +541,25 @@ func (s *serviceImpl) DeletePrice(ctx context.Context, priceId, userId uint) err
 
 	return nil
 }
+
+// 更新条件とするバージョン（If-Matchの指定がなければ更新前に取得したバージョン）
+//
+// 一致の判定はUPDATE文の条件で行い、一致しなければ更新件数が0件になる
+func matchVersions(current uint, ifMatch []uint) []uint {
+	if ifMatch == nil {
+		return []uint{current}
+	}
+	return ifMatch
+}
+
+// 更新件数が0件の場合のエラー（価格が残っていればバージョンの不一致）
+func (s *serviceImpl) notUpdatedError(ctx context.Context, priceId, userId uint) error {
+	price, err := s.repository.Price().Find(ctx, priceId, userId)
+	if err != nil {
+		return err
+	}
+	if price == nil {
+		return wrap(ErrNotFound)
+	}
+	return wrap(ErrPreconditionFailed)
+}
@@ -0,0 +1,510 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+const maxItemsPerShoppingList = 100
+
+// 見積もりに使う価格
+const (
+	EstimateByLatest   = "latest"   // 商品の最新の価格
+	EstimateByCheapest = "cheapest" // 商品の店舗ごとの最新の価格のうち最安
+)
+
+// 見積もりの品目（小計は価格と個数の積）
+type EstimatedItem struct {
+	Item     entity.ShoppingListItem
+	Price    entity.Price
+	Subtotal uint
+}
+
+// 店舗ごとの見積もり（Itemsはリストの並び順）
+type StoreEstimate struct {
+	Store string
+	Total uint
+	Items []EstimatedItem
+}
+
+// 買い物リストの見積もり
+type ShoppingListEstimate struct {
+	Total   uint
+	Stores  []StoreEstimate           // 店舗名の順
+	Missing []entity.ShoppingListItem // 価格がない品目
+}
+
+// 買い物リストの登録（品目はItemsの順）
+func (s *serviceImpl) CreateShoppingList(ctx context.Context, list *entity.ShoppingList) (*entity.ShoppingList, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 買い物リストの登録
+	items := list.Items
+	if err = s.repository.ShoppingList().Create(ctx, list); err != nil {
+		return nil, err
+	}
+
+	// 品目の登録
+	if err = s.createShoppingListItems(ctx, list, items); err != nil {
+		return nil, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// 買い物リストの一覧
+func (s *serviceImpl) FindShoppingLists(ctx context.Context, userId uint) ([]entity.ShoppingList, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	lists, err := s.repository.ShoppingList().FindByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	listIds := make([]uint, len(lists))
+	for i, v := range lists {
+		listIds[i] = v.ID
+	}
+	items, err := s.repository.ShoppingListItem().FindByListIds(ctx, listIds)
+	if err != nil {
+		return nil, err
+	}
+	byList := make(map[uint][]entity.ShoppingListItem, len(lists))
+	for _, v := range items {
+		byList[v.ListID] = append(byList[v.ListID], v)
+	}
+	for i := range lists {
+		lists[i].Items = byList[lists[i].ID]
+	}
+
+	return lists, nil
+}
+
+// 買い物リストの取得
+func (s *serviceImpl) FindShoppingList(ctx context.Context, listId, userId uint) (*entity.ShoppingList, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	list, err := s.repository.ShoppingList().Find(ctx, listId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return nil, nil
+	}
+	if list.Items, err = s.repository.ShoppingListItem().FindByListIds(ctx, []uint{list.ID}); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// 買い物リストの更新（品目はItemsで置き換え）
+func (s *serviceImpl) UpdateShoppingList(ctx context.Context, list *entity.ShoppingList) (*entity.ShoppingList, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 買い物リストの更新
+	rows, err := s.repository.ShoppingList().Update(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return nil, wrap(ErrNotFound)
+		}
+		return nil, wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 品目の置き換え
+	if err = s.repository.ShoppingListItem().DeleteByListId(ctx, list.ID); err != nil {
+		return nil, err
+	}
+	if err = s.createShoppingListItems(ctx, list, list.Items); err != nil {
+		return nil, err
+	}
+
+	// 更新後の買い物リストの取得
+	updated, err := s.FindShoppingList(ctx, list.ID, list.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// 買い物リストの削除（品目も削除）
+func (s *serviceImpl) DeleteShoppingList(ctx context.Context, listId, userId uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.rollback(ctx)
+
+	// 買い物リストの削除
+	rows, err := s.repository.ShoppingList().Delete(ctx, listId, userId)
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return wrap(ErrNotFound)
+		}
+		return wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 品目の削除
+	if err = s.repository.ShoppingListItem().DeleteByListId(ctx, listId); err != nil {
+		return err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// 品目の追加（末尾）
+func (s *serviceImpl) AddShoppingListItem(ctx context.Context, item *entity.ShoppingListItem) (*entity.ShoppingListItem, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 買い物リストの存在確認（品目の件数を数える間に変更されないようロック）
+	list, err := s.repository.ShoppingList().FindForUpdate(ctx, item.ListID, item.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return nil, wrap(ErrNotFound)
+	}
+	count, err := s.repository.ShoppingListItem().CountByListId(ctx, list.ID)
+	if err != nil {
+		return nil, err
+	}
+	if maxItemsPerShoppingList <= count {
+		return nil, wrap(ErrTooManyListItems)
+	}
+	position, err := s.repository.ShoppingListItem().MaxPosition(ctx, list.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 品目の登録
+	item.Position = position + 1
+	items := []entity.ShoppingListItem{*item}
+	if err = s.repository.ShoppingListItem().Create(ctx, items); err != nil {
+		return nil, err
+	}
+	if err = s.repository.ShoppingList().Touch(ctx, list.ID); err != nil {
+		return nil, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return &items[0], nil
+}
+
+// 品目の更新（並び順は変えない）
+func (s *serviceImpl) UpdateShoppingListItem(ctx context.Context, item *entity.ShoppingListItem) (*entity.ShoppingListItem, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.updateShoppingListItem(ctx, item.ID, item.ListID, item.UserID, func(ctx context.Context) (int64, error) {
+		return s.repository.ShoppingListItem().Update(ctx, item)
+	})
+}
+
+// 品目のチェック（checkedがfalseならチェックを外す）
+func (s *serviceImpl) CheckShoppingListItem(ctx context.Context, itemId, listId, userId uint, checked bool) (*entity.ShoppingListItem, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.updateShoppingListItem(ctx, itemId, listId, userId, func(ctx context.Context) (int64, error) {
+		return s.repository.ShoppingListItem().UpdateChecked(ctx, itemId, listId, userId, checked)
+	})
+}
+
+// 品目の削除
+func (s *serviceImpl) DeleteShoppingListItem(ctx context.Context, itemId, listId, userId uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.rollback(ctx)
+
+	// 品目の削除
+	rows, err := s.repository.ShoppingListItem().Delete(ctx, itemId, listId, userId)
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return wrap(ErrNotFound)
+		}
+		return wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+	if err = s.repository.ShoppingList().Touch(ctx, listId); err != nil {
+		return err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// 品目の並べ替え（itemIdsはリストの全ての品目のIDを新しい順に）
+func (s *serviceImpl) ReorderShoppingList(ctx context.Context, listId, userId uint, itemIds []uint) (*entity.ShoppingList, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 買い物リストの存在確認（並べ替えの間に品目が変更されないようロック）
+	list, err := s.repository.ShoppingList().FindForUpdate(ctx, listId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return nil, wrap(ErrNotFound)
+	}
+	items, err := s.repository.ShoppingListItem().FindByListIds(ctx, []uint{listId})
+	if err != nil {
+		return nil, err
+	}
+
+	// 全ての品目が1回ずつ含まれているか
+	if len(itemIds) != len(items) {
+		return nil, wrap(ErrListOrder)
+	}
+	remaining := make(map[uint]bool, len(items))
+	for _, v := range items {
+		remaining[v.ID] = true
+	}
+	for _, v := range itemIds {
+		if !remaining[v] {
+			return nil, wrap(ErrListOrder)
+		}
+		delete(remaining, v)
+	}
+
+	// 並び順の更新
+	for i, v := range itemIds {
+		if _, err = s.repository.ShoppingListItem().UpdatePosition(ctx, v, listId, i); err != nil {
+			return nil, err
+		}
+	}
+	if err = s.repository.ShoppingList().Touch(ctx, listId); err != nil {
+		return nil, err
+	}
+
+	// 更新後の買い物リストの取得
+	reordered, err := s.FindShoppingList(ctx, listId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if reordered == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return reordered, nil
+}
+
+// 買い物リストの見積もり（sinceより前の価格とnowの時点で有効期間外の価格は対象外）
+//
+// 品目ごとにstrategyの価格と個数から小計を求め、価格の店舗ごとに集計する。
+// excludeCheckedならチェック済みの品目を除き、excludeSuspiciousなら外れ値の疑いがある価格を除く
+func (s *serviceImpl) EstimateShoppingList(ctx context.Context, listId, userId uint, strategy string, since, now time.Time, excludeChecked, excludeSuspicious bool) (*ShoppingListEstimate, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	list, err := s.FindShoppingList(ctx, listId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// 対象の品目の商品
+	var items []entity.ShoppingListItem
+	var products []string
+	for _, v := range list.Items {
+		if excludeChecked && v.Checked {
+			continue
+		}
+		items = append(items, v)
+		products = append(products, v.Product)
+	}
+
+	// 商品ごとの価格
+	latest, err := s.repository.Price().FindLatestByProducts(ctx, userId, products, since, now, excludeSuspicious)
+	if err != nil {
+		return nil, err
+	}
+	chosen := make(map[string]entity.Price, len(products))
+	for _, v := range latest {
+		current, found := chosen[v.Product]
+		switch {
+		case !found:
+			// 価格の昇順なので最初が最安
+			chosen[v.Product] = v
+		case strategy == EstimateByLatest:
+			if current.DateTime.Before(v.DateTime) || (current.DateTime.Equal(v.DateTime) && current.ID < v.ID) {
+				chosen[v.Product] = v
+			}
+		}
+	}
+
+	// 店舗ごとの集計
+	estimate := &ShoppingListEstimate{}
+	byStore := make(map[string]*StoreEstimate)
+	for _, v := range items {
+		price, found := chosen[v.Product]
+		if !found {
+			estimate.Missing = append(estimate.Missing, v)
+			continue
+		}
+		subtotal := price.Price * v.Quantity
+		store := byStore[price.Store]
+		if store == nil {
+			store = &StoreEstimate{Store: price.Store}
+			byStore[price.Store] = store
+		}
+		store.Total += subtotal
+		store.Items = append(store.Items, EstimatedItem{Item: v, Price: price, Subtotal: subtotal})
+		estimate.Total += subtotal
+	}
+	for _, v := range byStore {
+		estimate.Stores = append(estimate.Stores, *v)
+	}
+	sort.Slice(estimate.Stores, func(i, j int) bool {
+		return estimate.Stores[i].Store < estimate.Stores[j].Store
+	})
+
+	return estimate, nil
+}
+
+// 品目の登録（並び順はitemsの順）
+func (s *serviceImpl) createShoppingListItems(ctx context.Context, list *entity.ShoppingList, items []entity.ShoppingListItem) error {
+	for i := range items {
+		items[i].ListID = list.ID
+		items[i].UserID = list.UserID
+		items[i].Position = i
+	}
+	if err := s.repository.ShoppingListItem().Create(ctx, items); err != nil {
+		return err
+	}
+	list.Items = items
+	return nil
+}
+
+// 品目の更新と更新後の品目の取得
+func (s *serviceImpl) updateShoppingListItem(ctx context.Context, itemId, listId, userId uint, update func(context.Context) (int64, error)) (*entity.ShoppingListItem, error) {
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 品目の更新
+	rows, err := update(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return nil, wrap(ErrNotFound)
+		}
+		return nil, wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+	if err = s.repository.ShoppingList().Touch(ctx, listId); err != nil {
+		return nil, err
+	}
+
+	// 更新後の品目の取得
+	item, err := s.repository.ShoppingListItem().Find(ctx, itemId, listId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+// 近隣の店舗の最新の価格
+type NearbyPrice struct {
+	Store *entity.Store // Distanceは検索地点からの距離
+	Price entity.Price
+}
+
+// 店舗の登録
+func (s *serviceImpl) CreateStore(ctx context.Context, store *entity.Store) (*entity.Store, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 店舗の登録
+	if err = s.repository.Store().Create(ctx, store); err != nil {
+		return nil, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// 店舗の一覧
+func (s *serviceImpl) FindStores(ctx context.Context, userId uint) ([]entity.Store, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.repository.Store().FindByUserId(ctx, userId)
+}
+
+// 店舗の取得
+func (s *serviceImpl) FindStore(ctx context.Context, storeId, userId uint) (*entity.Store, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.repository.Store().Find(ctx, storeId, userId)
+}
+
+// 店舗の更新
+func (s *serviceImpl) UpdateStore(ctx context.Context, store *entity.Store) (*entity.Store, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 店舗の更新
+	rows, err := s.repository.Store().Update(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return nil, wrap(ErrNotFound)
+		}
+		return nil, wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 更新後の店舗の取得
+	updated, err := s.repository.Store().Find(ctx, store.ID, store.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// 店舗の削除（価格は削除しない）
+func (s *serviceImpl) DeleteStore(ctx context.Context, storeId, userId uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.rollback(ctx)
+
+	// 店舗の削除
+	rows, err := s.repository.Store().Delete(ctx, storeId, userId)
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return wrap(ErrNotFound)
+		}
+		return wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// 半径（メートル）以内の店舗の最新の価格（距離の昇順、同じ店舗は商品名の昇順、nowの時点で有効期間外の価格は対象外）
+//
+// excludeSuspiciousなら外れ値の疑いがある価格を除く
+func (s *serviceImpl) FindNearbyPrices(ctx context.Context, userId uint, latitude, longitude, radius float64, now time.Time, excludeSuspicious bool) ([]NearbyPrice, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	stores, err := s.repository.Store().FindNearby(ctx, userId, latitude, longitude, radius)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(stores))
+	for i, v := range stores {
+		names[i] = v.Name
+	}
+
+	prices, err := s.repository.Price().FindLatestByStores(ctx, userId, names, now, excludeSuspicious)
+	if err != nil {
+		return nil, err
+	}
+	byStore := make(map[string][]entity.Price)
+	for _, v := range prices {
+		byStore[v.Store] = append(byStore[v.Store], v)
+	}
+
+	var nearby []NearbyPrice
+	for i := range stores {
+		for _, v := range byStore[stores[i].Name] {
+			nearby = append(nearby, NearbyPrice{Store: &stores[i], Price: v})
+		}
+	}
+
+	return nearby, nil
+}
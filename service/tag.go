@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+// タグの登録
+func (s *serviceImpl) CreateTag(ctx context.Context, userId uint, name string) (*entity.Tag, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// タグの登録
+	tag, err := s.repository.Tag().Create(ctx, userId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// タグの一覧
+func (s *serviceImpl) FindTags(ctx context.Context, userId uint) ([]entity.Tag, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.repository.Tag().FindByUserId(ctx, userId)
+}
+
+// タグの取得
+func (s *serviceImpl) FindTag(ctx context.Context, tagId, userId uint) (*entity.Tag, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.repository.Tag().Find(ctx, tagId, userId)
+}
+
+// タグの名前の変更
+func (s *serviceImpl) RenameTag(ctx context.Context, tagId, userId uint, name string) (*entity.Tag, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 付与されている価格の変更前の状態の取得
+	prices, tags, err := s.findTaggedPrices(ctx, tagId)
+	if err != nil {
+		return nil, err
+	}
+
+	// タグの名前の変更
+	rows, err := s.repository.Tag().Rename(ctx, tagId, userId, name)
+	if err != nil {
+		return nil, err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return nil, wrap(ErrNotFound)
+		}
+		return nil, wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 付与されている価格のバージョンを上げる
+	if _, err = s.repository.Price().TouchByTagId(ctx, tagId); err != nil {
+		return nil, err
+	}
+
+	// 変更履歴の記録とWebhookの配信キューへの登録
+	if err = s.recordTagChange(ctx, userId, prices, tags); err != nil {
+		return nil, err
+	}
+
+	// 変更後のタグの取得
+	tag, err := s.repository.Tag().Find(ctx, tagId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// タグの統合（統合元のタグは削除）
+func (s *serviceImpl) MergeTag(ctx context.Context, tagId, intoId, userId uint) (*entity.Tag, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// 統合元と統合先のタグの存在確認
+	for _, id := range []uint{tagId, intoId} {
+		tag, err := s.repository.Tag().Find(ctx, id, userId)
+		if err != nil {
+			return nil, err
+		}
+		if tag == nil {
+			return nil, wrap(ErrNotFound)
+		}
+	}
+
+	// 付与されている価格の変更前の状態の取得
+	prices, tags, err := s.findTaggedPrices(ctx, tagId)
+	if err != nil {
+		return nil, err
+	}
+
+	// 付与されている価格のバージョンを上げる（統合でタグが消えるため先に実行）
+	if _, err = s.repository.Price().TouchByTagId(ctx, tagId); err != nil {
+		return nil, err
+	}
+
+	// タグの統合
+	rows, err := s.repository.Tag().Merge(ctx, tagId, intoId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return nil, wrap(ErrNotFound)
+		}
+		return nil, wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 変更履歴の記録とWebhookの配信キューへの登録
+	if err = s.recordTagChange(ctx, userId, prices, tags); err != nil {
+		return nil, err
+	}
+
+	// 統合後のタグの取得
+	tag, err := s.repository.Tag().Find(ctx, intoId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// タグの削除（価格からも外す）
+func (s *serviceImpl) DeleteTag(ctx context.Context, tagId, userId uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.rollback(ctx)
+
+	// 存在確認（他のユーザのタグの価格を更新しないため）
+	tag, err := s.repository.Tag().Find(ctx, tagId, userId)
+	if err != nil {
+		return err
+	}
+	if tag == nil {
+		return wrap(ErrNotFound)
+	}
+
+	// 付与されている価格の変更前の状態の取得
+	prices, tags, err := s.findTaggedPrices(ctx, tagId)
+	if err != nil {
+		return err
+	}
+
+	// 付与されている価格のバージョンを上げる
+	if _, err = s.repository.Price().TouchByTagId(ctx, tagId); err != nil {
+		return err
+	}
+
+	// タグの削除
+	rows, err := s.repository.Tag().Delete(ctx, tagId, userId)
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return wrap(ErrNotFound)
+		}
+		return wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 変更履歴の記録とWebhookの配信キューへの登録
+	if err = s.recordTagChange(ctx, userId, prices, tags); err != nil {
+		return err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// タグが付与されている価格と価格ごとのタグの名前（変更履歴の変更前の状態としてロックして取得）
+func (s *serviceImpl) findTaggedPrices(ctx context.Context, tagId uint) ([]entity.Price, map[uint][]string, error) {
+	prices, err := s.repository.Price().FindByTagIdForUpdate(ctx, tagId)
+	if err != nil {
+		return nil, nil, err
+	}
+	priceIds := make([]uint, len(prices))
+	for i, v := range prices {
+		priceIds[i] = v.ID
+	}
+	tags, err := s.repository.Tag().FindNamesByPriceIds(ctx, priceIds)
+	if err != nil {
+		return nil, nil, err
+	}
+	return prices, tags, nil
+}
+
+// タグの変更で表現が変わった価格の変更履歴の記録と、購読しているWebhookの配信キューとコミット後に配信するイベントへの登録
+func (s *serviceImpl) recordTagChange(ctx context.Context, userId uint, before []entity.Price, beforeTags map[uint][]string) error {
+	if len(before) == 0 {
+		return nil
+	}
+
+	// 変更後の価格とタグの取得
+	priceIds := make([]uint, len(before))
+	for i, v := range before {
+		priceIds[i] = v.ID
+	}
+	after, err := s.repository.Price().FindByIds(ctx, priceIds)
+	if err != nil {
+		return err
+	}
+	if len(after) != len(before) {
+		return wrap(fmt.Errorf("prices:%d,%d", len(before), len(after)))
+	}
+	afterTags, err := s.repository.Tag().FindNamesByPriceIds(ctx, priceIds)
+	if err != nil {
+		return err
+	}
+
+	// 変更履歴の記録（どちらもIDの昇順）
+	revisions := make([]entity.PriceRevision, len(after))
+	for i := range after {
+		revisions[i] = *newRevision(userId, RevisionUpdate, &before[i], &after[i])
+		revisions[i].Before.Tags = revisionTags(beforeTags[before[i].ID])
+		revisions[i].After.Tags = revisionTags(afterTags[after[i].ID])
+	}
+	if err = s.repository.PriceRevision().CreateAll(ctx, revisions); err != nil {
+		return err
+	}
+
+	queuePriceEvents(ctx, userId, EventPriceUpdated, after)
+	return s.enqueueWebhooks(ctx, userId, EventPriceUpdated, after)
+}
+
+// 価格ごとのタグの名前
+func (s *serviceImpl) FindPriceTags(ctx context.Context, priceIds []uint) (map[uint][]string, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.repository.Tag().FindNamesByPriceIds(ctx, priceIds)
+}
+
+// 価格のタグの置き換え（存在しないタグは登録）
+func (s *serviceImpl) setPriceTags(ctx context.Context, userId, priceId uint, names []string) error {
+	tags, err := s.repository.Tag().FindOrCreate(ctx, userId, uniqueTags(names))
+	if err != nil {
+		return err
+	}
+	tagIds := make([]uint, len(tags))
+	for i, v := range tags {
+		tagIds[i] = v.ID
+	}
+	return s.repository.Tag().ReplacePriceTags(ctx, priceId, tagIds)
+}
+
+// 重複を除いたタグの名前（指定順）
+func uniqueTags(names []string) []string {
+	unique := make([]string, 0, len(names))
+	for _, v := range names {
+		if !slices.Contains(unique, v) {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
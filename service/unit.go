@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"math"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+// 内容量の単位
+const (
+	UnitGram       = "g"
+	UnitKilogram   = "kg"
+	UnitMilliliter = "ml"
+	UnitLiter      = "L"
+	UnitPiece      = "piece"
+)
+
+// 単位の系統（同じ系統の単位どうしだけ換算できる）
+const (
+	unitFamilyMass   = "mass"
+	unitFamilyVolume = "volume"
+	unitFamilyCount  = "count"
+)
+
+type unitDef struct {
+	family string
+	base   float64 // 系統の基準単位への換算係数
+}
+
+var units = map[string]unitDef{
+	UnitGram:       {unitFamilyMass, 1},
+	UnitKilogram:   {unitFamilyMass, 1000},
+	UnitMilliliter: {unitFamilyVolume, 1},
+	UnitLiter:      {unitFamilyVolume, 1000},
+	UnitPiece:      {unitFamilyCount, 1},
+}
+
+// 単価の基準量（基準単位での量と表記）
+var unitPriceBases = map[string]struct {
+	amount float64
+	label  string
+}{
+	unitFamilyMass:   {100, "100g"},
+	unitFamilyVolume: {1000, "1L"},
+	unitFamilyCount:  {1, "1piece"},
+}
+
+// 100gあたり、1Lあたり、1個あたりの単価（小数点以下2桁に丸め、内容量がなければok=false）
+func UnitPrice(price *entity.Price) (unitPrice float64, basis string, ok bool) {
+	def, found := units[price.Unit]
+	if !found || price.Quantity <= 0 {
+		return 0, "", false
+	}
+	b := unitPriceBases[def.family]
+	v := float64(price.Price) / (price.Quantity * def.base) * b.amount
+	return math.Round(v*100) / 100, b.label, true
+}
+
+// 系統の基準単位での内容量と単位の系統（内容量がなければok=false）
+func baseQuantity(price *entity.Price) (amount float64, family string, ok bool) {
+	def, found := units[price.Unit]
+	if !found || price.Quantity <= 0 {
+		return 0, "", false
+	}
+	return price.Quantity * def.base, def.family, true
+}
+
+// priceをbasisの内容量に換算した価格（比較できなければok=false）
+//
+// basisに内容量があれば単位の系統が同じ内容量の価格を単価で換算し、なければ単位と内容量が同じ価格だけ
+func priceAtQuantity(price, basis *entity.Price) (float64, bool) {
+	amount, family, ok := baseQuantity(basis)
+	if !ok {
+		return float64(price.Price), price.Unit == basis.Unit && price.Quantity == basis.Quantity
+	}
+	other, otherFamily, ok := baseQuantity(price)
+	if !ok || otherFamily != family {
+		return 0, false
+	}
+	return float64(price.Price) / other * amount, true
+}
+
+// 同じ商品の他の価格と単位の系統が一致すること（単位の指定がなければ確認しない）
+func (s *serviceImpl) checkUnitFamily(ctx context.Context, userId uint, product, unit string, excludeId uint) error {
+	def, found := units[unit]
+	if !found {
+		return nil
+	}
+	others, err := s.repository.Price().FindUnitsByProduct(ctx, userId, product, excludeId)
+	if err != nil {
+		return err
+	}
+	for _, v := range others {
+		if other, found := units[v]; found && other.family != def.family {
+			return wrap(ErrUnitMismatch)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,459 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/ystkg/rest-example/entity"
+)
+
+// Webhookのイベント種別
+const (
+	EventPriceCreated = "price.created"
+	EventPriceUpdated = "price.updated"
+	EventPriceDeleted = "price.deleted"
+)
+
+// Webhookの配信状態
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead" // 再試行の上限に達した
+)
+
+// Webhookの配信で付与するヘッダ
+const (
+	HeaderWebhookEvent     = "X-Webhook-Event"
+	HeaderWebhookDelivery  = "X-Webhook-Delivery"
+	HeaderWebhookTimestamp = "X-Webhook-Timestamp" // UNIX時間（秒）
+	HeaderWebhookSignature = "X-Webhook-Signature" // "sha256=" + HMAC-SHA256(secret, timestamp + "." + body)の16進数
+)
+
+const (
+	webhookMaxAttempts  = 8                // 再試行の上限（初回を含む）
+	webhookBackoffBase  = 30 * time.Second // 再試行の間隔は30秒から倍々
+	webhookLease        = 5 * time.Minute  // 配信中の重複を防ぐ猶予
+	webhookBatchSize    = 100
+	webhookTimeout      = 10 * time.Second
+	webhookErrorMaxSize = 1000
+)
+
+// グローバルユニキャストでもWebhookの配信先に使えないアドレス
+var webhookBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // キャリアグレードNAT
+	netip.MustParsePrefix("198.18.0.0/15"), // ベンチマーク用
+	netip.MustParsePrefix("240.0.0.0/4"),   // 予約済み（ブロードキャストを含む）
+}
+
+var (
+	webhookClient        = newWebhookClient(false)
+	privateWebhookClient = newWebhookClient(true) // 内部ネットワークへの配信を許可（開発用）
+)
+
+func newWebhookClient(allowPrivate bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // プロキシを経由すると接続先のアドレスを確認できない
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivate {
+		dialer.Control = webhookDialControl
+	}
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: webhookTimeout, Transport: transport}
+}
+
+// 接続の直前に実際の接続先のアドレスを確認（登録後に名前解決の結果が変わる場合やリダイレクトに備える）
+func webhookDialControl(network, address string, c syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if isBlockedWebhookAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w:%s", ErrWebhookHost, address)
+	}
+	return nil
+}
+
+// Webhookの配信先のホストの確認（名前解決したアドレスのいずれかが内部ネットワークならエラー）
+//
+// 名前解決できない場合は登録を許可し、配信時の接続で確認する。allowPrivateは内部ネットワークを許可する（開発用）
+func CheckWebhookHost(ctx context.Context, host string, allowPrivate bool) error {
+	if allowPrivate {
+		return nil
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if isBlockedWebhookAddr(addr) {
+			return wrap(ErrWebhookHost)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		slog.DebugContext(ctx, "lookup failed", "host", host, "err", err)
+		return nil
+	}
+	for _, v := range addrs {
+		if isBlockedWebhookAddr(v) {
+			return wrap(ErrWebhookHost)
+		}
+	}
+	return nil
+}
+
+func isBlockedWebhookAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return true // ループバック、リンクローカル（169.254.169.254を含む）、マルチキャスト、未指定も対象
+	}
+	for _, v := range webhookBlockedPrefixes {
+		if v.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// 配信するJSON
+type webhookPayload struct {
+	Event      string
+	OccurredAt time.Time
+	Price      webhookPrice
+}
+
+type webhookPrice struct {
+	ID       uint
+	DateTime time.Time
+	Store    string
+	Product  string
+	GTIN     string `json:",omitempty"`
+	Price    uint
+	Quantity float64 `json:",omitempty"`
+	Unit     string  `json:",omitempty"`
+
+	PriceType string
+	ValidFrom *time.Time `json:",omitempty"`
+	ValidTo   *time.Time `json:",omitempty"`
+}
+
+// Webhookの購読の登録
+func (s *serviceImpl) CreateWebhook(ctx context.Context, webhook *entity.Webhook) (*entity.Webhook, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// Webhookの購読の登録
+	if err = s.repository.Webhook().Create(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+// Webhookの購読の一覧
+func (s *serviceImpl) FindWebhooks(ctx context.Context, userId uint) ([]entity.Webhook, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.repository.Webhook().FindByUserId(ctx, userId)
+}
+
+// Webhookの購読の取得
+func (s *serviceImpl) FindWebhook(ctx context.Context, webhookId, userId uint) (*entity.Webhook, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	return s.repository.Webhook().Find(ctx, webhookId, userId)
+}
+
+// Webhookの購読の更新
+func (s *serviceImpl) UpdateWebhook(ctx context.Context, webhook *entity.Webhook) (*entity.Webhook, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback(ctx)
+
+	// Webhookの購読の更新
+	rows, err := s.repository.Webhook().Update(ctx, webhook)
+	if err != nil {
+		return nil, err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return nil, wrap(ErrNotFound)
+		}
+		return nil, wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 更新後のWebhookの購読の取得
+	webhookEntity, err := s.repository.Webhook().Find(ctx, webhook.ID, webhook.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if webhookEntity == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return webhookEntity, nil
+}
+
+// Webhookの購読の削除（未配信も含めて配信も削除）
+func (s *serviceImpl) DeleteWebhook(ctx context.Context, webhookId, userId uint) error {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// トランザクション開始
+	ctx, err := s.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.rollback(ctx)
+
+	// Webhookの購読の削除
+	rows, err := s.repository.Webhook().Delete(ctx, webhookId, userId)
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		s.rollback(ctx)
+		if rows == 0 {
+			return wrap(ErrNotFound)
+		}
+		return wrap(fmt.Errorf("RowsAffected:%d", rows))
+	}
+
+	// 配信の削除
+	if _, err = s.repository.WebhookDelivery().DeleteByWebhookId(ctx, webhookId); err != nil {
+		return err
+	}
+
+	// コミット
+	if err = s.commit(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Webhookの配信の一覧
+func (s *serviceImpl) FindWebhookDeliveries(ctx context.Context, webhookId, userId uint) ([]entity.WebhookDelivery, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	// Webhookの購読の所有者の確認
+	webhook, err := s.repository.Webhook().Find(ctx, webhookId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		return nil, wrap(ErrNotFound)
+	}
+
+	return s.repository.WebhookDelivery().FindByWebhookId(ctx, webhookId)
+}
+
+// 価格の変更をイベントとして購読しているWebhookの配信キューに登録（価格の書き込みと同じトランザクション）
+func (s *serviceImpl) enqueueWebhooks(ctx context.Context, userId uint, event string, prices []entity.Price) error {
+	webhooks, err := s.repository.Webhook().FindByUserId(ctx, userId)
+	if err != nil {
+		return err
+	}
+	webhooks = slices.DeleteFunc(webhooks, func(v entity.Webhook) bool {
+		return !slices.Contains(v.Events, event)
+	})
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	deliveries := make([]entity.WebhookDelivery, 0, len(webhooks)*len(prices))
+	for _, price := range prices {
+		payload, err := json.Marshal(&webhookPayload{
+			Event:      event,
+			OccurredAt: now,
+			Price: webhookPrice{
+				ID:       price.ID,
+				DateTime: price.DateTime,
+				Store:    price.Store,
+				Product:  price.Product,
+				GTIN:     price.GTIN,
+				Price:    price.Price,
+				Quantity: price.Quantity,
+				Unit:     price.Unit,
+
+				PriceType: price.PriceType,
+				ValidFrom: price.ValidFrom,
+				ValidTo:   price.ValidTo,
+			},
+		})
+		if err != nil {
+			return wrap(err)
+		}
+		for _, webhook := range webhooks {
+			deliveries = append(deliveries, entity.WebhookDelivery{
+				WebhookID:     webhook.ID,
+				UserID:        userId,
+				Event:         event,
+				Payload:       string(payload),
+				Status:        DeliveryPending,
+				NextAttemptAt: now,
+			})
+		}
+	}
+
+	return s.repository.WebhookDelivery().CreateAll(ctx, deliveries)
+}
+
+// 配信予定日時を過ぎたWebhookを配信して、配信した件数を返す（allowPrivateは内部ネットワークへの配信を許可する）
+func (s *serviceImpl) DeliverWebhooks(ctx context.Context, now time.Time, allowPrivate bool) (int, error) {
+	slog.DebugContext(ctx, "start")
+	defer slog.DebugContext(ctx, "end")
+
+	deliveries, err := s.repository.WebhookDelivery().FindDue(ctx, DeliveryPending, now, webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, delivery := range deliveries {
+		// 配信の確保（他で配信中ならスキップ）
+		rows, err := s.repository.WebhookDelivery().Claim(ctx, delivery.ID, delivery.NextAttemptAt, now.Add(webhookLease))
+		if err != nil {
+			return delivered, err
+		}
+		if rows != 1 {
+			continue
+		}
+
+		// 配信先の取得
+		webhook, err := s.repository.Webhook().Find(ctx, delivery.WebhookID, delivery.UserID)
+		if err != nil {
+			return delivered, err
+		}
+
+		// 配信と結果の記録
+		delivery.Attempts++
+		var statusCode int
+		if webhook == nil {
+			err = fmt.Errorf("webhook not found")
+			delivery.Attempts = webhookMaxAttempts // 再試行しない
+		} else {
+			statusCode, err = s.postWebhook(ctx, webhook, &delivery, now, allowPrivate)
+		}
+		if statusCode != 0 {
+			delivery.LastStatusCode = &statusCode
+		}
+		if err == nil {
+			delivery.Status = DeliverySucceeded
+			delivery.LastError = nil
+			delivery.DeliveredAt = &now
+			delivered++
+		} else {
+			msg := truncateRunes(err.Error(), webhookErrorMaxSize)
+			delivery.LastError = &msg
+			if delivery.Attempts >= webhookMaxAttempts {
+				delivery.Status = DeliveryDead
+			} else {
+				delivery.NextAttemptAt = now.Add(webhookBackoff(delivery.Attempts))
+			}
+		}
+		if _, err = s.repository.WebhookDelivery().Update(ctx, &delivery); err != nil {
+			return delivered, err
+		}
+	}
+
+	return delivered, nil
+}
+
+// 先頭から最大n文字（マルチバイト文字の途中では切らない）
+func truncateRunes(s string, n int) string {
+	i := 0
+	for j := range s {
+		if i == n {
+			return s[:j]
+		}
+		i++
+	}
+	return s
+}
+
+// 再試行までの間隔（指数バックオフ）
+func webhookBackoff(attempts int) time.Duration {
+	return webhookBackoffBase << (attempts - 1)
+}
+
+func (s *serviceImpl) postWebhook(ctx context.Context, webhook *entity.Webhook, delivery *entity.WebhookDelivery, now time.Time, allowPrivate bool) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookEvent, delivery.Event)
+	req.Header.Set(HeaderWebhookDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(HeaderWebhookTimestamp, timestamp)
+	req.Header.Set(HeaderWebhookSignature, "sha256="+SignWebhook(webhook.Secret, timestamp, body))
+
+	client := webhookClient
+	if allowPrivate {
+		client = privateWebhookClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("unexpected status:%d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+// Webhookの署名（受信側の検証にも使える）
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}